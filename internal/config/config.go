@@ -1,9 +1,15 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/lib/strength"
 	"github.com/spf13/cobra"
 )
 
@@ -21,22 +27,81 @@ type AppConfig struct {
 	DisableAES256       bool
 	SubCommand          string
 	TempDecodeSeconds   int
+	RedactDiff          bool
+	HistoryLimit        int
+	HistoryDays         int
+	Revision            string
+	BackupCount         int
+	BackupDays          int
+	BackupNumber        int
+	LockTimeout         int
+	NoWait              bool
+	DisableShred        bool
+	ShredPasses         int
+	JSONOutput          bool
+	Output              string
+	Shares              int
+	Threshold           int
+	SlotName            string
+	KDFIterations       int
+	Keyfiles            []string
+	NewKeyfiles         []string
+	ReplaceKeyfiles     bool
+	PKCS11Module        string
+	Profile             string
+	PasswordPolicy      strength.Policy
+	EntryKey            string
+	GenLength           int
+	GenCharset          string
+	GenWords            int
+	GenSeparator        string
+	GenHex              bool
+	GenBase64           bool
+	GenShow             bool
+	Force               bool
+	OTPImport           string
+	OTPAlgorithm        string
+	OTPDigits           int
+	OTPPeriod           int
+	PrintClip           bool
+	PrintFormat         string
+	PrintOutputFile     string
+	ClipTimeout         int
+	Armor               bool
+	FilterOutput        string
+	// PasswordFloor is the password policy of the system config, it can not be lowered.
+	PasswordFloor strength.Policy
+	// Sources maps setting keys to the config file, env var or flag that set them.
+	Sources map[string]string
 }
 
 func defaultAppConfig() *AppConfig {
 	return &AppConfig{
-		Verbose:            false,
-		ShowVersion:        false,
-		PrivateKeyPath:     "~/.ssh/id_rsa",
-		PublicKeyPath:      "~/.ssh/id_rsa.pub",
-		Args:               []string{},
-		VaultFileExtension: "vt",
-		PlainFileExtension: "txt",
-		CleanPrint:         false,
-		DisableRSA:         false,
-		DisableAES256:      false,
-		SubCommand:         "",
-		TempDecodeSeconds:  10,
+		Verbose:             false,
+		ShowVersion:         false,
+		PrivateKeyPath:      "~/.ssh/id_rsa",
+		PublicKeyPath:       "~/.ssh/id_rsa.pub",
+		Args:                []string{},
+		VaultFileExtension:  "vt",
+		PlainFileExtension:  "txt",
+		BackupFileExtension: "bak",
+		CleanPrint:          false,
+		DisableRSA:          false,
+		DisableAES256:       false,
+		SubCommand:          "",
+		TempDecodeSeconds:   10,
+		LockTimeout:         30,
+		ShredPasses:         3,
+		Shares:              5,
+		Threshold:           3,
+		Output:              "text",
+		PasswordPolicy:      strength.Policy{MinLength: 4},
+		GenLength:           32,
+		GenCharset:          "alnum",
+		GenSeparator:        "-",
+		ClipTimeout:         45,
+		FilterOutput:        "-",
+		Sources:             map[string]string{},
 	}
 }
 
@@ -54,12 +119,46 @@ func versionCommand(appConfig *AppConfig) *cobra.Command {
 }
 
 func addCryptFlags(appConfig *AppConfig, cmd *cobra.Command) {
-	cmd.Flags().StringVarP(&appConfig.PrivateKeyPath, "private-key", "r", appConfig.PrivateKeyPath, "Defines the private key path (VAULT_PRIVATE_KEY_PATH)")
+	cmd.Flags().StringVarP(&appConfig.PrivateKeyPath, "private-key", "r", appConfig.PrivateKeyPath, "Defines the private key path or a pkcs11: URI of a token key (VAULT_PRIVATE_KEY_PATH)")
 	cmd.Flags().StringVarP(&appConfig.PublicKeyPath, "public-key", "u", appConfig.PublicKeyPath, "Defines the public key path (VAULT_PUBLIC_KEY_PATH)")
 	cmd.Flags().StringVarP(&appConfig.VaultFileExtension, "vault-ext", "e", appConfig.VaultFileExtension, "Defines the vault file extension (VAULT_EXT)")
 	cmd.Flags().StringVarP(&appConfig.PlainFileExtension, "plain-ext", "p", appConfig.PlainFileExtension, "Defines the plain file extension (VAULT_PLAIN_EXT)")
 	cmd.Flags().BoolVarP(&appConfig.DisableRSA, "no-rsa", "x", appConfig.DisableRSA, "Use RSA key encryption (VAULT_RSA)")
 	cmd.Flags().BoolVarP(&appConfig.DisableAES256, "no-aes", "a", appConfig.DisableAES256, "Use AES256 password encryption (VAULT_AES)")
+	cmd.Flags().StringArrayVar(&appConfig.Keyfiles, "keyfile", appConfig.Keyfiles, "Requires this keyfile in addition to the password, repeatable (VAULT_KEYFILE, path list)")
+}
+
+func addNewKeyfileFlags(appConfig *AppConfig, cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&appConfig.NewKeyfiles, "new-keyfile", appConfig.NewKeyfiles, "Keyfiles of the new password instead of --keyfile, repeatable, an empty path removes them")
+}
+
+func addHistoryFlags(appConfig *AppConfig, cmd *cobra.Command) {
+	cmd.Flags().IntVar(&appConfig.HistoryLimit, "history", appConfig.HistoryLimit, "Keeps this many encrypted revisions in the vault file, 0 disables the history (VAULT_HISTORY)")
+	cmd.Flags().IntVar(&appConfig.HistoryDays, "history-days", appConfig.HistoryDays, "Drops revisions older than this many days, 0 keeps them (VAULT_HISTORY_DAYS)")
+}
+
+func addBackupFlags(appConfig *AppConfig, cmd *cobra.Command) {
+	cmd.Flags().IntVar(&appConfig.BackupCount, "backups", appConfig.BackupCount, "Keeps this many rotating backups of the vault file before it gets overwritten, 0 disables backups (VAULT_BACKUPS)")
+	cmd.Flags().IntVar(&appConfig.BackupDays, "backup-days", appConfig.BackupDays, "Removes backups older than this many days, 0 keeps them (VAULT_BACKUP_DAYS)")
+	cmd.Flags().StringVar(&appConfig.BackupFileExtension, "backup-ext", appConfig.BackupFileExtension, "Defines the backup file extension (VAULT_BACKUP_EXT)")
+}
+
+func addLockFlags(appConfig *AppConfig, cmd *cobra.Command) {
+	cmd.Flags().IntVar(&appConfig.LockTimeout, "lock-timeout", appConfig.LockTimeout, "Seconds to wait for a vault file locked by another process, 0 waits forever (VAULT_LOCK_TIMEOUT)")
+	cmd.Flags().BoolVar(&appConfig.NoWait, "no-wait", appConfig.NoWait, "Fail immediately if the vault file is locked by another process (VAULT_NO_WAIT)")
+}
+
+func addShredFlags(appConfig *AppConfig, cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&appConfig.DisableShred, "no-shred", appConfig.DisableShred, "Only unlink plain files instead of overwriting them first (VAULT_NO_SHRED)")
+	cmd.Flags().IntVar(&appConfig.ShredPasses, "shred-passes", appConfig.ShredPasses, "Defines how often plain files get overwritten before removal (VAULT_SHRED_PASSES)")
+}
+
+func addKDFFlags(appConfig *AppConfig, cmd *cobra.Command) {
+	cmd.Flags().IntVar(&appConfig.KDFIterations, "kdf-iterations", appConfig.KDFIterations, "Defines the PBKDF2 iterations of new password key slots, 0 for the default (VAULT_KDF_ITERATIONS)")
+}
+
+func addArmorFlags(appConfig *AppConfig, cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&appConfig.Armor, "armor", appConfig.Armor, "Writes the vault file as armored text that survives chat and email (VAULT_ARMOR)")
 }
 
 func lockCommand(appConfig *AppConfig) *cobra.Command {
@@ -76,7 +175,13 @@ func lockCommand(appConfig *AppConfig) *cobra.Command {
 	cmd.Aliases = append(cmd.Aliases, "lo")
 	cmd.Aliases = append(cmd.Aliases, "l")
 
+	addHistoryFlags(appConfig, cmd)
+	addBackupFlags(appConfig, cmd)
+	addLockFlags(appConfig, cmd)
+	addShredFlags(appConfig, cmd)
 	addCryptFlags(appConfig, cmd)
+	addKDFFlags(appConfig, cmd)
+	addArmorFlags(appConfig, cmd)
 
 	return cmd
 }
@@ -95,6 +200,8 @@ func unlockCommand(appConfig *AppConfig) *cobra.Command {
 	cmd.Aliases = append(cmd.Aliases, "un")
 	cmd.Aliases = append(cmd.Aliases, "u")
 
+	addHistoryFlags(appConfig, cmd)
+	addLockFlags(appConfig, cmd)
 	addCryptFlags(appConfig, cmd)
 
 	return cmd
@@ -107,6 +214,7 @@ func passwdCommand(appConfig *AppConfig) *cobra.Command {
 		Run: func(cmd *cobra.Command, args []string) {
 			appConfig.Args = args
 			appConfig.SubCommand = "passwd"
+			appConfig.ReplaceKeyfiles = cmd.Flags().Changed("new-keyfile")
 		},
 	}
 
@@ -114,7 +222,62 @@ func passwdCommand(appConfig *AppConfig) *cobra.Command {
 	cmd.Aliases = append(cmd.Aliases, "pass")
 	cmd.Aliases = append(cmd.Aliases, "pa")
 
+	addBackupFlags(appConfig, cmd)
+	addLockFlags(appConfig, cmd)
 	addCryptFlags(appConfig, cmd)
+	addKDFFlags(appConfig, cmd)
+	addNewKeyfileFlags(appConfig, cmd)
+
+	addCmd := &cobra.Command{
+		Use:   "add [file] <name>",
+		Short: "Adds a named key slot with another password",
+		Args:  cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args[:len(args)-1]
+			appConfig.SlotName = args[len(args)-1]
+			appConfig.SubCommand = "passwd-add"
+			appConfig.ReplaceKeyfiles = cmd.Flags().Changed("new-keyfile")
+		},
+	}
+
+	addBackupFlags(appConfig, addCmd)
+	addLockFlags(appConfig, addCmd)
+	addCryptFlags(appConfig, addCmd)
+	addKDFFlags(appConfig, addCmd)
+	addNewKeyfileFlags(appConfig, addCmd)
+
+	removeCmd := &cobra.Command{
+		Use:   "remove [file] <name>",
+		Short: "Removes a key slot",
+		Args:  cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args[:len(args)-1]
+			appConfig.SlotName = args[len(args)-1]
+			appConfig.SubCommand = "passwd-remove"
+		},
+	}
+
+	removeCmd.Aliases = append(removeCmd.Aliases, "rm")
+
+	addBackupFlags(appConfig, removeCmd)
+	addLockFlags(appConfig, removeCmd)
+	addCryptFlags(appConfig, removeCmd)
+
+	listCmd := &cobra.Command{
+		Use:   "list [file]",
+		Short: "Lists the key slots of your vault file",
+		Args:  cobra.RangeArgs(0, 1),
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args
+			appConfig.SubCommand = "passwd-list"
+		},
+	}
+
+	listCmd.Aliases = append(listCmd.Aliases, "ls")
+
+	listCmd.Flags().StringVarP(&appConfig.VaultFileExtension, "vault-ext", "e", appConfig.VaultFileExtension, "Defines the vault file extension (VAULT_EXT)")
+
+	cmd.AddCommand(addCmd, removeCmd, listCmd)
 
 	return cmd
 }
@@ -135,6 +298,10 @@ func tempCommand(appConfig *AppConfig) *cobra.Command {
 
 	cmd.Flags().IntVarP(&appConfig.TempDecodeSeconds, "temp-seconds", "t", appConfig.TempDecodeSeconds, "Temporary decode time in seconds (VAULT_TEMP_DECODE_SECONDS)")
 
+	addHistoryFlags(appConfig, cmd)
+	addBackupFlags(appConfig, cmd)
+	addLockFlags(appConfig, cmd)
+	addShredFlags(appConfig, cmd)
 	addCryptFlags(appConfig, cmd)
 
 	return cmd
@@ -155,6 +322,11 @@ func printCommand(appConfig *AppConfig) *cobra.Command {
 	cmd.Aliases = append(cmd.Aliases, "p")
 
 	cmd.Flags().BoolVarP(&appConfig.CleanPrint, "clean-print", "c", appConfig.CleanPrint, "Clean print mode (VAULT_CLEAN_PRINT)")
+	cmd.Flags().StringVar(&appConfig.EntryKey, "key", appConfig.EntryKey, "Uses only the value of this KEY=value entry instead of the whole content")
+	cmd.Flags().StringVar(&appConfig.PrintFormat, "format", appConfig.PrintFormat, "Prints 'raw', 'hex', 'base64' or 'hexdump', without a format binary content is detected and printed raw")
+	cmd.Flags().BoolVar(&appConfig.PrintClip, "clip", appConfig.PrintClip, "Copies to the clipboard via wl-copy, xclip or OSC 52 instead of printing")
+	cmd.Flags().IntVar(&appConfig.ClipTimeout, "clip-timeout", appConfig.ClipTimeout, "Seconds until the clipboard is cleared if it still holds the secret, 0 never clears it (VAULT_CLIP_TIMEOUT)")
+	cmd.Flags().StringVarP(&appConfig.PrintOutputFile, "out", "o", appConfig.PrintOutputFile, "Writes the content into this file instead of printing it")
 	addCryptFlags(appConfig, cmd)
 
 	return cmd
@@ -174,11 +346,380 @@ func initCommand(appConfig *AppConfig) *cobra.Command {
 	cmd.Aliases = append(cmd.Aliases, "in")
 	cmd.Aliases = append(cmd.Aliases, "i")
 
+	addHistoryFlags(appConfig, cmd)
+	addLockFlags(appConfig, cmd)
+	addCryptFlags(appConfig, cmd)
+	addKDFFlags(appConfig, cmd)
+
+	return cmd
+}
+
+func logCommand(appConfig *AppConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "log [file]",
+		Short: "Lists the revisions of your vault file",
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args
+			appConfig.SubCommand = "log"
+		},
+	}
+
+	addCryptFlags(appConfig, cmd)
+
+	return cmd
+}
+
+func inspectCommand(appConfig *AppConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "inspect [file]",
+		Short: "Prints the format, encryption and recipients of your vault file without decrypting it",
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args
+			appConfig.SubCommand = "inspect"
+		},
+	}
+
+	addCryptFlags(appConfig, cmd)
+
+	return cmd
+}
+
+func verifyCommand(appConfig *AppConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify [file]",
+		Short: "Checks the integrity of every revision of your vault file without writing the plain text",
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args
+			appConfig.SubCommand = "verify"
+		},
+	}
+
+	cmd.Flags().BoolVar(&appConfig.JSONOutput, "json", appConfig.JSONOutput, "Prints the result as json")
+	addCryptFlags(appConfig, cmd)
+
+	return cmd
+}
+
+func revertCommand(appConfig *AppConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "revert [file] <rev>",
+		Short: "Restores an older revision of your vault file",
+		Args:  cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args[:len(args)-1]
+			appConfig.Revision = args[len(args)-1]
+			appConfig.SubCommand = "revert"
+		},
+	}
+
+	addHistoryFlags(appConfig, cmd)
+	addBackupFlags(appConfig, cmd)
+	addLockFlags(appConfig, cmd)
+	addCryptFlags(appConfig, cmd)
+
+	return cmd
+}
+
+func genCommand(appConfig *AppConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gen [file] <KEY>",
+		Short: "Generates a random secret into a KEY=value entry of your vault file",
+		Args:  cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args[:len(args)-1]
+			appConfig.EntryKey = args[len(args)-1]
+			appConfig.SubCommand = "gen"
+		},
+	}
+
+	cmd.Flags().IntVarP(&appConfig.GenLength, "length", "l", appConfig.GenLength, "Characters of a --charset secret, random bytes of --hex (2 characters per byte) and --base64 (4 characters per 3 bytes)")
+	cmd.Flags().StringVar(&appConfig.GenCharset, "charset", appConfig.GenCharset, "Characters of the secret, 'lower', 'upper', 'digit', 'alpha', 'alnum', 'hex', 'symbol' or the characters itself")
+	cmd.Flags().IntVar(&appConfig.GenWords, "words", appConfig.GenWords, "Generates a diceware passphrase of this many words of the EFF wordlist")
+	cmd.Flags().StringVar(&appConfig.GenSeparator, "separator", appConfig.GenSeparator, "Separator between the words of --words")
+	cmd.Flags().BoolVar(&appConfig.GenHex, "hex", appConfig.GenHex, "Generates --length random bytes as hex, twice as many characters")
+	cmd.Flags().BoolVar(&appConfig.GenBase64, "base64", appConfig.GenBase64, "Generates --length random bytes as base64, a third more characters")
+	cmd.Flags().BoolVar(&appConfig.GenShow, "show", appConfig.GenShow, "Prints the generated secret")
+	cmd.Flags().BoolVarP(&appConfig.Force, "force", "f", appConfig.Force, "Replaces an existing entry of the key")
+	// one mode, the length of --words is the number of words
+	cmd.MarkFlagsMutuallyExclusive("charset", "words", "hex", "base64")
+	cmd.MarkFlagsMutuallyExclusive("length", "words")
+
+	addHistoryFlags(appConfig, cmd)
+	addBackupFlags(appConfig, cmd)
+	addLockFlags(appConfig, cmd)
+	addCryptFlags(appConfig, cmd)
+	addKDFFlags(appConfig, cmd)
+
+	return cmd
+}
+
+func totpCommand(appConfig *AppConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "totp [file] <KEY>",
+		Short: "Prints the one-time password of an otpauth URI or base32 seed entry of your vault file",
+		Args:  cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args[:len(args)-1]
+			appConfig.EntryKey = args[len(args)-1]
+			appConfig.SubCommand = "totp"
+		},
+	}
+
+	cmd.Flags().StringVar(&appConfig.OTPImport, "import", appConfig.OTPImport, "Stores this otpauth:// URI or base32 seed as the entry, '-' reads it from stdin")
+	cmd.Flags().StringVar(&appConfig.OTPAlgorithm, "algorithm", appConfig.OTPAlgorithm, "Overrides the hash 'SHA1', 'SHA256' or 'SHA512', default SHA1 or from the otpauth URI")
+	cmd.Flags().IntVar(&appConfig.OTPDigits, "digits", appConfig.OTPDigits, "Overrides the digits of the code 6 or 8, default 6 or from the otpauth URI")
+	cmd.Flags().IntVar(&appConfig.OTPPeriod, "period", appConfig.OTPPeriod, "Overrides the seconds a code is valid, default 30 or from the otpauth URI")
+	cmd.Flags().BoolVarP(&appConfig.Force, "force", "f", appConfig.Force, "Replaces an existing entry of the key on --import")
+
+	addHistoryFlags(appConfig, cmd)
+	addBackupFlags(appConfig, cmd)
+	addLockFlags(appConfig, cmd)
+	addCryptFlags(appConfig, cmd)
+	addKDFFlags(appConfig, cmd)
+
+	return cmd
+}
+
+func splitCommand(appConfig *AppConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "split [file]",
+		Short: "Creates a recovery key for your vault file and splits it into shares",
+		Args:  cobra.RangeArgs(0, 1),
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args
+			appConfig.SubCommand = "split"
+		},
+	}
+
+	cmd.Flags().IntVar(&appConfig.Shares, "shares", appConfig.Shares, "Number of shares to create")
+	cmd.Flags().IntVar(&appConfig.Threshold, "threshold", appConfig.Threshold, "Number of shares needed to recover")
+
+	addBackupFlags(appConfig, cmd)
+	addLockFlags(appConfig, cmd)
+	addCryptFlags(appConfig, cmd)
+
+	return cmd
+}
+
+func recoverKeyCommand(appConfig *AppConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "recover-key [file]",
+		Short: "Combines recovery shares to set a new password and key for your vault file",
+		Args:  cobra.RangeArgs(0, 1),
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args
+			appConfig.SubCommand = "recover-key"
+		},
+	}
+
+	addBackupFlags(appConfig, cmd)
+	addLockFlags(appConfig, cmd)
+	addCryptFlags(appConfig, cmd)
+	addKDFFlags(appConfig, cmd)
+
+	return cmd
+}
+
+func restoreBackupCommand(appConfig *AppConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "restore-backup [file] [number]",
+		Short: "Lists the backups of your vault file or restores one",
+		Args:  cobra.RangeArgs(0, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args
+			appConfig.SubCommand = "restore-backup"
+
+			if len(args) != 0 {
+				number, err := strconv.Atoi(args[len(args)-1])
+				if err == nil {
+					appConfig.Args = args[:len(args)-1]
+					appConfig.BackupNumber = number
+				}
+			}
+		},
+	}
+
+	addBackupFlags(appConfig, cmd)
+	addLockFlags(appConfig, cmd)
+	addCryptFlags(appConfig, cmd)
+
+	return cmd
+}
+
+func shredCommand(appConfig *AppConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "shred <file>",
+		Short: "Overwrites and removes a plain file",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args
+			appConfig.SubCommand = "shred"
+		},
+	}
+
+	cmd.Flags().IntVar(&appConfig.ShredPasses, "shred-passes", appConfig.ShredPasses, "Defines how often the file gets overwritten before removal (VAULT_SHRED_PASSES)")
+
+	return cmd
+}
+
+func diffCommand(appConfig *AppConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff <old> <new>",
+		Short: "Shows the differences between two vault or plain files",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args
+			appConfig.SubCommand = "diff"
+		},
+	}
+
+	cmd.Aliases = append(cmd.Aliases, "dif")
+	cmd.Aliases = append(cmd.Aliases, "di")
+
+	cmd.Flags().BoolVarP(&appConfig.RedactDiff, "redact", "d", appConfig.RedactDiff, "Only show changed keys and line numbers, hide the values (VAULT_REDACT_DIFF)")
+	addCryptFlags(appConfig, cmd)
+
+	return cmd
+}
+
+func encryptCommand(appConfig *AppConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "encrypt [file|-]",
+		Short: "Encrypts a plain file or stdin into a vault payload on stdout",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args
+			appConfig.SubCommand = "encrypt"
+		},
+	}
+
+	cmd.Aliases = append(cmd.Aliases, "enc")
+
+	cmd.Flags().StringVarP(&appConfig.FilterOutput, "out", "o", appConfig.FilterOutput, "Writes the vault payload into this file, '-' is stdout")
+	addCryptFlags(appConfig, cmd)
+	addKDFFlags(appConfig, cmd)
+	addArmorFlags(appConfig, cmd)
+
+	return cmd
+}
+
+func decryptCommand(appConfig *AppConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "decrypt [file|-]",
+		Short: "Decrypts a vault file or stdin to stdout",
+		Args:  cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args
+			appConfig.SubCommand = "decrypt"
+		},
+	}
+
+	cmd.Aliases = append(cmd.Aliases, "dec")
+
+	cmd.Flags().StringVarP(&appConfig.FilterOutput, "out", "o", appConfig.FilterOutput, "Writes the plain text into this file, '-' is stdout")
+	addCryptFlags(appConfig, cmd)
+
+	return cmd
+}
+
+func gitFilterCommand(appConfig *AppConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:    "git-filter clean|smudge [file]",
+		Short:  "Git clean/smudge filter for vault files (reads stdin, writes stdout)",
+		Hidden: true,
+		Args:   cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args
+			appConfig.SubCommand = "git-filter"
+		},
+	}
+
 	addCryptFlags(appConfig, cmd)
 
 	return cmd
 }
 
+func gitTextconvCommand(appConfig *AppConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:    "git-textconv <file>",
+		Short:  "Git diff textconv that prints the decrypted vault file",
+		Hidden: true,
+		Args:   cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args
+			appConfig.SubCommand = "git-textconv"
+		},
+	}
+
+	addCryptFlags(appConfig, cmd)
+
+	return cmd
+}
+
+func gitCommand(appConfig *AppConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "git",
+		Short: "Git integration for vault files",
+	}
+
+	installCmd := &cobra.Command{
+		Use:   "install",
+		Short: "Configures the vault git filter and diff driver for this repository",
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args
+			appConfig.SubCommand = "git-install"
+		},
+	}
+
+	addCryptFlags(appConfig, installCmd)
+
+	cmd.AddCommand(installCmd)
+
+	return cmd
+}
+
+func keyfileCommand(appConfig *AppConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "keyfile",
+		Short: "Manages keyfiles that are needed in addition to the password",
+	}
+
+	newCmd := &cobra.Command{
+		Use:   "new <path>",
+		Short: "Creates a new random keyfile, e.g. on a usb stick",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args
+			appConfig.SubCommand = "keyfile-new"
+		},
+	}
+
+	cmd.AddCommand(newCmd)
+
+	return cmd
+}
+
+func configCommand(appConfig *AppConfig) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Shows the settings of config files, env vars and flags",
+	}
+
+	showCmd := &cobra.Command{
+		Use:   "show",
+		Short: "Prints the effective settings and where each one came from",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			appConfig.Args = args
+			appConfig.SubCommand = "config-show"
+		},
+	}
+
+	cmd.AddCommand(showCmd)
+
+	return cmd
+}
+
 func loadEnvVars(appConfig *AppConfig) {
 	EnvIsString("VAULT_PRIVATE_KEY_PATH", func(value string) {
 		appConfig.PrivateKeyPath = value
@@ -188,6 +729,14 @@ func loadEnvVars(appConfig *AppConfig) {
 		appConfig.PublicKeyPath = value
 	})
 
+	EnvIsString("VAULT_PKCS11_MODULE", func(value string) {
+		appConfig.PKCS11Module = value
+	})
+
+	EnvIsString("VAULT_KEYFILE", func(value string) {
+		appConfig.Keyfiles = filepath.SplitList(value)
+	})
+
 	EnvIsString("VAULT_EXT", func(value string) {
 		appConfig.VaultFileExtension = value
 	})
@@ -223,6 +772,78 @@ func loadEnvVars(appConfig *AppConfig) {
 	EnvIsInt("VAULT_TEMP_DECODE_SECONDS", func(value int) {
 		appConfig.TempDecodeSeconds = value
 	})
+
+	EnvIsInt("VAULT_CLIP_TIMEOUT", func(value int) {
+		appConfig.ClipTimeout = value
+	})
+
+	EnvIsBool("VAULT_ARMOR", func(value bool) {
+		appConfig.Armor = value
+	})
+
+	EnvIsBool("VAULT_REDACT_DIFF", func(value bool) {
+		appConfig.RedactDiff = value
+	})
+
+	EnvIsInt("VAULT_HISTORY", func(value int) {
+		appConfig.HistoryLimit = value
+	})
+
+	EnvIsInt("VAULT_HISTORY_DAYS", func(value int) {
+		appConfig.HistoryDays = value
+	})
+
+	EnvIsInt("VAULT_BACKUPS", func(value int) {
+		appConfig.BackupCount = value
+	})
+
+	EnvIsInt("VAULT_BACKUP_DAYS", func(value int) {
+		appConfig.BackupDays = value
+	})
+
+	EnvIsString("VAULT_BACKUP_EXT", func(value string) {
+		appConfig.BackupFileExtension = value
+	})
+
+	EnvIsInt("VAULT_LOCK_TIMEOUT", func(value int) {
+		appConfig.LockTimeout = value
+	})
+
+	EnvIsBool("VAULT_NO_WAIT", func(value bool) {
+		appConfig.NoWait = value
+	})
+
+	EnvIsBool("VAULT_NO_SHRED", func(value bool) {
+		appConfig.DisableShred = value
+	})
+
+	EnvIsInt("VAULT_SHRED_PASSES", func(value int) {
+		appConfig.ShredPasses = value
+	})
+
+	EnvIsInt("VAULT_KDF_ITERATIONS", func(value int) {
+		appConfig.KDFIterations = value
+	})
+
+	EnvIsString("VAULT_OUTPUT", func(value string) {
+		appConfig.Output = value
+	})
+
+	EnvIsInt("VAULT_PASSWORD_MIN_SCORE", func(value int) {
+		appConfig.PasswordPolicy.MinScore = value
+	})
+
+	EnvIsInt("VAULT_PASSWORD_MIN_LENGTH", func(value int) {
+		appConfig.PasswordPolicy.MinLength = value
+	})
+
+	EnvIsString("VAULT_PASSWORD_CLASSES", func(value string) {
+		appConfig.PasswordPolicy.Classes = strings.FieldsFunc(value, func(char rune) bool { return char == ',' || char == ' ' })
+	})
+
+	EnvIsString("VAULT_PASSWORD_DENY_LIST", func(value string) {
+		appConfig.PasswordPolicy.DenyLists = filepath.SplitList(value)
+	})
 }
 
 func ParseConfig(
@@ -244,6 +865,10 @@ func ParseConfig(
 
 	rootCmd.PersistentFlags().BoolVarP(&appConfig.Verbose, "verbose", "b", appConfig.Verbose, "enable verbose mode (VAULT_VERBOSE)")
 	rootCmd.Flags().BoolVarP(&appConfig.ShowVersion, "version", "v", appConfig.ShowVersion, "prints version")
+	// parsed before the flags by profileFromArgs, the config files are applied first
+	var profile string
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "Selects a profile of the config files (VAULT_PROFILE)")
+	rootCmd.PersistentFlags().StringVar(&appConfig.Output, "output", appConfig.Output, "Output format 'text' or 'json', json prints results and errors as json objects (VAULT_OUTPUT)")
 
 	rootCmd.AddCommand(
 		versionCommand(appConfig),
@@ -253,9 +878,35 @@ func ParseConfig(
 		unlockCommand(appConfig),
 		tempCommand(appConfig),
 		passwdCommand(appConfig),
+		splitCommand(appConfig),
+		recoverKeyCommand(appConfig),
+		diffCommand(appConfig),
+		encryptCommand(appConfig),
+		decryptCommand(appConfig),
+		logCommand(appConfig),
+		inspectCommand(appConfig),
+		verifyCommand(appConfig),
+		revertCommand(appConfig),
+		genCommand(appConfig),
+		totpCommand(appConfig),
+		restoreBackupCommand(appConfig),
+		shredCommand(appConfig),
+		keyfileCommand(appConfig),
+		configCommand(appConfig),
+		gitCommand(appConfig),
+		gitFilterCommand(appConfig),
+		gitTextconvCommand(appConfig),
 	)
 
+	// system, user and .vaultrc config files, then env vars, then flags
+	err := loadConfigFiles(appConfig, configFilePaths(), profileFromArgs(os.Args[1:]))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Config error:\n> "+err.Error())
+		os.Exit(exitcode.Usage)
+	}
+
 	loadEnvVars(appConfig)
+	recordEnvSources(appConfig)
 
 	// wanted behavior: shows an error when using the "help" subcommand and does not execute
 	rootCmd.SetHelpCommand(&cobra.Command{
@@ -265,16 +916,30 @@ func ParseConfig(
 
 	cmd, err := rootCmd.ExecuteC()
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitcode.Usage)
 	}
 
 	if commandHelpRequested(cmd) {
 		os.Exit(0)
 	}
 
+	recordFlagSources(appConfig, cmd)
+
+	err = applyPasswordFloor(appConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Config error:\n> "+err.Error())
+		os.Exit(exitcode.Usage)
+	}
+
+	err = parseOutput(appConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitcode.Usage)
+	}
+
 	if appConfig.Verbose {
-		fmt.Println("Verbose mode enabled")
+		fmt.Fprintln(os.Stderr, "Verbose mode enabled")
 	}
 
 	if appConfig.ShowVersion {
@@ -290,6 +955,23 @@ func ParseConfig(
 	return appConfig
 }
 
+// parseOutput validates the output format, the --json flag of verify selects json too.
+func parseOutput(appConfig *AppConfig) error {
+	if appConfig.JSONOutput {
+		appConfig.Output = "json"
+	}
+
+	switch appConfig.Output {
+	case "text":
+	case "json":
+		appConfig.JSONOutput = true
+	default:
+		return errors.New("invalid output format '" + appConfig.Output + "', use 'text' or 'json'")
+	}
+
+	return nil
+}
+
 func commandHelpRequested(cmd *cobra.Command) bool {
 	for c := cmd; c != nil; c = c.Parent() {
 		if helpFlag := c.Flags().Lookup("help"); helpFlag != nil && helpFlag.Changed {
@@ -3,7 +3,10 @@ package config
 import (
 	"os"
 	"os/exec"
+	"strings"
 	"testing"
+
+	"github.com/NobleMajo/vault/internal/exitcode"
 )
 
 func TestParseConfigLockCommand(t *testing.T) {
@@ -35,8 +38,8 @@ func TestParseConfigRejectsUnknownSubcommand(t *testing.T) {
 	if !ok {
 		t.Fatalf("expected exit error, got %v", err)
 	}
-	if exitErr.ExitCode() != 1 {
-		t.Fatalf("exit code = %d, want 1", exitErr.ExitCode())
+	if exitErr.ExitCode() != exitcode.Usage {
+		t.Fatalf("exit code = %d, want %d", exitErr.ExitCode(), exitcode.Usage)
 	}
 }
 
@@ -164,3 +167,445 @@ func TestParseConfigBareVaultShowsHelpExits(t *testing.T) {
 		t.Fatalf("expected exit 0, got %v", err)
 	}
 }
+
+func TestParseConfigGitFilterCommand(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	os.Args = []string{"vault", "git-filter", "clean", "secret.vt", "--no-aes"}
+	cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+	if cfg.SubCommand != "git-filter" {
+		t.Fatalf("SubCommand = %q, want git-filter", cfg.SubCommand)
+	}
+	if len(cfg.Args) != 2 || cfg.Args[0] != "clean" || cfg.Args[1] != "secret.vt" {
+		t.Fatalf("Args = %v, want [clean secret.vt]", cfg.Args)
+	}
+	if !cfg.DisableAES256 {
+		t.Fatal("expected --no-aes to disable AES256")
+	}
+}
+
+func TestParseConfigGitInstallCommand(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	os.Args = []string{"vault", "git", "install"}
+	cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+	if cfg.SubCommand != "git-install" {
+		t.Fatalf("SubCommand = %q, want git-install", cfg.SubCommand)
+	}
+}
+
+func TestParseConfigDiffRedactFlag(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	os.Args = []string{"vault", "diff", "a.vt", "b.txt", "--redact"}
+	cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+	if cfg.SubCommand != "diff" {
+		t.Fatalf("SubCommand = %q, want diff", cfg.SubCommand)
+	}
+	if len(cfg.Args) != 2 {
+		t.Fatalf("Args = %v, want 2 args", cfg.Args)
+	}
+	if !cfg.RedactDiff {
+		t.Fatal("expected --redact to enable RedactDiff")
+	}
+}
+
+func TestParseConfigRevertCommand(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	os.Args = []string{"vault", "revert", "secret.vt", "3", "--history", "5"}
+	cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+	if cfg.SubCommand != "revert" {
+		t.Fatalf("SubCommand = %q, want revert", cfg.SubCommand)
+	}
+	if len(cfg.Args) != 1 || cfg.Args[0] != "secret.vt" {
+		t.Fatalf("Args = %v, want [secret.vt]", cfg.Args)
+	}
+	if cfg.Revision != "3" {
+		t.Fatalf("Revision = %q, want 3", cfg.Revision)
+	}
+	if cfg.HistoryLimit != 5 {
+		t.Fatalf("HistoryLimit = %d, want 5", cfg.HistoryLimit)
+	}
+}
+
+func TestParseConfigGenCommand(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	os.Args = []string{"vault", "gen", "secret.vt", "DB_PASSWORD", "--words", "6", "--show"}
+	cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+	if cfg.SubCommand != "gen" || cfg.EntryKey != "DB_PASSWORD" {
+		t.Fatalf("SubCommand = %q, EntryKey = %q, want gen DB_PASSWORD", cfg.SubCommand, cfg.EntryKey)
+	}
+	if len(cfg.Args) != 1 || cfg.Args[0] != "secret.vt" {
+		t.Fatalf("Args = %v, want [secret.vt]", cfg.Args)
+	}
+	if cfg.GenWords != 6 || !cfg.GenShow || cfg.GenLength != 32 || cfg.GenCharset != "alnum" {
+		t.Fatalf("unexpected gen config: %+v", cfg)
+	}
+
+	os.Args = []string{"vault", "gen", "API_TOKEN"}
+	cfg = ParseConfig("Demo", "demo", "1.0.0", "abc")
+	if len(cfg.Args) != 0 || cfg.EntryKey != "API_TOKEN" {
+		t.Fatalf("Args = %v, EntryKey = %q, want [] API_TOKEN", cfg.Args, cfg.EntryKey)
+	}
+}
+
+func TestParseConfigGenModesExclusive(t *testing.T) {
+	if args := os.Getenv("TEST_PARSE_CONFIG_GEN_ARGS"); len(args) != 0 {
+		os.Args = append([]string{"vault", "gen", "API_TOKEN"}, strings.Fields(args)...)
+		ParseConfig("Demo", "demo", "1.0.0", "abc")
+		os.Exit(0)
+	}
+
+	for _, args := range []string{"--hex --base64", "--words 6 --charset symbol", "--words 6 --length 12"} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestParseConfigGenModesExclusive$")
+		cmd.Env = append(os.Environ(), "TEST_PARSE_CONFIG_GEN_ARGS="+args)
+		err := cmd.Run()
+		exitErr, ok := err.(*exec.ExitError)
+		if !ok {
+			t.Fatalf("%s: expected exit error, got %v", args, err)
+		}
+		if exitErr.ExitCode() != exitcode.Usage {
+			t.Fatalf("%s: exit code = %d, want %d", args, exitErr.ExitCode(), exitcode.Usage)
+		}
+	}
+}
+
+func TestParseConfigTotpCommand(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	os.Args = []string{"vault", "totp", "secret.vt", "GITHUB_2FA", "--import", "JBSWY3DPEHPK3PXP", "--digits", "8", "--algorithm", "sha256"}
+	cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+	if cfg.SubCommand != "totp" || cfg.EntryKey != "GITHUB_2FA" {
+		t.Fatalf("SubCommand = %q, EntryKey = %q, want totp GITHUB_2FA", cfg.SubCommand, cfg.EntryKey)
+	}
+	if cfg.OTPImport != "JBSWY3DPEHPK3PXP" || cfg.OTPDigits != 8 || cfg.OTPAlgorithm != "sha256" || cfg.OTPPeriod != 0 {
+		t.Fatalf("unexpected totp config: %+v", cfg)
+	}
+
+	os.Args = []string{"vault", "totp", "GITHUB_2FA"}
+	cfg = ParseConfig("Demo", "demo", "1.0.0", "abc")
+	if len(cfg.Args) != 0 || cfg.EntryKey != "GITHUB_2FA" || cfg.OTPImport != "" {
+		t.Fatalf("Args = %v, EntryKey = %q, OTPImport = %q", cfg.Args, cfg.EntryKey, cfg.OTPImport)
+	}
+}
+
+func TestParseConfigPrintClip(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	os.Args = []string{"vault", "print", "secret.vt", "--clip", "--key", "DB_PASSWORD"}
+	cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+	if !cfg.PrintClip || cfg.EntryKey != "DB_PASSWORD" || cfg.ClipTimeout != 45 {
+		t.Fatalf("PrintClip = %v, EntryKey = %q, ClipTimeout = %d", cfg.PrintClip, cfg.EntryKey, cfg.ClipTimeout)
+	}
+
+	t.Setenv("VAULT_CLIP_TIMEOUT", "10")
+	os.Args = []string{"vault", "print", "--clip"}
+	cfg = ParseConfig("Demo", "demo", "1.0.0", "abc")
+	if cfg.ClipTimeout != 10 || cfg.Sources["clip-timeout"] != "env VAULT_CLIP_TIMEOUT" {
+		t.Fatalf("ClipTimeout = %d from %q, want 10 from VAULT_CLIP_TIMEOUT", cfg.ClipTimeout, cfg.Sources["clip-timeout"])
+	}
+}
+
+func TestParseConfigLockArmor(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	os.Args = []string{"vault", "lock", "secret", "--armor"}
+	cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+	if !cfg.Armor || cfg.Sources["armor"] != "flag --armor" {
+		t.Fatalf("Armor = %v from %q, want true from --armor", cfg.Armor, cfg.Sources["armor"])
+	}
+
+	t.Setenv("VAULT_ARMOR", "true")
+	os.Args = []string{"vault", "passwd", "secret"}
+	cfg = ParseConfig("Demo", "demo", "1.0.0", "abc")
+	if !cfg.Armor || cfg.Sources["armor"] != "env VAULT_ARMOR" {
+		t.Fatalf("Armor = %v from %q, want true from VAULT_ARMOR", cfg.Armor, cfg.Sources["armor"])
+	}
+}
+
+func TestParseConfigFilterOutput(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	os.Args = []string{"vault", "decrypt"}
+	cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+	if cfg.SubCommand != "decrypt" || len(cfg.Args) != 0 || cfg.FilterOutput != "-" {
+		t.Fatalf("SubCommand = %q, Args = %v, FilterOutput = %q", cfg.SubCommand, cfg.Args, cfg.FilterOutput)
+	}
+
+	// -o is the output file, the global --output stays the output format
+	os.Args = []string{"vault", "encrypt", "-", "-o", "dump.vt", "--output", "json"}
+	cfg = ParseConfig("Demo", "demo", "1.0.0", "abc")
+	if cfg.SubCommand != "encrypt" || cfg.FilterOutput != "dump.vt" || cfg.Output != "json" || cfg.Args[0] != "-" {
+		t.Fatalf("SubCommand = %q, FilterOutput = %q, Output = %q, Args = %v", cfg.SubCommand, cfg.FilterOutput, cfg.Output, cfg.Args)
+	}
+}
+
+func TestParseConfigPrintOutputFile(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	os.Args = []string{"vault", "print", "keystore.vt", "--format", "raw", "--out", "keystore.p12"}
+	cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+	if cfg.PrintOutputFile != "keystore.p12" || cfg.Output != "text" || cfg.JSONOutput || cfg.PrintFormat != "raw" {
+		t.Fatalf("PrintOutputFile = %q, Output = %q, JSONOutput = %v, PrintFormat = %q", cfg.PrintOutputFile, cfg.Output, cfg.JSONOutput, cfg.PrintFormat)
+	}
+
+	// a file named json is written with -o, --output stays the format
+	os.Args = []string{"vault", "print", "keystore.vt", "--output", "json", "-o", "json"}
+	cfg = ParseConfig("Demo", "demo", "1.0.0", "abc")
+	if cfg.PrintOutputFile != "json" || !cfg.JSONOutput {
+		t.Fatalf("PrintOutputFile = %q, JSONOutput = %v, want json output into 'json'", cfg.PrintOutputFile, cfg.JSONOutput)
+	}
+}
+
+func TestParseConfigHistoryEnv(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	t.Setenv("VAULT_HISTORY", "10")
+	t.Setenv("VAULT_HISTORY_DAYS", "30")
+	os.Args = []string{"vault", "lock", "secret.txt"}
+	cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+	if cfg.HistoryLimit != 10 || cfg.HistoryDays != 30 {
+		t.Fatalf("history = (%d, %d), want (10, 30)", cfg.HistoryLimit, cfg.HistoryDays)
+	}
+}
+
+func TestParseConfigRestoreBackupCommand(t *testing.T) {
+	tests := []struct {
+		args         []string
+		expectedArgs int
+		expectedNum  int
+	}{
+		{[]string{"vault", "restore-backup"}, 0, 0},
+		{[]string{"vault", "restore-backup", "secret.vt"}, 1, 0},
+		{[]string{"vault", "restore-backup", "2"}, 0, 2},
+		{[]string{"vault", "restore-backup", "secret.vt", "3"}, 1, 3},
+	}
+
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	for _, test := range tests {
+		os.Args = test.args
+		cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+		if cfg.SubCommand != "restore-backup" {
+			t.Fatalf("SubCommand = %q, want restore-backup", cfg.SubCommand)
+		}
+		if len(cfg.Args) != test.expectedArgs || cfg.BackupNumber != test.expectedNum {
+			t.Fatalf("%v: Args = %v, BackupNumber = %d", test.args, cfg.Args, cfg.BackupNumber)
+		}
+	}
+}
+
+func TestParseConfigBackupEnv(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	t.Setenv("VAULT_BACKUPS", "4")
+	t.Setenv("VAULT_BACKUP_EXT", "old")
+	os.Args = []string{"vault", "lock", "secret.txt"}
+	cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+	if cfg.BackupCount != 4 || cfg.BackupFileExtension != "old" {
+		t.Fatalf("backup config = (%d, %q), want (4, old)", cfg.BackupCount, cfg.BackupFileExtension)
+	}
+}
+
+func TestParseConfigLockFlags(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	os.Args = []string{"vault", "passwd", "secret.vt", "--no-wait", "--lock-timeout", "5"}
+	cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+	if !cfg.NoWait {
+		t.Fatal("expected --no-wait to enable NoWait")
+	}
+	if cfg.LockTimeout != 5 {
+		t.Fatalf("LockTimeout = %d, want 5", cfg.LockTimeout)
+	}
+}
+
+func TestParseConfigShredDefaults(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	os.Args = []string{"vault", "lock", "secret.txt"}
+	cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+	if cfg.DisableShred {
+		t.Fatal("expected shredding to be enabled by default")
+	}
+	if cfg.ShredPasses != 3 {
+		t.Fatalf("ShredPasses = %d, want 3", cfg.ShredPasses)
+	}
+}
+
+func TestParseConfigShredCommand(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	os.Args = []string{"vault", "shred", "notes.txt", "--shred-passes", "1"}
+	cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+	if cfg.SubCommand != "shred" {
+		t.Fatalf("SubCommand = %q, want shred", cfg.SubCommand)
+	}
+	if len(cfg.Args) != 1 || cfg.Args[0] != "notes.txt" || cfg.ShredPasses != 1 {
+		t.Fatalf("Args = %v, ShredPasses = %d", cfg.Args, cfg.ShredPasses)
+	}
+}
+
+func TestParseConfigInspectVerifyJSON(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	for subCommand, args := range map[string][]string{
+		"inspect": {"--output", "json", "--no-aes"},
+		"verify":  {"--json", "--no-aes"},
+	} {
+		os.Args = append([]string{"vault", subCommand, "secret.vt"}, args...)
+		cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+		if cfg.SubCommand != subCommand {
+			t.Fatalf("SubCommand = %q, want %s", cfg.SubCommand, subCommand)
+		}
+		if len(cfg.Args) != 1 || cfg.Args[0] != "secret.vt" || !cfg.JSONOutput || !cfg.DisableAES256 {
+			t.Fatalf("Args = %v, JSONOutput = %v, DisableAES256 = %v", cfg.Args, cfg.JSONOutput, cfg.DisableAES256)
+		}
+	}
+}
+
+func TestParseConfigOutput(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	tests := []struct {
+		args       []string
+		env        string
+		wantOutput string
+	}{
+		{args: []string{"vault", "lock", "secret"}, wantOutput: "text"},
+		{args: []string{"vault", "lock", "secret", "--output", "json"}, wantOutput: "json"},
+		{args: []string{"vault", "--output", "json", "log", "secret"}, wantOutput: "json"},
+		{args: []string{"vault", "lock", "secret"}, env: "json", wantOutput: "json"},
+		{args: []string{"vault", "verify", "secret", "--json"}, wantOutput: "json"},
+	}
+
+	for _, test := range tests {
+		t.Setenv("VAULT_OUTPUT", test.env)
+		os.Args = test.args
+		cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+		if cfg.Output != test.wantOutput || cfg.JSONOutput != (test.wantOutput == "json") {
+			t.Fatalf("%v: Output = %q, JSONOutput = %v, want %s", test.args, cfg.Output, cfg.JSONOutput, test.wantOutput)
+		}
+	}
+}
+
+func TestParseOutputInvalid(t *testing.T) {
+	appConfig := defaultAppConfig()
+	appConfig.Output = "yaml"
+
+	if err := parseOutput(appConfig); err == nil {
+		t.Fatalf("expected error for output %q", appConfig.Output)
+	}
+}
+
+func TestParseConfigSplit(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	os.Args = []string{"vault", "split", "secret", "--shares", "7", "--threshold", "4"}
+	cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+	if cfg.SubCommand != "split" || cfg.Shares != 7 || cfg.Threshold != 4 {
+		t.Fatalf("SubCommand = %q, Shares = %d, Threshold = %d", cfg.SubCommand, cfg.Shares, cfg.Threshold)
+	}
+
+	os.Args = []string{"vault", "recover-key", "secret"}
+	cfg = ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+	if cfg.SubCommand != "recover-key" || len(cfg.Args) != 1 || cfg.Args[0] != "secret" {
+		t.Fatalf("SubCommand = %q, Args = %v", cfg.SubCommand, cfg.Args)
+	}
+}
+
+func TestParseConfigPasswdSlots(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	tests := []struct {
+		args       []string
+		subCommand string
+		fileArgs   int
+		slotName   string
+		iterations int
+	}{
+		{[]string{"vault", "passwd", "secret"}, "passwd", 1, "", 0},
+		{[]string{"vault", "passwd", "add", "ops-break-glass", "--kdf-iterations", "600000"}, "passwd-add", 0, "ops-break-glass", 600000},
+		{[]string{"vault", "passwd", "remove", "secret", "ops-break-glass"}, "passwd-remove", 1, "ops-break-glass", 0},
+		{[]string{"vault", "passwd", "ls"}, "passwd-list", 0, "", 0},
+	}
+
+	for _, test := range tests {
+		os.Args = test.args
+		cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+		if cfg.SubCommand != test.subCommand || len(cfg.Args) != test.fileArgs ||
+			cfg.SlotName != test.slotName || cfg.KDFIterations != test.iterations {
+			t.Fatalf("%v: unexpected config: %+v", test.args, cfg)
+		}
+	}
+}
+
+func TestParseConfigKeyfiles(t *testing.T) {
+	oldArgs := os.Args
+	t.Cleanup(func() { os.Args = oldArgs })
+
+	tests := []struct {
+		args        []string
+		subCommand  string
+		keyfiles    []string
+		newKeyfiles []string
+		replace     bool
+	}{
+		{[]string{"vault", "unlock", "secret", "--keyfile", "a.key", "--keyfile", "b.key"}, "unlock", []string{"a.key", "b.key"}, nil, false},
+		{[]string{"vault", "passwd", "secret", "--keyfile", "a.key"}, "passwd", []string{"a.key"}, nil, false},
+		{[]string{"vault", "passwd", "secret", "--new-keyfile", ""}, "passwd", nil, []string{""}, true},
+		{[]string{"vault", "passwd", "add", "usb", "--new-keyfile", "c.key"}, "passwd-add", nil, []string{"c.key"}, true},
+		{[]string{"vault", "keyfile", "new", "usb.key"}, "keyfile-new", nil, nil, false},
+	}
+
+	for _, test := range tests {
+		os.Args = test.args
+		cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+		if cfg.SubCommand != test.subCommand || cfg.ReplaceKeyfiles != test.replace ||
+			strings.Join(cfg.Keyfiles, ",") != strings.Join(test.keyfiles, ",") ||
+			strings.Join(cfg.NewKeyfiles, ",") != strings.Join(test.newKeyfiles, ",") ||
+			len(cfg.NewKeyfiles) != len(test.newKeyfiles) {
+			t.Fatalf("%v: unexpected config: %+v", test.args, cfg)
+		}
+	}
+}
@@ -0,0 +1,545 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/NobleMajo/vault/lib/strength"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// RCFileName is the per-directory config file, the nearest one from the
+// working directory upwards is used.
+const RCFileName = ".vaultrc"
+
+// SystemConfigPath is the config file for all users of the system.
+var SystemConfigPath = systemConfigPath()
+
+func systemConfigPath() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), "vault", "config.yaml")
+	}
+
+	return "/etc/vault/config.yaml"
+}
+
+// UserConfigPath returns ~/.config/vault/config.yaml or the platform equivalent.
+func UserConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(dir, "vault", "config.yaml")
+}
+
+// setting is a value that config files, env vars and flags can set.
+type setting struct {
+	key  string
+	env  []string
+	flag string
+	path bool
+	set  func(appConfig *AppConfig, value any, dir string) error
+	get  func(appConfig *AppConfig) string
+}
+
+// settings are the keys of config files and profiles, env and flag
+// names are only used to show where a value came from.
+var settings = []setting{
+	stringSetting("private-key", "VAULT_PRIVATE_KEY_PATH", "private-key", true, func(appConfig *AppConfig) *string { return &appConfig.PrivateKeyPath }),
+	stringSetting("public-key", "VAULT_PUBLIC_KEY_PATH", "public-key", true, func(appConfig *AppConfig) *string { return &appConfig.PublicKeyPath }),
+	stringSetting("vault-ext", "VAULT_EXT", "vault-ext", false, func(appConfig *AppConfig) *string { return &appConfig.VaultFileExtension }),
+	stringSetting("plain-ext", "VAULT_PLAIN_EXT", "plain-ext", false, func(appConfig *AppConfig) *string { return &appConfig.PlainFileExtension }),
+	stringSetting("backup-ext", "VAULT_BACKUP_EXT", "backup-ext", false, func(appConfig *AppConfig) *string { return &appConfig.BackupFileExtension }),
+	stringSetting("pkcs11-module", "VAULT_PKCS11_MODULE", "", true, func(appConfig *AppConfig) *string { return &appConfig.PKCS11Module }),
+	stringsSetting("keyfiles", "VAULT_KEYFILE", "keyfile", true, func(appConfig *AppConfig) *[]string { return &appConfig.Keyfiles }),
+	invertedBoolSetting("rsa", []string{"VAULT_RSA", "VAULT_DISABLE_RSA"}, "no-rsa", func(appConfig *AppConfig) *bool { return &appConfig.DisableRSA }),
+	invertedBoolSetting("aes", []string{"VAULT_AES", "VAULT_DISABLE_AES"}, "no-aes", func(appConfig *AppConfig) *bool { return &appConfig.DisableAES256 }),
+	intSetting("kdf-iterations", "VAULT_KDF_ITERATIONS", "kdf-iterations", func(appConfig *AppConfig) *int { return &appConfig.KDFIterations }),
+	intSetting("history", "VAULT_HISTORY", "history", func(appConfig *AppConfig) *int { return &appConfig.HistoryLimit }),
+	intSetting("history-days", "VAULT_HISTORY_DAYS", "history-days", func(appConfig *AppConfig) *int { return &appConfig.HistoryDays }),
+	intSetting("backups", "VAULT_BACKUPS", "backups", func(appConfig *AppConfig) *int { return &appConfig.BackupCount }),
+	intSetting("backup-days", "VAULT_BACKUP_DAYS", "backup-days", func(appConfig *AppConfig) *int { return &appConfig.BackupDays }),
+	intSetting("lock-timeout", "VAULT_LOCK_TIMEOUT", "lock-timeout", func(appConfig *AppConfig) *int { return &appConfig.LockTimeout }),
+	boolSetting("no-wait", "VAULT_NO_WAIT", "no-wait", func(appConfig *AppConfig) *bool { return &appConfig.NoWait }),
+	boolSetting("no-shred", "VAULT_NO_SHRED", "no-shred", func(appConfig *AppConfig) *bool { return &appConfig.DisableShred }),
+	intSetting("shred-passes", "VAULT_SHRED_PASSES", "shred-passes", func(appConfig *AppConfig) *int { return &appConfig.ShredPasses }),
+	intSetting("temp-seconds", "VAULT_TEMP_DECODE_SECONDS", "temp-seconds", func(appConfig *AppConfig) *int { return &appConfig.TempDecodeSeconds }),
+	boolSetting("armor", "VAULT_ARMOR", "armor", func(appConfig *AppConfig) *bool { return &appConfig.Armor }),
+	intSetting("clip-timeout", "VAULT_CLIP_TIMEOUT", "clip-timeout", func(appConfig *AppConfig) *int { return &appConfig.ClipTimeout }),
+	boolSetting("clean-print", "VAULT_CLEAN_PRINT", "clean-print", func(appConfig *AppConfig) *bool { return &appConfig.CleanPrint }),
+	boolSetting("redact", "VAULT_REDACT_DIFF", "redact", func(appConfig *AppConfig) *bool { return &appConfig.RedactDiff }),
+	intSetting("shares", "", "shares", func(appConfig *AppConfig) *int { return &appConfig.Shares }),
+	intSetting("threshold", "", "threshold", func(appConfig *AppConfig) *int { return &appConfig.Threshold }),
+	boolSetting("verbose", "VAULT_VERBOSE", "verbose", func(appConfig *AppConfig) *bool { return &appConfig.Verbose }),
+	intSetting("password-min-score", "VAULT_PASSWORD_MIN_SCORE", "", func(appConfig *AppConfig) *int { return &appConfig.PasswordPolicy.MinScore }),
+	intSetting("password-min-length", "VAULT_PASSWORD_MIN_LENGTH", "", func(appConfig *AppConfig) *int { return &appConfig.PasswordPolicy.MinLength }),
+	stringsSetting("password-classes", "VAULT_PASSWORD_CLASSES", "", false, func(appConfig *AppConfig) *[]string { return &appConfig.PasswordPolicy.Classes }),
+	stringsSetting("password-deny-list", "VAULT_PASSWORD_DENY_LIST", "", true, func(appConfig *AppConfig) *[]string { return &appConfig.PasswordPolicy.DenyLists }),
+}
+
+// rcSettings are the keys a .vaultrc can set. It comes with a checkout and is not trusted,
+// so keys, modules, keyfiles, encryption layers, shredding and the password policy
+// are left to the system and user config, env vars and flags.
+var rcSettings = []string{
+	"vault-ext", "plain-ext", "backup-ext", "history", "history-days", "backups", "backup-days",
+	"lock-timeout", "no-wait", "armor", "clean-print", "redact", "verbose",
+}
+
+func stringSetting(key string, env string, flag string, path bool, field func(appConfig *AppConfig) *string) setting {
+	return setting{
+		key:  key,
+		env:  envNames(env),
+		flag: flag,
+		path: path,
+		set: func(appConfig *AppConfig, value any, dir string) error {
+			text, err := stringValue(value)
+			if err != nil {
+				return err
+			}
+			if path {
+				text = resolvePath(text, dir)
+			}
+			*field(appConfig) = text
+			return nil
+		},
+		get: func(appConfig *AppConfig) string { return *field(appConfig) },
+	}
+}
+
+// stringsSetting is a list setting, a single string is a list with one item.
+func stringsSetting(key string, env string, flag string, path bool, field func(appConfig *AppConfig) *[]string) setting {
+	return setting{
+		key:  key,
+		env:  envNames(env),
+		flag: flag,
+		path: path,
+		set: func(appConfig *AppConfig, value any, dir string) error {
+			list, err := stringsValue(value)
+			if err != nil {
+				return err
+			}
+			if path {
+				for index := range list {
+					list[index] = resolvePath(list[index], dir)
+				}
+			}
+			*field(appConfig) = list
+			return nil
+		},
+		get: func(appConfig *AppConfig) string { return strings.Join(*field(appConfig), ", ") },
+	}
+}
+
+func intSetting(key string, env string, flag string, field func(appConfig *AppConfig) *int) setting {
+	return setting{
+		key:  key,
+		env:  envNames(env),
+		flag: flag,
+		set: func(appConfig *AppConfig, value any, dir string) error {
+			number, ok := value.(int)
+			if !ok {
+				return errors.New("expected a number")
+			}
+			*field(appConfig) = number
+			return nil
+		},
+		get: func(appConfig *AppConfig) string { return strconv.Itoa(*field(appConfig)) },
+	}
+}
+
+func boolSetting(key string, env string, flag string, field func(appConfig *AppConfig) *bool) setting {
+	return setting{
+		key:  key,
+		env:  envNames(env),
+		flag: flag,
+		set: func(appConfig *AppConfig, value any, dir string) error {
+			enabled, ok := value.(bool)
+			if !ok {
+				return errors.New("expected true or false")
+			}
+			*field(appConfig) = enabled
+			return nil
+		},
+		get: func(appConfig *AppConfig) string { return strconv.FormatBool(*field(appConfig)) },
+	}
+}
+
+// invertedBoolSetting is a setting like "rsa: false" for a field like DisableRSA.
+func invertedBoolSetting(key string, env []string, flag string, field func(appConfig *AppConfig) *bool) setting {
+	result := boolSetting(key, "", flag, field)
+	result.env = env
+	result.set = func(appConfig *AppConfig, value any, dir string) error {
+		enabled, ok := value.(bool)
+		if !ok {
+			return errors.New("expected true or false")
+		}
+		*field(appConfig) = !enabled
+		return nil
+	}
+	result.get = func(appConfig *AppConfig) string { return strconv.FormatBool(!*field(appConfig)) }
+
+	return result
+}
+
+func envNames(env string) []string {
+	if len(env) == 0 {
+		return nil
+	}
+
+	return []string{env}
+}
+
+func stringValue(value any) (string, error) {
+	switch typed := value.(type) {
+	case string:
+		return typed, nil
+	case int:
+		return strconv.Itoa(typed), nil
+	}
+
+	return "", errors.New("expected a string")
+}
+
+func stringsValue(value any) ([]string, error) {
+	if text, ok := value.(string); ok {
+		return []string{text}, nil
+	}
+
+	list, ok := value.([]any)
+	if !ok {
+		return nil, errors.New("expected a list of strings")
+	}
+
+	result := make([]string, 0, len(list))
+	for _, item := range list {
+		text, ok := item.(string)
+		if !ok {
+			return nil, errors.New("expected a list of strings")
+		}
+		result = append(result, text)
+	}
+
+	return result, nil
+}
+
+// resolvePath makes paths of a config file relative to its directory,
+// "~" and pkcs11: URIs are resolved later like flag values.
+func resolvePath(path string, dir string) string {
+	if len(path) == 0 || len(dir) == 0 || filepath.IsAbs(path) ||
+		strings.HasPrefix(path, "~") || strings.HasPrefix(path, "pkcs11:") {
+		return path
+	}
+
+	return filepath.Join(dir, path)
+}
+
+// configFile is a parsed config file layer.
+type configFile struct {
+	path     string
+	profile  string
+	values   map[string]any
+	profiles map[string]map[string]any
+}
+
+// configFilePaths returns the existing config files, the system one first
+// and the nearest .vaultrc last.
+func configFilePaths() []string {
+	var paths []string
+	for _, path := range []string{SystemConfigPath, UserConfigPath()} {
+		if fileExists(path) {
+			paths = append(paths, path)
+		}
+	}
+
+	if rcPath := nearestRCFile(); len(rcPath) != 0 {
+		paths = append(paths, rcPath)
+	}
+
+	return paths
+}
+
+// nearestRCFile walks up from the working directory to the first .vaultrc.
+func nearestRCFile() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+
+	for {
+		path := filepath.Join(dir, RCFileName)
+		if fileExists(path) {
+			return path
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func fileExists(path string) bool {
+	if len(path) == 0 {
+		return false
+	}
+
+	stat, err := os.Stat(path)
+	return err == nil && !stat.IsDir()
+}
+
+func readConfigFile(path string) (*configFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]any{}
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return nil, err
+	}
+
+	file := &configFile{
+		path:     path,
+		values:   map[string]any{},
+		profiles: map[string]map[string]any{},
+	}
+
+	for key, value := range values {
+		switch key {
+		case "profile":
+			file.profile, err = stringValue(value)
+			if err != nil {
+				return nil, errors.New("profile: " + err.Error())
+			}
+		case "profiles":
+			profiles, ok := value.(map[string]any)
+			if !ok {
+				return nil, errors.New("profiles: expected a map of profile names")
+			}
+
+			for name, profileValue := range profiles {
+				profile, ok := profileValue.(map[string]any)
+				if !ok && profileValue != nil {
+					return nil, errors.New("profiles." + name + ": expected a map of settings")
+				}
+				if err := checkKeys(profile, "profiles."+name+"."); err != nil {
+					return nil, err
+				}
+				file.profiles[name] = profile
+			}
+		default:
+			file.values[key] = value
+		}
+	}
+
+	if err := checkKeys(file.values, ""); err != nil {
+		return nil, err
+	}
+
+	if filepath.Base(path) == RCFileName {
+		if err := checkRCKeys(file.values, ""); err != nil {
+			return nil, err
+		}
+		for name, profile := range file.profiles {
+			if err := checkRCKeys(profile, "profiles."+name+"."); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return file, nil
+}
+
+func checkRCKeys(values map[string]any, prefix string) error {
+	for key := range values {
+		if !slices.Contains(rcSettings, key) {
+			return errors.New("setting '" + prefix + key + "' is not allowed in " + RCFileName + ", set it in the user config, an env var or a flag")
+		}
+	}
+
+	return nil
+}
+
+func checkKeys(values map[string]any, prefix string) error {
+	for key := range values {
+		if findSetting(key) == nil {
+			return errors.New("unknown setting '" + prefix + key + "'")
+		}
+	}
+
+	return nil
+}
+
+func findSetting(key string) *setting {
+	for index := range settings {
+		if settings[index].key == key {
+			return &settings[index]
+		}
+	}
+
+	return nil
+}
+
+// loadConfigFiles applies the config file layers, every layer applies its
+// own values first and then the values of the selected profile.
+// The profile comes from --profile, VAULT_PROFILE or the last "profile:" key.
+func loadConfigFiles(appConfig *AppConfig, paths []string, profile string) error {
+	var files []*configFile
+	for _, path := range paths {
+		file, err := readConfigFile(path)
+		if err != nil {
+			return errors.New("config file '" + path + "':\n> " + err.Error())
+		}
+		files = append(files, file)
+
+		if len(file.profile) != 0 {
+			appConfig.Profile = file.profile
+			appConfig.Sources["profile"] = path
+		}
+	}
+
+	if len(profile) != 0 {
+		appConfig.Profile = profile
+		appConfig.Sources["profile"] = "flag --profile"
+	} else if profile = os.Getenv("VAULT_PROFILE"); len(profile) != 0 {
+		appConfig.Profile = profile
+		appConfig.Sources["profile"] = "env VAULT_PROFILE"
+	}
+
+	profileFound := len(appConfig.Profile) == 0
+	for _, file := range files {
+		dir := filepath.Dir(file.path)
+		if err := applyValues(appConfig, file.values, dir, file.path); err != nil {
+			return err
+		}
+
+		profileValues, ok := file.profiles[appConfig.Profile]
+		if ok && len(appConfig.Profile) != 0 {
+			profileFound = true
+
+			source := file.path + " (profile " + appConfig.Profile + ")"
+			if err := applyValues(appConfig, profileValues, dir, source); err != nil {
+				return err
+			}
+		}
+
+		// the password policy of the system config is the org wide floor,
+		// later layers, env vars and flags can only raise it
+		if file.path == SystemConfigPath {
+			appConfig.PasswordFloor = appConfig.PasswordPolicy.Raise(strength.Policy{})
+		}
+	}
+
+	if !profileFound {
+		return errors.New("profile '" + appConfig.Profile + "' is not defined in any config file")
+	}
+
+	return nil
+}
+
+func applyValues(appConfig *AppConfig, values map[string]any, dir string, source string) error {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := findSetting(key).set(appConfig, values[key], dir); err != nil {
+			return errors.New("config file '" + source + "': " + key + ": " + err.Error())
+		}
+		appConfig.Sources[key] = source
+	}
+
+	return nil
+}
+
+// applyPasswordFloor raises the password policy to the floor of the system config
+// and marks the raised settings, then it validates the policy.
+func applyPasswordFloor(appConfig *AppConfig) error {
+	before := appConfig.Values()
+	appConfig.PasswordPolicy = appConfig.PasswordPolicy.Raise(appConfig.PasswordFloor)
+
+	for index, value := range appConfig.Values() {
+		if value.Value != before[index].Value {
+			appConfig.Sources[value.Key] = SystemConfigPath + " (floor)"
+		}
+	}
+
+	return appConfig.PasswordPolicy.Validate()
+}
+
+// recordEnvSources marks the settings that loadEnvVars took from env vars.
+func recordEnvSources(appConfig *AppConfig) {
+	for _, setting := range settings {
+		for _, env := range setting.env {
+			if len(os.Getenv(env)) != 0 {
+				appConfig.Sources[setting.key] = "env " + env
+			}
+		}
+	}
+}
+
+// recordFlagSources marks the settings that the flags of the executed command set.
+func recordFlagSources(appConfig *AppConfig, cmd *cobra.Command) {
+	for _, setting := range settings {
+		if len(setting.flag) == 0 {
+			continue
+		}
+
+		if flag := cmd.Flags().Lookup(setting.flag); flag != nil && flag.Changed {
+			appConfig.Sources[setting.key] = "flag --" + setting.flag
+		}
+	}
+}
+
+// profileFromArgs finds --profile before cobra parses the flags,
+// the config files have to be applied before the env vars and flags.
+func profileFromArgs(args []string) string {
+	for index, arg := range args {
+		if arg == "--" {
+			return ""
+		} else if value, ok := strings.CutPrefix(arg, "--profile="); ok {
+			return value
+		} else if arg == "--profile" && index+1 < len(args) {
+			return args[index+1]
+		}
+	}
+
+	return ""
+}
+
+// Value is an effective setting and where it came from.
+type Value struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// Values returns all settings of config files with their effective value.
+func (appConfig *AppConfig) Values() []Value {
+	values := make([]Value, 0, len(settings))
+	for _, setting := range settings {
+		source := appConfig.Sources[setting.key]
+		if len(source) == 0 {
+			source = "default"
+		}
+
+		values = append(values, Value{
+			Key:    setting.key,
+			Value:  setting.get(appConfig),
+			Source: source,
+		})
+	}
+
+	return values
+}
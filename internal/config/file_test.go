@@ -0,0 +1,201 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, path string, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfigFiles(t *testing.T) {
+	dir := t.TempDir()
+	system := filepath.Join(dir, "etc", "config.yaml")
+	user := filepath.Join(dir, "home", "config.yaml")
+	rc := filepath.Join(dir, "project", RCFileName)
+
+	writeConfigFile(t, system, "vault-ext: sys\nbackups: 1\nprofile: work\n")
+	writeConfigFile(t, user, "vault-ext: user\nkeyfiles:\n  - usb.key\n  - /abs.key\nprofiles:\n  work:\n    private-key: keys/work\n    backups: 5\n    rsa: false\n")
+	writeConfigFile(t, rc, "vault-ext: rc\n")
+
+	appConfig := defaultAppConfig()
+	if err := loadConfigFiles(appConfig, []string{system, user, rc}, ""); err != nil {
+		t.Fatalf("loadConfigFiles error: %v", err)
+	}
+
+	if appConfig.Profile != "work" || appConfig.VaultFileExtension != "rc" || appConfig.BackupCount != 5 ||
+		!appConfig.DisableRSA || appConfig.PrivateKeyPath != filepath.Join(dir, "home", "keys", "work") {
+		t.Fatalf("unexpected config: %+v", appConfig)
+	}
+
+	if len(appConfig.Keyfiles) != 2 || appConfig.Keyfiles[0] != filepath.Join(dir, "home", "usb.key") ||
+		appConfig.Keyfiles[1] != "/abs.key" {
+		t.Fatalf("unexpected keyfiles: %v", appConfig.Keyfiles)
+	}
+
+	if appConfig.Sources["vault-ext"] != rc || appConfig.Sources["backups"] != user+" (profile work)" ||
+		appConfig.Sources["profile"] != system {
+		t.Fatalf("unexpected sources: %v", appConfig.Sources)
+	}
+
+	// without the profile only the plain values apply
+	appConfig = defaultAppConfig()
+	writeConfigFile(t, system, "backups: 1\n")
+	if err := loadConfigFiles(appConfig, []string{system, user}, ""); err != nil {
+		t.Fatalf("loadConfigFiles error: %v", err)
+	}
+	if appConfig.BackupCount != 1 || appConfig.DisableRSA || len(appConfig.Profile) != 0 {
+		t.Fatalf("unexpected config without profile: %+v", appConfig)
+	}
+}
+
+func TestLoadConfigFilesInvalid(t *testing.T) {
+	tests := []struct {
+		content string
+		profile string
+	}{
+		{"unknown: 1\n", ""},
+		{"backups: many\n", ""},
+		{"rsa: maybe\n", ""},
+		{"keyfiles: [1, 2]\n", ""},
+		{"profiles:\n  work:\n    typo: 1\n", ""},
+		{"profiles: [work]\n", ""},
+		{"backups: 1\n", "missing"},
+		{"backups: [\n", ""},
+	}
+
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		writeConfigFile(t, path, test.content)
+
+		if err := loadConfigFiles(defaultAppConfig(), []string{path}, test.profile); err == nil {
+			t.Fatalf("%q with profile %q: expected an error", test.content, test.profile)
+		}
+	}
+}
+
+func TestLoadConfigFilesRC(t *testing.T) {
+	// a .vaultrc comes with a checkout, it can not load modules or change keys and encryption
+	tests := []string{
+		"pkcs11-module: evil.so\n",
+		"public-key: attacker.pub\n",
+		"private-key: key\n",
+		"keyfiles: [usb.key]\n",
+		"rsa: true\n",
+		"aes: false\n",
+		"no-shred: true\n",
+		"password-min-score: 0\n",
+		"profiles:\n  work:\n    public-key: attacker.pub\n",
+	}
+
+	for _, content := range tests {
+		path := filepath.Join(t.TempDir(), RCFileName)
+		writeConfigFile(t, path, content)
+
+		if err := loadConfigFiles(defaultAppConfig(), []string{path}, ""); err == nil || !strings.Contains(err.Error(), "not allowed") {
+			t.Fatalf("%q: error = %v, want not allowed", content, err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), RCFileName)
+	writeConfigFile(t, path, "plain-ext: md\nhistory: 5\nprofiles:\n  work:\n    backups: 2\n")
+	if err := loadConfigFiles(defaultAppConfig(), []string{path}, "work"); err != nil {
+		t.Fatalf("loadConfigFiles error: %v", err)
+	}
+}
+
+func TestProfileFromArgs(t *testing.T) {
+	tests := []struct {
+		args    []string
+		profile string
+	}{
+		{[]string{"lock", "--profile", "work"}, "work"},
+		{[]string{"--profile=home", "unlock"}, "home"},
+		{[]string{"lock", "--", "--profile", "work"}, ""},
+		{[]string{"lock", "--profile"}, ""},
+	}
+
+	for _, test := range tests {
+		if profile := profileFromArgs(test.args); profile != test.profile {
+			t.Fatalf("profileFromArgs(%v) = %q, want %q", test.args, profile, test.profile)
+		}
+	}
+}
+
+func TestParseConfigLayers(t *testing.T) {
+	oldArgs := os.Args
+	oldSystem := SystemConfigPath
+	t.Cleanup(func() {
+		os.Args = oldArgs
+		SystemConfigPath = oldSystem
+	})
+
+	dir := t.TempDir()
+	SystemConfigPath = filepath.Join(dir, "etc", "config.yaml")
+	writeConfigFile(t, SystemConfigPath, "history: 3\nbackups: 2\n")
+	writeConfigFile(t, filepath.Join(dir, "config", "vault", "config.yaml"), "profiles:\n  work:\n    vault-ext: work\n")
+	writeConfigFile(t, filepath.Join(dir, "project", RCFileName), "plain-ext: md\nbackups: 4\n")
+	if err := os.MkdirAll(filepath.Join(dir, "project", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("VAULT_BACKUPS", "6")
+	t.Chdir(filepath.Join(dir, "project", "sub"))
+
+	os.Args = []string{"vault", "lock", "secret", "--profile", "work", "--history", "7"}
+	cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+	if cfg.VaultFileExtension != "work" || cfg.PlainFileExtension != "md" ||
+		cfg.BackupCount != 6 || cfg.HistoryLimit != 7 {
+		t.Fatalf("unexpected config: %+v", cfg)
+	}
+
+	if cfg.Sources["backups"] != "env VAULT_BACKUPS" || cfg.Sources["history"] != "flag --history" ||
+		cfg.Sources["plain-ext"] != filepath.Join(dir, "project", RCFileName) {
+		t.Fatalf("unexpected sources: %v", cfg.Sources)
+	}
+}
+
+func TestParseConfigPasswordFloor(t *testing.T) {
+	oldArgs := os.Args
+	oldSystem := SystemConfigPath
+	t.Cleanup(func() {
+		os.Args = oldArgs
+		SystemConfigPath = oldSystem
+	})
+
+	dir := t.TempDir()
+	SystemConfigPath = filepath.Join(dir, "etc", "config.yaml")
+	writeConfigFile(t, SystemConfigPath, "password-min-score: 3\npassword-classes: [digit]\npassword-deny-list: deny.txt\n")
+	writeConfigFile(t, filepath.Join(dir, "config", "vault", "config.yaml"),
+		"password-min-score: 1\npassword-min-length: 12\npassword-classes: [upper]\npassword-deny-list: []\n")
+
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "config"))
+	t.Setenv("VAULT_PASSWORD_MIN_SCORE", "0")
+	t.Chdir(dir)
+
+	os.Args = []string{"vault", "lock", "secret"}
+	cfg := ParseConfig("Demo", "demo", "1.0.0", "abc")
+
+	policy := cfg.PasswordPolicy
+	if policy.MinScore != 3 || policy.MinLength != 12 || strings.Join(policy.Classes, ",") != "digit,upper" ||
+		strings.Join(policy.DenyLists, ",") != filepath.Join(dir, "etc", "deny.txt") {
+		t.Fatalf("unexpected policy: %+v", policy)
+	}
+
+	if cfg.Sources["password-min-score"] != SystemConfigPath+" (floor)" ||
+		cfg.Sources["password-min-length"] != filepath.Join(dir, "config", "vault", "config.yaml") {
+		t.Fatalf("unexpected sources: %v", cfg.Sources)
+	}
+}
@@ -0,0 +1,42 @@
+// Package exitcode defines the documented exit codes of the vault cli,
+// scripts can rely on them to tell failures apart.
+package exitcode
+
+const (
+	// OK means the command succeeded.
+	OK = 0
+	// Error is any failure without a more specific code.
+	Error = 1
+	// Usage is an unknown command, flag or argument, or an invalid config file.
+	Usage = 2
+	// NotFound is a missing vault, plain or backup file.
+	NotFound = 3
+	// AuthFailed is a wrong password, keyfile or private key.
+	AuthFailed = 4
+	// BadKey is a key that can not be loaded, like a wrong key format or a token error.
+	BadKey = 5
+	// Corrupted is a vault file that can not be parsed or fails its integrity checks.
+	Corrupted = 6
+	// Locked is a vault file that is locked by another process.
+	Locked = 7
+)
+
+var names = map[int]string{
+	OK:         "ok",
+	Error:      "error",
+	Usage:      "usage",
+	NotFound:   "not_found",
+	AuthFailed: "auth_failed",
+	BadKey:     "bad_key",
+	Corrupted:  "corrupted",
+	Locked:     "locked",
+}
+
+// Name returns the name of the exit code used in json errors, like "auth_failed".
+func Name(code int) string {
+	if name, ok := names[code]; ok {
+		return name
+	}
+
+	return "error"
+}
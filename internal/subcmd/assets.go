@@ -1,122 +1,297 @@
 package subcmd
 
 import (
+	"bytes"
+	"crypto"
 	"crypto/rsa"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/NobleMajo/vault/internal/config"
-	"github.com/NobleMajo/vault/lib/cryption"
+	"github.com/NobleMajo/vault/lib/pkcs11"
+	"github.com/NobleMajo/vault/lib/secret"
+	"github.com/NobleMajo/vault/lib/strength"
 	"github.com/NobleMajo/vault/lib/userin"
+	"github.com/NobleMajo/vault/pkg/vault"
 )
 
-var err error
-var lastUsedPrivateKey *rsa.PrivateKey
+// The cli caches loaded keys and the password for the whole process,
+// so commands that open and seal a vault ask only once.
+var lastUsedPrivateKey crypto.Decrypter
 var lastUsedPublicKey *rsa.PublicKey
-var lastUsedPassword string
 
-func loadDecryptionData(appConfig *config.AppConfig) {
+// lastUsedPassword lives in locked memory outside of the go heap,
+// use forgetPassword or WipeSecrets to zero it.
+var lastUsedPassword *secret.Buffer
+
+// openOptions returns the options to decrypt the current revision
+// with the configured private key and the prompted password.
+func openOptions(appConfig *config.AppConfig) vault.OpenOptions {
+	options := vault.OpenOptions{}
+
 	if !appConfig.DisableRSA {
-		lastUsedPrivateKey, err = cryption.LoadRsaPrivateKey(appConfig.PrivateKeyPath)
+		options.PrivateKey = func() (crypto.Decrypter, error) {
+			if lastUsedPrivateKey == nil {
+				protectSecrets(appConfig)
 
-		if err != nil {
-			exitError("Load private key error:\n> " + err.Error())
-			return
+				privateKey, err := loadPrivateKey(appConfig)
+				if err != nil {
+					return nil, &keyError{fmt.Errorf("Load private key error:\n> %w", err)}
+				}
+				lastUsedPrivateKey = privateKey
+			}
+
+			return lastUsedPrivateKey, nil
 		}
 	}
 
-	if !appConfig.DisableAES256 && len(lastUsedPassword) == 0 {
-		lastUsedPassword, err = userin.PromptPassword()
+	if !appConfig.DisableAES256 {
+		options.Password = func() ([]byte, error) {
+			if lastUsedPassword.Len() == 0 {
+				protectSecrets(appConfig)
 
-		if err != nil {
-			exitError("Prompt new password error:\n> " + err.Error())
-			return
+				password, err := userin.PromptPassword()
+				if err != nil {
+					return nil, fmt.Errorf("Prompt password error:\n> %w", err)
+				}
+				lastUsedPassword = password
+			}
+
+			return lastUsedPassword.Bytes(), nil
 		}
 	}
+
+	options.Keyfiles = keyfiles(appConfig.Keyfiles)
+
+	return options
+}
+
+// loadPrivateKey loads the private key file or opens the key
+// of a pkcs11: URI on its token, asking for slot and PIN if needed.
+func loadPrivateKey(appConfig *config.AppConfig) (crypto.Decrypter, error) {
+	if !pkcs11.IsURI(appConfig.PrivateKeyPath) {
+		return vault.PrivateKeyFile(appConfig.PrivateKeyPath)()
+	}
+
+	key, err := pkcs11.Open(appConfig.PrivateKeyPath, pkcs11.Options{
+		ModulePath: appConfig.PKCS11Module,
+		PIN: func(token pkcs11.Token) ([]byte, error) {
+			pin, err := userin.PromptPIN(token.Label)
+			if err != nil {
+				return nil, fmt.Errorf("Prompt PIN error:\n> %w", err)
+			}
+			defer pin.Destroy()
+
+			// the login wipes the copy
+			return bytes.Clone(pin.Bytes()), nil
+		},
+		SelectToken: func(tokens []pkcs11.Token) (int, error) {
+			labels := make([]string, len(tokens))
+			for index, token := range tokens {
+				labels[index] = token.Label + " (slot " + strconv.FormatUint(uint64(token.SlotID), 10) + ", " + token.Manufacturer + " " + token.Model + ")"
+			}
+
+			return userin.PromptSelect("Select the pkcs11 token:", labels)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
 }
 
-func loadEncryptionData(appConfig *config.AppConfig) {
+// sealOptions returns the options to encrypt with the configured public key
+// and the last used or a new prompted password.
+// With history the revisions of the previous vault file are kept,
+// without only its creation time.
+func sealOptions(
+	previousVaultFile string,
+	withHistory bool,
+	appConfig *config.AppConfig,
+) (vault.SealOptions, error) {
+	options := vault.SealOptions{
+		History:       withHistory,
+		HistoryLimit:  appConfig.HistoryLimit,
+		HistoryMaxAge: historyMaxAge(appConfig),
+		User:          currentUserName(),
+		KDFIterations: appConfig.KDFIterations,
+		Armor:         appConfig.Armor,
+		// vault files with key slots keep their data key, it is unlocked with the current keys
+		Unlock: openOptions(appConfig),
+	}
+
+	if len(previousVaultFile) != 0 {
+		stat, err := os.Stat(previousVaultFile)
+		if err == nil {
+			options.Previous, err = os.ReadFile(previousVaultFile)
+			if err != nil {
+				return options, errors.New("Error while read vault source from '" + previousVaultFile + "':\n> " + err.Error())
+			}
+			// vault files without history get their modification time as revision time
+			options.PreviousTime = stat.ModTime()
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return options, err
+		}
+	}
+
 	if !appConfig.DisableRSA {
-		lastUsedPublicKey, err = cryption.LoadRsaPublicKey(appConfig.PublicKeyPath)
+		options.PublicKey = func() (*rsa.PublicKey, error) {
+			if lastUsedPublicKey == nil {
+				publicKey, err := vault.PublicKeyFile(appConfig.PublicKeyPath)()
+				if err != nil {
+					return nil, &keyError{fmt.Errorf("Load public key error:\n> %w", err)}
+				}
+				lastUsedPublicKey = publicKey
+			}
 
-		if err != nil {
-			exitError("Load public key error:\n> " + err.Error())
-			return
+			return lastUsedPublicKey, nil
 		}
 	}
 
-	if !appConfig.DisableAES256 && len(lastUsedPassword) == 0 {
-		lastUsedPassword, err = userin.PromptNewPassword()
+	if !appConfig.DisableAES256 {
+		options.Password = func() ([]byte, error) {
+			if lastUsedPassword.Len() == 0 {
+				protectSecrets(appConfig)
 
-		if err != nil {
-			exitError("Prompt new password error:\n> " + err.Error())
-			return
+				check, err := passwordCheck(appConfig)
+				if err != nil {
+					return nil, fmt.Errorf("Password policy error:\n> %w", err)
+				}
+
+				password, err := userin.PromptNewPassword(check)
+				if err != nil {
+					return nil, fmt.Errorf("Prompt new password error:\n> %w", err)
+				}
+				lastUsedPassword = password
+			}
+
+			return lastUsedPassword.Bytes(), nil
 		}
 	}
+
+	options.Keyfiles = keyfiles(appConfig.Keyfiles)
+
+	return options, nil
 }
 
-func exitError(message string) {
-	fmt.Fprintln(
-		os.Stderr,
-		message,
-	)
-	os.Exit(1)
+// passwordCheck rates new passwords with the configured password policy.
+// The user and host name count as known words of the password.
+func passwordCheck(appConfig *config.AppConfig) (userin.PasswordCheck, error) {
+	userInputs := []string{currentUserName()}
+	if hostname, err := os.Hostname(); err == nil {
+		userInputs = append(userInputs, hostname)
+	}
+
+	checker, err := strength.NewChecker(appConfig.PasswordPolicy, userInputs...)
+	if err != nil {
+		return userin.PasswordCheck{}, err
+	}
+
+	return userin.PasswordCheck{
+		Meter: func(password []byte) string {
+			if len(password) == 0 {
+				return ""
+			}
+			return strengthMeter(checker.Estimate(password))
+		},
+		Check: checker.Check,
+	}, nil
 }
 
-func VaultEncrypt(
-	payload []byte,
-	doX509 bool,
-	X509PublicKey *rsa.PublicKey,
-	doAES256 bool,
-	AES256Key []byte,
-) ([]byte, error) {
-	if !doX509 && !doAES256 {
-		return nil, fmt.Errorf("no encryption method selected")
+// strengthMeter shows the score as bar with the crack time and the main weakness.
+func strengthMeter(result strength.Result) string {
+	meter := "[" + strings.Repeat("#", result.Score+1) + strings.Repeat("-", 4-result.Score) + "] " +
+		strength.ScoreNames[result.Score] + ", cracked in " + result.CrackTimeText()
+	if len(result.Warning) != 0 {
+		meter += ": " + result.Warning
 	}
-	var err error
 
-	if doAES256 {
-		payload, err = cryption.AES256Encrypt(AES256Key, payload)
-		if err != nil {
-			return nil, fmt.Errorf("AES256 encrypt error, maybe wrong password:\n> %v", err)
+	return meter
+}
+
+// keyfiles returns the provider for the configured keyfiles, nil without keyfiles.
+// Empty paths are skipped, so an empty --new-keyfile means no keyfile.
+func keyfiles(paths []string) vault.KeyfileProvider {
+	var existing []string
+	for _, path := range paths {
+		if len(path) != 0 {
+			existing = append(existing, path)
 		}
 	}
 
-	if doX509 {
-		payload, err = cryption.X509AES256Encrypt(X509PublicKey, payload)
+	if len(existing) == 0 {
+		return nil
+	}
+
+	provider := vault.KeyfileFiles(existing...)
+	return func() ([]byte, error) {
+		hash, err := provider()
 		if err != nil {
-			return nil, fmt.Errorf("x509 encrypt error:\n> %v", err)
+			return nil, &keyError{err}
 		}
+
+		return hash, nil
 	}
+}
 
-	return payload, nil
+// openVault decrypts the current content of a raw vault file.
+func openVault(
+	rawPayload []byte,
+	appConfig *config.AppConfig,
+) ([]byte, error) {
+	return vault.Open(rawPayload, openOptions(appConfig))
 }
 
-func VaultDecrypt(
-	payload []byte,
-	doX509 bool,
-	X509PrivateKey *rsa.PrivateKey,
-	doAES256 bool,
-	AES256Key []byte,
+// sealVault encrypts the plain text into the raw vault file content.
+func sealVault(
+	plainText []byte,
+	previousVaultFile string,
+	withHistory bool,
+	appConfig *config.AppConfig,
 ) ([]byte, error) {
-	if !doX509 && !doAES256 {
-		return nil, fmt.Errorf("no decryption method selected")
+	options, err := sealOptions(previousVaultFile, withHistory, appConfig)
+	if err != nil {
+		return nil, err
 	}
-	var err error
 
-	if doX509 {
-		payload, err = cryption.X509AES256Decrypt(X509PrivateKey, payload)
-		if err != nil {
-			return nil, fmt.Errorf("x509 decrypt error:\n> %v", err)
-		}
-	}
+	return vault.Seal(plainText, options)
+}
 
-	if doAES256 {
-		payload, err = cryption.AES256Decrypt(AES256Key, payload)
-		if err != nil {
-			return nil, fmt.Errorf("AES256 decrypt error, maybe wrong password:\n> %v", err)
-		}
+// protectSecrets disables core dumps before any password or key is loaded,
+// so they can not end up in a crash dump of the process.
+func protectSecrets(appConfig *config.AppConfig) {
+	err := secret.DisableCoreDumps()
+	if err != nil && appConfig.Verbose {
+		fmt.Fprintln(os.Stderr, "Disable core dumps error:\n> "+err.Error())
 	}
+}
+
+// forgetPassword zeroes the last used password, the next operation prompts again.
+func forgetPassword() {
+	lastUsedPassword.Destroy()
+	lastUsedPassword = nil
+}
 
-	return payload, nil
+// detachPassword returns the last used password and clears the cache, the next operation prompts again.
+// The caller has to destroy the returned buffer once the running operation does not use it anymore.
+func detachPassword() *secret.Buffer {
+	password := lastUsedPassword
+	lastUsedPassword = nil
+	return password
+}
+
+// WipeSecrets zeroes the loaded password and drops the loaded keys.
+func WipeSecrets() {
+	forgetPassword()
+	if closer, ok := lastUsedPrivateKey.(io.Closer); ok {
+		// token keys log out and unload their module
+		closer.Close()
+	}
+	lastUsedPrivateKey = nil
+	lastUsedPublicKey = nil
 }
@@ -0,0 +1,138 @@
+package subcmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/lib/stringfs"
+)
+
+// backupVaultFile saves the current vault file as newest rotating backup
+// before it gets overwritten and prunes the backups by count and age.
+func backupVaultFile(
+	vaultFile string,
+	appConfig *config.AppConfig,
+) {
+	if appConfig.BackupCount < 1 {
+		return
+	}
+
+	err := stringfs.RotateBackups(
+		vaultFile,
+		appConfig.BackupFileExtension,
+		appConfig.BackupCount,
+	)
+
+	if err != nil {
+		exitWithError("Backup vault file error", err)
+		return
+	}
+
+	err = stringfs.PruneBackups(
+		vaultFile,
+		appConfig.BackupFileExtension,
+		time.Duration(appConfig.BackupDays)*24*time.Hour,
+		time.Now(),
+	)
+
+	if err != nil {
+		exitWithError("Prune backups error", err)
+		return
+	}
+}
+
+// RestoreBackupOperation lists the backups of a vault file if no backup number
+// is given, otherwise it restores the backup as vault file.
+// The replaced vault file becomes the newest backup.
+func RestoreBackupOperation(
+	targetFile string,
+	appConfig *config.AppConfig,
+) {
+	targetVaultFile := targetFile + "." + appConfig.VaultFileExtension
+
+	lockVault(targetVaultFile, appConfig)
+	defer unlockVault()
+
+	backups, err := stringfs.ListBackups(targetVaultFile, appConfig.BackupFileExtension)
+	if err != nil {
+		exitWithError("List backups error", err)
+		return
+	}
+
+	if appConfig.BackupNumber == 0 && outputJSON {
+		list := []map[string]any{}
+		for _, backup := range backups {
+			list = append(list, map[string]any{
+				"number": backup.Number,
+				"file":   backup.Path,
+				"time":   backup.ModTime,
+				"size":   backup.Size,
+			})
+		}
+
+		printResult("", map[string]any{
+			"vaultFile": targetVaultFile,
+			"backups":   list,
+		})
+		return
+	}
+
+	if appConfig.BackupNumber == 0 {
+		if len(backups) == 0 {
+			fmt.Println("No backups of '" + targetVaultFile + "' found!")
+			return
+		}
+
+		fmt.Printf("%-5s %-20s %10s  %s\n", "NUM", "TIME", "SIZE", "FILE")
+		for _, backup := range backups {
+			fmt.Printf(
+				"%-5s %-20s %10d  %s\n",
+				strconv.Itoa(backup.Number),
+				backup.ModTime.Local().Format("2006-01-02 15:04:05"),
+				backup.Size,
+				backup.Path,
+			)
+		}
+		return
+	}
+
+	backupFile := stringfs.BackupPath(targetVaultFile, appConfig.BackupFileExtension, appConfig.BackupNumber)
+
+	if _, err := os.Stat(backupFile); errors.Is(err, os.ErrNotExist) {
+		exitErrorCode(exitcode.NotFound, "Backup file '"+backupFile+"' does not exist!")
+		return
+	}
+
+	backupPayload, err := os.ReadFile(backupFile)
+	if err != nil {
+		exitWithError("Read backup error", err)
+		return
+	}
+
+	if appConfig.BackupCount < 1 {
+		// never lose the replaced vault file, even if backups are not configured
+		appConfig.BackupCount = len(backups) + 1
+	}
+
+	backupVaultFile(targetVaultFile, appConfig)
+
+	err = stringfs.SafeWriteFileBytes(
+		targetVaultFile,
+		backupPayload,
+		0640,
+	)
+
+	if err != nil {
+		exitWithError("Write file error", err)
+		return
+	}
+
+	fields := vaultFields(targetVaultFile, backupPayload)
+	fields["backupFile"] = backupFile
+	printResult("Backup "+strconv.Itoa(appConfig.BackupNumber)+" restored!", fields)
+}
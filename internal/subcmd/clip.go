@@ -0,0 +1,83 @@
+package subcmd
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/lib/clipboard"
+)
+
+// copyToClipboard copies the value to the clipboard instead of printing it and waits
+// the clip timeout to clear it again, CTRL+C clears it right away.
+// The clipboard is only cleared if it can be read back and still holds the value.
+func copyToClipboard(
+	sourceVaultFile string,
+	key string,
+	value []byte,
+	appConfig *config.AppConfig,
+) {
+	board, err := clipboard.Detect()
+	if err != nil {
+		exitWithError("Clipboard error", err)
+		return
+	}
+
+	err = board.Write(value)
+	if err != nil {
+		exitWithError("Copy to clipboard error", err)
+		return
+	}
+
+	fields := map[string]any{
+		"vaultFile":   sourceVaultFile,
+		"clipboard":   board.Name(),
+		"clipTimeout": appConfig.ClipTimeout,
+	}
+
+	copied := "Copied '" + sourceVaultFile + "' to the clipboard via " + board.Name()
+	if len(key) != 0 {
+		fields["key"] = key
+		copied = "Copied '" + key + "' of '" + sourceVaultFile + "' to the clipboard via " + board.Name()
+	}
+
+	if appConfig.ClipTimeout <= 0 {
+		printResult(copied+"!", fields)
+		return
+	}
+
+	printText(copied + ", it is cleared in " + strconv.Itoa(appConfig.ClipTimeout) + " seconds or on CTRL+C...")
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	timer := time.NewTimer(time.Duration(appConfig.ClipTimeout) * time.Second)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+
+	cleared, err := clipboard.ClearIf(board, value)
+	if errors.Is(err, clipboard.ErrUnreadable) {
+		fields["cleared"] = false
+		printResult("Clipboard can not be read back via "+board.Name()+", it is not cleared, clear it yourself!", fields)
+		return
+	} else if err != nil {
+		exitWithError("Clear clipboard error", err)
+		return
+	}
+
+	fields["cleared"] = cleared
+	if cleared {
+		printResult("Clipboard cleared!", fields)
+	} else {
+		printResult("Clipboard was changed in the meantime, it is not cleared.", fields)
+	}
+}
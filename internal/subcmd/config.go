@@ -0,0 +1,30 @@
+package subcmd
+
+import (
+	"fmt"
+
+	"github.com/NobleMajo/vault/internal/config"
+)
+
+// ConfigShowOperation prints the effective settings and where each one came from.
+func ConfigShowOperation(
+	appConfig *config.AppConfig,
+) {
+	if outputJSON {
+		printResult("", map[string]any{
+			"profile": appConfig.Profile,
+			"values":  appConfig.Values(),
+		})
+		return
+	}
+
+	if len(appConfig.Profile) != 0 {
+		fmt.Println("Profile: " + appConfig.Profile + " (" + appConfig.Sources["profile"] + ")")
+		fmt.Println()
+	}
+
+	fmt.Printf("%-20s %-32s %s\n", "KEY", "VALUE", "SOURCE")
+	for _, value := range appConfig.Values() {
+		fmt.Printf("%-20s %-32s %s\n", value.Key, value.Value, value.Source)
+	}
+}
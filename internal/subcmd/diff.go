@@ -0,0 +1,95 @@
+package subcmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/lib/cryption"
+	"github.com/NobleMajo/vault/lib/stringfs"
+	"github.com/NobleMajo/vault/lib/textdiff"
+)
+
+// DiffOperation prints the differences between two files as unified diff.
+// Vault files are decrypted in memory, plain files are read as they are.
+func DiffOperation(
+	oldFile string,
+	newFile string,
+	appConfig *config.AppConfig,
+) {
+	oldText := readDiffSource(oldFile, appConfig)
+	newText := readDiffSource(newFile, appConfig)
+
+	var result string
+	if appConfig.RedactDiff {
+		result = textdiff.Redacted(oldFile, newFile, oldText, newText)
+	} else {
+		result = textdiff.Unified(oldFile, newFile, oldText, newText, 3)
+	}
+
+	if outputJSON {
+		printResult("", map[string]any{
+			"oldFile":   oldFile,
+			"newFile":   newFile,
+			"different": len(result) != 0,
+			"diff":      result,
+			"redacted":  appConfig.RedactDiff,
+		})
+		return
+	}
+
+	if len(result) == 0 {
+		fmt.Println("No differences!")
+		return
+	}
+
+	fmt.Print(result)
+}
+
+// readDiffSource returns the plain text of a file, vault files are decrypted.
+// The diff works on strings, so the decrypted text can not be wiped afterwards.
+func readDiffSource(
+	sourceFile string,
+	appConfig *config.AppConfig,
+) string {
+	if _, err := os.Stat(sourceFile); errors.Is(err, os.ErrNotExist) {
+		exitErrorCode(exitcode.NotFound, "Source file '"+sourceFile+"' does not exist!")
+		return ""
+	}
+
+	rawPayload, err := stringfs.ReadFile(sourceFile)
+	if err != nil {
+		exitWithError("Error while read source from '"+sourceFile+"'", err)
+		return ""
+	}
+
+	if !strings.HasSuffix(sourceFile, "."+appConfig.VaultFileExtension) {
+		return rawPayload
+	}
+
+	plainText, err := openVault(
+		[]byte(rawPayload),
+		appConfig,
+	)
+
+	if errors.Is(err, cryption.ErrAuthFailed) && !appConfig.DisableAES256 {
+		// both vault files can have different passwords, e.g. after passwd
+		fmt.Fprintln(os.Stderr, "Password does not match '"+sourceFile+"', try another one.")
+		forgetPassword()
+
+		plainText, err = openVault(
+			[]byte(rawPayload),
+			appConfig,
+		)
+	}
+
+	if err != nil {
+		exitWithError("Vault decrypt '"+sourceFile+"' error", err)
+		return ""
+	}
+
+	return string(plainText)
+}
@@ -0,0 +1,105 @@
+package subcmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/lib/armor"
+	"github.com/NobleMajo/vault/lib/stringfs"
+	"github.com/NobleMajo/vault/lib/userin"
+	"golang.org/x/term"
+)
+
+// readVaultSource reads the vault file of the target and returns it with its name,
+// the target '-' reads a binary or armored vault message from stdin.
+func readVaultSource(targetFile string, appConfig *config.AppConfig) (string, []byte) {
+	if targetFile == "-" {
+		if term.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Fprintln(os.Stderr, "Paste the armored vault message:")
+		}
+
+		// an armored message ends at its END line, so it can be pasted without CTRL+D
+		rawPayload, err := armor.ReadMessage(userin.Stdin())
+		if err != nil {
+			exitWithError("Error while read vault message from stdin", err)
+			return "", nil
+		}
+		if len(rawPayload) == 0 {
+			exitError("No vault message on stdin!")
+			return "", nil
+		}
+
+		return "stdin", rawPayload
+	}
+
+	sourceVaultFile := targetFile + "." + appConfig.VaultFileExtension
+
+	if _, err := os.Stat(sourceVaultFile); errors.Is(err, os.ErrNotExist) {
+		exitErrorCode(exitcode.NotFound, "Source vault file '"+sourceVaultFile+"' does not exist!")
+		return "", nil
+	}
+
+	rawPayload, err := os.ReadFile(sourceVaultFile)
+	if err != nil {
+		exitWithError("Error while read vault source from '"+sourceVaultFile+"'", err)
+		return "", nil
+	}
+
+	return sourceVaultFile, rawPayload
+}
+
+// readVaultContent decrypts the current content of a vault file for a change,
+// a missing vault file has no content. The caller has to wipe the content.
+func readVaultContent(vaultFile string, appConfig *config.AppConfig) []byte {
+	if _, err := os.Stat(vaultFile); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	rawPayload, err := os.ReadFile(vaultFile)
+	if err != nil {
+		exitWithError("Error while read vault source from '"+vaultFile+"'", err)
+		return nil
+	}
+
+	plainText, err := openVault(rawPayload, appConfig)
+	if err != nil {
+		exitWithError("Decrypt error", err)
+		return nil
+	}
+
+	return plainText
+}
+
+// writeVaultContent seals the changed content as new revision into the vault file
+// and returns the written payload.
+func writeVaultContent(vaultFile string, plainText []byte, appConfig *config.AppConfig) []byte {
+	cipherPayload, err := sealVault(
+		plainText,
+		vaultFile,
+		historyEnabled(appConfig),
+		appConfig,
+	)
+
+	if err != nil {
+		exitWithError("Vault encrypt error", err)
+		return nil
+	}
+
+	backupVaultFile(vaultFile, appConfig)
+
+	err = stringfs.SafeWriteFileBytes(
+		vaultFile,
+		cipherPayload,
+		0640,
+	)
+
+	if err != nil {
+		exitWithError("Write file error", err)
+		return nil
+	}
+
+	return cipherPayload
+}
@@ -0,0 +1,274 @@
+package subcmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/lib/cryption"
+	"github.com/NobleMajo/vault/lib/secret"
+	"github.com/NobleMajo/vault/lib/stringfs"
+	"github.com/NobleMajo/vault/lib/userin"
+	"github.com/NobleMajo/vault/pkg/vault"
+	"golang.org/x/term"
+)
+
+// EncryptOperation seals the plain input into a vault payload, '-' is stdin or stdout,
+// so vault can be used in pipelines. Passwords are read from the terminal.
+// The input is streamed into an encrypted stream, armored messages and keyfiles
+// need a vault file, which is sealed as a whole.
+func EncryptOperation(
+	input string,
+	output string,
+	appConfig *config.AppConfig,
+) {
+	checkFilterOutput(output)
+
+	if output == "-" && !appConfig.Armor && term.IsTerminal(int(os.Stdout.Fd())) {
+		exitErrorCode(exitcode.Usage, "Refusing to write a binary vault payload to the terminal, use -o FILE, --armor or a pipe!")
+		return
+	}
+
+	source := openFilterSource(input)
+
+	options, err := sealOptions("", false, appConfig)
+	if err != nil {
+		exitWithError("Vault encrypt error", err)
+		return
+	}
+
+	if appConfig.Armor || options.Keyfiles != nil {
+		encryptVaultFile(input, output, source, appConfig)
+		return
+	}
+
+	var size int64
+	err = writeFilterOutput(output, func(writer io.Writer) error {
+		encrypter, err := vault.SealStream(writer, options)
+		if err != nil {
+			return err
+		}
+
+		if input == "-" && term.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Fprintln(os.Stderr, "Type the plain text, end it with CTRL+D:")
+		}
+
+		size, err = io.Copy(encrypter, source)
+		if err != nil {
+			return err
+		}
+
+		return encrypter.Close()
+	})
+
+	if err != nil {
+		exitWithError("Vault encrypt error", err)
+		return
+	}
+
+	if output == "-" {
+		return
+	}
+
+	printResult("Encrypted '"+filterName(input)+"' into '"+output+"'!", map[string]any{
+		"vaultFile": output,
+		"plainFile": filterName(input),
+		"size":      size,
+		"stream":    true,
+	})
+}
+
+// encryptVaultFile seals the whole input into a new vault file,
+// an existing output is replaced without its history.
+func encryptVaultFile(
+	input string,
+	output string,
+	source io.Reader,
+	appConfig *config.AppConfig,
+) {
+	if input == "-" && term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprintln(os.Stderr, "Type the plain text, end it with CTRL+D:")
+	}
+
+	plainText, err := io.ReadAll(source)
+	if err != nil {
+		exitWithError("Read plain source error", err)
+		return
+	}
+	defer secret.Wipe(plainText)
+
+	cipherPayload, err := sealVault(
+		plainText,
+		"",
+		false,
+		appConfig,
+	)
+
+	if err != nil {
+		exitWithError("Vault encrypt error", err)
+		return
+	}
+
+	if output == "-" {
+		writeStdout(cipherPayload)
+		return
+	}
+
+	writeFilterFile(output, cipherPayload)
+
+	fields := vaultFields(output, cipherPayload)
+	fields["plainFile"] = filterName(input)
+	printResult("Encrypted '"+filterName(input)+"' into '"+output+"'!", fields)
+}
+
+// DecryptOperation writes the plain text of a vault payload, '-' is stdin or stdout,
+// so vault can be used in pipelines. Encrypted streams are decrypted while they are read,
+// binary and armored vault files are read as a whole.
+func DecryptOperation(
+	input string,
+	output string,
+	appConfig *config.AppConfig,
+) {
+	checkFilterOutput(output)
+
+	source := openFilterSource(input)
+
+	// a stream is never typed, a terminal gets a pasted armored message
+	if input != "-" || !term.IsTerminal(int(os.Stdin.Fd())) {
+		prefix, _ := source.Peek(len(cryption.StreamMagic))
+		if vault.IsStream(prefix) {
+			decryptStream(input, output, source, appConfig)
+			return
+		}
+	}
+
+	var vaultRaw []byte
+	if input == "-" {
+		_, vaultRaw = readVaultSource(input, appConfig)
+	} else {
+		var err error
+		vaultRaw, err = io.ReadAll(source)
+		if err != nil {
+			exitWithError("Error while read vault source from '"+input+"'", err)
+			return
+		}
+	}
+
+	plainText, err := openVault(
+		vaultRaw,
+		appConfig,
+	)
+
+	if err != nil {
+		exitWithError("Decrypt error", err)
+		return
+	}
+	defer secret.Wipe(plainText)
+
+	if output == "-" {
+		writeStdout(plainText)
+		return
+	}
+
+	writeFilterFile(output, plainText)
+
+	printResult("Decrypted '"+filterName(input)+"' into '"+output+"'!", map[string]any{
+		"vaultFile": filterName(input),
+		"plainFile": output,
+		"size":      len(plainText),
+	})
+}
+
+// decryptStream writes the plain text of an encrypted stream while it is read.
+// Only authenticated chunks are written, a damaged stream fails at the damaged chunk,
+// an output file is then not changed.
+func decryptStream(
+	input string,
+	output string,
+	source io.Reader,
+	appConfig *config.AppConfig,
+) {
+	reader, err := vault.OpenStream(source, openOptions(appConfig))
+	if err != nil {
+		exitWithError("Decrypt error", err)
+		return
+	}
+
+	var size int64
+	err = writeFilterOutput(output, func(writer io.Writer) error {
+		size, err = io.Copy(writer, reader)
+		return err
+	})
+
+	if err != nil {
+		exitWithError("Decrypt error", err)
+		return
+	}
+
+	if output == "-" {
+		return
+	}
+
+	printResult("Decrypted '"+filterName(input)+"' into '"+output+"'!", map[string]any{
+		"vaultFile": filterName(input),
+		"plainFile": output,
+		"size":      size,
+		"stream":    true,
+	})
+}
+
+// checkFilterOutput rejects json output on stdout, it would mix with the payload.
+func checkFilterOutput(output string) {
+	if outputJSON && output == "-" {
+		exitErrorCode(exitcode.Usage, "Json output needs -o FILE, stdout carries the payload!")
+	}
+}
+
+// openFilterSource opens the input file by its exact name, no extension is added.
+// Stdin is reserved for the data, so prompts read from the terminal.
+func openFilterSource(input string) *bufio.Reader {
+	if input == "-" {
+		userin.ReserveStdin()
+		return userin.Stdin()
+	}
+
+	file, err := os.Open(input)
+	if errors.Is(err, os.ErrNotExist) {
+		exitErrorCode(exitcode.NotFound, "Source file '"+input+"' does not exist!")
+		return nil
+	} else if err != nil {
+		exitWithError("Open source file error", err)
+		return nil
+	}
+
+	// the file is closed on exit
+	return bufio.NewReader(file)
+}
+
+// writeFilterOutput streams into stdout or atomically replaces the output file.
+func writeFilterOutput(output string, write func(writer io.Writer) error) error {
+	if output == "-" {
+		return write(os.Stdout)
+	}
+
+	return stringfs.SafeWriteFileFunc(output, 0640, write)
+}
+
+func writeFilterFile(output string, payload []byte) {
+	err := stringfs.SafeWriteFileBytes(output, payload, 0640)
+	if err != nil {
+		exitWithError("Write file error", err)
+	}
+}
+
+func filterName(input string) string {
+	if input == "-" {
+		return "stdin"
+	}
+
+	return input
+}
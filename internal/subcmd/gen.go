@@ -0,0 +1,96 @@
+package subcmd
+
+import (
+	"math"
+	"os"
+
+	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/lib/envfile"
+	"github.com/NobleMajo/vault/lib/generate"
+	"github.com/NobleMajo/vault/lib/secret"
+)
+
+// GenOperation generates a random secret and stores it as KEY=value entry
+// in the vault file, a missing vault file is created.
+// The secret is only printed with --show.
+func GenOperation(
+	targetFile string,
+	key string,
+	appConfig *config.AppConfig,
+) {
+	targetVaultFile := targetFile + "." + appConfig.VaultFileExtension
+
+	if !envfile.ValidKey(key) {
+		exitErrorCode(exitcode.Usage, "Key '"+key+"' is invalid, use letters, digits and _ and do not start with a digit!")
+		return
+	}
+
+	value, bits, err := generateSecret(appConfig)
+	if err != nil {
+		exitErrorCode(exitcode.Usage, "Generate error:\n> "+err.Error())
+		return
+	}
+	defer secret.Wipe(value)
+
+	lockVault(targetVaultFile, appConfig)
+	defer unlockVault()
+
+	plainText := readVaultContent(targetVaultFile, appConfig)
+	defer secret.Wipe(plainText)
+
+	if existing, found := envfile.Lookup(plainText, key); found {
+		secret.Wipe(existing)
+		if !appConfig.Force {
+			exitError("Key '" + key + "' already exists in '" + targetVaultFile + "', use --force to replace it!")
+			return
+		}
+	}
+
+	newPlainText, replaced, err := envfile.Set(plainText, key, value)
+	if err != nil {
+		exitWithError("Set entry error", err)
+		return
+	}
+	defer secret.Wipe(newPlainText)
+
+	cipherPayload := writeVaultContent(targetVaultFile, newPlainText, appConfig)
+
+	fields := vaultFields(targetVaultFile, cipherPayload)
+	fields["key"] = key
+	fields["replaced"] = replaced
+	fields["entropyBits"] = math.Floor(bits)
+	if appConfig.GenShow && outputJSON {
+		fields["value"] = string(value)
+	}
+
+	printResult("Generated '"+key+"' into '"+targetVaultFile+"'!", fields)
+
+	if appConfig.GenShow && !outputJSON {
+		os.Stdout.Write(value)
+		os.Stdout.Write([]byte("\n"))
+	}
+}
+
+// generateSecret creates the secret of the gen flags and returns its entropy in bits.
+func generateSecret(appConfig *config.AppConfig) ([]byte, float64, error) {
+	switch {
+	case appConfig.GenWords > 0:
+		value, err := generate.Words(appConfig.GenWords, appConfig.GenSeparator)
+		return value, generate.WordsBits(appConfig.GenWords), err
+	case appConfig.GenHex:
+		value, err := generate.Hex(appConfig.GenLength)
+		return value, generate.BytesBits(appConfig.GenLength), err
+	case appConfig.GenBase64:
+		value, err := generate.Base64(appConfig.GenLength)
+		return value, generate.BytesBits(appConfig.GenLength), err
+	}
+
+	charset, err := generate.ResolveCharset(appConfig.GenCharset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	value, err := generate.Charset(appConfig.GenLength, charset)
+	return value, generate.CharsetBits(appConfig.GenLength, charset), err
+}
@@ -0,0 +1,291 @@
+package subcmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/lib/secret"
+	"github.com/NobleMajo/vault/lib/stringfs"
+	"github.com/NobleMajo/vault/pkg/vault"
+)
+
+// GitFilterOperation implements the git clean and smudge filter.
+// Both read the file content from stdin and write the converted content to stdout.
+//
+// clean encrypts the plain working copy for the repository. If the plain text
+// equals the decrypted version in the index, the already stored cipher payload
+// is reused, so unchanged content always results in the same blob even if the
+// encryption itself uses random salts and ivs.
+//
+// smudge decrypts the stored vault data for the working copy. If that is not
+// possible (e.g. missing key) the data is passed through unchanged.
+func GitFilterOperation(
+	args []string,
+	appConfig *config.AppConfig,
+) {
+	mode := args[0]
+	path := ""
+	if len(args) >= 2 {
+		path = args[1]
+	}
+
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		exitWithError("Read stdin error", err)
+		return
+	}
+
+	defer secret.Wipe(input)
+
+	if len(input) == 0 {
+		return
+	}
+
+	if mode == "clean" {
+		gitClean(path, input, appConfig)
+	} else if mode == "smudge" {
+		gitSmudge(path, input, appConfig)
+	} else {
+		exitErrorCode(exitcode.Usage, "Unknown git filter mode '"+mode+"', use 'clean' or 'smudge'!")
+	}
+}
+
+func gitClean(
+	path string,
+	plainText []byte,
+	appConfig *config.AppConfig,
+) {
+	var previousPayload []byte
+	if len(path) != 0 {
+		previousPayload = gitStoredBlob(path)
+	}
+
+	if previousPayload != nil {
+		if bytes.Equal(previousPayload, plainText) {
+			// working copy was never smudged, it is already the stored cipher payload
+			writeStdout(plainText)
+			return
+		}
+
+		previousPlainText, err := openVault(
+			previousPayload,
+			appConfig,
+		)
+
+		if err != nil {
+			exitWithError("Decrypt stored version of '"+path+"' error, wrong password or key?", err)
+			return
+		}
+
+		equal := bytes.Equal(previousPlainText, plainText)
+		secret.Wipe(previousPlainText)
+
+		if equal {
+			writeStdout(previousPayload)
+			return
+		}
+	}
+
+	options, err := sealOptions("", false, appConfig)
+	if err != nil {
+		exitWithError("", err)
+		return
+	}
+
+	// the key slots of the stored version are kept
+	options.Previous = previousPayload
+
+	cipherPayload, err := vault.Seal(plainText, options)
+
+	if err != nil {
+		exitWithError("Vault encrypt error", err)
+		return
+	}
+
+	writeStdout(cipherPayload)
+}
+
+func gitSmudge(
+	path string,
+	cipherPayload []byte,
+	appConfig *config.AppConfig,
+) {
+	plainText, err := openVault(cipherPayload, appConfig)
+
+	if err != nil {
+		fmt.Fprintln(
+			os.Stderr,
+			"vault: keep '"+path+"' encrypted, decrypt error:\n> "+err.Error(),
+		)
+		writeStdout(cipherPayload)
+		return
+	}
+
+	writeStdout(plainText)
+	secret.Wipe(plainText)
+}
+
+// GitTextconvOperation prints the decrypted content of the given file for git diff.
+// Files that can not be decrypted are printed as they are.
+func GitTextconvOperation(
+	path string,
+	appConfig *config.AppConfig,
+) {
+	rawPayload, err := os.ReadFile(path)
+	if err != nil {
+		exitWithError("Read file error", err)
+		return
+	}
+
+	if len(rawPayload) == 0 {
+		return
+	}
+
+	plainText, err := openVault(rawPayload, appConfig)
+	if err != nil {
+		writeStdout(rawPayload)
+		return
+	}
+
+	writeStdout(plainText)
+	secret.Wipe(plainText)
+}
+
+// GitInstallOperation registers the vault filter and diff driver in the local
+// git config and adds the vault file pattern to the repositories .gitattributes.
+func GitInstallOperation(
+	appConfig *config.AppConfig,
+) {
+	rootDir, err := gitOutput("rev-parse", "--show-toplevel")
+	if err != nil {
+		exitWithError("Not inside a git repository", err)
+		return
+	}
+
+	attributesFile := filepath.Join(strings.TrimSpace(rootDir), ".gitattributes")
+	attributesLine := "*." + appConfig.VaultFileExtension + " filter=vault diff=vault"
+
+	attributes := ""
+	if stringfs.Exists(attributesFile) {
+		attributes, err = stringfs.ReadFile(attributesFile)
+		if err != nil {
+			exitWithError("Read .gitattributes error", err)
+			return
+		}
+	}
+
+	if !containsLine(attributes, attributesLine) {
+		if len(attributes) != 0 && !strings.HasSuffix(attributes, "\n") {
+			attributes += "\n"
+		}
+		attributes += attributesLine + "\n"
+
+		err = stringfs.SafeWriteFile(attributesFile, attributes, 0644)
+		if err != nil {
+			exitWithError("Write .gitattributes error", err)
+			return
+		}
+	}
+
+	vaultBinary, cryptFlags := gitVaultCommand(appConfig)
+
+	gitSettings := [][2]string{
+		{"filter.vault.clean", vaultBinary + " git-filter clean %f" + cryptFlags},
+		{"filter.vault.smudge", vaultBinary + " git-filter smudge %f" + cryptFlags},
+		{"filter.vault.required", "true"},
+		// git appends the file path to the textconv command
+		{"diff.vault.textconv", vaultBinary + " git-textconv" + cryptFlags},
+	}
+
+	for _, setting := range gitSettings {
+		_, err = gitOutput("config", "--local", setting[0], setting[1])
+		if err != nil {
+			exitWithError("Set git config '"+setting[0]+"' error", err)
+			return
+		}
+	}
+
+	printResult("Git integration installed for '"+attributesLine+"'!", map[string]any{
+		"attributesFile": attributesFile,
+		"attributes":     attributesLine,
+	})
+}
+
+// gitStoredBlob returns the staged or committed content of the path or nil.
+func gitStoredBlob(path string) []byte {
+	for _, object := range []string{":" + path, "HEAD:" + path} {
+		blob, err := exec.Command("git", "cat-file", "blob", object).Output()
+		if err == nil && len(blob) != 0 {
+			return blob
+		}
+	}
+
+	return nil
+}
+
+func gitOutput(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = os.Stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+
+	return string(output), nil
+}
+
+// gitVaultCommand returns the quoted vault binary git should call
+// and the crypt flags of the install call.
+func gitVaultCommand(appConfig *config.AppConfig) (string, string) {
+	executable, err := exec.LookPath("vault")
+	if err != nil {
+		executable, err = os.Executable()
+		if err != nil {
+			executable = "vault"
+		}
+	}
+
+	flags := ""
+
+	if appConfig.DisableRSA {
+		flags += " --no-rsa"
+	} else {
+		flags += " --private-key " + shellQuote(appConfig.PrivateKeyPath) +
+			" --public-key " + shellQuote(appConfig.PublicKeyPath)
+	}
+
+	if appConfig.DisableAES256 {
+		flags += " --no-aes"
+	}
+
+	return shellQuote(executable), flags
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
+
+func containsLine(content string, line string) bool {
+	for _, contentLine := range strings.Split(content, "\n") {
+		if strings.TrimSpace(contentLine) == line {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeStdout(payload []byte) {
+	_, err := os.Stdout.Write(payload)
+	if err != nil {
+		exitWithError("Write stdout error", err)
+	}
+}
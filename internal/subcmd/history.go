@@ -0,0 +1,87 @@
+package subcmd
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/pkg/vault"
+)
+
+func historyEnabled(appConfig *config.AppConfig) bool {
+	return appConfig.HistoryLimit > 0 || appConfig.HistoryDays > 0
+}
+
+func historyMaxAge(appConfig *config.AppConfig) time.Duration {
+	return time.Duration(appConfig.HistoryDays) * 24 * time.Hour
+}
+
+// readVaultFile reads the raw vault file and its metadata.
+func readVaultFile(vaultFile string) ([]byte, *vault.Info, error) {
+	stat, err := os.Stat(vaultFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rawPayload, err := os.ReadFile(vaultFile)
+	if err != nil {
+		return nil, nil, errors.New("Error while read vault source from '" + vaultFile + "':\n> " + err.Error())
+	}
+
+	info, err := vault.Inspect(rawPayload)
+	if err != nil {
+		return nil, nil, errors.New("Parse vault file '" + vaultFile + "' error:\n> " + err.Error())
+	}
+
+	if !info.Container {
+		// vault files without history get their modification time as revision time
+		info.Revisions[0].Time = stat.ModTime()
+	}
+
+	return rawPayload, info, nil
+}
+
+// findRevision returns the index of a revision by its number (1 is the oldest)
+// or by a prefix of its content hash.
+func findRevision(
+	hashes []string,
+	revisionArg string,
+) (int, error) {
+	number, err := strconv.Atoi(revisionArg)
+	if err == nil {
+		if number < 1 || number > len(hashes) {
+			return 0, errors.New("revision " + revisionArg + " does not exist, use 1 to " + strconv.Itoa(len(hashes)))
+		}
+
+		return number - 1, nil
+	}
+
+	found := -1
+	for index, hash := range hashes {
+		if strings.HasPrefix(hash, strings.ToLower(revisionArg)) {
+			if found != -1 {
+				return 0, errors.New("revision hash prefix '" + revisionArg + "' is ambiguous")
+			}
+			found = index
+		}
+	}
+
+	if found == -1 {
+		return 0, errors.New("revision '" + revisionArg + "' does not exist")
+	}
+
+	return found, nil
+}
+
+func currentUserName() string {
+	currentUser, err := user.Current()
+	if err == nil && len(currentUser.Username) != 0 {
+		return currentUser.Username
+	}
+
+	return os.Getenv("USER")
+}
@@ -11,6 +11,9 @@ func InitOperation(
 ) {
 	targetVaultFile := targetFile + "." + appConfig.VaultFileExtension
 
+	lockVault(targetVaultFile, appConfig)
+	defer unlockVault()
+
 	exists := stringfs.Exists(targetFile + "." + appConfig.PlainFileExtension)
 	if exists {
 		exitError("File plain text file '" + targetFile + "." + appConfig.PlainFileExtension + "' already exists!")
@@ -25,18 +28,15 @@ func InitOperation(
 
 	initText := "Hello and welcome to your own vault!\n\n<3"
 
-	loadEncryptionData(appConfig)
-
-	cipherPayload, err := VaultEncrypt(
+	cipherPayload, err := sealVault(
 		[]byte(initText),
-		!appConfig.DisableRSA,
-		lastUsedPublicKey,
-		!appConfig.DisableAES256,
-		[]byte(lastUsedPassword),
+		targetVaultFile,
+		historyEnabled(appConfig),
+		appConfig,
 	)
 
 	if err != nil {
-		exitError("Vault encrypt error:\n> " + err.Error())
+		exitWithError("Vault encrypt error", err)
 		return
 	}
 
@@ -47,7 +47,9 @@ func InitOperation(
 	)
 
 	if err != nil {
-		exitError("Write file error:\n> " + err.Error())
+		exitWithError("Write file error", err)
 		return
 	}
+
+	printResult("", vaultFields(targetVaultFile, cipherPayload))
 }
@@ -0,0 +1,238 @@
+package subcmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/pkg/vault"
+)
+
+// InspectOperation prints the metadata of a vault file, no key or password is needed.
+func InspectOperation(
+	targetFile string,
+	appConfig *config.AppConfig,
+) {
+	sourceVaultFile := targetFile + "." + appConfig.VaultFileExtension
+
+	if _, err := os.Stat(sourceVaultFile); errors.Is(err, os.ErrNotExist) {
+		exitErrorCode(exitcode.NotFound, "Source vault file '"+sourceVaultFile+"' does not exist!")
+		return
+	}
+
+	_, info, err := readVaultFile(sourceVaultFile)
+	if err != nil {
+		exitWithError("", err)
+		return
+	}
+
+	if outputJSON {
+		fields := map[string]any{
+			"file":      sourceVaultFile,
+			"container": info.Container,
+			"version":   info.Version,
+			"armored":   info.Armored,
+			"size":      info.Size,
+			"revisions": info.Revisions,
+		}
+		if !info.Created.IsZero() {
+			fields["created"] = info.Created
+		}
+		if len(info.Slots) != 0 {
+			fields["slots"] = info.Slots
+		}
+		printResult("", fields)
+		return
+	}
+
+	fmt.Printf("%-12s %s\n", "File:", sourceVaultFile)
+	if info.Container {
+		fmt.Printf("%-12s %s\n", "Format:", "vault container v"+strconv.Itoa(info.Version))
+	} else {
+		fmt.Printf("%-12s %s\n", "Format:", "vault payload without metadata (older version)")
+	}
+	if info.Armored {
+		fmt.Printf("%-12s %s\n", "Armor:", "armored text message")
+	}
+	fmt.Printf("%-12s %s\n", "Size:", strconv.Itoa(info.Size)+" bytes")
+	if !info.Created.IsZero() {
+		fmt.Printf("%-12s %s\n", "Created:", info.Created.Local().Format("2006-01-02 15:04:05"))
+	}
+	fmt.Printf("%-12s %s\n", "Revisions:", strconv.Itoa(len(info.Revisions)))
+
+	for _, slot := range info.Slots {
+		fmt.Println()
+		fmt.Println("Key slot " + strconv.Itoa(slot.Number) + " (" + slot.Name + ")")
+
+		if slot.Kind == "recovery" {
+			fmt.Printf(
+				"  %-12s %s\n",
+				"Recovery:",
+				strconv.Itoa(slot.Threshold)+" of "+strconv.Itoa(slot.Shares)+" shares",
+			)
+			continue
+		}
+
+		printEncryption(slot.Encryption)
+	}
+
+	for index := len(info.Revisions) - 1; index >= 0; index-- {
+		revision := info.Revisions[index]
+
+		fmt.Println()
+		fmt.Println("Revision " + strconv.Itoa(revision.Number) + currentMark(info, index))
+		fmt.Printf("  %-12s %s\n", "Time:", revisionTime(revision.Time))
+		fmt.Printf("  %-12s %s\n", "User:", revisionUser(revision.User))
+		fmt.Printf("  %-12s %s\n", "Size:", strconv.Itoa(revision.Size)+" bytes")
+
+		if revision.DataKey {
+			fmt.Printf("  %-12s %s\n", "Encryption:", "AES-256-CFB, HMAC-SHA256 with the data key of the key slots")
+			continue
+		}
+
+		printEncryption(revision.Encryption)
+	}
+}
+
+func printEncryption(encryption *vault.EncryptionInfo) {
+	if encryption == nil {
+		fmt.Printf("  %-12s %s\n", "Encryption:", "unknown")
+		return
+	}
+
+	fmt.Printf("  %-12s %s\n", "Encryption:", strings.Join(encryption.Algorithms, ", "))
+	if encryption.Password {
+		fmt.Printf(
+			"  %-12s %s\n",
+			"KDF:",
+			encryption.KDF+", "+strconv.Itoa(encryption.KDFIterations)+" iterations",
+		)
+	}
+	if encryption.Keyfile {
+		fmt.Printf("  %-12s %s\n", "Keyfile:", "required")
+	}
+	for _, recipient := range encryption.Recipients {
+		fmt.Printf("  %-12s %s\n", "Recipient:", recipient)
+	}
+}
+
+// VerifyOperation decrypts every revision in memory to check its integrity.
+// The plain text is never written.
+func VerifyOperation(
+	targetFile string,
+	appConfig *config.AppConfig,
+) {
+	sourceVaultFile := targetFile + "." + appConfig.VaultFileExtension
+
+	if _, err := os.Stat(sourceVaultFile); errors.Is(err, os.ErrNotExist) {
+		exitErrorCode(exitcode.NotFound, "Source vault file '"+sourceVaultFile+"' does not exist!")
+		return
+	}
+
+	rawPayload, err := os.ReadFile(sourceVaultFile)
+	if err != nil {
+		exitWithError("Error while read vault source from '"+sourceVaultFile+"'", err)
+		return
+	}
+
+	slotChecks, checks, err := vault.Verify(rawPayload, openOptions(appConfig))
+	if err != nil {
+		exitWithError("Verify error", err)
+		return
+	}
+
+	type slotResult struct {
+		Number  int    `json:"number"`
+		Name    string `json:"name"`
+		Checked bool   `json:"checked"`
+		OK      bool   `json:"ok"`
+		Error   string `json:"error,omitempty"`
+	}
+
+	type revisionResult struct {
+		Number int    `json:"number"`
+		OK     bool   `json:"ok"`
+		Error  string `json:"error,omitempty"`
+	}
+
+	failed := 0
+	slots := []slotResult{}
+	for _, check := range slotChecks {
+		result := slotResult{Number: check.Number, Name: check.Name, Checked: check.Opened, OK: check.Opened && check.Err == nil}
+		if check.Err != nil {
+			result.Error = check.Err.Error()
+		}
+		if check.Opened && check.Err != nil {
+			failed++
+		}
+		slots = append(slots, result)
+	}
+
+	results := []revisionResult{}
+	for _, check := range checks {
+		result := revisionResult{Number: check.Number, OK: check.Err == nil}
+		if check.Err != nil {
+			result.Error = check.Err.Error()
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	if appConfig.JSONOutput {
+		printJSON(struct {
+			File      string           `json:"file"`
+			OK        bool             `json:"ok"`
+			Slots     []slotResult     `json:"slots"`
+			Revisions []revisionResult `json:"revisions"`
+		}{sourceVaultFile, failed == 0, slots, results})
+	} else {
+		for index, result := range slots {
+			name := "Key slot " + strconv.Itoa(result.Number) + " (" + result.Name + ")"
+			switch {
+			case result.OK:
+				fmt.Println(name + ": ok")
+			case result.Checked:
+				fmt.Println(name + ": corrupted\n> " + result.Error)
+			case len(result.Error) != 0:
+				fmt.Println(name + ": not opened, another password or damaged\n> " + result.Error)
+			case slotChecks[index].Kind == "recovery":
+				fmt.Println(name + ": not checked, needs the recovery shares")
+			default:
+				fmt.Println(name + ": not checked, needs other keys")
+			}
+		}
+		for _, result := range results {
+			if result.OK {
+				fmt.Println("Revision " + strconv.Itoa(result.Number) + ": ok")
+			} else {
+				fmt.Println("Revision " + strconv.Itoa(result.Number) + ": corrupted\n> " + result.Error)
+			}
+		}
+	}
+
+	if failed != 0 {
+		exitErrorCode(exitcode.Corrupted, "Verify failed, "+strconv.Itoa(failed)+" of "+strconv.Itoa(len(slotChecks)+len(checks))+" key slots and revisions corrupted!")
+		return
+	}
+
+	if !appConfig.JSONOutput {
+		fmt.Println("Verified!")
+	}
+}
+
+func printJSON(value any) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	err := encoder.Encode(value)
+	if err != nil {
+		exitWithError("Json encode error", err)
+		return
+	}
+}
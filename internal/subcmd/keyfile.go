@@ -0,0 +1,58 @@
+package subcmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/NobleMajo/vault/lib/cryption"
+	"github.com/NobleMajo/vault/lib/secret"
+)
+
+// keyfileSize is the number of random bytes of a new keyfile.
+const keyfileSize = 64
+
+// KeyfileNewOperation creates a new random keyfile, an existing file is never overwritten.
+func KeyfileNewOperation(
+	keyfilePath string,
+) {
+	if _, err := os.Stat(keyfilePath); err == nil {
+		exitError("Keyfile '" + keyfilePath + "' already exists!")
+		return
+	} else if !errors.Is(err, os.ErrNotExist) {
+		exitWithError("Keyfile error", err)
+		return
+	}
+
+	content, err := cryption.RandomByteArray(keyfileSize)
+	if err != nil {
+		exitWithError("Generate keyfile error", err)
+		return
+	}
+	defer secret.Wipe(content)
+
+	file, err := os.OpenFile(keyfilePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0400)
+	if err != nil {
+		exitWithError("Create keyfile error", err)
+		return
+	}
+
+	_, err = file.Write(content)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(keyfilePath)
+		exitWithError("Write keyfile error", err)
+		return
+	}
+
+	printResult("Keyfile '"+keyfilePath+"' created!", map[string]any{
+		"keyfile": keyfilePath,
+		"size":    keyfileSize,
+	})
+	printText("Keep a copy, a vault file locked with it can not be unlocked without it.")
+}
@@ -2,10 +2,11 @@ package subcmd
 
 import (
 	"errors"
-	"fmt"
 	"os"
 
 	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/lib/secret"
 	"github.com/NobleMajo/vault/lib/stringfs"
 )
 
@@ -16,32 +17,35 @@ func LockOperation(
 	sourcePlainFile := targetFile + "." + appConfig.PlainFileExtension
 	targetVaultFile := targetFile + "." + appConfig.VaultFileExtension
 
+	lockVault(targetVaultFile, appConfig)
+	defer unlockVault()
+
 	if _, err := os.Stat(sourcePlainFile); errors.Is(err, os.ErrNotExist) {
-		exitError("Plain source file '" + sourcePlainFile + "' does not exist!")
+		exitErrorCode(exitcode.NotFound, "Plain source file '"+sourcePlainFile+"' does not exist!")
 		return
 	}
 
-	plainText, err := stringfs.ReadFile(sourcePlainFile)
+	plainText, err := os.ReadFile(sourcePlainFile)
 	if err != nil {
-		exitError("Read plain source error:\n> " + err.Error())
+		exitWithError("Read plain source error", err)
 		return
 	}
+	defer secret.Wipe(plainText)
 
-	loadEncryptionData(appConfig)
-
-	cipherPayload, err := VaultEncrypt(
-		[]byte(plainText),
-		!appConfig.DisableRSA,
-		lastUsedPublicKey,
-		!appConfig.DisableAES256,
-		[]byte(lastUsedPassword),
+	cipherPayload, err := sealVault(
+		plainText,
+		targetVaultFile,
+		historyEnabled(appConfig),
+		appConfig,
 	)
 
 	if err != nil {
-		exitError("Vault encrypt error:\n> " + err.Error())
+		exitWithError("Vault encrypt error", err)
 		return
 	}
 
+	backupVaultFile(targetVaultFile, appConfig)
+
 	err = stringfs.SafeWriteFileBytes(
 		targetVaultFile,
 		cipherPayload,
@@ -49,15 +53,18 @@ func LockOperation(
 	)
 
 	if err != nil {
-		exitError("Write file error:\n> " + err.Error())
+		exitWithError("Write file error", err)
 		return
 	}
 
-	err = stringfs.RemoveFile(sourcePlainFile)
+	shredded, err := removePlainFile(sourcePlainFile, appConfig)
 	if err != nil {
-		exitError("Remove plain source file error:\n> " + err.Error())
+		exitWithError("Remove plain source file error", err)
 		return
 	}
 
-	fmt.Println("Locked!")
+	fields := vaultFields(targetVaultFile, cipherPayload)
+	fields["plainFile"] = sourcePlainFile
+	fields["shredded"] = shredded
+	printResult("Locked!", fields)
 }
@@ -0,0 +1,58 @@
+package subcmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/lib/filelock"
+)
+
+var heldVaultLock *filelock.Lock
+
+// lockVault acquires the advisory lock of a vault file for the rest of the operation,
+// so concurrent vault processes can not interleave their read-modify-write cycles.
+// The lock is released by unlockVault or on exitError.
+func lockVault(
+	vaultFile string,
+	appConfig *config.AppConfig,
+) {
+	timeout := time.Duration(appConfig.LockTimeout) * time.Second
+
+	lock, err := filelock.Acquire(vaultFile, false, 0)
+	if errors.Is(err, filelock.ErrLocked) && !appConfig.NoWait {
+		fmt.Fprintln(
+			os.Stderr,
+			"Vault file '"+vaultFile+"' is locked by another process, wait up to "+
+				strconv.Itoa(appConfig.LockTimeout)+" seconds...",
+		)
+		lock, err = filelock.Acquire(vaultFile, true, timeout)
+	}
+
+	if errors.Is(err, filelock.ErrLocked) {
+		exitErrorCode(exitcode.Locked, "Vault file '"+vaultFile+"' is locked by another process!")
+		return
+	} else if err != nil {
+		exitWithError("Lock vault file error", err)
+		return
+	}
+
+	heldVaultLock = lock
+}
+
+func unlockVault() {
+	if heldVaultLock == nil {
+		return
+	}
+
+	err := heldVaultLock.Unlock()
+	heldVaultLock = nil
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Unlock vault file error:\n> "+err.Error())
+	}
+}
@@ -0,0 +1,128 @@
+package subcmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/pkg/vault"
+)
+
+// LogOperation lists the revisions of a vault file, the newest first.
+func LogOperation(
+	targetFile string,
+	appConfig *config.AppConfig,
+) {
+	sourceVaultFile := targetFile + "." + appConfig.VaultFileExtension
+
+	if _, err := os.Stat(sourceVaultFile); errors.Is(err, os.ErrNotExist) {
+		exitErrorCode(exitcode.NotFound, "Source vault file '"+sourceVaultFile+"' does not exist!")
+		return
+	}
+
+	rawPayload, info, err := readVaultFile(sourceVaultFile)
+	if err != nil {
+		exitWithError("", err)
+		return
+	}
+
+	entries, err := vault.History(rawPayload, openOptions(appConfig))
+	if err != nil {
+		exitWithError("Decrypt error", err)
+		return
+	}
+
+	if outputJSON {
+		revisions := []map[string]any{}
+		for index := len(entries) - 1; index >= 0; index-- {
+			entry := entries[index]
+			revision := map[string]any{
+				"number":        entry.Number,
+				"user":          entry.User,
+				"authenticated": entry.Authenticated,
+				"changed":       entry.Changed,
+				"current":       index == len(entries)-1,
+			}
+			if !entry.Time.IsZero() {
+				revision["time"] = entry.Time
+			}
+			if entry.Err != nil {
+				revision["error"] = entry.Err.Error()
+			} else {
+				revision["sha256"] = entry.Hash
+			}
+			revisions = append(revisions, revision)
+		}
+
+		printResult("", map[string]any{
+			"vaultFile": sourceVaultFile,
+			"revisions": revisions,
+		})
+		return
+	}
+
+	fmt.Printf("%-5s %-20s %-16s %s\n", "REV", "TIME", "USER", "SHA256")
+
+	failed := 0
+	for index := len(entries) - 1; index >= 0; index-- {
+		entry := entries[index]
+
+		hash := "-"
+		if entry.Err == nil {
+			hash = entry.Hash[:16]
+		} else {
+			failed++
+		}
+
+		fmt.Printf(
+			"%-5s %-20s %-16s %-16s%s%s\n",
+			strconv.Itoa(entry.Number),
+			revisionTime(entry.Time),
+			revisionUser(entry.User),
+			hash,
+			changedMark(entry),
+			currentMark(info, index),
+		)
+	}
+
+	if failed != 0 {
+		fmt.Fprintln(os.Stderr, strconv.Itoa(failed)+" revisions could not be opened, they may use another password or keys.")
+	}
+}
+
+func revisionTime(revisionTime time.Time) string {
+	if revisionTime.IsZero() {
+		return "-"
+	}
+
+	return revisionTime.Local().Format("2006-01-02 15:04:05")
+}
+
+func revisionUser(user string) string {
+	if len(user) == 0 {
+		return "-"
+	}
+
+	return user
+}
+
+// changedMark marks revisions whose clear text time or user was changed after sealing.
+func changedMark(entry vault.HistoryEntry) string {
+	if entry.Changed {
+		return " (time or user changed)"
+	}
+
+	return ""
+}
+
+func currentMark(info *vault.Info, index int) string {
+	if index == len(info.Revisions)-1 {
+		return " (current)"
+	}
+
+	return ""
+}
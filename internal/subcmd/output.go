@@ -0,0 +1,213 @@
+package subcmd
+
+import (
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/lib/cryption"
+	"github.com/NobleMajo/vault/lib/filelock"
+	"github.com/NobleMajo/vault/lib/pkcs11"
+	"github.com/NobleMajo/vault/lib/userin"
+	"github.com/NobleMajo/vault/pkg/vault"
+)
+
+// startTime is used for the durationMs of json results.
+var startTime = time.Now()
+
+// outputJSON prints results and errors as json, see ConfigureOutput.
+var outputJSON bool
+
+// subCommand names the command in json results and errors.
+var subCommand string
+
+// ConfigureOutput selects text or json output for all operations.
+func ConfigureOutput(appConfig *config.AppConfig) {
+	outputJSON = appConfig.JSONOutput
+	subCommand = appConfig.SubCommand
+}
+
+// printResult prints the message, or with json output an object with
+// "ok", "command", "message", the fields and "durationMs" on stdout.
+func printResult(message string, fields map[string]any) {
+	if !outputJSON {
+		if len(message) != 0 {
+			fmt.Println(message)
+		}
+		return
+	}
+
+	result := map[string]any{
+		"ok":         true,
+		"command":    subCommand,
+		"durationMs": time.Since(startTime).Milliseconds(),
+	}
+	if len(message) != 0 {
+		result["message"] = message
+	}
+	for key, value := range fields {
+		result[key] = value
+	}
+
+	printJSON(result)
+}
+
+// printText prints informational lines that json output leaves out.
+func printText(message string) {
+	if !outputJSON {
+		fmt.Println(message)
+	}
+}
+
+// vaultFields describes a written vault file for json results.
+func vaultFields(vaultFile string, raw []byte) map[string]any {
+	fields := map[string]any{
+		"vaultFile": vaultFile,
+		"size":      len(raw),
+	}
+
+	info, err := vault.Inspect(raw)
+	if err != nil {
+		return fields
+	}
+
+	recipients := []string{}
+	for _, slot := range info.Slots {
+		if slot.Encryption != nil {
+			recipients = append(recipients, slot.Encryption.Recipients...)
+		}
+	}
+	fields["revisions"] = len(info.Revisions)
+	fields["keySlots"] = len(info.Slots)
+	fields["recipients"] = recipients
+
+	return fields
+}
+
+// exitCodeOf classifies an error for the exit code.
+func exitCodeOf(err error) int {
+	var keyErr *keyError
+	var keyFileErr *cryption.KeyError
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return exitcode.NotFound
+	case errors.Is(err, vault.ErrCorrupted), errors.Is(err, cryption.ErrTruncated):
+		return exitcode.Corrupted
+	case errors.As(err, &keyErr), errors.As(err, &keyFileErr), errors.Is(err, cryption.ErrUnsupportedKey),
+		errors.Is(err, pkcs11.ErrNoModule), errors.Is(err, pkcs11.ErrNoToken), errors.Is(err, pkcs11.ErrNoKey):
+		return exitcode.BadKey
+	case errors.Is(err, cryption.ErrAuthFailed), errors.Is(err, cryption.ErrWrongRecipient),
+		errors.Is(err, vault.ErrKeyMismatch), errors.Is(err, vault.ErrWrongShares),
+		errors.Is(err, pkcs11.ErrPINIncorrect), errors.Is(err, rsa.ErrDecryption):
+		return exitcode.AuthFailed
+	case errors.Is(err, filelock.ErrLocked):
+		return exitcode.Locked
+	}
+
+	return exitcode.Error
+}
+
+// errorHint returns what the user can do about the error or an empty string.
+func errorHint(err error) string {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return "Check the path, relative paths are relative to the working directory."
+	case errors.Is(err, vault.ErrCorrupted), errors.Is(err, cryption.ErrTruncated):
+		return "The vault file is damaged, check it with 'vault verify' or use 'vault restore-backup'."
+	case errors.Is(err, cryption.ErrUnsupportedKey):
+		return "Keys have to be openssh or pem encoded rsa keys, check --private-key and --public-key."
+	case errors.Is(err, pkcs11.ErrNoModule):
+		return "Install the PKCS#11 module of the token or set it with --pkcs11-module or module-path in the uri."
+	case errors.Is(err, pkcs11.ErrNoToken), errors.Is(err, pkcs11.ErrNoKey):
+		return "Check that the token is plugged in and the pkcs11: uri matches it."
+	case errors.Is(err, pkcs11.ErrPINIncorrect):
+		return "The PIN is wrong, tokens lock after a few wrong PINs."
+	case errors.Is(err, cryption.ErrWrongRecipient):
+		return "The vault file is encrypted for another rsa key, 'vault inspect' shows its recipients."
+	case errors.Is(err, vault.ErrKeyMismatch):
+		return "The vault file needs other keys, 'vault inspect' shows what its key slots need."
+	case errors.Is(err, vault.ErrWrongShares):
+		return "The recovery shares do not match the recovery key of the vault file."
+	case errors.Is(err, cryption.ErrAuthFailed):
+		return "The password or keyfile is wrong, or the vault file was modified."
+	case errors.Is(err, userin.ErrNoTerminal):
+		return "Run vault in a terminal or use rsa-only vault files (--no-aes) for a prompt-free workflow."
+	case errors.Is(err, filelock.ErrLocked):
+		return "Another vault process uses the vault file, wait for it or raise --lock-timeout."
+	}
+
+	return ""
+}
+
+// keyError marks errors of loading the configured keys.
+type keyError struct {
+	err error
+}
+
+func (err *keyError) Error() string {
+	return err.err.Error()
+}
+
+func (err *keyError) Unwrap() error {
+	return err.err
+}
+
+// exitWithError exits with the message, the exit code and the hint of the error.
+func exitWithError(message string, err error) {
+	if len(message) != 0 {
+		message += ":\n> "
+	}
+
+	exitErrorHint(exitCodeOf(err), message+err.Error(), errorHint(err))
+}
+
+func exitError(message string) {
+	exitErrorCode(exitcode.Error, message)
+}
+
+// exitErrorCode prints the message to stderr, as json object with json output,
+// and exits with the code.
+func exitErrorCode(code int, message string) {
+	exitErrorHint(code, message, "")
+}
+
+func exitErrorHint(code int, message string, hint string) {
+	unlockVault()
+	WipeSecrets()
+
+	if outputJSON {
+		details := map[string]any{
+			"code":    code,
+			"kind":    exitcode.Name(code),
+			"message": message,
+		}
+		if len(hint) != 0 {
+			details["hint"] = hint
+		}
+
+		encoder := json.NewEncoder(os.Stderr)
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(map[string]any{
+			"ok":      false,
+			"command": subCommand,
+			"error":   details,
+		})
+		os.Exit(code)
+	}
+
+	if len(hint) != 0 {
+		message += "\nHint: " + hint
+	}
+
+	fmt.Fprintln(
+		os.Stderr,
+		message,
+	)
+	os.Exit(code)
+}
@@ -4,70 +4,253 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/lib/secret"
 	"github.com/NobleMajo/vault/lib/stringfs"
+	"github.com/NobleMajo/vault/pkg/vault"
 )
 
+// PasswdOperation changes the password and key of the key slot that the old password unlocks.
 func PasswdOperation(
 	targetFile string,
 	appConfig *config.AppConfig,
 ) {
 	sourceVaultFile := targetFile + "." + appConfig.VaultFileExtension
 
+	lockVault(sourceVaultFile, appConfig)
+	defer unlockVault()
+
 	if _, err := os.Stat(sourceVaultFile); errors.Is(err, os.ErrNotExist) {
-		exitError("Source vault file '" + sourceVaultFile + "' does not exist!")
+		exitErrorCode(exitcode.NotFound, "Source vault file '"+sourceVaultFile+"' does not exist!")
 		return
 	}
 
-	vaultRaw, err := stringfs.ReadFile(sourceVaultFile)
+	vaultRaw, err := os.ReadFile(sourceVaultFile)
 
 	if err != nil {
-		exitError("Error while read vault source from '" + sourceVaultFile + "':\n> " + err.Error())
+		exitWithError("Error while read vault source from '"+sourceVaultFile+"'", err)
 		return
 	}
 
-	loadDecryptionData(appConfig)
+	newOptions, err := sealOptions("", false, appConfig)
+	if err != nil {
+		exitWithError("", err)
+		return
+	}
 
-	plainText, err := VaultDecrypt(
-		[]byte(vaultRaw),
-		!appConfig.DisableRSA,
-		lastUsedPrivateKey,
-		!appConfig.DisableAES256,
-		[]byte(lastUsedPassword),
-	)
+	var oldPassword *secret.Buffer
+	promptNewPassword := newOptions.Password
+	if promptNewPassword != nil {
+		newOptions.Password = func() ([]byte, error) {
+			// the old password is cached, ask for the new one,
+			// the old one can still be in use until the rewrap returns
+			if oldPassword == nil {
+				oldPassword = detachPassword()
+			}
+			return promptNewPassword()
+		}
+	}
+
+	if appConfig.ReplaceKeyfiles {
+		newOptions.Keyfiles = keyfiles(appConfig.NewKeyfiles)
+	}
+
+	// only the key slot of the old password changes, the revisions stay as they are
+	cipherPayload, err := vault.Rewrap(vaultRaw, vault.RewrapOptions{
+		From: openOptions(appConfig),
+		To:   newOptions,
+	})
+	oldPassword.Destroy()
 
 	if err != nil {
-		exitError("Vault decrypt error:\n> " + err.Error())
+		exitWithError("Vault rewrap error", err)
 		return
 	}
 
-	lastUsedPassword = ""
-	loadEncryptionData(appConfig)
+	backupVaultFile(sourceVaultFile, appConfig)
 
-	cipherPayload, err := VaultEncrypt(
-		[]byte(plainText),
-		!appConfig.DisableRSA,
-		lastUsedPublicKey,
-		!appConfig.DisableAES256,
-		[]byte(lastUsedPassword),
+	err = stringfs.SafeWriteFileBytes(
+		sourceVaultFile,
+		cipherPayload,
+		0640,
 	)
 
 	if err != nil {
-		exitError("Vault encrypt error:\n> " + err.Error())
+		exitWithError("Write file error", err)
 		return
 	}
 
-	err = stringfs.SafeWriteFileBytes(
+	printResult("Password changed!", vaultFields(sourceVaultFile, cipherPayload))
+}
+
+// PasswdAddOperation adds a named key slot with a new password.
+func PasswdAddOperation(
+	targetFile string,
+	appConfig *config.AppConfig,
+) {
+	sourceVaultFile := targetFile + "." + appConfig.VaultFileExtension
+
+	lockVault(sourceVaultFile, appConfig)
+	defer unlockVault()
+
+	vaultRaw := readKeySlotVault(sourceVaultFile)
+
+	newOptions, err := sealOptions("", false, appConfig)
+	if err != nil {
+		exitWithError("", err)
+		return
+	}
+
+	var oldPassword *secret.Buffer
+	promptNewPassword := newOptions.Password
+	if promptNewPassword != nil {
+		newOptions.Password = func() ([]byte, error) {
+			// the password of an existing key slot is cached, ask for the new one,
+			// the old one can still be in use until the key slot is added
+			if oldPassword == nil {
+				oldPassword = detachPassword()
+			}
+			// stderr, stdout is left for the result
+			fmt.Fprintln(os.Stderr, "Key slot '"+appConfig.SlotName+"':")
+			return promptNewPassword()
+		}
+	}
+
+	if appConfig.ReplaceKeyfiles {
+		newOptions.Keyfiles = keyfiles(appConfig.NewKeyfiles)
+	}
+
+	cipherPayload, err := vault.AddSlot(vaultRaw, appConfig.SlotName, vault.RewrapOptions{
+		From: openOptions(appConfig),
+		To:   newOptions,
+	})
+	oldPassword.Destroy()
+
+	if err != nil {
+		exitWithError("Vault add key slot error", err)
+		return
+	}
+
+	writeKeySlotVault(sourceVaultFile, cipherPayload, appConfig)
+
+	fields := vaultFields(sourceVaultFile, cipherPayload)
+	fields["keySlot"] = appConfig.SlotName
+	printResult("Key slot '"+appConfig.SlotName+"' added!", fields)
+}
+
+// PasswdRemoveOperation removes a key slot, any other key slot has to unlock the vault.
+func PasswdRemoveOperation(
+	targetFile string,
+	appConfig *config.AppConfig,
+) {
+	sourceVaultFile := targetFile + "." + appConfig.VaultFileExtension
+
+	lockVault(sourceVaultFile, appConfig)
+	defer unlockVault()
+
+	vaultRaw := readKeySlotVault(sourceVaultFile)
+
+	cipherPayload, err := vault.RemoveSlot(vaultRaw, appConfig.SlotName, openOptions(appConfig))
+	if err != nil {
+		exitWithError("Vault remove key slot error", err)
+		return
+	}
+
+	writeKeySlotVault(sourceVaultFile, cipherPayload, appConfig)
+
+	fields := vaultFields(sourceVaultFile, cipherPayload)
+	fields["keySlot"] = appConfig.SlotName
+	printResult("Key slot '"+appConfig.SlotName+"' removed!", fields)
+}
+
+// PasswdListOperation prints the key slots of a vault file, no key or password is needed.
+func PasswdListOperation(
+	targetFile string,
+	appConfig *config.AppConfig,
+) {
+	sourceVaultFile := targetFile + "." + appConfig.VaultFileExtension
+
+	vaultRaw := readKeySlotVault(sourceVaultFile)
+
+	info, err := vault.Inspect(vaultRaw)
+	if err != nil {
+		exitWithError("Vault inspect error", err)
+		return
+	}
+
+	if outputJSON {
+		printResult("", map[string]any{
+			"vaultFile": sourceVaultFile,
+			"keySlots":  info.Slots,
+		})
+		return
+	}
+
+	if len(info.Slots) == 0 {
+		fmt.Println("No key slots, the vault file is from an older version. Use 'vault passwd' to add them.")
+		return
+	}
+
+	for _, slot := range info.Slots {
+		fmt.Printf("%-3s %-20s %s\n", strconv.Itoa(slot.Number), slot.Name, slotDescription(slot))
+	}
+}
+
+func slotDescription(slot vault.SlotInfo) string {
+	if slot.Kind == "recovery" {
+		return "recovery key, " + strconv.Itoa(slot.Threshold) + " of " + strconv.Itoa(slot.Shares) + " shares"
+	}
+
+	encryption := slot.Encryption
+	if encryption == nil {
+		return "unknown"
+	}
+
+	parts := []string{}
+	if encryption.RSABits != 0 {
+		parts = append(parts, "rsa "+strconv.Itoa(encryption.RSABits))
+	}
+	if encryption.Password {
+		parts = append(parts, "password "+strconv.Itoa(encryption.KDFIterations)+" iterations")
+	}
+	if encryption.Keyfile {
+		parts = append(parts, "keyfile")
+	}
+	parts = append(parts, encryption.Recipients...)
+
+	return strings.Join(parts, ", ")
+}
+
+func readKeySlotVault(sourceVaultFile string) []byte {
+	if _, err := os.Stat(sourceVaultFile); errors.Is(err, os.ErrNotExist) {
+		exitErrorCode(exitcode.NotFound, "Source vault file '"+sourceVaultFile+"' does not exist!")
+		return nil
+	}
+
+	vaultRaw, err := os.ReadFile(sourceVaultFile)
+	if err != nil {
+		exitWithError("Error while read vault source from '"+sourceVaultFile+"'", err)
+		return nil
+	}
+
+	return vaultRaw
+}
+
+func writeKeySlotVault(sourceVaultFile string, cipherPayload []byte, appConfig *config.AppConfig) {
+	backupVaultFile(sourceVaultFile, appConfig)
+
+	err := stringfs.SafeWriteFileBytes(
 		sourceVaultFile,
 		cipherPayload,
 		0640,
 	)
 
 	if err != nil {
-		exitError("Write file error:\n> " + err.Error())
+		exitWithError("Write file error", err)
 		return
 	}
-
-	fmt.Println("Password changed!")
 }
@@ -1,54 +1,206 @@
 package subcmd
 
 import (
-	"errors"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/lib/envfile"
+	"github.com/NobleMajo/vault/lib/secret"
 	"github.com/NobleMajo/vault/lib/stringfs"
+	"github.com/NobleMajo/vault/lib/userin"
+	"golang.org/x/term"
 )
 
+// printFormats are the values of print --format, without a format binary content is detected.
+var printFormats = []string{"raw", "hex", "base64", "hexdump"}
+
 func PrintOperation(
 	targetFile string,
 	appConfig *config.AppConfig,
 ) {
-	sourceVaultFile := targetFile + "." + appConfig.VaultFileExtension
-
-	if _, err := os.Stat(sourceVaultFile); errors.Is(err, os.ErrNotExist) {
-		exitError("Source vault file '" + sourceVaultFile + "' does not exist!")
+	if len(appConfig.PrintFormat) != 0 && !validPrintFormat(appConfig.PrintFormat) {
+		exitErrorCode(exitcode.Usage, "Invalid print format '"+appConfig.PrintFormat+"', use '"+strings.Join(printFormats, "', '")+"'!")
 		return
 	}
 
-	vaultRaw, err := stringfs.ReadFile(sourceVaultFile)
+	sourceVaultFile, vaultRaw := readVaultSource(targetFile, appConfig)
+
+	plainText, err := openVault(
+		vaultRaw,
+		appConfig,
+	)
 
 	if err != nil {
-		exitError("Error while read vault source from '" + sourceVaultFile + "':\n> " + err.Error())
+		exitWithError("Decrypt error", err)
 		return
 	}
+	defer secret.Wipe(plainText)
 
-	loadDecryptionData(appConfig)
+	content := plainText
+	if len(appConfig.EntryKey) != 0 {
+		value, found := envfile.Lookup(plainText, appConfig.EntryKey)
+		if !found {
+			exitErrorCode(exitcode.NotFound, "Key '"+appConfig.EntryKey+"' does not exist in '"+sourceVaultFile+"'!")
+			return
+		}
+		defer secret.Wipe(value)
+		content = value
+	}
 
-	plainText, err := VaultDecrypt(
-		[]byte(vaultRaw),
-		!appConfig.DisableRSA,
-		lastUsedPrivateKey,
-		!appConfig.DisableAES256,
-		[]byte(lastUsedPassword),
-	)
+	if appConfig.PrintClip {
+		clipContent := formatContent(content, appConfig.PrintFormat, false)
+		defer secret.Wipe(clipContent)
 
-	if err != nil {
-		exitError("Decrypt error:\n> " + err.Error())
+		copyToClipboard(sourceVaultFile, appConfig.EntryKey, clipContent, appConfig)
 		return
 	}
 
-	if appConfig.CleanPrint {
-		fmt.Println(plainText)
-	} else {
-		fmt.Println(
-			"### Vault Content:\n\n" +
-				string(plainText) + "\n\n" +
-				"### Don't forget to clear!",
-		)
+	formatted := formatContent(content, appConfig.PrintFormat, true)
+	defer secret.Wipe(formatted)
+
+	binary := isBinary(content)
+
+	if len(appConfig.PrintOutputFile) != 0 {
+		err = stringfs.SafeWriteFileBytes(appConfig.PrintOutputFile, formatted, 0640)
+		if err != nil {
+			exitWithError("Write file error", err)
+			return
+		}
+
+		printResult("Printed '"+sourceVaultFile+"' into '"+appConfig.PrintOutputFile+"'!", map[string]any{
+			"vaultFile":  sourceVaultFile,
+			"outputFile": appConfig.PrintOutputFile,
+			"format":     printFormatName(appConfig.PrintFormat),
+			"binary":     binary,
+			"size":       len(formatted),
+		})
+		return
 	}
+
+	if outputJSON {
+		fields := map[string]any{
+			"vaultFile": sourceVaultFile,
+			"size":      len(content),
+			"binary":    binary,
+		}
+		if len(appConfig.EntryKey) != 0 {
+			fields["key"] = appConfig.EntryKey
+		}
+		switch {
+		case appConfig.PrintFormat == "hex":
+			fields["contentHex"] = string(formatted[:len(formatted)-1])
+		case appConfig.PrintFormat == "hexdump":
+			fields["hexdump"] = string(formatted)
+		case appConfig.PrintFormat == "base64" || !utf8.Valid(content):
+			fields["contentBase64"] = base64.StdEncoding.EncodeToString(content)
+		default:
+			fields["content"] = string(content)
+		}
+		printResult("", fields)
+		return
+	}
+
+	// the content is written as bytes, a string copy could not be wiped
+	switch {
+	case len(appConfig.PrintFormat) != 0:
+		os.Stdout.Write(formatted)
+	case binary:
+		if term.IsTerminal(int(os.Stdout.Fd())) && !confirmBinaryPrint(sourceVaultFile, len(content)) {
+			exitError("Print aborted, use --format hexdump or -o/--out FILE for binary content!")
+			return
+		}
+		os.Stdout.Write(content)
+	case appConfig.CleanPrint:
+		os.Stdout.Write(content)
+		if !bytes.HasSuffix(content, []byte("\n")) {
+			fmt.Println()
+		}
+	default:
+		fmt.Print("### Vault Content:\n\n")
+		os.Stdout.Write(content)
+		fmt.Println("\n\n### Don't forget to clear!")
+	}
+}
+
+func validPrintFormat(format string) bool {
+	for _, name := range printFormats {
+		if format == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func printFormatName(format string) string {
+	if len(format) == 0 {
+		return "raw"
+	}
+
+	return format
+}
+
+// formatContent encodes the content in the print format, raw and the empty format
+// return a copy of the content. Hex and base64 get a trailing newline if wanted.
+func formatContent(content []byte, format string, newline bool) []byte {
+	var formatted []byte
+	switch format {
+	case "hex":
+		formatted = hex.AppendEncode(make([]byte, 0, hex.EncodedLen(len(content))+1), content)
+	case "base64":
+		formatted = base64.StdEncoding.AppendEncode(make([]byte, 0, base64.StdEncoding.EncodedLen(len(content))+1), content)
+	case "hexdump":
+		// the canonical hex+ascii format of hexdump -C
+		var dump bytes.Buffer
+		dump.Grow((len(content)/16 + 1) * 80)
+		dumper := hex.Dumper(&dump)
+		dumper.Write(content)
+		dumper.Close()
+		return dump.Bytes()
+	default:
+		return bytes.Clone(content)
+	}
+
+	if newline {
+		formatted = append(formatted, '\n')
+	}
+	return formatted
+}
+
+// isBinary reports content that is not utf-8 text or has control characters
+// other than whitespace, which would mess up a terminal.
+func isBinary(content []byte) bool {
+	if !utf8.Valid(content) {
+		return true
+	}
+
+	for _, char := range content {
+		if char < ' ' && char != '\t' && char != '\n' && char != '\r' && char != '\f' && char != '\v' || char == 0x7f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// confirmBinaryPrint warns before binary content is written to the terminal.
+func confirmBinaryPrint(sourceVaultFile string, size int) bool {
+	fmt.Fprintln(os.Stderr, "Warning: '"+sourceVaultFile+"' holds "+strconv.Itoa(size)+" bytes of binary content, printing it can mess up your terminal.")
+	fmt.Fprintln(os.Stderr, "Print it anyway? (y/N)")
+
+	answer, err := userin.ReadLine()
+	if err != nil {
+		return false
+	}
+
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
 }
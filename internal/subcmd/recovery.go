@@ -0,0 +1,185 @@
+package subcmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/lib/secret"
+	"github.com/NobleMajo/vault/lib/stringfs"
+	"github.com/NobleMajo/vault/lib/userin"
+	"github.com/NobleMajo/vault/pkg/vault"
+)
+
+// SplitOperation stores a new recovery key in the vault file and prints its shares.
+func SplitOperation(
+	targetFile string,
+	appConfig *config.AppConfig,
+) {
+	sourceVaultFile := targetFile + "." + appConfig.VaultFileExtension
+
+	lockVault(sourceVaultFile, appConfig)
+	defer unlockVault()
+
+	if _, err := os.Stat(sourceVaultFile); errors.Is(err, os.ErrNotExist) {
+		exitErrorCode(exitcode.NotFound, "Source vault file '"+sourceVaultFile+"' does not exist!")
+		return
+	}
+
+	vaultRaw, err := os.ReadFile(sourceVaultFile)
+	if err != nil {
+		exitWithError("Error while read vault source from '"+sourceVaultFile+"'", err)
+		return
+	}
+
+	cipherPayload, shares, err := vault.Split(vaultRaw, vault.SplitOptions{
+		Open:      openOptions(appConfig),
+		Shares:    appConfig.Shares,
+		Threshold: appConfig.Threshold,
+	})
+
+	if err != nil {
+		exitWithError("Vault split error", err)
+		return
+	}
+
+	backupVaultFile(sourceVaultFile, appConfig)
+
+	err = stringfs.SafeWriteFileBytes(
+		sourceVaultFile,
+		cipherPayload,
+		0640,
+	)
+
+	if err != nil {
+		exitWithError("Write file error", err)
+		return
+	}
+
+	if outputJSON {
+		lines := make([]string, len(shares))
+		for index, share := range shares {
+			lines[index] = share.String()
+		}
+
+		fields := vaultFields(sourceVaultFile, cipherPayload)
+		fields["threshold"] = appConfig.Threshold
+		fields["shares"] = lines
+		printResult("", fields)
+		return
+	}
+
+	fmt.Println(
+		"Recovery key split into " + strconv.Itoa(len(shares)) + " shares, any " +
+			strconv.Itoa(appConfig.Threshold) + " of them recover the vault with 'vault recover-key':",
+	)
+	fmt.Println()
+	for _, share := range shares {
+		fmt.Println(share.String())
+	}
+	fmt.Println()
+	fmt.Println("Store every share at another place. A new split replaces these shares.")
+}
+
+// RecoverKeyOperation combines recovery shares to unlock the data key
+// and sets the configured key and a new password for the vault file.
+func RecoverKeyOperation(
+	targetFile string,
+	appConfig *config.AppConfig,
+) {
+	sourceVaultFile := targetFile + "." + appConfig.VaultFileExtension
+
+	lockVault(sourceVaultFile, appConfig)
+	defer unlockVault()
+
+	if _, err := os.Stat(sourceVaultFile); errors.Is(err, os.ErrNotExist) {
+		exitErrorCode(exitcode.NotFound, "Source vault file '"+sourceVaultFile+"' does not exist!")
+		return
+	}
+
+	vaultRaw, err := os.ReadFile(sourceVaultFile)
+	if err != nil {
+		exitWithError("Error while read vault source from '"+sourceVaultFile+"'", err)
+		return
+	}
+
+	recoveryKey, err := readRecoveryKey()
+	if err != nil {
+		exitWithError("Read recovery shares error", err)
+		return
+	}
+	defer secret.Wipe(recoveryKey.Key)
+
+	newOptions, err := sealOptions("", false, appConfig)
+	if err != nil {
+		exitWithError("", err)
+		return
+	}
+
+	cipherPayload, err := vault.Rewrap(vaultRaw, vault.RewrapOptions{
+		From: vault.OpenOptions{Recovery: recoveryKey},
+		To:   newOptions,
+	})
+
+	if err != nil {
+		exitWithError("Vault recover error", err)
+		return
+	}
+
+	backupVaultFile(sourceVaultFile, appConfig)
+
+	err = stringfs.SafeWriteFileBytes(
+		sourceVaultFile,
+		cipherPayload,
+		0640,
+	)
+
+	if err != nil {
+		exitWithError("Write file error", err)
+		return
+	}
+
+	printResult("Recovered! The vault file uses the new password and key now.", vaultFields(sourceVaultFile, cipherPayload))
+}
+
+// readRecoveryKey reads shares line by line until the threshold is reached.
+// Mistyped shares are reported and can be entered again.
+func readRecoveryKey() (*vault.RecoveryKey, error) {
+	fmt.Fprintln(os.Stderr, "Enter the recovery shares, one per line:")
+
+	shares := []vault.RecoveryShare{}
+	for len(shares) == 0 || len(shares) < shares[0].Threshold {
+		line, err := userin.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(strings.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		share, err := vault.ParseShare(line)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid share:\n> "+err.Error())
+			continue
+		}
+
+		duplicate := false
+		for _, other := range shares {
+			duplicate = duplicate || other.Number == share.Number
+		}
+
+		if duplicate {
+			fmt.Fprintln(os.Stderr, "Share "+strconv.Itoa(share.Number)+" was already entered.")
+			continue
+		}
+
+		shares = append(shares, share)
+	}
+
+	return vault.CombineShares(shares)
+}
@@ -0,0 +1,101 @@
+package subcmd
+
+import (
+	"errors"
+	"os"
+	"strconv"
+
+	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/lib/secret"
+	"github.com/NobleMajo/vault/lib/stringfs"
+	"github.com/NobleMajo/vault/pkg/vault"
+)
+
+// RevertOperation restores the content of an older revision.
+// The restored content is appended as new current revision,
+// so the revert itself is part of the history.
+func RevertOperation(
+	targetFile string,
+	revisionArg string,
+	appConfig *config.AppConfig,
+) {
+	sourceVaultFile := targetFile + "." + appConfig.VaultFileExtension
+
+	lockVault(sourceVaultFile, appConfig)
+	defer unlockVault()
+
+	if _, err := os.Stat(sourceVaultFile); errors.Is(err, os.ErrNotExist) {
+		exitErrorCode(exitcode.NotFound, "Source vault file '"+sourceVaultFile+"' does not exist!")
+		return
+	}
+
+	rawPayload, err := os.ReadFile(sourceVaultFile)
+	if err != nil {
+		exitWithError("Error while read vault source from '"+sourceVaultFile+"'", err)
+		return
+	}
+
+	options := openOptions(appConfig)
+
+	entries, err := vault.History(rawPayload, options)
+	if err != nil {
+		exitWithError("Decrypt error", err)
+		return
+	}
+
+	// revisions that can not be opened have no hash and are only found by number
+	hashes := make([]string, len(entries))
+	for index, entry := range entries {
+		hashes[index] = entry.Hash
+	}
+
+	index, err := findRevision(hashes, revisionArg)
+	if err != nil {
+		exitWithError("Find revision error", err)
+		return
+	}
+
+	if index == len(hashes)-1 {
+		exitError("Revision " + strconv.Itoa(index+1) + " is already the current revision!")
+		return
+	}
+
+	options.Revision = index + 1
+
+	plainText, err := vault.Open(rawPayload, options)
+	if err != nil {
+		exitWithError("Vault decrypt error", err)
+		return
+	}
+	defer secret.Wipe(plainText)
+
+	cipherPayload, err := sealVault(
+		plainText,
+		sourceVaultFile,
+		true,
+		appConfig,
+	)
+
+	if err != nil {
+		exitWithError("Vault encrypt error", err)
+		return
+	}
+
+	backupVaultFile(sourceVaultFile, appConfig)
+
+	err = stringfs.SafeWriteFileBytes(
+		sourceVaultFile,
+		cipherPayload,
+		0640,
+	)
+
+	if err != nil {
+		exitWithError("Write file error", err)
+		return
+	}
+
+	fields := vaultFields(sourceVaultFile, cipherPayload)
+	fields["revertedTo"] = index + 1
+	printResult("Reverted to revision "+strconv.Itoa(index+1)+"!", fields)
+}
@@ -0,0 +1,84 @@
+package subcmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/lib/stringfs"
+)
+
+// ShredOperation overwrites and removes the given file.
+func ShredOperation(
+	sourceFile string,
+	appConfig *config.AppConfig,
+) {
+	if _, err := os.Stat(sourceFile); errors.Is(err, os.ErrNotExist) {
+		exitErrorCode(exitcode.NotFound, "File '"+sourceFile+"' does not exist!")
+		return
+	}
+
+	warnCopyOnWrite(sourceFile)
+
+	shredded, err := shredFile(sourceFile, appConfig)
+	if err != nil {
+		exitWithError("Shred file error", err)
+		return
+	}
+
+	message := "Shredded!"
+	if !shredded {
+		message = "Removed, not overwritten!"
+	}
+
+	printResult(message, map[string]any{
+		"file":     sourceFile,
+		"passes":   appConfig.ShredPasses,
+		"shredded": shredded,
+	})
+}
+
+// removePlainFile removes a plain file after its content got locked.
+// By default the file gets shredded, so the secret does not stay on disk.
+// It reports whether the file was overwritten.
+func removePlainFile(
+	plainFile string,
+	appConfig *config.AppConfig,
+) (bool, error) {
+	if appConfig.DisableShred {
+		return false, stringfs.RemoveFile(plainFile)
+	}
+
+	warnCopyOnWrite(plainFile)
+
+	return shredFile(plainFile, appConfig)
+}
+
+// shredFile shreds the file and warns if it was only unlinked.
+func shredFile(path string, appConfig *config.AppConfig) (bool, error) {
+	shredded, err := stringfs.ShredFile(path, appConfig.ShredPasses)
+	if err == nil && !shredded {
+		fmt.Fprintln(
+			os.Stderr,
+			"Warning: '"+path+"' has other hard links, it was only removed and not overwritten, "+
+				"the content stays readable through the other links!",
+		)
+	}
+
+	return shredded, err
+}
+
+func warnCopyOnWrite(path string) {
+	fileSystem := stringfs.CopyOnWriteFileSystem(path)
+	if len(fileSystem) == 0 {
+		return
+	}
+
+	fmt.Fprintln(
+		os.Stderr,
+		"Warning: '"+path+"' is on a copy-on-write file system ("+fileSystem+"), "+
+			"overwriting does not reliably destroy the old data!",
+	)
+}
@@ -2,12 +2,13 @@ package subcmd
 
 import (
 	"errors"
-	"fmt"
 	"os"
 	"strconv"
 	"time"
 
 	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/lib/secret"
 	"github.com/NobleMajo/vault/lib/stringfs"
 )
 
@@ -18,36 +19,34 @@ func TempOperation(
 	sourceVaultFile := targetFile + "." + appConfig.VaultFileExtension
 	targetPlainFile := targetFile + "." + appConfig.PlainFileExtension
 
-	fmt.Println(
+	lockVault(sourceVaultFile, appConfig)
+	defer unlockVault()
+
+	printText(
 		"Temporary unlock vault for " +
 			strconv.Itoa(appConfig.TempDecodeSeconds) +
 			" seconds...",
 	)
 
 	if _, err := os.Stat(sourceVaultFile); errors.Is(err, os.ErrNotExist) {
-		exitError("Source vault file '" + sourceVaultFile + "' does not exist!")
+		exitErrorCode(exitcode.NotFound, "Source vault file '"+sourceVaultFile+"' does not exist!")
 		return
 	}
 
 	vaultRaw, err := stringfs.ReadFile(sourceVaultFile)
 
 	if err != nil {
-		exitError("Error while read vault source from '" + sourceVaultFile + "':\n> " + err.Error())
+		exitWithError("Error while read vault source from '"+sourceVaultFile+"'", err)
 		return
 	}
 
-	loadDecryptionData(appConfig)
-
-	decryptedPlainText, err := VaultDecrypt(
+	decryptedPlainText, err := openVault(
 		[]byte(vaultRaw),
-		!appConfig.DisableRSA,
-		lastUsedPrivateKey,
-		!appConfig.DisableAES256,
-		[]byte(lastUsedPassword),
+		appConfig,
 	)
 
 	if err != nil {
-		exitError("Vault decrypt error:\n> " + err.Error())
+		exitWithError("Vault decrypt error", err)
 		return
 	}
 
@@ -56,42 +55,43 @@ func TempOperation(
 		decryptedPlainText,
 		0640,
 	)
+	secret.Wipe(decryptedPlainText)
 
 	if err != nil {
-		exitError("Write file error:\n> " + err.Error())
+		exitWithError("Write file error", err)
 		return
 	}
 
-	fmt.Println("Unlocked! Wait for " + strconv.Itoa(appConfig.TempDecodeSeconds) + " seconds...")
+	printText("Unlocked! Wait for " + strconv.Itoa(appConfig.TempDecodeSeconds) + " seconds...")
 	time.Sleep(time.Duration(appConfig.TempDecodeSeconds) * time.Second)
-	fmt.Println("Lock vault now again...")
+	printText("Lock vault now again...")
 
 	if _, err := os.Stat(targetPlainFile); errors.Is(err, os.ErrNotExist) {
-		exitError("Plain source file '" + targetPlainFile + "' does not exist!")
+		exitErrorCode(exitcode.NotFound, "Plain source file '"+targetPlainFile+"' does not exist!")
 		return
 	}
 
-	plainText, err := stringfs.ReadFile(targetPlainFile)
+	plainText, err := os.ReadFile(targetPlainFile)
 	if err != nil {
-		exitError("Read plain source error:\n> " + err.Error())
+		exitWithError("Read plain source error", err)
 		return
 	}
+	defer secret.Wipe(plainText)
 
-	loadEncryptionData(appConfig)
-
-	cipherPayload, err := VaultEncrypt(
-		[]byte(plainText),
-		!appConfig.DisableRSA,
-		lastUsedPublicKey,
-		!appConfig.DisableAES256,
-		[]byte(lastUsedPassword),
+	cipherPayload, err := sealVault(
+		plainText,
+		sourceVaultFile,
+		historyEnabled(appConfig),
+		appConfig,
 	)
 
 	if err != nil {
-		exitError("Vault encrypt error:\n> " + err.Error())
+		exitWithError("Vault encrypt error", err)
 		return
 	}
 
+	backupVaultFile(sourceVaultFile, appConfig)
+
 	err = stringfs.SafeWriteFileBytes(
 		sourceVaultFile,
 		cipherPayload,
@@ -99,15 +99,19 @@ func TempOperation(
 	)
 
 	if err != nil {
-		exitError("Write file error:\n> " + err.Error())
+		exitWithError("Write file error", err)
 		return
 	}
 
-	err = stringfs.RemoveFile(targetPlainFile)
+	shredded, err := removePlainFile(targetPlainFile, appConfig)
 	if err != nil {
-		exitError("Remove plain source file error:\n> " + err.Error())
+		exitWithError("Remove plain source file error", err)
 		return
 	}
 
-	fmt.Println("Locked again!")
+	fields := vaultFields(sourceVaultFile, cipherPayload)
+	fields["plainFile"] = targetPlainFile
+	fields["shredded"] = shredded
+	fields["seconds"] = appConfig.TempDecodeSeconds
+	printResult("Locked again!", fields)
 }
@@ -0,0 +1,210 @@
+package subcmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/lib/envfile"
+	"github.com/NobleMajo/vault/lib/otp"
+	"github.com/NobleMajo/vault/lib/secret"
+	"github.com/NobleMajo/vault/lib/userin"
+)
+
+// TotpOperation prints the current code of an otpauth:// URI or base32 seed
+// that is stored as KEY=value entry in the vault file.
+// The code goes to stdout, the remaining validity to stderr.
+// HOTP entries get their counter increased, so every code is used once.
+// With --import the URI or seed is stored instead.
+func TotpOperation(
+	targetFile string,
+	key string,
+	appConfig *config.AppConfig,
+) {
+	sourceVaultFile := targetFile + "." + appConfig.VaultFileExtension
+
+	if !envfile.ValidKey(key) {
+		exitErrorCode(exitcode.Usage, "Key '"+key+"' is invalid, use letters, digits and _ and do not start with a digit!")
+		return
+	}
+
+	if len(appConfig.OTPImport) != 0 {
+		importOTP(sourceVaultFile, key, appConfig)
+		return
+	}
+
+	lockVault(sourceVaultFile, appConfig)
+	defer unlockVault()
+
+	if _, err := os.Stat(sourceVaultFile); errors.Is(err, os.ErrNotExist) {
+		exitErrorCode(exitcode.NotFound, "Source vault file '"+sourceVaultFile+"' does not exist!")
+		return
+	}
+
+	plainText := readVaultContent(sourceVaultFile, appConfig)
+	defer secret.Wipe(plainText)
+
+	value, found := envfile.Lookup(plainText, key)
+	if !found {
+		exitErrorCode(exitcode.NotFound, "Key '"+key+"' does not exist in '"+sourceVaultFile+"'!")
+		return
+	}
+
+	otpKey, err := otp.Parse(string(value))
+	secret.Wipe(value)
+	if err != nil {
+		exitWithError("Read otp key '"+key+"' error", err)
+		return
+	}
+	defer otpKey.Wipe()
+
+	err = overrideOTP(otpKey, appConfig)
+	if err != nil {
+		exitErrorCode(exitcode.Usage, "Invalid otp parameters:\n> "+err.Error())
+		return
+	}
+
+	fields := map[string]any{
+		"vaultFile": sourceVaultFile,
+		"key":       key,
+		"type":      otpKey.Type,
+		"issuer":    otpKey.Issuer,
+		"account":   otpKey.Account,
+		"algorithm": otpKey.Algorithm,
+		"digits":    otpKey.Digits,
+	}
+
+	var code string
+	if otpKey.Type == otp.TypeHOTP {
+		code, err = otp.HOTP(otpKey.Secret, otpKey.Counter, otpKey.Digits, otpKey.Algorithm)
+		if err != nil {
+			exitWithError("Generate code error", err)
+			return
+		}
+		fields["counter"] = otpKey.Counter
+
+		// the next call has to use the next counter
+		otpKey.Counter++
+		newPlainText, _, err := envfile.Set(plainText, key, []byte(otpKey.URI()))
+		if err != nil {
+			exitWithError("Set entry error", err)
+			return
+		}
+		defer secret.Wipe(newPlainText)
+
+		writeVaultContent(sourceVaultFile, newPlainText, appConfig)
+	} else {
+		var remaining time.Duration
+		code, remaining, err = otp.TOTP(otpKey, time.Now())
+		if err != nil {
+			exitWithError("Generate code error", err)
+			return
+		}
+		fields["period"] = otpKey.Period
+		fields["remainingSeconds"] = int(remaining.Round(time.Second) / time.Second)
+	}
+
+	if outputJSON {
+		fields["code"] = code
+		printResult("", fields)
+		return
+	}
+
+	fmt.Println(code)
+	if otpKey.Type == otp.TypeHOTP {
+		fmt.Fprintln(os.Stderr, "Counter "+strconv.FormatUint(otpKey.Counter-1, 10)+", the next code uses "+strconv.FormatUint(otpKey.Counter, 10))
+	} else {
+		fmt.Fprintln(os.Stderr, "Valid for "+strconv.Itoa(fields["remainingSeconds"].(int))+" of "+strconv.Itoa(otpKey.Period)+" seconds")
+	}
+}
+
+// overrideOTP applies the --algorithm, --digits and --period flags.
+func overrideOTP(otpKey *otp.Key, appConfig *config.AppConfig) error {
+	if len(appConfig.OTPAlgorithm) != 0 {
+		otpKey.Algorithm = strings.ToUpper(appConfig.OTPAlgorithm)
+	}
+	if appConfig.OTPDigits != 0 {
+		otpKey.Digits = appConfig.OTPDigits
+	}
+	if appConfig.OTPPeriod != 0 {
+		otpKey.Period = appConfig.OTPPeriod
+	}
+
+	return otpKey.Validate()
+}
+
+// importOTP stores an otpauth:// URI or base32 seed as entry.
+// Seeds with parameter flags are stored as URI, so the parameters are kept.
+func importOTP(
+	targetVaultFile string,
+	key string,
+	appConfig *config.AppConfig,
+) {
+	text := appConfig.OTPImport
+	if text == "-" {
+		line, err := userin.ReadLine()
+		if err != nil {
+			exitWithError("Read otp key from stdin error", err)
+			return
+		}
+		text = line
+	}
+	text = strings.TrimSpace(text)
+
+	otpKey, err := otp.Parse(text)
+	if err != nil {
+		exitErrorCode(exitcode.Usage, "Invalid otp key:\n> "+err.Error())
+		return
+	}
+	defer otpKey.Wipe()
+
+	err = overrideOTP(otpKey, appConfig)
+	if err != nil {
+		exitErrorCode(exitcode.Usage, "Invalid otp parameters:\n> "+err.Error())
+		return
+	}
+
+	overridden := len(appConfig.OTPAlgorithm) != 0 || appConfig.OTPDigits != 0 || appConfig.OTPPeriod != 0
+	if overridden || otpKey.Type == otp.TypeHOTP {
+		if len(otpKey.Account) == 0 {
+			otpKey.Account = key
+		}
+		text = otpKey.URI()
+	}
+
+	lockVault(targetVaultFile, appConfig)
+	defer unlockVault()
+
+	plainText := readVaultContent(targetVaultFile, appConfig)
+	defer secret.Wipe(plainText)
+
+	if existing, found := envfile.Lookup(plainText, key); found {
+		secret.Wipe(existing)
+		if !appConfig.Force {
+			exitError("Key '" + key + "' already exists in '" + targetVaultFile + "', use --force to replace it!")
+			return
+		}
+	}
+
+	newPlainText, replaced, err := envfile.Set(plainText, key, []byte(text))
+	if err != nil {
+		exitWithError("Set entry error", err)
+		return
+	}
+	defer secret.Wipe(newPlainText)
+
+	cipherPayload := writeVaultContent(targetVaultFile, newPlainText, appConfig)
+
+	fields := vaultFields(targetVaultFile, cipherPayload)
+	fields["key"] = key
+	fields["type"] = otpKey.Type
+	fields["issuer"] = otpKey.Issuer
+	fields["account"] = otpKey.Account
+	fields["replaced"] = replaced
+	printResult("Imported '"+key+"' into '"+targetVaultFile+"'!", fields)
+}
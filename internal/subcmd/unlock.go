@@ -2,11 +2,13 @@ package subcmd
 
 import (
 	"errors"
-	"fmt"
 	"os"
 
 	"github.com/NobleMajo/vault/internal/config"
+	"github.com/NobleMajo/vault/internal/exitcode"
+	"github.com/NobleMajo/vault/lib/secret"
 	"github.com/NobleMajo/vault/lib/stringfs"
+	"github.com/NobleMajo/vault/pkg/vault"
 )
 
 func UnlockOperation(
@@ -16,32 +18,31 @@ func UnlockOperation(
 	sourceVaultFile := targetFile + "." + appConfig.VaultFileExtension
 	targetPlainFile := targetFile + "." + appConfig.PlainFileExtension
 
+	lockVault(sourceVaultFile, appConfig)
+	defer unlockVault()
+
 	if _, err := os.Stat(sourceVaultFile); errors.Is(err, os.ErrNotExist) {
-		exitError("Source vault file '" + sourceVaultFile + "' does not exist!")
+		exitErrorCode(exitcode.NotFound, "Source vault file '"+sourceVaultFile+"' does not exist!")
 		return
 	}
 
 	vaultRaw, err := stringfs.ReadFile(sourceVaultFile)
 
 	if err != nil {
-		exitError("Error while read vault source from '" + sourceVaultFile + "':\n> " + err.Error())
+		exitWithError("Error while read vault source from '"+sourceVaultFile+"'", err)
 		return
 	}
 
-	loadDecryptionData(appConfig)
-
-	plainText, err := VaultDecrypt(
+	plainText, err := openVault(
 		[]byte(vaultRaw),
-		!appConfig.DisableRSA,
-		lastUsedPrivateKey,
-		!appConfig.DisableAES256,
-		[]byte(lastUsedPassword),
+		appConfig,
 	)
 
 	if err != nil {
-		exitError("Vault decrypt error:\n> " + err.Error())
+		exitWithError("Vault decrypt error", err)
 		return
 	}
+	defer secret.Wipe(plainText)
 
 	err = stringfs.SafeWriteFileBytes(
 		targetPlainFile,
@@ -50,15 +51,40 @@ func UnlockOperation(
 	)
 
 	if err != nil {
-		exitError("Write file error:\n> " + err.Error())
+		exitWithError("Write file error", err)
+		return
+	}
+
+	fields := map[string]any{
+		"vaultFile": sourceVaultFile,
+		"plainFile": targetPlainFile,
+		"size":      len(plainText),
+		"vaultKept": true,
+	}
+
+	if info, err := vault.Inspect([]byte(vaultRaw)); err == nil && customKeySlots(info) {
+		// removing the vault file would lose the recovery key and the other key slots
+		printResult("Unlocked! Vault file kept for its key slots.", fields)
+		return
+	}
+
+	if historyEnabled(appConfig) {
+		// keep the vault file, so the next lock appends to its history
+		printResult("Unlocked! Vault file kept for its history.", fields)
 		return
 	}
 
 	err = stringfs.RemoveFile(sourceVaultFile)
 	if err != nil {
-		exitError("Remove source file error:\n> " + err.Error())
+		exitWithError("Remove source file error", err)
 		return
 	}
 
-	fmt.Println("Unlocked!")
+	fields["vaultKept"] = false
+	printResult("Unlocked!", fields)
+}
+
+// customKeySlots is true if the key slots are more than the default key slot of a new lock.
+func customKeySlots(info *vault.Info) bool {
+	return len(info.Slots) > 1 || len(info.Slots) == 1 && info.Slots[0].Name != vault.DefaultSlot
 }
@@ -0,0 +1,171 @@
+// Package armor encodes binary data as text that survives chat and email,
+// like OpenPGP ascii armor: a BEGIN line, headers, a base64 body,
+// a CRC-24 checksum line and an END line.
+package armor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// BeginLine and EndLine enclose an armored message.
+const (
+	BeginLine = "-----BEGIN VAULT MESSAGE-----"
+	EndLine   = "-----END VAULT MESSAGE-----"
+)
+
+// lineLength is the length of the base64 body lines.
+const lineLength = 64
+
+var (
+	// ErrInvalid is matched by errors of armored messages that can not be decoded.
+	ErrInvalid = errors.New("invalid armored message")
+	// ErrChecksum is matched by errors of armored messages that were changed in transit.
+	ErrChecksum = errors.New("armor checksum mismatch")
+)
+
+// Header is a "Key: Value" line of an armored message, headers are not protected by the checksum.
+type Header struct {
+	Key   string
+	Value string
+}
+
+func invalid(message string) error {
+	return fmt.Errorf("%w: %s", ErrInvalid, message)
+}
+
+// IsArmored reports if the data contains the BEGIN line of an armored message.
+func IsArmored(data []byte) bool {
+	return bytes.Contains(data, []byte(BeginLine))
+}
+
+// Encode returns the armored message of the data.
+func Encode(data []byte, headers []Header) []byte {
+	var armored bytes.Buffer
+	armored.WriteString(BeginLine + "\n")
+	for _, header := range headers {
+		armored.WriteString(header.Key + ": " + header.Value + "\n")
+	}
+	armored.WriteString("\n")
+
+	body := base64.StdEncoding.EncodeToString(data)
+	for len(body) > lineLength {
+		armored.WriteString(body[:lineLength] + "\n")
+		body = body[lineLength:]
+	}
+	if len(body) != 0 {
+		armored.WriteString(body + "\n")
+	}
+
+	armored.WriteString("=" + checksum(data) + "\n")
+	armored.WriteString(EndLine + "\n")
+
+	return armored.Bytes()
+}
+
+// Decode returns the data and headers of the armored message in the text.
+// Text around the message, CRLF line endings, indentation and lost empty lines are accepted,
+// so messages can be pasted from chat and email.
+func Decode(text []byte) ([]byte, []Header, error) {
+	lines := strings.Split(strings.ReplaceAll(string(text), "\r\n", "\n"), "\n")
+
+	index := 0
+	for index < len(lines) && strings.TrimSpace(lines[index]) != BeginLine {
+		index++
+	}
+	if index == len(lines) {
+		return nil, nil, invalid("BEGIN line missing")
+	}
+	index++
+
+	var headers []Header
+	for ; index < len(lines); index++ {
+		line := strings.TrimSpace(lines[index])
+		key, value, found := strings.Cut(line, ": ")
+		if !found || strings.ContainsAny(key, " \t") {
+			break
+		}
+		headers = append(headers, Header{Key: key, Value: value})
+	}
+
+	var body strings.Builder
+	sum := ""
+	ended := false
+	for ; index < len(lines); index++ {
+		if strings.TrimSpace(lines[index]) == EndLine {
+			ended = true
+			break
+		}
+
+		// chat apps can insert spaces into long lines
+		line := strings.Join(strings.Fields(lines[index]), "")
+		if encoded, found := strings.CutPrefix(line, "="); found && len(encoded) == 4 {
+			sum = encoded
+			continue
+		}
+		body.WriteString(line)
+	}
+
+	if !ended {
+		return nil, nil, invalid("END line missing, the message is incomplete")
+	}
+	if len(sum) == 0 {
+		return nil, nil, invalid("checksum line missing")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(body.String())
+	if err != nil {
+		return nil, nil, invalid("body is not base64")
+	}
+
+	if checksum(data) != sum {
+		return nil, nil, fmt.Errorf("%w, the message was changed in transit", ErrChecksum)
+	}
+
+	return data, headers, nil
+}
+
+// ReadMessage reads an armored message up to its END line, other content up to EOF,
+// so a message pasted into a terminal needs no CTRL+D.
+func ReadMessage(reader *bufio.Reader) ([]byte, error) {
+	var data []byte
+	armored := false
+	for {
+		line, err := reader.ReadBytes('\n')
+		data = append(data, line...)
+
+		trimmed := string(bytes.TrimSpace(line))
+		if trimmed == BeginLine {
+			armored = true
+		} else if armored && trimmed == EndLine {
+			return data, nil
+		}
+
+		if err == io.EOF {
+			return data, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// checksum is the base64 encoded CRC-24 of RFC 4880.
+func checksum(data []byte) string {
+	crc := uint32(0xb704ce)
+	for _, char := range data {
+		crc ^= uint32(char) << 16
+		for bit := 0; bit < 8; bit++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= 0x1864cfb
+			}
+		}
+	}
+
+	return base64.StdEncoding.EncodeToString([]byte{byte(crc >> 16), byte(crc >> 8), byte(crc)})
+}
@@ -0,0 +1,129 @@
+package armor
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestChecksum(t *testing.T) {
+	// CRC-24 of RFC 4880, 0xb704ce for no data and 0x21cf02 for "123456789"
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"", "twTO"},
+		{"123456789", "Ic8C"},
+	}
+
+	for _, test := range tests {
+		if got := checksum([]byte(test.data)); got != test.want {
+			t.Fatalf("checksum(%q) = %q, want %q", test.data, got, test.want)
+		}
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	data := bytes.Repeat([]byte("VAULT\x00\x01binary\xff"), 20)
+	headers := []Header{{"Version", "1"}, {"Comment", "decrypt with vault print -"}}
+
+	armored := Encode(data, headers)
+	if !strings.HasPrefix(string(armored), BeginLine+"\nVersion: 1\n") || !strings.HasSuffix(string(armored), EndLine+"\n") {
+		t.Fatalf("unexpected armor:\n%s", armored)
+	}
+	for _, line := range strings.Split(string(armored), "\n") {
+		if len(line) > lineLength {
+			t.Fatalf("line longer than %d: %q", lineLength, line)
+		}
+	}
+
+	decoded, decodedHeaders, err := Decode(armored)
+	if err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Fatalf("decoded data differs")
+	}
+	if len(decodedHeaders) != 2 || decodedHeaders[1] != headers[1] {
+		t.Fatalf("headers = %v, want %v", decodedHeaders, headers)
+	}
+}
+
+func TestDecodePasted(t *testing.T) {
+	data := []byte("pasted from chat")
+	armored := string(Encode(data, []Header{{"Version", "1"}}))
+
+	// indented with CRLF, empty lines lost and text around it
+	pasted := "Hi, here is the token:\r\n"
+	for _, line := range strings.Split(armored, "\n") {
+		if len(line) != 0 {
+			pasted += "    " + line + "\r\n"
+		}
+	}
+	pasted += "Cheers\r\n"
+
+	if !IsArmored([]byte(pasted)) {
+		t.Fatal("IsArmored = false for pasted message")
+	}
+
+	decoded, _, err := Decode([]byte(pasted))
+	if err != nil {
+		t.Fatalf("Decode error: %v", err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Fatalf("decoded = %q, want %q", decoded, data)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	armored := string(Encode([]byte("some secret vault data"), nil))
+	lines := strings.Split(armored, "\n")
+
+	changed := strings.Replace(armored, lines[2][:4], "AAAA", 1)
+	withoutEnd := strings.Join(lines[:len(lines)-2], "\n")
+	withoutSum := strings.Join(append(lines[:len(lines)-3:len(lines)-3], lines[len(lines)-2:]...), "\n")
+
+	tests := []struct {
+		name string
+		text string
+		want error
+	}{
+		{"changed", changed, ErrChecksum},
+		{"no end", withoutEnd, ErrInvalid},
+		{"no checksum", withoutSum, ErrInvalid},
+		{"no begin", "just text", ErrInvalid},
+	}
+
+	for _, test := range tests {
+		_, _, err := Decode([]byte(test.text))
+		if !errors.Is(err, test.want) {
+			t.Fatalf("%s: error = %v, want %v", test.name, err, test.want)
+		}
+	}
+}
+
+func TestReadMessage(t *testing.T) {
+	armored := Encode([]byte("secret"), nil)
+	reader := bufio.NewReader(bytes.NewReader(append(bytes.Clone(armored), "password\n"...)))
+
+	message, err := ReadMessage(reader)
+	if err != nil {
+		t.Fatalf("ReadMessage error: %v", err)
+	}
+	if !bytes.Equal(message, armored) {
+		t.Fatalf("message = %q, want %q", message, armored)
+	}
+
+	rest, _ := reader.ReadString('\n')
+	if rest != "password\n" {
+		t.Fatalf("rest = %q, want the line after the END line", rest)
+	}
+
+	binary := []byte("VAULT\x00\x01\nno armor")
+	message, err = ReadMessage(bufio.NewReader(bytes.NewReader(binary)))
+	if err != nil || !bytes.Equal(message, binary) {
+		t.Fatalf("ReadMessage = %q, %v, want all data", message, err)
+	}
+}
@@ -0,0 +1,76 @@
+// Package clipboard copies secrets to the system clipboard with wl-copy, xclip
+// or the OSC 52 terminal escape sequence, which also works over ssh,
+// and clears them again if the clipboard still holds them.
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+
+	"github.com/NobleMajo/vault/lib/secret"
+)
+
+// ErrUnavailable is returned by Detect if no clipboard can be used.
+var ErrUnavailable = errors.New("no clipboard found, install wl-copy or xclip or use a terminal with OSC 52 support")
+
+// ErrUnreadable is returned by Read if the content of the clipboard can not be read back.
+var ErrUnreadable = errors.New("clipboard can not be read")
+
+// Clipboard is a system clipboard.
+type Clipboard interface {
+	// Name is the tool or escape sequence that is used, like "wl-copy".
+	Name() string
+	// Write replaces the clipboard content.
+	Write(data []byte) error
+	// Read returns the clipboard content or ErrUnreadable.
+	Read() ([]byte, error)
+	// Clear empties the clipboard.
+	Clear() error
+}
+
+// Detect returns wl-copy on wayland, xclip on X11 or OSC 52 if a terminal is attached.
+func Detect() (Clipboard, error) {
+	if len(os.Getenv("WAYLAND_DISPLAY")) != 0 && installed("wl-copy", "wl-paste") {
+		return wlClipboard, nil
+	}
+
+	if len(os.Getenv("DISPLAY")) != 0 && installed("xclip") {
+		return xclipClipboard, nil
+	}
+
+	if tty, err := os.OpenFile(ttyPath, os.O_RDWR, 0); err == nil {
+		tty.Close()
+		return &osc52Clipboard{tmux: len(os.Getenv("TMUX")) != 0}, nil
+	}
+
+	return nil, ErrUnavailable
+}
+
+func installed(names ...string) bool {
+	for _, name := range names {
+		if _, err := exec.LookPath(name); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ClearIf clears the clipboard if it still holds the value and reports if it was cleared.
+// A clipboard that can not be read back is not cleared, because it could hold something
+// that was copied in the meantime, ErrUnreadable is returned then.
+func ClearIf(clipboard Clipboard, value []byte) (bool, error) {
+	content, err := clipboard.Read()
+	defer secret.Wipe(content)
+
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(content, value) {
+		return false, nil
+	}
+
+	return true, clipboard.Clear()
+}
@@ -0,0 +1,100 @@
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type memoryClipboard struct {
+	content    []byte
+	unreadable bool
+	cleared    bool
+}
+
+func (clipboard *memoryClipboard) Name() string { return "memory" }
+
+func (clipboard *memoryClipboard) Write(data []byte) error {
+	clipboard.content = bytes.Clone(data)
+	return nil
+}
+
+func (clipboard *memoryClipboard) Read() ([]byte, error) {
+	if clipboard.unreadable {
+		return nil, ErrUnreadable
+	}
+	return bytes.Clone(clipboard.content), nil
+}
+
+func (clipboard *memoryClipboard) Clear() error {
+	clipboard.content = nil
+	clipboard.cleared = true
+	return nil
+}
+
+func TestClearIf(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		unreadable bool
+		want       bool
+		err        error
+	}{
+		{"still ours", "s3cret", false, true, nil},
+		{"changed", "something else", false, false, nil},
+		{"unreadable", "something else", true, false, ErrUnreadable},
+		{"unreadable still ours", "s3cret", true, false, ErrUnreadable},
+	}
+
+	for _, test := range tests {
+		clipboard := &memoryClipboard{content: []byte(test.content), unreadable: test.unreadable}
+
+		cleared, err := ClearIf(clipboard, []byte("s3cret"))
+		if !errors.Is(err, test.err) {
+			t.Fatalf("%s: ClearIf error = %v, want %v", test.name, err, test.err)
+		}
+		if cleared != test.want || clipboard.cleared != test.want {
+			t.Fatalf("%s: cleared = %v, want %v", test.name, cleared, test.want)
+		}
+		if !test.want && string(clipboard.content) != test.content {
+			t.Fatalf("%s: content = %q, want it kept", test.name, clipboard.content)
+		}
+	}
+}
+
+func TestOSC52Sequence(t *testing.T) {
+	if got := string(osc52Sequence([]byte("hello"), false)); got != "\033]52;c;aGVsbG8=\a" {
+		t.Fatalf("sequence = %q", got)
+	}
+
+	if got := string(osc52Sequence(nil, false)); got != "\033]52;c;\a" {
+		t.Fatalf("clear sequence = %q", got)
+	}
+
+	if got := string(osc52Sequence([]byte("hi"), true)); got != "\033Ptmux;\033\033]52;c;aGk=\a\033\\" {
+		t.Fatalf("tmux sequence = %q", got)
+	}
+}
+
+func TestParseOSC52Response(t *testing.T) {
+	tests := []struct {
+		response string
+		content  string
+		complete bool
+		err      error
+	}{
+		{"\033]52;c;aGVsbG8=\a", "hello", true, nil},
+		{"\033]52;c;aGVsbG8=\033\\", "hello", true, nil},
+		{"noise\033]52;p;aGk=\a", "hi", true, nil},
+		{"\033]52;c;aGVs", "", false, nil},
+		{"", "", false, nil},
+		{"\033]52;c;!!!\a", "", true, ErrUnreadable},
+	}
+
+	for _, test := range tests {
+		content, complete, err := parseOSC52Response([]byte(test.response))
+		if complete != test.complete || !errors.Is(err, test.err) || string(content) != test.content {
+			t.Fatalf("parse %q = (%q, %v, %v), want (%q, %v, %v)", test.response, content, complete, err, test.content, test.complete, test.err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package clipboard
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+)
+
+// commandClipboard runs clipboard tools, the data is passed on stdin
+// and never as argument, so it does not show up in the process list.
+type commandClipboard struct {
+	name  string
+	write []string
+	read  []string
+	// clear is nil if the clipboard is cleared by writing no data.
+	clear []string
+}
+
+var wlClipboard = &commandClipboard{
+	name:  "wl-copy",
+	write: []string{"wl-copy"},
+	read:  []string{"wl-paste", "--no-newline"},
+	clear: []string{"wl-copy", "--clear"},
+}
+
+var xclipClipboard = &commandClipboard{
+	name:  "xclip",
+	write: []string{"xclip", "-selection", "clipboard", "-in"},
+	read:  []string{"xclip", "-selection", "clipboard", "-out"},
+}
+
+func (clipboard *commandClipboard) Name() string {
+	return clipboard.name
+}
+
+func (clipboard *commandClipboard) Write(data []byte) error {
+	// the tools fork to serve the clipboard, stdout and stderr are not
+	// connected, so waiting for the command does not wait for the fork
+	cmd := exec.Command(clipboard.write[0], clipboard.write[1:]...)
+	cmd.Stdin = bytes.NewReader(data)
+
+	return cmd.Run()
+}
+
+func (clipboard *commandClipboard) Read() ([]byte, error) {
+	content, err := exec.Command(clipboard.read[0], clipboard.read[1:]...).Output()
+
+	// the tools fail if the clipboard is empty or owned by nobody
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return nil, nil
+	} else if errors.Is(err, exec.ErrNotFound) {
+		return nil, ErrUnreadable
+	}
+
+	return content, err
+}
+
+func (clipboard *commandClipboard) Clear() error {
+	if clipboard.clear == nil {
+		return clipboard.Write(nil)
+	}
+
+	return exec.Command(clipboard.clear[0], clipboard.clear[1:]...).Run()
+}
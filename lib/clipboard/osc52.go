@@ -0,0 +1,166 @@
+package clipboard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/NobleMajo/vault/lib/secret"
+	"golang.org/x/term"
+)
+
+const ttyPath = "/dev/tty"
+
+// MaxOSC52Size is the largest content for OSC 52, terminals like xterm drop longer sequences.
+const MaxOSC52Size = 74994
+
+// queryTimeout is how long a terminal has to answer a clipboard query.
+const queryTimeout = 500 * time.Millisecond
+
+// osc52Clipboard writes the OSC 52 escape sequence to the terminal, the terminal
+// sets the clipboard of the machine it runs on, so it works over ssh.
+type osc52Clipboard struct {
+	// tmux wraps the sequences for the tmux passthrough.
+	tmux bool
+}
+
+func (clipboard *osc52Clipboard) Name() string {
+	return "osc52"
+}
+
+func (clipboard *osc52Clipboard) Write(data []byte) error {
+	if len(data) > MaxOSC52Size {
+		return errors.New("content is too large for OSC 52")
+	}
+
+	tty, err := os.OpenFile(ttyPath, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer tty.Close()
+
+	sequence := osc52Sequence(data, clipboard.tmux)
+	defer secret.Wipe(sequence)
+
+	_, err = tty.Write(sequence)
+	return err
+}
+
+// Read queries the clipboard, most terminals do not answer or only if it is enabled.
+func (clipboard *osc52Clipboard) Read() ([]byte, error) {
+	// only a non-blocking file supports the read deadline
+	tty, err := os.OpenFile(ttyPath, os.O_RDWR|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer tty.Close()
+
+	// without a deadline a terminal that does not answer would block forever
+	if err := tty.SetReadDeadline(time.Now().Add(queryTimeout)); err != nil {
+		return nil, ErrUnreadable
+	}
+
+	state, err := term.MakeRaw(int(tty.Fd()))
+	if err != nil {
+		return nil, ErrUnreadable
+	}
+	defer term.Restore(int(tty.Fd()), state)
+
+	if _, err := tty.Write(wrapTmux([]byte("\033]52;c;?\a"), clipboard.tmux)); err != nil {
+		return nil, err
+	}
+
+	var response []byte
+	defer func() { secret.Wipe(response) }()
+
+	buffer := make([]byte, 4096)
+	defer secret.Wipe(buffer)
+	for {
+		count, err := tty.Read(buffer)
+		response = append(response, buffer[:count]...)
+		if content, complete, parseErr := parseOSC52Response(response); complete {
+			return content, parseErr
+		}
+		if err != nil {
+			return nil, ErrUnreadable
+		}
+	}
+}
+
+func (clipboard *osc52Clipboard) Clear() error {
+	tty, err := os.OpenFile(ttyPath, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer tty.Close()
+
+	// a selection with no data clears the clipboard
+	_, err = tty.Write(osc52Sequence(nil, clipboard.tmux))
+	return err
+}
+
+// osc52Sequence is ESC ] 52 ; c ; <base64> BEL.
+func osc52Sequence(data []byte, tmux bool) []byte {
+	sequence := make([]byte, 0, base64.StdEncoding.EncodedLen(len(data))+8)
+	sequence = append(sequence, "\033]52;c;"...)
+	sequence = base64.StdEncoding.AppendEncode(sequence, data)
+	sequence = append(sequence, '\a')
+
+	if !tmux {
+		return sequence
+	}
+
+	wrapped := wrapTmux(sequence, true)
+	secret.Wipe(sequence)
+	return wrapped
+}
+
+// wrapTmux passes a sequence through tmux to the outer terminal,
+// every ESC inside is doubled.
+func wrapTmux(sequence []byte, tmux bool) []byte {
+	if !tmux {
+		return sequence
+	}
+
+	wrapped := []byte("\033Ptmux;")
+	for _, char := range sequence {
+		if char == '\033' {
+			wrapped = append(wrapped, '\033')
+		}
+		wrapped = append(wrapped, char)
+	}
+
+	return append(wrapped, "\033\\"...)
+}
+
+// parseOSC52Response reads the answer ESC ] 52 ; <selection> ; <base64> of a query,
+// which ends with BEL or ESC \. It reports if the answer is complete.
+func parseOSC52Response(response []byte) ([]byte, bool, error) {
+	start := bytes.Index(response, []byte("\033]52;"))
+	if start < 0 {
+		return nil, false, nil
+	}
+	body := response[start+5:]
+
+	end := bytes.IndexAny(body, "\a\033")
+	if end < 0 {
+		return nil, false, nil
+	}
+	body = body[:end]
+
+	_, encoded, found := bytes.Cut(body, []byte(";"))
+	if !found {
+		return nil, true, ErrUnreadable
+	}
+
+	content, err := base64.StdEncoding.AppendDecode(nil, encoded)
+	if err != nil {
+		secret.Wipe(content)
+		return nil, true, ErrUnreadable
+	}
+
+	return content, true, nil
+}
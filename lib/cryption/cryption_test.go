@@ -1,6 +1,12 @@
 package cryption
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -339,3 +345,185 @@ func TestAES256(t *testing.T) {
 		})
 	}
 }
+
+func TestAES256DecryptKeepsCipherPayload(t *testing.T) {
+	encryptedPayload, err := AES256Encrypt([]byte("key"), []byte("payload"))
+	if err != nil {
+		t.Fatalf("failed to encrypt payload: %v", err)
+	}
+
+	original := string(encryptedPayload)
+
+	if _, err := AES256Decrypt([]byte("key"), encryptedPayload); err != nil {
+		t.Fatalf("failed to decrypt payload: %v", err)
+	}
+
+	if string(encryptedPayload) != original {
+		t.Fatal("expected cipher payload to be unchanged after decrypt")
+	}
+}
+
+func TestAES256Iterations(t *testing.T) {
+	encryptedPayload, err := AES256EncryptIterations([]byte("key"), []byte("payload"), 1000)
+	if err != nil {
+		t.Fatalf("failed to encrypt payload: %v", err)
+	}
+
+	if _, err := AES256Decrypt([]byte("key"), encryptedPayload); err == nil {
+		t.Fatal("expected decrypt with other iterations to fail")
+	}
+
+	decryptedPayload, err := AES256DecryptIterations([]byte("key"), encryptedPayload, 1000)
+	if err != nil || string(decryptedPayload) != "payload" {
+		t.Fatalf("AES256DecryptIterations = %q, %v", decryptedPayload, err)
+	}
+
+	if _, err := AES256EncryptIterations([]byte("key"), []byte("payload"), 0); err == nil {
+		t.Fatal("expected 0 iterations to fail")
+	}
+}
+
+func TestAES256Keyfile(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.key")
+	second := filepath.Join(dir, "second.key")
+	empty := filepath.Join(dir, "empty.key")
+	if err := os.WriteFile(first, []byte("first keyfile"), 0600); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile(second, []byte("second keyfile"), 0600); err != nil {
+		t.Fatal(err)
+	} else if err := os.WriteFile(empty, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	keyfileHash, err := HashKeyfiles(first, second)
+	if err != nil {
+		t.Fatalf("failed to hash keyfiles: %v", err)
+	}
+	swappedHash, err := HashKeyfiles(second, first)
+	if err != nil || !bytes.Equal(keyfileHash, swappedHash) {
+		t.Fatalf("expected keyfile order to not matter: %v", err)
+	}
+
+	encryptedPayload, err := AES256EncryptKeyfile([]byte("key"), []byte("payload"), 1000, keyfileHash)
+	if err != nil {
+		t.Fatalf("failed to encrypt payload: %v", err)
+	}
+
+	if _, err := AES256DecryptIterations([]byte("key"), encryptedPayload, 1000); err == nil {
+		t.Fatal("expected decrypt without keyfile to fail")
+	}
+
+	firstHash, _ := HashKeyfiles(first)
+	if _, err := AES256DecryptKeyfile([]byte("key"), encryptedPayload, 1000, firstHash); err == nil {
+		t.Fatal("expected decrypt with one of two keyfiles to fail")
+	}
+
+	decryptedPayload, err := AES256DecryptKeyfile([]byte("key"), encryptedPayload, 1000, swappedHash)
+	if err != nil || string(decryptedPayload) != "payload" {
+		t.Fatalf("AES256DecryptKeyfile = %q, %v", decryptedPayload, err)
+	}
+
+	if _, err := HashKeyfiles(empty); err == nil {
+		t.Fatal("expected empty keyfile to fail")
+	} else if _, err := HashKeyfiles(filepath.Join(dir, "missing.key")); err == nil {
+		t.Fatal("expected missing keyfile to fail")
+	}
+}
+
+// tokenKey is a crypto.Decrypter that is not a *rsa.PrivateKey, like a pkcs11 key.
+type tokenKey struct {
+	privateKey *rsa.PrivateKey
+	calls      int
+}
+
+func (key *tokenKey) Public() crypto.PublicKey {
+	return &key.privateKey.PublicKey
+}
+
+func (key *tokenKey) Decrypt(rand io.Reader, ciphertext []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	key.calls++
+	return key.privateKey.Decrypt(rand, ciphertext, opts)
+}
+
+func TestX509AES256DecryptDecrypter(t *testing.T) {
+	privateKey, err := LoadRsaPrivateKey(filepath.Join(testKeysDir(t), "test_id_rsa"))
+	if err != nil {
+		t.Fatalf("failed to load private key: %v", err)
+	}
+
+	cipherPayload, err := X509AES256Encrypt(&privateKey.PublicKey, []byte("payload"))
+	if err != nil {
+		t.Fatalf("failed to encrypt payload: %v", err)
+	}
+
+	key := &tokenKey{privateKey: privateKey}
+	plainPayload, err := X509AES256Decrypt(key, cipherPayload)
+	if err != nil || string(plainPayload) != "payload" || key.calls != 1 {
+		t.Fatalf("X509AES256Decrypt = %q, %v, %d calls", plainPayload, err, key.calls)
+	}
+
+	var nilKey *rsa.PrivateKey
+	if _, err := X509ChunkDecrypt(nilKey, cipherPayload); err == nil {
+		t.Fatal("expected a nil private key to fail")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	keys := testKeysDir(t)
+	dir := t.TempDir()
+
+	invalidKey := filepath.Join(dir, "invalid_key")
+	if err := os.WriteFile(invalidKey, []byte("not a key"), 0600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	_, err := LoadRsaPrivateKey(invalidKey)
+	var keyErr *KeyError
+	if !errors.As(err, &keyErr) || keyErr.Path != invalidKey || !errors.Is(err, ErrUnsupportedKey) {
+		t.Fatalf("invalid private key error = %v, want KeyError with ErrUnsupportedKey", err)
+	}
+
+	_, err = LoadRsaPublicKey(invalidKey)
+	if !errors.Is(err, ErrUnsupportedKey) {
+		t.Fatalf("invalid public key error = %v, want ErrUnsupportedKey", err)
+	}
+
+	_, err = LoadRsaPrivateKey(filepath.Join(dir, "missing"))
+	if !errors.As(err, &keyErr) || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("missing private key error = %v, want KeyError with os.ErrNotExist", err)
+	}
+
+	publicKey, err := LoadRsaPublicKey(filepath.Join(keys, "test_id_rsa.pub"))
+	if err != nil {
+		t.Fatalf("LoadRsaPublicKey: %v", err)
+	}
+	cipherPayload, err := X509AES256Encrypt(publicKey, []byte("content"))
+	if err != nil {
+		t.Fatalf("X509AES256Encrypt: %v", err)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	_, err = X509AES256Decrypt(otherKey, cipherPayload)
+	if !errors.Is(err, ErrWrongRecipient) {
+		t.Fatalf("decrypt with another key error = %v, want ErrWrongRecipient", err)
+	}
+
+	aesPayload, err := AES256Encrypt([]byte("pass1234"), []byte("content"))
+	if err != nil {
+		t.Fatalf("AES256Encrypt: %v", err)
+	}
+
+	_, err = AES256Decrypt([]byte("wrong123"), aesPayload)
+	if !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("wrong password error = %v, want ErrAuthFailed", err)
+	}
+
+	_, err = AES256Decrypt([]byte("pass1234"), aesPayload[:20])
+	if !errors.Is(err, ErrTruncated) {
+		t.Fatalf("truncated payload error = %v, want ErrTruncated", err)
+	}
+}
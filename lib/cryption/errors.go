@@ -0,0 +1,53 @@
+package cryption
+
+import "errors"
+
+var (
+	// ErrAuthFailed is returned if the key or password is wrong or the data was modified.
+	ErrAuthFailed = errors.New("decryption failed: invalid key or corrupted data")
+	// ErrTruncated is returned if encrypted data ends before its last byte, like a stream before its final chunk.
+	ErrTruncated = errors.New("encrypted data truncated")
+	// ErrWrongRecipient is returned if a rsa private key can not decrypt the key of the payload,
+	// the payload was encrypted for another rsa key.
+	ErrWrongRecipient = errors.New("encrypted for another rsa key")
+	// ErrUnsupportedKey is returned for key files that are not openssh or pem encoded rsa keys.
+	ErrUnsupportedKey = errors.New("unsupported key")
+)
+
+// KeyError is returned if a key file or keyfile can not be read or parsed.
+// It matches ErrUnsupportedKey for unknown formats and os.ErrNotExist for missing files.
+type KeyError struct {
+	Path string
+	Err  error
+}
+
+func (err *KeyError) Error() string {
+	return err.Err.Error()
+}
+
+func (err *KeyError) Unwrap() error {
+	return err.Err
+}
+
+// sentinelError keeps the message of its cause and matches the sentinel error too.
+type sentinelError struct {
+	sentinel error
+	err      error
+}
+
+func (err *sentinelError) Error() string {
+	return err.err.Error()
+}
+
+func (err *sentinelError) Unwrap() []error {
+	return []error{err.sentinel, err.err}
+}
+
+func withSentinel(sentinel error, err error) error {
+	return &sentinelError{sentinel, err}
+}
+
+// unsupportedKey returns an error with the message that matches ErrUnsupportedKey.
+func unsupportedKey(message string) error {
+	return withSentinel(ErrUnsupportedKey, errors.New(message))
+}
@@ -0,0 +1,471 @@
+package cryption
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/NobleMajo/vault/lib/secret"
+)
+
+// StreamMagic prefixes every encrypted stream.
+const StreamMagic = "VSTR"
+
+const StreamVersion = 1
+
+// DefaultStreamChunkSize is the plain text size of one authenticated chunk.
+const DefaultStreamChunkSize = 64 * 1024
+
+// DefaultStreamIterations are the PBKDF2 iterations for new password protected streams.
+const DefaultStreamIterations = 4096
+
+const maxStreamChunkSize = 16 * 1024 * 1024
+const maxStreamIterations = 100_000_000
+
+const (
+	streamFlagPassword byte = 1 << 0
+	streamFlagRSA      byte = 1 << 1
+)
+
+const streamFileKeySize = 32
+
+// StreamOptions configure the stream encryption.
+// At least one of Password and PublicKey (encrypt) or PrivateKey (decrypt) is required.
+type StreamOptions struct {
+	Password   []byte
+	PublicKey  *rsa.PublicKey
+	PrivateKey crypto.Decrypter
+
+	// ChunkSize and Iterations are only used to encrypt,
+	// both are stored in the stream header.
+	ChunkSize  int
+	Iterations int
+}
+
+// The stream format is:
+//
+//	header: magic(4) | version(1) | flags(1) | chunk size(4) | iterations(4) | salt(16)
+//	        [rsa: key length(2) | rsa encrypted file key] | header hmac(32)
+//	chunks: AES-256-GCM sealed chunks of chunk size, the last one can be shorter
+//
+// The GCM nonce is the chunk counter with a final flag in the last byte,
+// so removed, reordered or appended chunks and a missing final chunk are detected.
+type streamHeader struct {
+	flags        byte
+	chunkSize    int
+	iterations   int
+	salt         []byte
+	encryptedKey []byte
+}
+
+func (header *streamHeader) marshal() []byte {
+	var buffer bytes.Buffer
+
+	buffer.WriteString(StreamMagic)
+	buffer.WriteByte(StreamVersion)
+	buffer.WriteByte(header.flags)
+	buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(header.chunkSize)))
+	buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(header.iterations)))
+	buffer.Write(header.salt)
+
+	if header.flags&streamFlagRSA != 0 {
+		buffer.Write(binary.BigEndian.AppendUint16(nil, uint16(len(header.encryptedKey))))
+		buffer.Write(header.encryptedKey)
+	}
+
+	return buffer.Bytes()
+}
+
+// streamKeys derives the chunk cipher and the header hmac key
+// from the password and / or the random file key.
+func streamKeys(header *streamHeader, password []byte, fileKey []byte) (cipher.AEAD, []byte, error) {
+	var passwordKey []byte
+	if header.flags&streamFlagPassword != 0 {
+		passwordKey = deriveKey(password, header.salt, header.iterations, 32, nil)
+		defer secret.Wipe(passwordKey)
+	}
+
+	streamKey := hmac.New(sha256.New, passwordKey)
+	streamKey.Write([]byte("vault stream v1"))
+	streamKey.Write(header.salt)
+	if header.flags&streamFlagRSA != 0 {
+		streamKey.Write(fileKey)
+	}
+	masterKey := streamKey.Sum(nil)
+	defer secret.Wipe(masterKey)
+
+	chunkKey := generateHMAC(masterKey, []byte("chunk"))
+	defer secret.Wipe(chunkKey)
+	headerKey := generateHMAC(masterKey, []byte("header"))
+
+	block, err := aes.NewCipher(chunkKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return aead, headerKey, nil
+}
+
+func streamNonce(counter uint64, final bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if final {
+		nonce[11] = 1
+	}
+
+	return nonce
+}
+
+type encryptWriter struct {
+	writer  io.Writer
+	aead    cipher.AEAD
+	buffer  []byte
+	counter uint64
+	closed  bool
+	err     error
+}
+
+// NewEncryptWriter returns a writer that encrypts everything written to it into w.
+// The header is written immediately, Close writes the final chunk and must be called.
+// Close does not close w.
+func NewEncryptWriter(w io.Writer, options StreamOptions) (io.WriteCloser, error) {
+	if options.PublicKey == nil && len(options.Password) == 0 {
+		return nil, errors.New("no stream encryption method selected")
+	}
+
+	header := &streamHeader{
+		chunkSize:  options.ChunkSize,
+		iterations: options.Iterations,
+	}
+
+	if header.chunkSize == 0 {
+		header.chunkSize = DefaultStreamChunkSize
+	} else if header.chunkSize < 1 || header.chunkSize > maxStreamChunkSize {
+		return nil, errors.New("invalid stream chunk size " + strconv.Itoa(header.chunkSize))
+	}
+
+	if header.iterations == 0 {
+		header.iterations = DefaultStreamIterations
+	} else if header.iterations < 1 || header.iterations > maxStreamIterations {
+		return nil, errors.New("invalid stream iterations " + strconv.Itoa(header.iterations))
+	}
+
+	var err error
+	header.salt, err = RandomByteArray(16)
+	if err != nil {
+		return nil, err
+	}
+
+	fileKey, err := RandomByteArray(streamFileKeySize)
+	if err != nil {
+		return nil, err
+	}
+	defer secret.Wipe(fileKey)
+
+	if len(options.Password) != 0 {
+		header.flags |= streamFlagPassword
+	}
+
+	if options.PublicKey != nil {
+		header.flags |= streamFlagRSA
+		header.encryptedKey, err = X509ChunkEncrypt(options.PublicKey, fileKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	aead, headerKey, err := streamKeys(header, options.Password, fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	headerBytes := header.marshal()
+	headerBytes = append(headerBytes, generateHMAC(headerKey, headerBytes)...)
+
+	err = writeFull(w, headerBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{
+		writer: w,
+		aead:   aead,
+		buffer: make([]byte, 0, header.chunkSize),
+	}, nil
+}
+
+func (writer *encryptWriter) Write(data []byte) (int, error) {
+	if writer.err != nil {
+		return 0, writer.err
+	} else if writer.closed {
+		return 0, errors.New("write to closed encrypt writer")
+	}
+
+	written := 0
+	for len(data) != 0 {
+		// a full chunk is only sealed when more data follows,
+		// because the last chunk has to be marked as final
+		if len(writer.buffer) == cap(writer.buffer) {
+			writer.err = writer.sealChunk(false)
+			if writer.err != nil {
+				return written, writer.err
+			}
+		}
+
+		n := copy(writer.buffer[len(writer.buffer):cap(writer.buffer)], data)
+		writer.buffer = writer.buffer[:len(writer.buffer)+n]
+		data = data[n:]
+		written += n
+	}
+
+	return written, nil
+}
+
+// Close seals the final chunk.
+func (writer *encryptWriter) Close() error {
+	if writer.err != nil {
+		return writer.err
+	} else if writer.closed {
+		return nil
+	}
+
+	writer.closed = true
+	writer.err = writer.sealChunk(true)
+	secret.Wipe(writer.buffer[:cap(writer.buffer)])
+
+	return writer.err
+}
+
+func (writer *encryptWriter) sealChunk(final bool) error {
+	if writer.counter == ^uint64(0) {
+		return errors.New("encrypted stream too long")
+	}
+
+	sealed := writer.aead.Seal(nil, streamNonce(writer.counter, final), writer.buffer, nil)
+	writer.counter++
+	secret.Wipe(writer.buffer)
+	writer.buffer = writer.buffer[:0]
+
+	return writeFull(writer.writer, sealed)
+}
+
+func writeFull(w io.Writer, data []byte) error {
+	n, err := w.Write(data)
+	if err != nil {
+		return err
+	} else if n != len(data) {
+		return io.ErrShortWrite
+	}
+
+	return nil
+}
+
+type decryptReader struct {
+	reader  io.Reader
+	aead    cipher.AEAD
+	sealed  []byte
+	next    []byte
+	opened  []byte
+	plain   []byte
+	counter uint64
+	final   bool
+	err     error
+}
+
+// NewDecryptReader returns a reader that decrypts the stream from r.
+// The header is read and authenticated immediately.
+// Read returns ErrTruncated if the stream ends before the final chunk
+// and ErrAuthFailed if any chunk was modified.
+func NewDecryptReader(r io.Reader, options StreamOptions) (io.Reader, error) {
+	header, headerBytes, err := readStreamHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.flags&streamFlagPassword != 0 && len(options.Password) == 0 {
+		return nil, errors.New("encrypted stream needs a password")
+	}
+
+	var fileKey []byte
+	if header.flags&streamFlagRSA != 0 {
+		if options.PrivateKey == nil {
+			return nil, errors.New("encrypted stream needs a private key")
+		}
+
+		fileKey, err = X509ChunkDecrypt(options.PrivateKey, header.encryptedKey)
+		if err != nil {
+			return nil, withSentinel(ErrAuthFailed, err)
+		}
+		defer secret.Wipe(fileKey)
+	}
+
+	aead, headerKey, err := streamKeys(header, options.Password, fileKey)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := make([]byte, sha256.Size)
+	_, err = io.ReadFull(r, mac)
+	if err != nil {
+		return nil, streamReadError(err)
+	}
+
+	if !hmac.Equal(mac, generateHMAC(headerKey, headerBytes)) {
+		return nil, ErrAuthFailed
+	}
+
+	sealedSize := header.chunkSize + aead.Overhead()
+
+	return &decryptReader{
+		reader: r,
+		aead:   aead,
+		sealed: make([]byte, sealedSize),
+		next:   make([]byte, 0, 1),
+		opened: make([]byte, 0, header.chunkSize),
+	}, nil
+}
+
+func readStreamHeader(r io.Reader) (*streamHeader, []byte, error) {
+	fixed := make([]byte, len(StreamMagic)+1+1+4+4+16)
+	_, err := io.ReadFull(r, fixed)
+	if err != nil {
+		return nil, nil, streamReadError(err)
+	}
+
+	if string(fixed[:len(StreamMagic)]) != StreamMagic {
+		return nil, nil, errors.New("not an encrypted stream")
+	}
+
+	data := fixed[len(StreamMagic):]
+	if data[0] != StreamVersion {
+		return nil, nil, errors.New("unsupported encrypted stream version " + strconv.Itoa(int(data[0])))
+	}
+
+	header := &streamHeader{
+		flags:      data[1],
+		chunkSize:  int(binary.BigEndian.Uint32(data[2:6])),
+		iterations: int(binary.BigEndian.Uint32(data[6:10])),
+		salt:       data[10:26],
+	}
+
+	if header.flags&^(streamFlagPassword|streamFlagRSA) != 0 || header.flags == 0 {
+		return nil, nil, errors.New("invalid encrypted stream flags")
+	} else if header.chunkSize < 1 || header.chunkSize > maxStreamChunkSize {
+		return nil, nil, errors.New("invalid encrypted stream chunk size")
+	} else if header.iterations < 1 || header.iterations > maxStreamIterations {
+		return nil, nil, errors.New("invalid encrypted stream iterations")
+	}
+
+	headerBytes := fixed
+
+	if header.flags&streamFlagRSA != 0 {
+		keyLength := make([]byte, 2)
+		_, err = io.ReadFull(r, keyLength)
+		if err != nil {
+			return nil, nil, streamReadError(err)
+		}
+
+		header.encryptedKey = make([]byte, binary.BigEndian.Uint16(keyLength))
+		_, err = io.ReadFull(r, header.encryptedKey)
+		if err != nil {
+			return nil, nil, streamReadError(err)
+		}
+
+		headerBytes = append(headerBytes, keyLength...)
+		headerBytes = append(headerBytes, header.encryptedKey...)
+	}
+
+	return header, headerBytes, nil
+}
+
+func streamReadError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrTruncated
+	}
+
+	return err
+}
+
+func (reader *decryptReader) Read(data []byte) (int, error) {
+	for len(reader.plain) == 0 {
+		if reader.err != nil {
+			return 0, reader.err
+		}
+
+		if reader.final {
+			return 0, io.EOF
+		}
+
+		reader.err = reader.openChunk()
+	}
+
+	n := copy(data, reader.plain)
+	reader.plain = reader.plain[n:]
+
+	return n, nil
+}
+
+// openChunk reads and decrypts the next chunk. A chunk is final if no data follows,
+// to know that one byte is read ahead and kept for the next chunk.
+func (reader *decryptReader) openChunk() error {
+	secret.Wipe(reader.opened[:cap(reader.opened)])
+
+	sealed := reader.sealed[:copy(reader.sealed, reader.next)]
+	reader.next = reader.next[:0]
+
+	n, err := io.ReadFull(reader.reader, reader.sealed[len(sealed):])
+	sealed = reader.sealed[:len(sealed)+n]
+	final := false
+
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		final = true
+	} else if err != nil {
+		return err
+	} else {
+		// full chunk, final if nothing follows
+		reader.next = reader.next[:1]
+		_, err = io.ReadFull(reader.reader, reader.next)
+		if err == io.EOF {
+			reader.next = reader.next[:0]
+			final = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if len(sealed) < reader.aead.Overhead() {
+		return ErrTruncated
+	}
+
+	plain, err := reader.aead.Open(reader.opened[:0], streamNonce(reader.counter, final), sealed, nil)
+	if err != nil {
+		if final {
+			// a chunk that is valid as non-final means the following chunks were cut off
+			_, err = reader.aead.Open(nil, streamNonce(reader.counter, false), sealed, nil)
+			if err == nil {
+				return ErrTruncated
+			}
+		}
+
+		return ErrAuthFailed
+	}
+
+	reader.counter++
+	reader.final = final
+	reader.plain = plain
+
+	return nil
+}
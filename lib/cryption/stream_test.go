@@ -0,0 +1,314 @@
+package cryption
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+)
+
+func encryptStream(t *testing.T, plain []byte, options StreamOptions) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	writer, err := NewEncryptWriter(&buffer, options)
+	if err != nil {
+		t.Fatalf("NewEncryptWriter error: %v", err)
+	}
+
+	_, err = writer.Write(plain)
+	if err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	return buffer.Bytes()
+}
+
+func decryptStream(encrypted []byte, options StreamOptions) ([]byte, error) {
+	reader, err := NewDecryptReader(bytes.NewReader(encrypted), options)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(reader)
+}
+
+func streamTestPlain(size int) []byte {
+	plain := make([]byte, size)
+	for i := range plain {
+		plain[i] = byte(i * 7)
+	}
+
+	return plain
+}
+
+func TestStreamRoundTrip(t *testing.T) {
+	keysDir := testKeysDir(t)
+
+	publicKey, err := LoadRsaPublicKey(filepath.Join(keysDir, "test_id_rsa.pub"))
+	if err != nil {
+		t.Fatalf("LoadRsaPublicKey: %v", err)
+	}
+	privateKey, err := LoadRsaPrivateKey(filepath.Join(keysDir, "test_id_rsa"))
+	if err != nil {
+		t.Fatalf("LoadRsaPrivateKey: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		encrypt StreamOptions
+		decrypt StreamOptions
+	}{
+		{
+			name:    "password",
+			encrypt: StreamOptions{Password: []byte("pass1234"), ChunkSize: 64},
+			decrypt: StreamOptions{Password: []byte("pass1234")},
+		},
+		{
+			name:    "rsa",
+			encrypt: StreamOptions{PublicKey: publicKey, ChunkSize: 64},
+			decrypt: StreamOptions{PrivateKey: privateKey},
+		},
+		{
+			name:    "rsa and password",
+			encrypt: StreamOptions{PublicKey: publicKey, Password: []byte("pass1234"), ChunkSize: 64},
+			decrypt: StreamOptions{PrivateKey: privateKey, Password: []byte("pass1234")},
+		},
+	}
+
+	for _, test := range tests {
+		for _, size := range []int{0, 1, 63, 64, 65, 128, 1000} {
+			plain := streamTestPlain(size)
+			encrypted := encryptStream(t, plain, test.encrypt)
+
+			decrypted, err := decryptStream(encrypted, test.decrypt)
+			if err != nil {
+				t.Fatalf("%s size %d: decrypt error: %v", test.name, size, err)
+			}
+
+			if !bytes.Equal(decrypted, plain) {
+				t.Fatalf("%s size %d: decrypted content differs", test.name, size)
+			}
+		}
+	}
+}
+
+func TestStreamWrongKey(t *testing.T) {
+	encrypted := encryptStream(t, []byte("secret"), StreamOptions{Password: []byte("pass1234")})
+
+	_, err := decryptStream(encrypted, StreamOptions{Password: []byte("wrong123")})
+	if !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("wrong password error = %v, want ErrAuthFailed", err)
+	}
+
+	_, err = decryptStream(encrypted, StreamOptions{})
+	if err == nil {
+		t.Fatalf("missing password should fail")
+	}
+}
+
+func TestStreamTruncation(t *testing.T) {
+	options := StreamOptions{Password: []byte("pass1234"), ChunkSize: 32, Iterations: 1}
+	encrypted := encryptStream(t, streamTestPlain(100), options)
+
+	for length := 0; length < len(encrypted); length++ {
+		_, err := decryptStream(encrypted[:length], options)
+		if err == nil {
+			t.Fatalf("truncated to %d of %d bytes: no error", length, len(encrypted))
+		}
+	}
+
+	// cut at a chunk boundary: header(30) + hmac(32) + 2 full chunks of 32+16
+	boundary := 30 + 32 + 2*(32+16)
+	_, err := decryptStream(encrypted[:boundary], options)
+	if !errors.Is(err, ErrTruncated) {
+		t.Fatalf("truncated at chunk boundary error = %v, want ErrTruncated", err)
+	}
+}
+
+func TestStreamTampering(t *testing.T) {
+	options := StreamOptions{Password: []byte("pass1234"), ChunkSize: 32, Iterations: 1}
+	encrypted := encryptStream(t, streamTestPlain(100), options)
+
+	for index := range encrypted {
+		if index >= 10 && index < 13 {
+			// high bytes of the iterations would only make the test slow
+			continue
+		}
+
+		tampered := bytes.Clone(encrypted)
+		tampered[index] ^= 0x01
+
+		_, err := decryptStream(tampered, options)
+		if err == nil {
+			t.Fatalf("flipped bit in byte %d: no error", index)
+		}
+	}
+
+	appended := append(bytes.Clone(encrypted), 0x00)
+	_, err := decryptStream(appended, options)
+	if err == nil {
+		t.Fatalf("appended data: no error")
+	}
+
+	// swap the first two chunks
+	start := 30 + 32
+	chunk := 32 + 16
+	swapped := bytes.Clone(encrypted)
+	copy(swapped[start:], encrypted[start+chunk:start+2*chunk])
+	copy(swapped[start+chunk:], encrypted[start:start+chunk])
+
+	_, err = decryptStream(swapped, options)
+	if !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("swapped chunks error = %v, want ErrAuthFailed", err)
+	}
+}
+
+func TestStreamShortReads(t *testing.T) {
+	options := StreamOptions{Password: []byte("pass1234"), ChunkSize: 32, Iterations: 1}
+	plain := streamTestPlain(200)
+	encrypted := encryptStream(t, plain, options)
+
+	readers := map[string]func(io.Reader) io.Reader{
+		"one byte": iotest.OneByteReader,
+		"half":     iotest.HalfReader,
+		"data err": iotest.DataErrReader,
+	}
+
+	for name, wrap := range readers {
+		reader, err := NewDecryptReader(wrap(bytes.NewReader(encrypted)), options)
+		if err != nil {
+			t.Fatalf("%s: NewDecryptReader error: %v", name, err)
+		}
+
+		decrypted, err := io.ReadAll(iotest.OneByteReader(reader))
+		if err != nil {
+			t.Fatalf("%s: read error: %v", name, err)
+		}
+
+		if !bytes.Equal(decrypted, plain) {
+			t.Fatalf("%s: decrypted content differs", name)
+		}
+	}
+
+	err := iotest.TestReader(mustDecryptReader(t, encrypted, options), plain)
+	if err != nil {
+		t.Fatalf("TestReader: %v", err)
+	}
+}
+
+func mustDecryptReader(t *testing.T, encrypted []byte, options StreamOptions) io.Reader {
+	t.Helper()
+
+	reader, err := NewDecryptReader(bytes.NewReader(encrypted), options)
+	if err != nil {
+		t.Fatalf("NewDecryptReader error: %v", err)
+	}
+
+	return reader
+}
+
+func TestStreamSmallWrites(t *testing.T) {
+	options := StreamOptions{Password: []byte("pass1234"), ChunkSize: 32, Iterations: 1}
+	plain := streamTestPlain(200)
+
+	for _, step := range []int{1, 7, 32, 33} {
+		var buffer bytes.Buffer
+		writer, err := NewEncryptWriter(&buffer, options)
+		if err != nil {
+			t.Fatalf("NewEncryptWriter error: %v", err)
+		}
+
+		for start := 0; start < len(plain); start += step {
+			end := min(start+step, len(plain))
+			n, err := writer.Write(plain[start:end])
+			if err != nil || n != end-start {
+				t.Fatalf("step %d: Write = %d, %v", step, n, err)
+			}
+		}
+
+		err = writer.Close()
+		if err != nil {
+			t.Fatalf("step %d: Close error: %v", step, err)
+		}
+
+		decrypted, err := decryptStream(buffer.Bytes(), options)
+		if err != nil || !bytes.Equal(decrypted, plain) {
+			t.Fatalf("step %d: decrypt = %v", step, err)
+		}
+	}
+}
+
+// shortWriter accepts only limit bytes in total, then writes less than requested.
+type shortWriter struct {
+	limit int
+}
+
+func (writer *shortWriter) Write(data []byte) (int, error) {
+	if len(data) > writer.limit {
+		n := writer.limit
+		writer.limit = 0
+		return n, nil
+	}
+
+	writer.limit -= len(data)
+	return len(data), nil
+}
+
+func TestStreamShortWrites(t *testing.T) {
+	options := StreamOptions{Password: []byte("pass1234"), ChunkSize: 32, Iterations: 1}
+
+	_, err := NewEncryptWriter(&shortWriter{limit: 10}, options)
+	if !errors.Is(err, io.ErrShortWrite) {
+		t.Fatalf("short header write error = %v, want io.ErrShortWrite", err)
+	}
+
+	for _, limit := range []int{62, 80, 110} {
+		writer, err := NewEncryptWriter(&shortWriter{limit: limit}, options)
+		if err != nil {
+			t.Fatalf("limit %d: NewEncryptWriter error: %v", limit, err)
+		}
+
+		_, err = writer.Write(streamTestPlain(100))
+		if err == nil {
+			err = writer.Close()
+		}
+
+		if !errors.Is(err, io.ErrShortWrite) {
+			t.Fatalf("limit %d: error = %v, want io.ErrShortWrite", limit, err)
+		}
+
+		_, err = writer.Write([]byte("more"))
+		if err == nil {
+			t.Fatalf("limit %d: write after error should fail", limit)
+		}
+	}
+}
+
+func TestStreamInvalidOptions(t *testing.T) {
+	tests := []StreamOptions{
+		{},
+		{Password: []byte("pass1234"), ChunkSize: -1},
+		{Password: []byte("pass1234"), ChunkSize: maxStreamChunkSize + 1},
+		{Password: []byte("pass1234"), Iterations: -1},
+	}
+
+	for index, options := range tests {
+		_, err := NewEncryptWriter(io.Discard, options)
+		if err == nil {
+			t.Fatalf("options %d: no error", index)
+		}
+	}
+
+	_, err := NewDecryptReader(bytes.NewReader([]byte("not a stream at all, really not")), StreamOptions{Password: []byte("x")})
+	if err == nil {
+		t.Fatalf("invalid stream: no error")
+	}
+}
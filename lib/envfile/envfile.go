@@ -0,0 +1,200 @@
+// Package envfile reads and writes single KEY=value entries of dotenv content
+// without parsing the whole file, so comments and formatting stay as they are.
+// Values are returned as new byte slices, so the caller can wipe them.
+package envfile
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/NobleMajo/vault/lib/secret"
+)
+
+// ErrInvalidKey is returned for keys that are not shell variable names.
+var ErrInvalidKey = errors.New("invalid key, use letters, digits and _ and do not start with a digit")
+
+// ValidKey reports if the key is a shell variable name like API_TOKEN.
+func ValidKey(key string) bool {
+	if len(key) == 0 {
+		return false
+	}
+
+	for index := 0; index < len(key); index++ {
+		char := key[index]
+		switch {
+		case char == '_', 'a' <= char && char <= 'z', 'A' <= char && char <= 'Z':
+		case '0' <= char && char <= '9' && index != 0:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// Keys returns the keys of all entries in the order of the content.
+func Keys(content []byte) []string {
+	var keys []string
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		if key, _, found := entry(line); found {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// Lookup returns the unquoted value of the last entry of the key.
+func Lookup(content []byte, key string) ([]byte, bool) {
+	var value []byte
+	found := false
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		if lineKey, rawValue, ok := entry(line); ok && lineKey == key {
+			secret.Wipe(value)
+			value = unquote(rawValue)
+			found = true
+		}
+	}
+
+	return value, found
+}
+
+// Set replaces the entries of the key or appends a new entry.
+// It returns the new content and if an entry was replaced.
+func Set(content []byte, key string, value []byte) ([]byte, bool, error) {
+	if !ValidKey(key) {
+		return nil, false, ErrInvalidKey
+	}
+
+	quoted := quote(value)
+	defer secret.Wipe(quoted)
+
+	newLine := make([]byte, 0, len(key)+1+len(quoted))
+	newLine = append(newLine, key...)
+	newLine = append(newLine, '=')
+	newLine = append(newLine, quoted...)
+	defer secret.Wipe(newLine)
+
+	// the first entry is replaced, duplicates are removed
+	var lines [][]byte
+	replaced := false
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		if lineKey, _, ok := entry(line); ok && lineKey == key {
+			if !replaced {
+				lines = append(lines, newLine)
+			}
+			replaced = true
+			continue
+		}
+		lines = append(lines, line)
+	}
+
+	if !replaced {
+		// the last line is empty if the content ends with a newline
+		if len(lines[len(lines)-1]) == 0 {
+			lines = lines[:len(lines)-1]
+		}
+		lines = append(lines, newLine, nil)
+	}
+
+	return bytes.Join(lines, []byte("\n")), replaced, nil
+}
+
+// entry parses a line like "export KEY = value # comment" into key and raw value.
+func entry(line []byte) (string, []byte, bool) {
+	line = bytes.TrimSpace(line)
+	line = bytes.TrimPrefix(line, []byte("export "))
+
+	separator := bytes.IndexByte(line, '=')
+	if separator <= 0 {
+		return "", nil, false
+	}
+
+	key := string(bytes.TrimSpace(line[:separator]))
+	if !ValidKey(key) {
+		return "", nil, false
+	}
+
+	return key, bytes.TrimSpace(line[separator+1:]), true
+}
+
+// unquote returns the value of single, double or unquoted values like godotenv.
+func unquote(raw []byte) []byte {
+	if len(raw) >= 2 && raw[0] == '\'' {
+		if end := bytes.IndexByte(raw[1:], '\''); end >= 0 {
+			return bytes.Clone(raw[1 : end+1])
+		}
+	}
+
+	if len(raw) >= 2 && raw[0] == '"' {
+		value := make([]byte, 0, len(raw))
+		for index := 1; index < len(raw); index++ {
+			char := raw[index]
+			if char == '"' {
+				return value
+			}
+			if char == '\\' && index+1 < len(raw) {
+				index++
+				switch raw[index] {
+				case 'n':
+					char = '\n'
+				case 'r':
+					char = '\r'
+				case 't':
+					char = '\t'
+				default:
+					char = raw[index]
+				}
+			}
+			value = append(value, char)
+		}
+		secret.Wipe(value)
+	}
+
+	// unquoted values end at a comment
+	if comment := bytes.Index(raw, []byte(" #")); comment >= 0 {
+		raw = bytes.TrimSpace(raw[:comment])
+	}
+
+	return bytes.Clone(raw)
+}
+
+// quote writes values with special characters in quotes, single quotes if possible,
+// because godotenv expands variables in double quotes.
+func quote(value []byte) []byte {
+	safe := len(value) != 0
+	for _, char := range value {
+		if char <= ' ' || char > '~' || bytes.IndexByte([]byte("#$'\"\\`"), char) >= 0 {
+			safe = false
+			break
+		}
+	}
+
+	if safe {
+		return bytes.Clone(value)
+	}
+
+	if bytes.IndexByte(value, '\'') < 0 && bytes.IndexByte(value, '\n') < 0 {
+		quoted := make([]byte, 0, len(value)+2)
+		quoted = append(quoted, '\'')
+		quoted = append(quoted, value...)
+		return append(quoted, '\'')
+	}
+
+	quoted := make([]byte, 0, 2*len(value)+2)
+	quoted = append(quoted, '"')
+	for _, char := range value {
+		switch char {
+		case '\n':
+			quoted = append(quoted, '\\', 'n')
+		case '\r':
+			quoted = append(quoted, '\\', 'r')
+		case '"', '\\', '$', '`':
+			quoted = append(quoted, '\\', char)
+		default:
+			quoted = append(quoted, char)
+		}
+	}
+
+	return append(quoted, '"')
+}
@@ -0,0 +1,73 @@
+package envfile
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const content = `# database
+export DB_USER=admin
+DB_PASSWORD = 'se#cret' # comment
+API_TOKEN="a\"b\\c\nd"
+PLAIN=value # comment
+`
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+		found bool
+	}{
+		{"DB_USER", "admin", true},
+		{"DB_PASSWORD", "se#cret", true},
+		{"API_TOKEN", "a\"b\\c\nd", true},
+		{"PLAIN", "value", true},
+		{"MISSING", "", false},
+	}
+
+	for _, test := range tests {
+		value, found := Lookup([]byte(content), test.key)
+		if found != test.found || string(value) != test.value {
+			t.Fatalf("Lookup(%s) = %q, %v, want %q, %v", test.key, value, found, test.value, test.found)
+		}
+	}
+
+	if keys := strings.Join(Keys([]byte(content)), ","); keys != "DB_USER,DB_PASSWORD,API_TOKEN,PLAIN" {
+		t.Fatalf("Keys() = %s", keys)
+	}
+}
+
+func TestSet(t *testing.T) {
+	tests := []struct {
+		content  string
+		key      string
+		value    string
+		want     string
+		replaced bool
+	}{
+		{"", "KEY", "abc", "KEY=abc\n", false},
+		{"A=1", "KEY", "abc", "A=1\nKEY=abc\n", false},
+		{"A=1\nKEY=old\nB=2\nKEY=dup\n", "KEY", "new", "A=1\nKEY=new\nB=2\n", true},
+		{"", "KEY", "a b#$", "KEY='a b#$'\n", false},
+		{"", "KEY", "it's", "KEY=\"it's\"\n", false},
+		{"", "KEY", "a\"$\n", "KEY=\"a\\\"\\$\\n\"\n", false},
+	}
+
+	for _, test := range tests {
+		result, replaced, err := Set([]byte(test.content), test.key, []byte(test.value))
+		if err != nil || string(result) != test.want || replaced != test.replaced {
+			t.Fatalf("Set(%q, %s, %q) = %q, %v, %v, want %q", test.content, test.key, test.value, result, replaced, err, test.want)
+		}
+
+		// every written value reads back unchanged
+		value, found := Lookup(result, test.key)
+		if !found || string(value) != test.value {
+			t.Fatalf("Lookup after Set(%q) = %q", test.value, value)
+		}
+	}
+
+	if _, _, err := Set(nil, "1KEY", []byte("x")); !errors.Is(err, ErrInvalidKey) {
+		t.Fatalf("Set() with invalid key error = %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package filelock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrLocked is returned if the lock is held by another process
+// and waiting is disabled or timed out.
+var ErrLocked = errors.New("file is locked by another process")
+
+// pollInterval is the delay between two lock attempts while waiting.
+const pollInterval = 100 * time.Millisecond
+
+// Lock is an advisory lock for a file. It is held on a separate lock file
+// next to the file, because vault files get replaced by rename on write.
+type Lock struct {
+	file *os.File
+	path string
+}
+
+// LockPath returns the lock file path for a file, e.g. ".secret.vt.lock".
+func LockPath(path string) string {
+	dir, file := filepath.Split(path)
+
+	return dir + "." + file + ".lock"
+}
+
+// Acquire locks the given file.
+// If wait is false, ErrLocked is returned immediately if the lock is held by another process.
+// If wait is true, it retries until the lock is free or the timeout is reached.
+// A timeout of 0 waits forever.
+func Acquire(path string, wait bool, timeout time.Duration) (*Lock, error) {
+	lockPath := LockPath(path)
+	deadline := time.Now().Add(timeout)
+
+	for {
+		lock, err := tryAcquire(lockPath)
+		if err == nil {
+			return lock, nil
+		}
+
+		if !errors.Is(err, ErrLocked) {
+			return nil, err
+		}
+
+		if !wait || (timeout > 0 && time.Now().After(deadline)) {
+			return nil, ErrLocked
+		}
+
+		time.Sleep(pollInterval)
+	}
+}
+
+func tryAcquire(lockPath string) (*Lock, error) {
+	file, err := os.OpenFile(lockPath, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.New("open lock file error:\n> " + err.Error())
+	}
+
+	err = lockFile(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// the previous holder may have removed the lock file while we waited,
+	// then our lock is on an orphaned file and we need to try again
+	if !sameFile(file, lockPath) {
+		unlockFile(file)
+		file.Close()
+		return nil, ErrLocked
+	}
+
+	return &Lock{
+		file: file,
+		path: lockPath,
+	}, nil
+}
+
+func sameFile(file *os.File, path string) bool {
+	fileStat, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	pathStat, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(fileStat, pathStat)
+}
+
+// Unlock releases the lock and removes the lock file.
+// It is safe to call Unlock more than once.
+func (lock *Lock) Unlock() error {
+	if lock == nil || lock.file == nil {
+		return nil
+	}
+
+	file := lock.file
+	lock.file = nil
+
+	// remove while still locked, so nobody can lock the file that is about to vanish
+	removeErr := removeLockFile(lock.path)
+	unlockErr := unlockFile(file)
+	closeErr := file.Close()
+	removeErr = errors.Join(removeErr, removeLockFileAfterClose(lock.path))
+
+	return errors.Join(unlockErr, closeErr, removeErr)
+}
@@ -0,0 +1,85 @@
+package filelock
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquireUnlock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.vt")
+
+	lock, err := Acquire(path, false, 0)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	if _, err := os.Stat(LockPath(path)); err != nil {
+		t.Fatalf("expected lock file, got %v", err)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("second Unlock: %v", err)
+	}
+
+	if _, err := os.Stat(LockPath(path)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected lock file removed, got %v", err)
+	}
+}
+
+func TestAcquireFailFast(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.vt")
+
+	lock, err := Acquire(path, false, 0)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	defer lock.Unlock()
+
+	if _, err := Acquire(path, false, 0); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+}
+
+func TestAcquireWaitTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.vt")
+
+	lock, err := Acquire(path, false, 0)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	defer lock.Unlock()
+
+	start := time.Now()
+	if _, err := Acquire(path, true, 300*time.Millisecond); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked after timeout, got %v", err)
+	}
+	if time.Since(start) < 300*time.Millisecond {
+		t.Fatal("expected Acquire to wait for the timeout")
+	}
+}
+
+func TestAcquireWaitsForRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.vt")
+
+	lock, err := Acquire(path, false, 0)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		lock.Unlock()
+	}()
+
+	second, err := Acquire(path, true, 5*time.Second)
+	if err != nil {
+		t.Fatalf("expected lock after release, got %v", err)
+	}
+	second.Unlock()
+}
@@ -0,0 +1,191 @@
+// Package generate creates random secrets like passwords, passphrases and tokens.
+// All randomness comes from crypto/rand and is sampled without modulo bias.
+// The results are byte slices, so the caller can wipe them after use.
+package generate
+
+import (
+	_ "embed"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/NobleMajo/vault/lib/cryption"
+	"github.com/NobleMajo/vault/lib/secret"
+)
+
+// effLargeWordlist is the EFF large wordlist for five dice, 7776 words.
+// https://www.eff.org/deeplinks/2016/07/new-wordlists-random-passphrases (CC BY 3.0 US)
+//
+//go:embed eff_large_wordlist.txt
+var effLargeWordlist string
+
+// Charsets are the named character sets of ResolveCharset.
+var Charsets = map[string]string{
+	"lower":  "abcdefghijklmnopqrstuvwxyz",
+	"upper":  "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"digit":  "0123456789",
+	"alpha":  "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"alnum":  "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
+	"hex":    "0123456789abcdef",
+	"symbol": "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789!%&()*+,-./:;<=>?@[]^_{|}~",
+}
+
+// ResolveCharset returns the characters of a named set or the unique
+// characters of the value itself. Only printable ascii characters are allowed.
+func ResolveCharset(charset string) (string, error) {
+	if named, ok := Charsets[charset]; ok {
+		return named, nil
+	}
+
+	var unique []byte
+	for index := 0; index < len(charset); index++ {
+		char := charset[index]
+		if char <= ' ' || char > '~' {
+			return "", errors.New("charset contains a character that is not printable ascii")
+		}
+		if strings.IndexByte(string(unique), char) < 0 {
+			unique = append(unique, char)
+		}
+	}
+
+	if len(unique) < 2 {
+		return "", errors.New("charset needs at least 2 different characters")
+	}
+
+	return string(unique), nil
+}
+
+// Uniform returns a random number from 0 to n-1. Values of the upper
+// incomplete range are rejected, so every number is equally likely.
+func Uniform(n int) (int, error) {
+	if n <= 0 || uint64(n) > math.MaxUint32 {
+		return 0, errors.New("uniform range out of bounds")
+	}
+
+	limit := math.MaxUint32 - (math.MaxUint32%uint64(n)+1)%uint64(n)
+	for {
+		random, err := cryption.RandomByteArray(4)
+		if err != nil {
+			return 0, err
+		}
+
+		value := uint64(binary.BigEndian.Uint32(random))
+		secret.Wipe(random)
+
+		if value <= limit {
+			return int(value % uint64(n)), nil
+		}
+	}
+}
+
+// Charset returns length random characters of the charset.
+func Charset(length int, charset string) ([]byte, error) {
+	if length <= 0 {
+		return nil, errors.New("length must be positive")
+	}
+
+	result := make([]byte, length)
+	for index := range result {
+		position, err := Uniform(len(charset))
+		if err != nil {
+			secret.Wipe(result)
+			return nil, err
+		}
+		result[index] = charset[position]
+	}
+
+	return result, nil
+}
+
+// CharsetBits is the entropy of a random string of the charset.
+func CharsetBits(length int, charset string) float64 {
+	return float64(length) * math.Log2(float64(len(charset)))
+}
+
+var wordlist = sync.OnceValue(func() []string {
+	var words []string
+	for _, line := range strings.Split(effLargeWordlist, "\n") {
+		// lines are "<dice rolls>\t<word>"
+		if _, word, found := strings.Cut(line, "\t"); found {
+			words = append(words, strings.TrimSpace(word))
+		}
+	}
+
+	return words
+})
+
+// Words returns a diceware passphrase of count words of the EFF large wordlist.
+func Words(count int, separator string) ([]byte, error) {
+	if count <= 0 {
+		return nil, errors.New("word count must be positive")
+	}
+
+	words := wordlist()
+
+	var result []byte
+	for index := 0; index < count; index++ {
+		position, err := Uniform(len(words))
+		if err != nil {
+			secret.Wipe(result)
+			return nil, err
+		}
+
+		if index != 0 {
+			result = appendWiped(result, separator)
+		}
+		result = appendWiped(result, words[position])
+	}
+
+	return result, nil
+}
+
+// appendWiped appends like append, but wipes the old backing array if it had to grow.
+func appendWiped(result []byte, text string) []byte {
+	if len(result)+len(text) <= cap(result) {
+		return append(result, text...)
+	}
+
+	grown := make([]byte, len(result), 2*(len(result)+len(text)))
+	copy(grown, result)
+	secret.Wipe(result)
+
+	return append(grown, text...)
+}
+
+// WordsBits is the entropy of a passphrase of count words.
+func WordsBits(count int) float64 {
+	return float64(count) * math.Log2(float64(len(wordlist())))
+}
+
+// Hex returns size random bytes as hex text.
+func Hex(size int) ([]byte, error) {
+	return encoded(size, hex.AppendEncode)
+}
+
+// Base64 returns size random bytes as standard base64 text.
+func Base64(size int) ([]byte, error) {
+	return encoded(size, base64.StdEncoding.AppendEncode)
+}
+
+func encoded(size int, appendEncode func(dst []byte, src []byte) []byte) ([]byte, error) {
+	if size <= 0 {
+		return nil, errors.New("length must be positive")
+	}
+
+	random, err := cryption.RandomByteArray(size)
+	if err != nil {
+		return nil, err
+	}
+	defer secret.Wipe(random)
+
+	return appendEncode(nil, random), nil
+}
+
+// BytesBits is the entropy of size random bytes.
+func BytesBits(size int) float64 {
+	return float64(size) * 8
+}
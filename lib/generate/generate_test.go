@@ -0,0 +1,117 @@
+package generate
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestResolveCharset(t *testing.T) {
+	tests := []struct {
+		charset string
+		want    string
+		fails   bool
+	}{
+		{charset: "digit", want: "0123456789"},
+		{charset: "aabbc", want: "abc"},
+		{charset: "a", fails: true},
+		{charset: "ab c", fails: true},
+		{charset: "abü", fails: true},
+	}
+
+	for _, test := range tests {
+		resolved, err := ResolveCharset(test.charset)
+		if test.fails {
+			if err == nil {
+				t.Fatalf("ResolveCharset(%q) succeeded", test.charset)
+			}
+			continue
+		}
+
+		if err != nil || resolved != test.want {
+			t.Fatalf("ResolveCharset(%q) = %q, %v, want %q", test.charset, resolved, err, test.want)
+		}
+	}
+}
+
+func TestUniform(t *testing.T) {
+	counts := make([]int, 7)
+	for index := 0; index < 7000; index++ {
+		value, err := Uniform(len(counts))
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[value]++
+	}
+
+	for value, count := range counts {
+		// 1000 expected, a fair sampler is far inside these bounds
+		if count < 800 || count > 1200 {
+			t.Fatalf("value %d drawn %d times of 7000: %v", value, count, counts)
+		}
+	}
+
+	if _, err := Uniform(0); err == nil {
+		t.Fatal("Uniform(0) succeeded")
+	}
+}
+
+func TestCharset(t *testing.T) {
+	result, err := Charset(64, Charsets["hex"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result) != 64 || strings.Trim(string(result), Charsets["hex"]) != "" {
+		t.Fatalf("Charset() = %q", result)
+	}
+
+	if bits := CharsetBits(32, Charsets["hex"]); bits != 128 {
+		t.Fatalf("CharsetBits() = %f, want 128", bits)
+	}
+}
+
+func TestWords(t *testing.T) {
+	if len(wordlist()) != 7776 {
+		t.Fatalf("wordlist has %d words, want 7776", len(wordlist()))
+	}
+
+	result, err := Words(6, " ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	words := strings.Split(string(result), " ")
+	if len(words) != 6 {
+		t.Fatalf("Words(6) = %q", result)
+	}
+	for _, word := range words {
+		if !slices.Contains(wordlist(), word) {
+			t.Fatalf("Words(6) word %q is not in the wordlist", word)
+		}
+	}
+}
+
+func TestHexBase64(t *testing.T) {
+	hexResult, err := Hex(16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err := hex.DecodeString(string(hexResult)); err != nil || len(decoded) != 16 {
+		t.Fatalf("Hex(16) = %q", hexResult)
+	}
+
+	base64Result, err := Base64(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, err := base64.StdEncoding.DecodeString(string(base64Result)); err != nil || len(decoded) != 32 {
+		t.Fatalf("Base64(32) = %q", base64Result)
+	}
+
+	if _, err := Hex(0); err == nil {
+		t.Fatal("Hex(0) succeeded")
+	}
+}
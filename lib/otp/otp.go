@@ -0,0 +1,275 @@
+// Package otp computes one-time passwords of RFC 4226 (HOTP) and RFC 6238 (TOTP)
+// and parses otpauth:// URIs of authenticator apps.
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/NobleMajo/vault/lib/secret"
+)
+
+// Types of a Key.
+const (
+	TypeTOTP = "totp"
+	TypeHOTP = "hotp"
+)
+
+// Defaults of authenticator apps for missing parameters.
+const (
+	DefaultAlgorithm = "SHA1"
+	DefaultDigits    = 6
+	DefaultPeriod    = 30
+)
+
+// ErrInvalidKey is returned for seeds and URIs that can not be used.
+var ErrInvalidKey = errors.New("invalid otp key")
+
+// Key is the seed and the parameters of a one-time password.
+type Key struct {
+	Type      string
+	Secret    []byte
+	Algorithm string
+	Digits    int
+	// Period is the lifetime of a TOTP code in seconds.
+	Period int
+	// Counter is the next HOTP counter.
+	Counter uint64
+	Issuer  string
+	Account string
+}
+
+// Wipe zeroes the secret of the key.
+func (key *Key) Wipe() {
+	secret.Wipe(key.Secret)
+}
+
+func invalid(message string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidKey, message)
+}
+
+// Parse reads an otpauth:// URI or a base32 seed, seeds are TOTP keys with the defaults.
+func Parse(text string) (*Key, error) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(strings.ToLower(text), "otpauth://") {
+		return ParseURI(text)
+	}
+
+	seed, err := DecodeSecret(text)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		Type:      TypeTOTP,
+		Secret:    seed,
+		Algorithm: DefaultAlgorithm,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+	}, nil
+}
+
+// ParseURI reads a URI like otpauth://totp/Issuer:account?secret=BASE32&issuer=Issuer&digits=6.
+func ParseURI(uri string) (*Key, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return nil, invalid("malformed uri")
+	}
+
+	if !strings.EqualFold(parsed.Scheme, "otpauth") {
+		return nil, invalid("uri scheme is not otpauth")
+	}
+
+	key := &Key{
+		Type:      strings.ToLower(parsed.Host),
+		Algorithm: DefaultAlgorithm,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+	}
+	if key.Type != TypeTOTP && key.Type != TypeHOTP {
+		return nil, invalid("unknown type '" + parsed.Host + "', use totp or hotp")
+	}
+
+	label := strings.TrimPrefix(parsed.Path, "/")
+	if issuer, account, found := strings.Cut(label, ":"); found {
+		key.Issuer, key.Account = strings.TrimSpace(issuer), strings.TrimSpace(account)
+	} else {
+		key.Account = label
+	}
+
+	query := parsed.Query()
+	if issuer := query.Get("issuer"); len(issuer) != 0 {
+		key.Issuer = issuer
+	}
+
+	if algorithm := query.Get("algorithm"); len(algorithm) != 0 {
+		key.Algorithm = strings.ToUpper(algorithm)
+	}
+	if digits := query.Get("digits"); len(digits) != 0 {
+		key.Digits, err = strconv.Atoi(digits)
+		if err != nil {
+			return nil, invalid("digits is not a number")
+		}
+	}
+	if period := query.Get("period"); len(period) != 0 {
+		key.Period, err = strconv.Atoi(period)
+		if err != nil {
+			return nil, invalid("period is not a number")
+		}
+	}
+	if counter := query.Get("counter"); len(counter) != 0 {
+		key.Counter, err = strconv.ParseUint(counter, 10, 64)
+		if err != nil {
+			return nil, invalid("counter is not a number")
+		}
+	} else if key.Type == TypeHOTP {
+		return nil, invalid("hotp uri without counter")
+	}
+
+	key.Secret, err = DecodeSecret(query.Get("secret"))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := key.Validate(); err != nil {
+		key.Wipe()
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// DecodeSecret decodes a base32 seed, spaces, lowercase and missing padding are accepted.
+func DecodeSecret(text string) ([]byte, error) {
+	text = strings.ToUpper(strings.ReplaceAll(strings.ReplaceAll(text, " ", ""), "-", ""))
+	text = strings.TrimRight(text, "=")
+	if len(text) == 0 {
+		return nil, invalid("empty secret")
+	}
+
+	seed, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(text)
+	if err != nil {
+		return nil, invalid("secret is not base32")
+	}
+
+	return seed, nil
+}
+
+// Validate checks the algorithm, digits and period.
+func (key *Key) Validate() error {
+	if _, err := hashOf(key.Algorithm); err != nil {
+		return err
+	}
+
+	if key.Digits < 6 || key.Digits > 8 {
+		return invalid("digits must be 6 to 8, got " + strconv.Itoa(key.Digits))
+	}
+
+	if key.Period <= 0 {
+		return invalid("period must be positive")
+	}
+
+	if len(key.Secret) == 0 {
+		return invalid("empty secret")
+	}
+
+	return nil
+}
+
+// URI returns the otpauth:// URI of the key.
+func (key *Key) URI() string {
+	label := key.Account
+	if len(key.Issuer) != 0 {
+		label = key.Issuer + ":" + key.Account
+	}
+
+	query := url.Values{}
+	query.Set("secret", base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(key.Secret))
+	if len(key.Issuer) != 0 {
+		query.Set("issuer", key.Issuer)
+	}
+	query.Set("algorithm", key.Algorithm)
+	query.Set("digits", strconv.Itoa(key.Digits))
+	if key.Type == TypeHOTP {
+		query.Set("counter", strconv.FormatUint(key.Counter, 10))
+	} else {
+		query.Set("period", strconv.Itoa(key.Period))
+	}
+
+	return (&url.URL{
+		Scheme:   "otpauth",
+		Host:     key.Type,
+		Path:     "/" + label,
+		RawQuery: query.Encode(),
+	}).String()
+}
+
+func hashOf(algorithm string) (func() hash.Hash, error) {
+	switch strings.ToUpper(algorithm) {
+	case "SHA1":
+		return sha1.New, nil
+	case "SHA256":
+		return sha256.New, nil
+	case "SHA512":
+		return sha512.New, nil
+	}
+
+	return nil, invalid("unknown algorithm '" + algorithm + "', use SHA1, SHA256 or SHA512")
+}
+
+// HOTP returns the code of the counter like RFC 4226 with dynamic truncation.
+func HOTP(seed []byte, counter uint64, digits int, algorithm string) (string, error) {
+	newHash, err := hashOf(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, counter)
+
+	mac := hmac.New(newHash, seed)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+	defer secret.Wipe(sum)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for index := 0; index < digits; index++ {
+		modulo *= 10
+	}
+
+	code := strconv.FormatUint(uint64(value%modulo), 10)
+	return strings.Repeat("0", digits-len(code)) + code, nil
+}
+
+// TOTP returns the code of the time like RFC 6238 and how long it stays valid.
+func TOTP(key *Key, at time.Time) (string, time.Duration, error) {
+	if key.Period <= 0 {
+		return "", 0, invalid("period must be positive")
+	}
+
+	period := int64(key.Period)
+	unix := at.Unix()
+	counter := unix / period
+
+	code, err := HOTP(key.Secret, uint64(counter), key.Digits, key.Algorithm)
+	if err != nil {
+		return "", 0, err
+	}
+
+	next := time.Unix((counter+1)*period, 0)
+	return code, next.Sub(at), nil
+}
@@ -0,0 +1,105 @@
+package otp
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHOTP(t *testing.T) {
+	// RFC 4226 appendix D
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	for counter, code := range want {
+		got, err := HOTP([]byte("12345678901234567890"), uint64(counter), 6, "SHA1")
+		if err != nil || got != code {
+			t.Fatalf("HOTP(%d) = %s, %v, want %s", counter, got, err, code)
+		}
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238 appendix B
+	seeds := map[string][]byte{
+		"SHA1":   []byte("12345678901234567890"),
+		"SHA256": []byte("12345678901234567890123456789012"),
+		"SHA512": []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+
+	tests := []struct {
+		unix      int64
+		algorithm string
+		code      string
+	}{
+		{59, "SHA1", "94287082"},
+		{59, "SHA256", "46119246"},
+		{59, "SHA512", "90693936"},
+		{1111111109, "SHA1", "07081804"},
+		{1111111111, "SHA256", "67062674"},
+		{1234567890, "SHA512", "93441116"},
+		{2000000000, "SHA1", "69279037"},
+		{20000000000, "SHA256", "77737706"},
+	}
+
+	for _, test := range tests {
+		key := &Key{Type: TypeTOTP, Secret: seeds[test.algorithm], Algorithm: test.algorithm, Digits: 8, Period: 30}
+		code, remaining, err := TOTP(key, time.Unix(test.unix, 0))
+		if err != nil || code != test.code {
+			t.Fatalf("TOTP(%d, %s) = %s, %v, want %s", test.unix, test.algorithm, code, err, test.code)
+		}
+
+		if want := time.Duration(30-test.unix%30) * time.Second; remaining != want {
+			t.Fatalf("TOTP(%d) remaining = %s, want %s", test.unix, remaining, want)
+		}
+	}
+}
+
+func TestParseURI(t *testing.T) {
+	key, err := Parse("otpauth://totp/ACME%20Co:john@example.com?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=ACME+Co&algorithm=sha256&digits=8&period=60")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key.Type != TypeTOTP || key.Issuer != "ACME Co" || key.Account != "john@example.com" ||
+		key.Algorithm != "SHA256" || key.Digits != 8 || key.Period != 60 || string(key.Secret) != "12345678901234567890" {
+		t.Fatalf("Parse() = %+v", key)
+	}
+
+	// the generated uri reads back the same key
+	again, err := ParseURI(key.URI())
+	if err != nil || again.Issuer != key.Issuer || again.Account != key.Account || again.Period != 60 ||
+		string(again.Secret) != string(key.Secret) {
+		t.Fatalf("ParseURI(URI()) = %+v, %v", again, err)
+	}
+
+	hotp, err := Parse("otpauth://hotp/account?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&counter=7")
+	if err != nil || hotp.Type != TypeHOTP || hotp.Counter != 7 {
+		t.Fatalf("Parse(hotp) = %+v, %v", hotp, err)
+	}
+}
+
+func TestParseSeed(t *testing.T) {
+	key, err := Parse("gezd gnbv gy3t qojq gezd gnbv gy3t qojq")
+	if err != nil || string(key.Secret) != "12345678901234567890" || key.Digits != DefaultDigits || key.Period != DefaultPeriod {
+		t.Fatalf("Parse(seed) = %+v, %v", key, err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	invalid := []string{
+		"",
+		"not base32!",
+		"https://example.com/?secret=GEZDGNBV",
+		"otpauth://sms/account?secret=GEZDGNBV",
+		"otpauth://totp/account",
+		"otpauth://totp/account?secret=GEZDGNBV&digits=10",
+		"otpauth://totp/account?secret=GEZDGNBV&algorithm=MD5",
+		"otpauth://hotp/account?secret=GEZDGNBV",
+	}
+
+	for _, text := range invalid {
+		if _, err := Parse(text); !errors.Is(err, ErrInvalidKey) {
+			t.Fatalf("Parse(%q) error = %v, want ErrInvalidKey", text, err)
+		}
+	}
+}
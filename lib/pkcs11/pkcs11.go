@@ -0,0 +1,151 @@
+// Package pkcs11 uses rsa private keys that never leave a PKCS#11 token,
+// like a HSM, a smart card or SoftHSM2. Keys are selected with pkcs11: URIs (RFC 7512).
+//
+// The token decrypts with CKM_RSA_PKCS, the returned Key is a crypto.Decrypter
+// for cryption.X509ChunkDecrypt. Without cgo Open always fails.
+package pkcs11
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/NobleMajo/vault/lib/secret"
+)
+
+var (
+	// ErrNoModule is returned if no PKCS#11 module is found or it can not be loaded.
+	ErrNoModule = errors.New("no pkcs11 module")
+	// ErrNoToken is returned if no token matches the uri.
+	ErrNoToken = errors.New("no pkcs11 token matches the uri")
+	// ErrNoKey is returned if no or several rsa private keys on the token match the uri.
+	ErrNoKey = errors.New("no pkcs11 key matches the uri")
+	// ErrPINIncorrect is returned if the token rejects the PIN.
+	ErrPINIncorrect = errors.New("pkcs11 pin incorrect")
+)
+
+// Token describes a token in a slot of the module.
+type Token struct {
+	SlotID           uint
+	SlotDescription  string
+	SlotManufacturer string
+	Label            string
+	Manufacturer     string
+	Model            string
+	Serial           string
+}
+
+// Library describes the loaded module.
+type Library struct {
+	Manufacturer string
+	Description  string
+}
+
+// Options configure how a token key is opened.
+// Nil callbacks fail if they are needed.
+type Options struct {
+	// ModulePath is used if the uri has no module-path or module-name,
+	// without both the DefaultModules are searched.
+	ModulePath string
+
+	// PIN asks for the user PIN if the uri has no pin-value or pin-source
+	// and the token needs a login. The returned bytes are wiped after the login.
+	PIN func(token Token) ([]byte, error)
+
+	// SelectToken chooses one of several tokens that match the uri, it returns the index.
+	SelectToken func(tokens []Token) (int, error)
+}
+
+// DefaultModules are searched if neither the uri nor the options define a module.
+var DefaultModules = []string{
+	"/usr/lib/softhsm/libsofthsm2.so",
+	"/usr/lib/x86_64-linux-gnu/softhsm/libsofthsm2.so",
+	"/usr/lib/aarch64-linux-gnu/softhsm/libsofthsm2.so",
+	"/usr/lib64/pkcs11/libsofthsm2.so",
+	"/usr/local/lib/softhsm/libsofthsm2.so",
+	"/opt/homebrew/lib/softhsm/libsofthsm2.so",
+	"/usr/lib/x86_64-linux-gnu/opensc-pkcs11.so",
+	"/usr/lib/opensc-pkcs11.so",
+	"/usr/lib64/opensc-pkcs11.so",
+	"/opt/homebrew/lib/opensc-pkcs11.so",
+}
+
+// moduleDirs are searched for the module-name of an uri.
+var moduleDirs = []string{
+	"/usr/lib/pkcs11",
+	"/usr/lib64/pkcs11",
+	"/usr/lib/x86_64-linux-gnu/pkcs11",
+	"/usr/lib/aarch64-linux-gnu/pkcs11",
+	"/usr/lib",
+	"/usr/lib64",
+	"/usr/lib/x86_64-linux-gnu",
+	"/usr/lib/aarch64-linux-gnu",
+	"/usr/local/lib",
+	"/opt/homebrew/lib",
+}
+
+// IsURI reports if the key path is a pkcs11: URI instead of a file path.
+func IsURI(path string) bool {
+	return strings.HasPrefix(strings.TrimSpace(path), "pkcs11:")
+}
+
+// modulePath resolves the module of the uri and the options.
+func modulePath(uri *URI, options Options) (string, error) {
+	if len(uri.ModulePath) != 0 {
+		return uri.ModulePath, nil
+	}
+
+	if len(uri.ModuleName) != 0 {
+		for _, dir := range moduleDirs {
+			for _, name := range []string{uri.ModuleName, uri.ModuleName + ".so", "lib" + uri.ModuleName + ".so"} {
+				path := filepath.Join(dir, name)
+				if fileExists(path) {
+					return path, nil
+				}
+			}
+		}
+
+		return "", fmt.Errorf("%w '%s' found, use module-path", ErrNoModule, uri.ModuleName)
+	}
+
+	if len(options.ModulePath) != 0 {
+		return options.ModulePath, nil
+	}
+
+	for _, path := range DefaultModules {
+		if fileExists(path) {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w found, add module-path to the uri", ErrNoModule)
+}
+
+// pin returns the PIN of the uri or nil if it has none.
+func (uri *URI) pin() ([]byte, error) {
+	if len(uri.PINValue) != 0 {
+		return []byte(uri.PINValue), nil
+	}
+
+	if len(uri.PINSource) == 0 {
+		return nil, nil
+	}
+
+	path := strings.TrimPrefix(uri.PINSource, "file:")
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.New("read pin-source error:\n> " + err.Error())
+	}
+
+	pin := []byte(strings.TrimRight(string(content), "\r\n"))
+	secret.Wipe(content)
+
+	return pin, nil
+}
+
+func fileExists(path string) bool {
+	stat, err := os.Stat(path)
+	return err == nil && !stat.IsDir()
+}
@@ -0,0 +1,323 @@
+//go:build cgo
+
+package pkcs11
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"sync"
+
+	"github.com/NobleMajo/vault/lib/secret"
+	"github.com/miekg/pkcs11"
+)
+
+// Key is a rsa private key on a token, it has to be closed after use.
+type Key struct {
+	mutex     sync.Mutex
+	ctx       *pkcs11.Ctx
+	session   pkcs11.SessionHandle
+	handle    pkcs11.ObjectHandle
+	publicKey *rsa.PublicKey
+	Token     Token
+}
+
+// Open loads the module of the uri, logs into the matching token
+// and finds its rsa private key.
+func Open(rawURI string, options Options) (*Key, error) {
+	uri, err := ParseURI(rawURI)
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := modulePath(uri, options)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := pkcs11.New(path)
+	if ctx == nil {
+		return nil, fmt.Errorf("%w, can not load '%s'", ErrNoModule, path)
+	}
+
+	if err := ctx.Initialize(); err != nil {
+		ctx.Destroy()
+		return nil, errors.New("initialize pkcs11 module '" + path + "' error:\n> " + err.Error())
+	}
+
+	key := &Key{ctx: ctx}
+	if err := key.open(uri, options); err != nil {
+		key.Close()
+		return nil, err
+	}
+
+	return key, nil
+}
+
+func (key *Key) open(uri *URI, options Options) error {
+	token, err := selectToken(key.ctx, uri, options)
+	if err != nil {
+		return err
+	}
+	key.Token = token
+
+	key.session, err = key.ctx.OpenSession(token.SlotID, pkcs11.CKF_SERIAL_SESSION)
+	if err != nil {
+		return errors.New("open pkcs11 session error:\n> " + err.Error())
+	}
+
+	if err := login(key.ctx, key.session, token, uri, options); err != nil {
+		return err
+	}
+
+	key.handle, err = findPrivateKey(key.ctx, key.session, uri, token)
+	if err != nil {
+		return err
+	}
+
+	key.publicKey, err = readPublicKey(key.ctx, key.session, key.handle)
+	return err
+}
+
+// Public returns the rsa public key of the token key.
+func (key *Key) Public() crypto.PublicKey {
+	return key.publicKey
+}
+
+// Decrypt decrypts a PKCS #1 v1.5 ciphertext on the token, other options are not supported.
+func (key *Key) Decrypt(_ io.Reader, ciphertext []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	if pkcs1Options, ok := opts.(*rsa.PKCS1v15DecryptOptions); opts != nil && (!ok || pkcs1Options.SessionKeyLen != 0) {
+		return nil, errors.New("pkcs11 key only supports PKCS #1 v1.5 decryption")
+	}
+
+	key.mutex.Lock()
+	defer key.mutex.Unlock()
+
+	if key.ctx == nil {
+		return nil, errors.New("pkcs11 key is closed")
+	}
+
+	mechanism := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)}
+	if err := key.ctx.DecryptInit(key.session, mechanism, key.handle); err != nil {
+		return nil, errors.New("pkcs11 decrypt error:\n> " + err.Error())
+	}
+
+	plain, err := key.ctx.Decrypt(key.session, ciphertext)
+	if err != nil {
+		return nil, errors.New("pkcs11 decrypt error:\n> " + err.Error())
+	}
+
+	return plain, nil
+}
+
+// Close logs out, closes the session and unloads the module.
+func (key *Key) Close() error {
+	key.mutex.Lock()
+	defer key.mutex.Unlock()
+
+	if key.ctx == nil {
+		return nil
+	}
+
+	if key.session != 0 {
+		key.ctx.Logout(key.session)
+		key.ctx.CloseSession(key.session)
+	}
+	key.ctx.Finalize()
+	key.ctx.Destroy()
+	key.ctx = nil
+
+	return nil
+}
+
+func selectToken(ctx *pkcs11.Ctx, uri *URI, options Options) (Token, error) {
+	info, err := ctx.GetInfo()
+	if err != nil {
+		return Token{}, errors.New("pkcs11 module info error:\n> " + err.Error())
+	}
+	library := Library{
+		Manufacturer: info.ManufacturerID,
+		Description:  info.LibraryDescription,
+	}
+
+	slots, err := ctx.GetSlotList(true)
+	if err != nil {
+		return Token{}, errors.New("pkcs11 slot list error:\n> " + err.Error())
+	}
+
+	var tokens []Token
+	for _, slotID := range slots {
+		slotInfo, err := ctx.GetSlotInfo(slotID)
+		if err != nil {
+			continue
+		}
+
+		tokenInfo, err := ctx.GetTokenInfo(slotID)
+		if err != nil || tokenInfo.Flags&pkcs11.CKF_TOKEN_INITIALIZED == 0 {
+			continue
+		}
+
+		token := Token{
+			SlotID:           slotID,
+			SlotDescription:  slotInfo.SlotDescription,
+			SlotManufacturer: slotInfo.ManufacturerID,
+			Label:            tokenInfo.Label,
+			Manufacturer:     tokenInfo.ManufacturerID,
+			Model:            tokenInfo.Model,
+			Serial:           tokenInfo.SerialNumber,
+		}
+
+		if uri.matches(token, library) {
+			tokens = append(tokens, token)
+		}
+	}
+
+	if len(tokens) == 0 {
+		return Token{}, ErrNoToken
+	} else if len(tokens) == 1 {
+		return tokens[0], nil
+	}
+
+	if options.SelectToken == nil {
+		return Token{}, errors.New(strconv.Itoa(len(tokens)) + " pkcs11 tokens match the uri, add token= or slot-id=")
+	}
+
+	index, err := options.SelectToken(tokens)
+	if err != nil {
+		return Token{}, err
+	} else if index < 0 || index >= len(tokens) {
+		return Token{}, errors.New("invalid pkcs11 token selection")
+	}
+
+	return tokens[index], nil
+}
+
+func login(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, token Token, uri *URI, options Options) error {
+	tokenInfo, err := ctx.GetTokenInfo(token.SlotID)
+	if err != nil {
+		return errors.New("pkcs11 token info error:\n> " + err.Error())
+	}
+
+	if tokenInfo.Flags&pkcs11.CKF_LOGIN_REQUIRED == 0 {
+		return nil
+	}
+
+	pin, err := uri.pin()
+	if err != nil {
+		return err
+	}
+
+	// readers with a pin pad ask for the pin themselves
+	if pin == nil && tokenInfo.Flags&pkcs11.CKF_PROTECTED_AUTHENTICATION_PATH == 0 {
+		if options.PIN == nil {
+			return errors.New("pkcs11 token '" + token.Label + "' needs a pin, add pin-source to the uri")
+		}
+
+		pin, err = options.PIN(token)
+		if err != nil {
+			return err
+		}
+	}
+	defer secret.Wipe(pin)
+
+	err = ctx.Login(session, pkcs11.CKU_USER, string(pin))
+	if errors.Is(err, pkcs11.Error(pkcs11.CKR_PIN_INCORRECT)) || errors.Is(err, pkcs11.Error(pkcs11.CKR_PIN_LOCKED)) {
+		return fmt.Errorf("pkcs11 login error:\n> %w: %v", ErrPINIncorrect, err)
+	} else if err != nil && !errors.Is(err, pkcs11.Error(pkcs11.CKR_USER_ALREADY_LOGGED_IN)) {
+		return errors.New("pkcs11 login error, maybe wrong pin:\n> " + err.Error())
+	}
+
+	return nil
+}
+
+func findPrivateKey(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, uri *URI, token Token) (pkcs11.ObjectHandle, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+	}
+	if len(uri.Object) != 0 {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_LABEL, uri.Object))
+	}
+	if len(uri.ID) != 0 {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_ID, uri.ID))
+	}
+
+	handles, err := findObjects(ctx, session, template)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(handles) == 0 {
+		return 0, fmt.Errorf("%w, no rsa private key on token '%s'", ErrNoKey, token.Label)
+	} else if len(handles) > 1 {
+		return 0, fmt.Errorf("%w, several rsa private keys on token '%s' match, add object= or id=", ErrNoKey, token.Label)
+	}
+
+	return handles[0], nil
+}
+
+func findObjects(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, template []*pkcs11.Attribute) ([]pkcs11.ObjectHandle, error) {
+	if err := ctx.FindObjectsInit(session, template); err != nil {
+		return nil, errors.New("pkcs11 find objects error:\n> " + err.Error())
+	}
+	defer ctx.FindObjectsFinal(session)
+
+	handles, _, err := ctx.FindObjects(session, 2)
+	if err != nil {
+		return nil, errors.New("pkcs11 find objects error:\n> " + err.Error())
+	}
+
+	return handles, nil
+}
+
+// readPublicKey reads modulus and exponent of the private key,
+// or of the public key with the same id if the token hides them.
+func readPublicKey(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, handle pkcs11.ObjectHandle) (*rsa.PublicKey, error) {
+	publicKey, err := rsaAttributes(ctx, session, handle)
+	if err == nil {
+		return publicKey, nil
+	}
+
+	attributes, idErr := ctx.GetAttributeValue(session, handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_ID, nil),
+	})
+	if idErr != nil || len(attributes) != 1 || len(attributes[0].Value) == 0 {
+		return nil, err
+	}
+
+	handles, findErr := findObjects(ctx, session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, attributes[0].Value),
+	})
+	if findErr != nil || len(handles) != 1 {
+		return nil, err
+	}
+
+	return rsaAttributes(ctx, session, handles[0])
+}
+
+func rsaAttributes(ctx *pkcs11.Ctx, session pkcs11.SessionHandle, handle pkcs11.ObjectHandle) (*rsa.PublicKey, error) {
+	attributes, err := ctx.GetAttributeValue(session, handle, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+	})
+	if err != nil {
+		return nil, errors.New("pkcs11 read public key error:\n> " + err.Error())
+	} else if len(attributes) != 2 || len(attributes[0].Value) == 0 || len(attributes[1].Value) == 0 {
+		return nil, errors.New("pkcs11 read public key error: no modulus or exponent")
+	}
+
+	exponent := new(big.Int).SetBytes(attributes[1].Value)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 || exponent.Int64() < 3 {
+		return nil, errors.New("pkcs11 read public key error: invalid exponent")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(attributes[0].Value),
+		E: int(exponent.Int64()),
+	}, nil
+}
@@ -0,0 +1,38 @@
+//go:build !cgo
+
+package pkcs11
+
+import (
+	"crypto"
+	"errors"
+	"io"
+)
+
+// Key is a rsa private key on a token, it needs a build with cgo.
+type Key struct {
+	Token Token
+}
+
+// Open always fails, loading a PKCS#11 module needs cgo.
+func Open(rawURI string, options Options) (*Key, error) {
+	if _, err := ParseURI(rawURI); err != nil {
+		return nil, err
+	}
+
+	return nil, errors.New("pkcs11 support needs a build with cgo")
+}
+
+// Public returns nil, the key can not be opened without cgo.
+func (key *Key) Public() crypto.PublicKey {
+	return nil
+}
+
+// Decrypt always fails, the key can not be opened without cgo.
+func (key *Key) Decrypt(_ io.Reader, ciphertext []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	return nil, errors.New("pkcs11 support needs a build with cgo")
+}
+
+// Close does nothing, the key can not be opened without cgo.
+func (key *Key) Close() error {
+	return nil
+}
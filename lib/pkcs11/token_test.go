@@ -0,0 +1,143 @@
+//go:build cgo
+
+package pkcs11
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/NobleMajo/vault/lib/cryption"
+)
+
+// softHSM creates a SoftHSM2 token "vault-test" with the rsa key "vault-key" and the pin 1234.
+// The test is skipped if SoftHSM2 is not installed, SOFTHSM2_MODULE overrides the module path.
+func softHSM(t *testing.T) (string, *rsa.PrivateKey) {
+	t.Helper()
+
+	util, err := exec.LookPath("softhsm2-util")
+	if err != nil {
+		skipSoftHSM(t, "softhsm2-util not installed")
+	}
+
+	module := os.Getenv("SOFTHSM2_MODULE")
+	for _, path := range DefaultModules {
+		if len(module) == 0 && strings.Contains(path, "softhsm") && fileExists(path) {
+			module = path
+		}
+	}
+	if len(module) == 0 {
+		skipSoftHSM(t, "libsofthsm2.so not found, set SOFTHSM2_MODULE")
+	}
+
+	dir := t.TempDir()
+	tokenDir := filepath.Join(dir, "tokens")
+	if err := os.Mkdir(tokenDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	conf := filepath.Join(dir, "softhsm2.conf")
+	err = os.WriteFile(conf, []byte("directories.tokendir = "+tokenDir+"\nobjectstore.backend = file\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOFTHSM2_CONF", conf)
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "key.pem")
+	err = os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commands := [][]string{
+		{"--init-token", "--free", "--label", "vault-test", "--pin", "1234", "--so-pin", "5678", "--module", module},
+		{"--import", keyFile, "--token", "vault-test", "--label", "vault-key", "--id", "01", "--pin", "1234", "--module", module},
+	}
+	for _, args := range commands {
+		if output, err := exec.Command(util, args...).CombinedOutput(); err != nil {
+			t.Fatalf("softhsm2-util %v error: %v\n%s", args[0], err, output)
+		}
+	}
+
+	return module, privateKey
+}
+
+// skipSoftHSM skips the test, or fails it if VAULT_REQUIRE_SOFTHSM is set,
+// so the ci can not pass without running the token tests.
+func skipSoftHSM(t *testing.T, reason string) {
+	t.Helper()
+
+	if len(os.Getenv("VAULT_REQUIRE_SOFTHSM")) != 0 {
+		t.Fatal(reason + ", but VAULT_REQUIRE_SOFTHSM is set")
+	}
+	t.Skip(reason)
+}
+
+func TestSoftHSMDecrypt(t *testing.T) {
+	module, privateKey := softHSM(t)
+
+	key, err := Open("pkcs11:token=vault-test;object=vault-key?module-path="+module+"&pin-value=1234", Options{})
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	defer key.Close()
+
+	if !privateKey.PublicKey.Equal(key.Public()) {
+		t.Fatal("expected the public key of the token key")
+	}
+
+	cipherPayload, err := cryption.X509AES256Encrypt(&privateKey.PublicKey, []byte("payload"))
+	if err != nil {
+		t.Fatalf("encrypt error: %v", err)
+	}
+
+	plainPayload, err := cryption.X509AES256Decrypt(key, cipherPayload)
+	if err != nil || string(plainPayload) != "payload" {
+		t.Fatalf("X509AES256Decrypt = %q, %v", plainPayload, err)
+	}
+}
+
+func TestSoftHSMPIN(t *testing.T) {
+	module, _ := softHSM(t)
+
+	var asked string
+	key, err := Open("pkcs11:token=vault-test;id=%01", Options{
+		ModulePath: module,
+		PIN: func(token Token) ([]byte, error) {
+			asked = token.Label
+			return []byte("1234"), nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	key.Close()
+
+	if asked != "vault-test" {
+		t.Fatalf("expected the pin of token 'vault-test', got %q", asked)
+	}
+
+	_, err = Open("pkcs11:token=vault-test?pin-value=0000", Options{ModulePath: module})
+	if err == nil {
+		t.Fatal("expected a wrong pin to fail")
+	}
+
+	_, err = Open("pkcs11:token=other?pin-value=1234", Options{ModulePath: module})
+	if err == nil {
+		t.Fatal("expected an unknown token to fail")
+	}
+}
@@ -0,0 +1,169 @@
+package pkcs11
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// URI is a parsed pkcs11: URI (RFC 7512), empty fields match everything.
+type URI struct {
+	// path attributes that select the token
+	Token               string
+	Manufacturer        string
+	Serial              string
+	Model               string
+	SlotID              *uint
+	SlotDescription     string
+	SlotManufacturer    string
+	LibraryManufacturer string
+	LibraryDescription  string
+
+	// path attributes that select the key
+	Object string
+	ID     []byte
+	Type   string
+
+	// query attributes
+	ModulePath string
+	ModuleName string
+	PINValue   string
+	PINSource  string
+}
+
+// ParseURI parses a pkcs11: URI like
+// "pkcs11:token=vault;object=vault-key?module-path=/usr/lib/softhsm/libsofthsm2.so".
+// Unknown attributes are an error, vendor attributes with a "x-" prefix are ignored.
+func ParseURI(raw string) (*URI, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.HasPrefix(raw, "pkcs11:") {
+		return nil, errors.New("pkcs11 uri has to start with 'pkcs11:'")
+	}
+
+	path, query, _ := strings.Cut(strings.TrimPrefix(raw, "pkcs11:"), "?")
+	uri := &URI{}
+	seen := map[string]bool{}
+
+	for _, attribute := range splitAttributes(path, ";") {
+		name, value, err := parseAttribute(attribute, seen)
+		if err != nil {
+			return nil, err
+		}
+
+		switch name {
+		case "token":
+			uri.Token = value
+		case "manufacturer":
+			uri.Manufacturer = value
+		case "serial":
+			uri.Serial = value
+		case "model":
+			uri.Model = value
+		case "slot-id":
+			slotID, err := strconv.ParseUint(value, 10, 0)
+			if err != nil {
+				return nil, errors.New("invalid pkcs11 uri slot-id '" + value + "'")
+			}
+			id := uint(slotID)
+			uri.SlotID = &id
+		case "slot-description":
+			uri.SlotDescription = value
+		case "slot-manufacturer":
+			uri.SlotManufacturer = value
+		case "library-manufacturer":
+			uri.LibraryManufacturer = value
+		case "library-description":
+			uri.LibraryDescription = value
+		case "library-version":
+			// the library version is not compared
+		case "object":
+			uri.Object = value
+		case "id":
+			uri.ID = []byte(value)
+		case "type":
+			uri.Type = value
+		default:
+			if !strings.HasPrefix(name, "x-") {
+				return nil, errors.New("unsupported pkcs11 uri attribute '" + name + "'")
+			}
+		}
+	}
+
+	for _, attribute := range splitAttributes(query, "&") {
+		name, value, err := parseAttribute(attribute, seen)
+		if err != nil {
+			return nil, err
+		}
+
+		switch name {
+		case "module-path":
+			uri.ModulePath = value
+		case "module-name":
+			uri.ModuleName = value
+		case "pin-value":
+			uri.PINValue = value
+		case "pin-source":
+			uri.PINSource = value
+		default:
+			if !strings.HasPrefix(name, "x-") {
+				return nil, errors.New("unsupported pkcs11 uri query attribute '" + name + "'")
+			}
+		}
+	}
+
+	if len(uri.Type) != 0 && uri.Type != "private" {
+		return nil, errors.New("pkcs11 uri type has to be 'private', got '" + uri.Type + "'")
+	}
+
+	if len(uri.PINValue) != 0 && len(uri.PINSource) != 0 {
+		return nil, errors.New("pkcs11 uri can not have pin-value and pin-source")
+	}
+
+	return uri, nil
+}
+
+// matches reports if the token is selected by the path attributes of the uri.
+func (uri *URI) matches(token Token, library Library) bool {
+	return (uri.SlotID == nil || *uri.SlotID == token.SlotID) &&
+		matchAttribute(uri.Token, token.Label) &&
+		matchAttribute(uri.Manufacturer, token.Manufacturer) &&
+		matchAttribute(uri.Serial, token.Serial) &&
+		matchAttribute(uri.Model, token.Model) &&
+		matchAttribute(uri.SlotDescription, token.SlotDescription) &&
+		matchAttribute(uri.SlotManufacturer, token.SlotManufacturer) &&
+		matchAttribute(uri.LibraryManufacturer, library.Manufacturer) &&
+		matchAttribute(uri.LibraryDescription, library.Description)
+}
+
+func matchAttribute(want string, have string) bool {
+	return len(want) == 0 || want == strings.TrimRight(have, " \x00")
+}
+
+func splitAttributes(part string, separator string) []string {
+	if len(part) == 0 {
+		return nil
+	}
+
+	return strings.Split(part, separator)
+}
+
+func parseAttribute(attribute string, seen map[string]bool) (string, string, error) {
+	name, value, ok := strings.Cut(attribute, "=")
+	if !ok || len(name) == 0 {
+		return "", "", errors.New("invalid pkcs11 uri attribute '" + attribute + "'")
+	}
+
+	name = strings.ToLower(name)
+	if seen[name] {
+		return "", "", errors.New("duplicate pkcs11 uri attribute '" + name + "'")
+	}
+	seen[name] = true
+
+	value, err := url.PathUnescape(value)
+	if err != nil {
+		return "", "", errors.New("invalid pkcs11 uri attribute '" + name + "':\n> " + err.Error())
+	}
+
+	return name, value, nil
+}
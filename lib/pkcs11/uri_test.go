@@ -0,0 +1,58 @@
+package pkcs11
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseURI(t *testing.T) {
+	uri, err := ParseURI("pkcs11:token=My%20Token;object=vault-key;id=%01%02;type=private;slot-id=3" +
+		"?module-path=/usr/lib/softhsm/libsofthsm2.so&pin-source=file:/run/pin&x-vendor=1")
+	if err != nil {
+		t.Fatalf("ParseURI error: %v", err)
+	}
+
+	if uri.Token != "My Token" || uri.Object != "vault-key" || !bytes.Equal(uri.ID, []byte{1, 2}) ||
+		uri.SlotID == nil || *uri.SlotID != 3 || uri.ModulePath != "/usr/lib/softhsm/libsofthsm2.so" ||
+		uri.PINSource != "file:/run/pin" {
+		t.Fatalf("unexpected uri: %+v", uri)
+	}
+
+	if !uri.matches(Token{SlotID: 3, Label: "My Token"}, Library{}) {
+		t.Fatal("expected the token to match")
+	}
+	if uri.matches(Token{SlotID: 4, Label: "My Token"}, Library{}) {
+		t.Fatal("expected another slot to not match")
+	}
+
+	empty, err := ParseURI("pkcs11:")
+	if err != nil || !empty.matches(Token{Label: "any"}, Library{}) {
+		t.Fatalf("expected an empty uri to match every token: %v", err)
+	}
+}
+
+func TestParseURIInvalid(t *testing.T) {
+	tests := []string{
+		"token=vault",
+		"pkcs11:token",
+		"pkcs11:token=a;token=b",
+		"pkcs11:unknown=1",
+		"pkcs11:token=vault?unknown=1",
+		"pkcs11:type=public",
+		"pkcs11:slot-id=abc",
+		"pkcs11:object=%zz",
+		"pkcs11:token=vault?pin-value=1234&pin-source=/run/pin",
+	}
+
+	for _, test := range tests {
+		if _, err := ParseURI(test); err == nil {
+			t.Fatalf("ParseURI(%q) expected an error", test)
+		}
+	}
+}
+
+func TestIsURI(t *testing.T) {
+	if !IsURI("pkcs11:token=vault") || !IsURI(" pkcs11:") || IsURI("~/.ssh/id_rsa") || IsURI("./pkcs11:key") {
+		t.Fatal("unexpected IsURI result")
+	}
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package secret
+
+import (
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestDisableCoreDumps(t *testing.T) {
+	err := DisableCoreDumps()
+	if err != nil {
+		t.Fatalf("DisableCoreDumps error: %v", err)
+	}
+
+	var limit unix.Rlimit
+	err = unix.Getrlimit(unix.RLIMIT_CORE, &limit)
+	if err != nil {
+		t.Fatalf("Getrlimit error: %v", err)
+	}
+
+	if limit.Cur != 0 {
+		t.Fatalf("core dump limit = %d, want 0", limit.Cur)
+	}
+}
@@ -0,0 +1,119 @@
+package secret
+
+import (
+	"errors"
+	"sync"
+)
+
+// Buffer holds secret bytes like passwords or keys outside of the garbage
+// collected heap. If possible the memory is locked (mlock), so it never gets
+// swapped to disk. Destroy zeroes the memory and releases it.
+type Buffer struct {
+	data   []byte
+	memory []byte
+	locked bool
+}
+
+// New allocates a zeroed buffer of the given size.
+func New(size int) (*Buffer, error) {
+	if size < 0 {
+		return nil, errors.New("negative secret buffer size")
+	}
+
+	memory, locked, err := allocate(size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Buffer{
+		data:   memory[:size],
+		memory: memory,
+		locked: locked,
+	}, nil
+}
+
+// FromBytes moves the bytes into a new buffer and wipes the source slice.
+func FromBytes(source []byte) (*Buffer, error) {
+	buffer, err := New(len(source))
+	if err != nil {
+		Wipe(source)
+		return nil, err
+	}
+
+	copy(buffer.data, source)
+	Wipe(source)
+
+	return buffer, nil
+}
+
+// Bytes returns the secret content. The slice is only valid until Destroy.
+func (buffer *Buffer) Bytes() []byte {
+	if buffer == nil {
+		return nil
+	}
+
+	return buffer.data
+}
+
+// Len returns the length of the secret content.
+func (buffer *Buffer) Len() int {
+	if buffer == nil {
+		return 0
+	}
+
+	return len(buffer.data)
+}
+
+// Locked returns true if the memory is locked against swapping.
+func (buffer *Buffer) Locked() bool {
+	return buffer != nil && buffer.locked
+}
+
+// Equal compares the content of two buffers.
+func (buffer *Buffer) Equal(other *Buffer) bool {
+	a, b := buffer.Bytes(), other.Bytes()
+	if len(a) != len(b) {
+		return false
+	}
+
+	var difference byte
+	for i := range a {
+		difference |= a[i] ^ b[i]
+	}
+
+	return difference == 0
+}
+
+// Destroy zeroes and releases the memory. It is safe to call Destroy more than once.
+func (buffer *Buffer) Destroy() {
+	if buffer == nil || buffer.memory == nil {
+		return
+	}
+
+	Wipe(buffer.memory)
+	release(buffer.memory, buffer.locked)
+
+	buffer.data = nil
+	buffer.memory = nil
+	buffer.locked = false
+}
+
+// Wipe overwrites the slice with zeros.
+func Wipe(data []byte) {
+	for i := range data {
+		data[i] = 0
+	}
+}
+
+var disableCoreDumpsOnce sync.Once
+var disableCoreDumpsErr error
+
+// DisableCoreDumps prevents that the process memory with loaded secrets ends
+// up in a core dump. It is applied once for the whole process.
+func DisableCoreDumps() error {
+	disableCoreDumpsOnce.Do(func() {
+		disableCoreDumpsErr = disableCoreDumps()
+	})
+
+	return disableCoreDumpsErr
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package secret
+
+func allocate(size int) ([]byte, bool, error) {
+	return make([]byte, size), false, nil
+}
+
+func release(memory []byte, locked bool) {}
+
+func disableCoreDumps() error {
+	return nil
+}
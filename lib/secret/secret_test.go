@@ -0,0 +1,107 @@
+package secret
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFromBytesWipesSource(t *testing.T) {
+	source := []byte("my secret password")
+	expected := append([]byte{}, source...)
+
+	buffer, err := FromBytes(source)
+	if err != nil {
+		t.Fatalf("FromBytes error: %v", err)
+	}
+	defer buffer.Destroy()
+
+	if !bytes.Equal(buffer.Bytes(), expected) {
+		t.Fatalf("buffer content = %q, want %q", buffer.Bytes(), expected)
+	}
+
+	if !bytes.Equal(source, make([]byte, len(source))) {
+		t.Fatalf("source not wiped: %q", source)
+	}
+}
+
+func TestDestroy(t *testing.T) {
+	buffer, err := FromBytes([]byte("secret"))
+	if err != nil {
+		t.Fatalf("FromBytes error: %v", err)
+	}
+
+	buffer.Destroy()
+	buffer.Destroy()
+
+	if buffer.Len() != 0 || buffer.Bytes() != nil {
+		t.Fatalf("destroyed buffer still has content: %q", buffer.Bytes())
+	}
+}
+
+func TestNilBuffer(t *testing.T) {
+	var buffer *Buffer
+
+	if buffer.Len() != 0 || buffer.Bytes() != nil || buffer.Locked() {
+		t.Fatalf("nil buffer should be empty")
+	}
+
+	buffer.Destroy()
+}
+
+func TestNewSizes(t *testing.T) {
+	for _, size := range []int{0, 1, 4095, 4096, 4097, 100000} {
+		buffer, err := New(size)
+		if err != nil {
+			t.Fatalf("New(%d) error: %v", size, err)
+		}
+
+		if buffer.Len() != size {
+			t.Fatalf("New(%d) length = %d", size, buffer.Len())
+		}
+
+		if !bytes.Equal(buffer.Bytes(), make([]byte, size)) {
+			t.Fatalf("New(%d) is not zeroed", size)
+		}
+
+		buffer.Destroy()
+	}
+
+	_, err := New(-1)
+	if err == nil {
+		t.Fatalf("New(-1) should fail")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a     string
+		b     string
+		equal bool
+	}{
+		{"password", "password", true},
+		{"password", "passwore", false},
+		{"password", "pass", false},
+		{"", "", true},
+	}
+
+	for _, test := range tests {
+		a, _ := FromBytes([]byte(test.a))
+		b, _ := FromBytes([]byte(test.b))
+
+		if a.Equal(b) != test.equal {
+			t.Fatalf("Equal(%q, %q) = %v, want %v", test.a, test.b, !test.equal, test.equal)
+		}
+
+		a.Destroy()
+		b.Destroy()
+	}
+}
+
+func TestWipe(t *testing.T) {
+	data := []byte("secret")
+	Wipe(data)
+
+	if !bytes.Equal(data, make([]byte, 6)) {
+		t.Fatalf("Wipe did not zero: %q", data)
+	}
+}
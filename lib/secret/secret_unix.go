@@ -0,0 +1,42 @@
+//go:build unix
+
+package secret
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// allocate maps anonymous memory outside of the go heap and tries to lock it.
+// Without permission to lock memory (RLIMIT_MEMLOCK) the memory stays unlocked.
+func allocate(size int) ([]byte, bool, error) {
+	pageSize := os.Getpagesize()
+	mappedSize := ((size + pageSize - 1) / pageSize) * pageSize
+	if mappedSize == 0 {
+		mappedSize = pageSize
+	}
+
+	memory, err := unix.Mmap(
+		-1,
+		0,
+		mappedSize,
+		unix.PROT_READ|unix.PROT_WRITE,
+		unix.MAP_PRIVATE|unix.MAP_ANON,
+	)
+	if err != nil {
+		return nil, false, err
+	}
+
+	locked := unix.Mlock(memory) == nil
+
+	return memory, locked, nil
+}
+
+func release(memory []byte, locked bool) {
+	if locked {
+		unix.Munlock(memory)
+	}
+
+	unix.Munmap(memory)
+}
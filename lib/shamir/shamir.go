@@ -0,0 +1,150 @@
+// Package shamir implements Shamir's secret sharing over GF(256).
+//
+// Every byte of the secret is shared with its own random polynomial of degree
+// threshold-1, a share is the evaluation of all polynomials at its x coordinate.
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+	"strconv"
+)
+
+// Share is one part of a split secret. X is never 0, Y has the length of the secret.
+type Share struct {
+	X byte
+	Y []byte
+}
+
+// Split splits the secret into the given number of shares,
+// any threshold of them can recreate the secret.
+func Split(secret []byte, shares int, threshold int) ([]Share, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("empty secret")
+	} else if threshold < 2 {
+		return nil, errors.New("threshold must be at least 2")
+	} else if shares < threshold {
+		return nil, errors.New("shares must be at least the threshold")
+	} else if shares > 255 {
+		return nil, errors.New("at most 255 shares are possible, got " + strconv.Itoa(shares))
+	}
+
+	result := make([]Share, shares)
+	for index := range result {
+		result[index] = Share{
+			X: byte(index + 1),
+			Y: make([]byte, len(secret)),
+		}
+	}
+
+	coefficients := make([]byte, threshold)
+	for position, secretByte := range secret {
+		_, err := rand.Read(coefficients[1:])
+		if err != nil {
+			return nil, err
+		}
+		coefficients[0] = secretByte
+
+		for index := range result {
+			result[index].Y[position] = evaluate(coefficients, result[index].X)
+		}
+	}
+
+	for index := range coefficients {
+		coefficients[index] = 0
+	}
+
+	return result, nil
+}
+
+// Combine recreates the secret from at least threshold shares.
+// With fewer shares the result is a wrong secret, the caller has to verify it.
+func Combine(shares []Share) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("at least 2 shares are needed")
+	}
+
+	length := len(shares[0].Y)
+	for index, share := range shares {
+		if share.X == 0 {
+			return nil, errors.New("invalid share x coordinate 0")
+		} else if len(share.Y) != length || length == 0 {
+			return nil, errors.New("shares have different lengths")
+		}
+
+		for _, other := range shares[:index] {
+			if other.X == share.X {
+				return nil, errors.New("duplicate share " + strconv.Itoa(int(share.X)))
+			}
+		}
+	}
+
+	secret := make([]byte, length)
+	for position := range secret {
+		// lagrange interpolation at x = 0
+		var value byte
+		for i, share := range shares {
+			basis := byte(1)
+			for j, other := range shares {
+				if i == j {
+					continue
+				}
+				// other.X / (other.X - share.X), subtraction is xor in GF(256)
+				basis = mul(basis, div(other.X, other.X^share.X))
+			}
+			value ^= mul(share.Y[position], basis)
+		}
+		secret[position] = value
+	}
+
+	return secret, nil
+}
+
+// evaluate calculates the polynomial at x with the horner scheme.
+func evaluate(coefficients []byte, x byte) byte {
+	var result byte
+	for index := len(coefficients) - 1; index >= 0; index-- {
+		result = mul(result, x) ^ coefficients[index]
+	}
+
+	return result
+}
+
+// exp and log tables for the generator 3 of GF(256) with the AES polynomial x^8+x^4+x^3+x+1
+var expTable, logTable = func() ([510]byte, [256]byte) {
+	var exp [510]byte
+	var log [256]byte
+
+	value := byte(1)
+	for power := 0; power < 255; power++ {
+		exp[power] = value
+		exp[power+255] = value
+		log[value] = byte(power)
+
+		// multiply by 3 = x + 1
+		high := value & 0x80
+		doubled := value << 1
+		if high != 0 {
+			doubled ^= 0x1b
+		}
+		value ^= doubled
+	}
+
+	return exp, log
+}()
+
+func mul(a byte, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+func div(a byte, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+
+	return expTable[int(logTable[a])+255-int(logTable[b])]
+}
@@ -0,0 +1,110 @@
+package shamir
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMulDiv(t *testing.T) {
+	for a := 0; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			product := mul(byte(a), byte(b))
+			if div(product, byte(b)) != byte(a) {
+				t.Fatalf("div(mul(%d, %d), %d) != %d", a, b, b, a)
+			}
+		}
+	}
+
+	// known value from the AES specification: {57} * {83} = {c1}
+	if mul(0x57, 0x83) != 0xc1 {
+		t.Fatalf("mul(0x57, 0x83) = %#x, want 0xc1", mul(0x57, 0x83))
+	}
+}
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	tests := []struct {
+		shares    int
+		threshold int
+	}{
+		{2, 2},
+		{3, 2},
+		{5, 3},
+		{10, 10},
+		{255, 4},
+	}
+
+	for _, test := range tests {
+		shares, err := Split(secret, test.shares, test.threshold)
+		if err != nil {
+			t.Fatalf("Split(%d, %d) error: %v", test.shares, test.threshold, err)
+		}
+
+		if len(shares) != test.shares {
+			t.Fatalf("Split(%d, %d) returned %d shares", test.shares, test.threshold, len(shares))
+		}
+
+		// every window of threshold shares recreates the secret
+		for start := 0; start+test.threshold <= len(shares); start++ {
+			combined, err := Combine(shares[start : start+test.threshold])
+			if err != nil {
+				t.Fatalf("Combine error: %v", err)
+			}
+
+			if !bytes.Equal(combined, secret) {
+				t.Fatalf("Combine of shares %d..%d = %x", start, start+test.threshold, combined)
+			}
+		}
+
+		// all shares work too
+		combined, err := Combine(shares)
+		if err != nil || !bytes.Equal(combined, secret) {
+			t.Fatalf("Combine of all shares = %x, %v", combined, err)
+		}
+
+		// one share less than the threshold does not
+		if test.threshold > 2 {
+			combined, _ := Combine(shares[:test.threshold-1])
+			if bytes.Equal(combined, secret) {
+				t.Fatalf("Combine with too few shares recreated the secret")
+			}
+		}
+	}
+}
+
+func TestSplitInvalid(t *testing.T) {
+	tests := []struct {
+		secret    []byte
+		shares    int
+		threshold int
+	}{
+		{nil, 5, 3},
+		{[]byte("x"), 5, 1},
+		{[]byte("x"), 2, 3},
+		{[]byte("x"), 256, 3},
+	}
+
+	for _, test := range tests {
+		_, err := Split(test.secret, test.shares, test.threshold)
+		if err == nil {
+			t.Fatalf("Split(%q, %d, %d) should fail", test.secret, test.shares, test.threshold)
+		}
+	}
+}
+
+func TestCombineInvalid(t *testing.T) {
+	tests := [][]Share{
+		{{X: 1, Y: []byte{1}}},
+		{{X: 1, Y: []byte{1}}, {X: 1, Y: []byte{2}}},
+		{{X: 0, Y: []byte{1}}, {X: 1, Y: []byte{2}}},
+		{{X: 1, Y: []byte{1}}, {X: 2, Y: []byte{2, 3}}},
+	}
+
+	for index, shares := range tests {
+		_, err := Combine(shares)
+		if err == nil {
+			t.Fatalf("test %d: Combine should fail", index)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package strength
+
+// feedback explains the weakest part of a weak password and suggests how to improve it.
+func feedback(result Result) (string, []string) {
+	if len(result.Sequence) == 0 {
+		return "", []string{
+			"Use a few words, avoid common phrases.",
+			"No need for symbols, digits, or uppercase letters.",
+		}
+	}
+
+	if result.Score > 2 {
+		return "", nil
+	}
+
+	// the longest match is the main weakness
+	longest := result.Sequence[0]
+	for _, match := range result.Sequence[1:] {
+		if match.End-match.Start > longest.End-longest.Start {
+			longest = match
+		}
+	}
+
+	warning, suggestions := matchFeedback(longest, len(result.Sequence) == 1)
+	suggestions = append([]string{"Add another word or two. Uncommon words are better."}, suggestions...)
+
+	return warning, suggestions
+}
+
+func matchFeedback(match Match, sole bool) (string, []string) {
+	switch match.Pattern {
+	case PatternDictionary:
+		return dictionaryFeedback(match, sole)
+	case PatternSpatial:
+		warning := "Short keyboard patterns are easy to guess."
+		if match.Turns == 1 {
+			warning = "Straight rows of keys are easy to guess."
+		}
+		return warning, []string{"Use a longer keyboard pattern with more turns."}
+	case PatternRepeat:
+		warning := "Repeats like \"abcabcabc\" are only slightly harder to guess than \"abc\"."
+		if match.RepeatUnit == 1 {
+			warning = "Repeats like \"aaa\" are easy to guess."
+		}
+		return warning, []string{"Avoid repeated words and characters."}
+	case PatternSequence:
+		return "Sequences like abc or 6543 are easy to guess.", []string{"Avoid sequences."}
+	case PatternDate:
+		if match.End-match.Start == 4 {
+			return "Recent years are easy to guess.", []string{"Avoid recent years.", "Avoid years that are associated with you."}
+		}
+		return "Dates are often easy to guess.", []string{"Avoid dates and years that are associated with you."}
+	}
+
+	return "", nil
+}
+
+func dictionaryFeedback(match Match, sole bool) (string, []string) {
+	warning := ""
+	switch match.Dictionary {
+	case "passwords":
+		switch {
+		case sole && !match.L33t && !match.Reversed && match.Rank <= 10:
+			warning = "This is a top-10 common password."
+		case sole && !match.L33t && !match.Reversed && match.Rank <= 100:
+			warning = "This is a top-100 common password."
+		case sole && !match.L33t && !match.Reversed:
+			warning = "This is a very common password."
+		case match.Log10Guesses <= 4:
+			warning = "This is similar to a commonly used password."
+		}
+	case "words":
+		if sole {
+			warning = "A common word by itself is easy to guess."
+		}
+	case "names":
+		warning = "Common names and surnames are easy to guess."
+		if sole {
+			warning = "Names and surnames by themselves are easy to guess."
+		}
+	case "user":
+		warning = "This contains a word that is on the deny list or about you."
+	}
+
+	var suggestions []string
+	if match.Uppercase {
+		suggestions = append(suggestions, "Capitalization doesn't help very much.")
+	}
+	if match.Reversed && match.End-match.Start >= 4 {
+		suggestions = append(suggestions, "Reversed words aren't much harder to guess.")
+	}
+	if match.L33t {
+		suggestions = append(suggestions, "Predictable substitutions like '@' instead of 'a' don't help very much.")
+	}
+
+	return warning, suggestions
+}
@@ -0,0 +1,107 @@
+package strength
+
+import "strings"
+
+// graph is the adjacency of the keys of a keyboard layout.
+// The neighbors of a key are ordered by direction, missing neighbors are empty.
+type graph struct {
+	slanted   bool
+	neighbors map[byte][]string
+	shifted   map[byte]bool
+}
+
+// Keyboard layouts, every key is written as unshifted and shifted character.
+// The rows of slanted layouts are offset by one more space per row.
+const (
+	qwertyLayout = "" +
+		"`~ 1! 2@ 3# 4$ 5% 6^ 7& 8* 9( 0) -_ =+\n" +
+		"    qQ wW eE rR tT yY uU iI oO pP [{ ]} \\|\n" +
+		"     aA sS dD fF gG hH jJ kK lL ;: '\"\n" +
+		"      zZ xX cC vV bB nN mM ,< .> /?"
+	qwertzLayout = "" +
+		"^° 1! 2\" 3§ 4$ 5% 6& 7/ 8( 9) 0= ß? ´`\n" +
+		"    qQ wW eE rR tT zZ uU iI oO pP üÜ +*\n" +
+		"     aA sS dD fF gG hH jJ kK lL öÖ äÄ #'\n" +
+		"   <> yY xX cC vV bB nN mM ,; .: -_"
+	keypadLayout = "" +
+		"  / * -\n" +
+		"7 8 9 +\n" +
+		"4 5 6\n" +
+		"1 2 3\n" +
+		"  0 ."
+)
+
+var graphNames = []string{"qwerty", "qwertz", "keypad"}
+
+var graphs = map[string]*graph{
+	"qwerty": newGraph(qwertyLayout, true),
+	"qwertz": newGraph(qwertzLayout, true),
+	"keypad": newGraph(keypadLayout, false),
+}
+
+// newGraph builds the adjacency like zxcvbn. On slanted layouts a key has
+// 6 neighbors, on aligned layouts like keypads 8 including the diagonals.
+func newGraph(layout string, slanted bool) *graph {
+	type position struct{ x, y int }
+
+	tokenSize := 1
+	if slanted {
+		tokenSize = 2
+	}
+
+	tokens := map[position]string{}
+	for y, line := range strings.Split(layout, "\n") {
+		runes := []rune(line)
+		for index := 0; index < len(runes); index++ {
+			if runes[index] == ' ' {
+				continue
+			}
+
+			slant := 0
+			if slanted {
+				slant = y
+			}
+			tokens[position{(index - slant) / (tokenSize + 1), y}] = string(runes[index : index+tokenSize])
+			index += tokenSize - 1
+		}
+	}
+
+	adjacent := func(x int, y int) []position {
+		if slanted {
+			return []position{{x - 1, y}, {x, y - 1}, {x + 1, y - 1}, {x + 1, y}, {x, y + 1}, {x - 1, y + 1}}
+		}
+		return []position{{x - 1, y}, {x - 1, y - 1}, {x, y - 1}, {x + 1, y - 1}, {x + 1, y}, {x + 1, y + 1}, {x, y + 1}, {x - 1, y + 1}}
+	}
+
+	result := &graph{slanted: slanted, neighbors: map[byte][]string{}, shifted: map[byte]bool{}}
+	for at, token := range tokens {
+		var neighbors []string
+		for _, next := range adjacent(at.x, at.y) {
+			neighbors = append(neighbors, tokens[next])
+		}
+
+		for index, char := range []rune(token) {
+			// only ascii keys are matched, the password is analysed byte wise
+			if char < 0x80 {
+				result.neighbors[byte(char)] = neighbors
+				result.shifted[byte(char)] = index > 0
+			}
+		}
+	}
+
+	return result
+}
+
+// averageDegree is the average number of neighbors of a key.
+func (graph *graph) averageDegree() float64 {
+	total := 0
+	for _, neighbors := range graph.neighbors {
+		for _, neighbor := range neighbors {
+			if len(neighbor) != 0 {
+				total++
+			}
+		}
+	}
+
+	return float64(total) / float64(len(graph.neighbors))
+}
@@ -0,0 +1,576 @@
+package strength
+
+import (
+	"bytes"
+	_ "embed"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/NobleMajo/vault/lib/secret"
+)
+
+//go:embed passwords.txt
+var passwordList string
+
+//go:embed words.txt
+var wordList string
+
+//go:embed names.txt
+var nameList string
+
+// referenceYear is the year that dates are compared with.
+var referenceYear = time.Now().Year()
+
+// dictionary maps lowercase words to their rank, 1 is the most common word.
+type dictionary struct {
+	name      string
+	ranks     map[string]int
+	maxLength int
+}
+
+var (
+	dictionariesOnce sync.Once
+	dictionaries     []*dictionary
+)
+
+func newDictionary(name string, words []string) *dictionary {
+	dict := &dictionary{name: name, ranks: make(map[string]int, len(words))}
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if len(word) == 0 {
+			continue
+		}
+		if _, exists := dict.ranks[word]; !exists {
+			dict.ranks[word] = len(dict.ranks) + 1
+		}
+		dict.maxLength = max(dict.maxLength, len(word))
+	}
+
+	return dict
+}
+
+// rankedDictionaries returns the embedded dictionaries and one for the user inputs.
+func rankedDictionaries(userInputs []string) []*dictionary {
+	dictionariesOnce.Do(func() {
+		dictionaries = []*dictionary{
+			newDictionary("passwords", strings.Split(passwordList, "\n")),
+			newDictionary("words", strings.Split(wordList, "\n")),
+			newDictionary("names", strings.Split(nameList, "\n")),
+		}
+	})
+
+	if len(userInputs) == 0 {
+		return dictionaries
+	}
+
+	return append(dictionaries[:len(dictionaries):len(dictionaries)], newDictionary("user", userInputs))
+}
+
+// omnimatch returns all matches of all patterns, they can overlap.
+func omnimatch(password []byte, userInputs []string) []Match {
+	lower := toLower(password)
+	defer secret.Wipe(lower)
+
+	dicts := rankedDictionaries(userInputs)
+
+	var matches []Match
+	matches = append(matches, dictionaryMatches(password, lower, dicts)...)
+	matches = append(matches, reversedMatches(password, lower, dicts)...)
+	matches = append(matches, l33tMatches(password, lower, dicts)...)
+	matches = append(matches, spatialMatches(password)...)
+	matches = append(matches, sequenceMatches(password)...)
+	matches = append(matches, repeatMatches(password, userInputs)...)
+	matches = append(matches, dateMatches(password)...)
+
+	return matches
+}
+
+func toLower(password []byte) []byte {
+	lower := make([]byte, len(password))
+	for index, char := range password {
+		if 'A' <= char && char <= 'Z' {
+			char += 'a' - 'A'
+		}
+		lower[index] = char
+	}
+
+	return lower
+}
+
+// dictionaryMatches finds every word of the dictionaries in the password.
+// The lookup uses the lowercase password, the original one counts the uppercase variations.
+func dictionaryMatches(password []byte, lower []byte, dicts []*dictionary) []Match {
+	var matches []Match
+	for _, dict := range dicts {
+		for start := range lower {
+			for end := start + 1; end <= len(lower) && end-start <= dict.maxLength; end++ {
+				rank, found := dict.ranks[string(lower[start:end])]
+				if !found {
+					continue
+				}
+
+				matches = append(matches, Match{
+					Pattern:    PatternDictionary,
+					Start:      start,
+					End:        end,
+					Dictionary: dict.name,
+					Rank:       rank,
+					Uppercase:  hasUppercase(password[start:end]),
+					base:       uppercaseVariations(password[start:end]),
+				})
+			}
+		}
+	}
+
+	return matches
+}
+
+// reversedMatches finds reversed dictionary words, like "drowssap".
+func reversedMatches(password []byte, lower []byte, dicts []*dictionary) []Match {
+	reversed := make([]byte, len(lower))
+	defer secret.Wipe(reversed)
+	for index, char := range lower {
+		reversed[len(lower)-1-index] = char
+	}
+
+	var matches []Match
+	for _, match := range dictionaryMatches(password, reversed, dicts) {
+		if match.End-match.Start < 2 {
+			continue
+		}
+
+		match.Start, match.End = len(lower)-match.End, len(lower)-match.Start
+		match.Reversed = true
+		match.Uppercase = hasUppercase(password[match.Start:match.End])
+		match.base = uppercaseVariations(password[match.Start:match.End])
+		matches = append(matches, match)
+	}
+
+	return matches
+}
+
+// l33tTable lists the characters that are commonly used instead of a letter.
+var l33tTable = map[byte][]byte{
+	'4': {'a'}, '@': {'a'},
+	'8': {'b'},
+	'(': {'c'}, '{': {'c'}, '[': {'c'}, '<': {'c'},
+	'3': {'e'},
+	'6': {'g'}, '9': {'g'},
+	'1': {'i', 'l'}, '!': {'i'}, '|': {'i', 'l'},
+	'7': {'l', 't'},
+	'0': {'o'},
+	'$': {'s'}, '5': {'s'},
+	'+': {'t'},
+	'%': {'x'},
+	'2': {'z'},
+}
+
+// l33tMatches finds dictionary words with substitutions, like "p@ssw0rd".
+// Every combination of the substitutions in the password is tried.
+func l33tMatches(password []byte, lower []byte, dicts []*dictionary) []Match {
+	var subbed []byte
+	for _, char := range lower {
+		if _, found := l33tTable[char]; found && bytes.IndexByte(subbed, char) < 0 {
+			subbed = append(subbed, char)
+		}
+	}
+
+	if len(subbed) == 0 {
+		return nil
+	}
+
+	var matches []Match
+	translated := make([]byte, len(lower))
+	defer secret.Wipe(translated)
+
+	choice := make([]int, len(subbed))
+	for {
+		substitution := make(map[byte]byte, len(subbed))
+		for index, char := range subbed {
+			substitution[char] = l33tTable[char][choice[index]]
+		}
+
+		for index, char := range lower {
+			if letter, found := substitution[char]; found {
+				translated[index] = letter
+			} else {
+				translated[index] = char
+			}
+		}
+
+		for _, match := range dictionaryMatches(password, translated, dicts) {
+			token := lower[match.Start:match.End]
+			variations, used := l33tVariations(token, translated[match.Start:match.End], substitution)
+			if !used || len(token) < 2 {
+				continue
+			}
+
+			match.L33t = true
+			match.base += variations
+			matches = append(matches, match)
+		}
+
+		// next combination, like counting with mixed digits
+		index := 0
+		for ; index < len(choice); index++ {
+			choice[index]++
+			if choice[index] < len(l33tTable[subbed[index]]) {
+				break
+			}
+			choice[index] = 0
+		}
+		if index == len(choice) {
+			break
+		}
+	}
+
+	return matches
+}
+
+// l33tVariations returns the log10 of the ways to substitute the letters of the word.
+func l33tVariations(token []byte, translated []byte, substitution map[byte]byte) (float64, bool) {
+	variations := 0.0
+	used := false
+	for char, letter := range substitution {
+		substituted := bytes.Count(token, []byte{char})
+		if substituted == 0 {
+			continue
+		}
+
+		used = true
+		unsubstituted := bytes.Count(translated, []byte{letter}) - substituted
+		variations += caseVariations(substituted, unsubstituted)
+	}
+
+	return variations, used
+}
+
+func hasUppercase(token []byte) bool {
+	for _, char := range token {
+		if 'A' <= char && char <= 'Z' {
+			return true
+		}
+	}
+
+	return false
+}
+
+// uppercaseVariations returns the log10 of the ways to capitalize the word.
+// A capitalized first or last letter and all uppercase only double the guesses.
+func uppercaseVariations(token []byte) float64 {
+	upper, lower := 0, 0
+	for _, char := range token {
+		switch {
+		case 'A' <= char && char <= 'Z':
+			upper++
+		case 'a' <= char && char <= 'z':
+			lower++
+		}
+	}
+
+	switch {
+	case upper == 0:
+		return 0
+	case lower == 0:
+		return math.Log10(2)
+	case upper == 1 && (isUpper(token[0]) || isUpper(token[len(token)-1])):
+		return math.Log10(2)
+	}
+
+	return caseVariations(upper, lower)
+}
+
+func isUpper(char byte) bool {
+	return 'A' <= char && char <= 'Z'
+}
+
+// spatialMatches finds keyboard patterns like "qwerty" or "zaq1" with at least 3 keys.
+func spatialMatches(password []byte) []Match {
+	var matches []Match
+	for _, name := range graphNames {
+		graph := graphs[name]
+
+		for start := 0; start < len(password)-1; {
+			end := start + 1
+			lastDirection := -1
+			turns := 0
+			shifted := 0
+			if graph.slanted && graph.shifted[password[start]] {
+				shifted = 1
+			}
+
+			for {
+				found := false
+				if end < len(password) {
+					for direction, neighbor := range graph.neighbors[password[end-1]] {
+						position := strings.IndexByte(neighbor, password[end])
+						if position < 0 {
+							continue
+						}
+
+						found = true
+						if position > 0 {
+							shifted++
+						}
+						if direction != lastDirection {
+							turns++
+							lastDirection = direction
+						}
+						break
+					}
+				}
+
+				if found {
+					end++
+					continue
+				}
+
+				if end-start > 2 {
+					matches = append(matches, Match{
+						Pattern: PatternSpatial,
+						Start:   start,
+						End:     end,
+						Graph:   name,
+						Turns:   turns,
+						Shifted: shifted,
+					})
+				}
+				start = end
+				break
+			}
+		}
+	}
+
+	return matches
+}
+
+// maxSequenceDelta is the largest step between the characters of a sequence, like "aceg".
+const maxSequenceDelta = 5
+
+// sequenceMatches finds sequences like "abcd", "9753" or "ZYX".
+func sequenceMatches(password []byte) []Match {
+	if len(password) < 2 {
+		return nil
+	}
+
+	var matches []Match
+	add := func(start int, end int, delta int) {
+		if end-start > 2 || (end-start == 2 && (delta == 1 || delta == -1)) {
+			if delta != 0 && -maxSequenceDelta <= delta && delta <= maxSequenceDelta && sameClass(password[start:end]) {
+				base := 26.0
+				switch {
+				case bytes.IndexByte([]byte("aAzZ019"), password[start]) >= 0:
+					base = 4
+				case '0' <= password[start] && password[start] <= '9':
+					base = 10
+				}
+
+				matches = append(matches, Match{
+					Pattern:   PatternSequence,
+					Start:     start,
+					End:       end,
+					Ascending: delta > 0,
+					base:      math.Log10(base),
+				})
+			}
+		}
+	}
+
+	start := 0
+	lastDelta := int(password[1]) - int(password[0])
+	for index := 2; index < len(password); index++ {
+		delta := int(password[index]) - int(password[index-1])
+		if delta == lastDelta {
+			continue
+		}
+
+		add(start, index, lastDelta)
+		start = index - 1
+		lastDelta = delta
+	}
+	add(start, len(password), lastDelta)
+
+	return matches
+}
+
+// sameClass reports if all characters are lowercase letters, uppercase letters or digits.
+func sameClass(token []byte) bool {
+	classOf := func(char byte) int {
+		switch {
+		case 'a' <= char && char <= 'z':
+			return 1
+		case 'A' <= char && char <= 'Z':
+			return 2
+		case '0' <= char && char <= '9':
+			return 3
+		}
+		return 0
+	}
+
+	class := classOf(token[0])
+	for _, char := range token[1:] {
+		if classOf(char) != class {
+			return false
+		}
+	}
+
+	return class != 0
+}
+
+// repeatMatches finds repeated parts like "aaa" or "abcabc".
+// The longest repeat wins, for the same length the shortest unit.
+func repeatMatches(password []byte, userInputs []string) []Match {
+	var matches []Match
+	for start := 0; start < len(password); {
+		bestLength, bestUnit := 0, 0
+		for unit := 1; start+2*unit <= len(password); unit++ {
+			count := 1
+			for start+(count+1)*unit <= len(password) &&
+				bytes.Equal(password[start+count*unit:start+(count+1)*unit], password[start:start+unit]) {
+				count++
+			}
+
+			if count >= 2 && count*unit > bestLength {
+				bestLength, bestUnit = count*unit, unit
+			}
+		}
+
+		if bestLength == 0 {
+			start++
+			continue
+		}
+
+		unit := password[start : start+bestUnit]
+		base, _ := mostGuessableSequence(unit, omnimatch(unit, userInputs))
+		matches = append(matches, Match{
+			Pattern:    PatternRepeat,
+			Start:      start,
+			End:        start + bestLength,
+			RepeatUnit: bestUnit,
+			Repeats:    bestLength / bestUnit,
+			base:       base,
+		})
+		start += bestLength
+	}
+
+	return matches
+}
+
+// dateMatches finds years like "1990" and dates like "13.05.1990", "130590" or "1990-05-13".
+func dateMatches(password []byte) []Match {
+	var matches []Match
+	for start := range password {
+		for end := start + 4; end <= len(password) && end-start <= 10; end++ {
+			year, separator, found := parseDate(password[start:end])
+			if found {
+				matches = append(matches, Match{
+					Pattern:   PatternDate,
+					Start:     start,
+					End:       end,
+					Year:      year,
+					Separator: separator,
+				})
+			}
+		}
+	}
+
+	return matches
+}
+
+// parseDate returns the year of a year or date token.
+func parseDate(token []byte) (int, bool, bool) {
+	if isDigits(token) {
+		switch len(token) {
+		case 4:
+			year := atoi(token)
+			return year, false, 1900 <= year && year <= 2099
+		case 6:
+			year, found := splitDate(token, []int{2, 2, 2})
+			return year, false, found
+		case 8:
+			year, found := splitDate(token, []int{2, 2, 4}, []int{4, 2, 2})
+			return year, false, found
+		}
+		return 0, false, false
+	}
+
+	first := bytes.IndexFunc(token, func(char rune) bool { return char < '0' || char > '9' })
+	separator := token[first]
+	if bytes.IndexByte([]byte(" -/._\\"), separator) < 0 {
+		return 0, false, false
+	}
+
+	parts := bytes.Split(token, []byte{separator})
+	if len(parts) != 3 {
+		return 0, false, false
+	}
+
+	lengths := make([]int, 3)
+	for index, part := range parts {
+		if len(part) == 0 || !isDigits(part) {
+			return 0, false, false
+		}
+		lengths[index] = len(part)
+	}
+
+	year, found := splitDate(bytes.Join(parts, nil), lengths)
+	return year, true, found
+}
+
+// splitDate checks the digits as day, month and year in any plausible order.
+func splitDate(digits []byte, layouts ...[]int) (int, bool) {
+	for _, layout := range layouts {
+		if layout[0]+layout[1]+layout[2] != len(digits) {
+			continue
+		}
+
+		parts := []int{
+			atoi(digits[:layout[0]]),
+			atoi(digits[layout[0] : layout[0]+layout[1]]),
+			atoi(digits[layout[0]+layout[1]:]),
+		}
+
+		orders := [][3]int{{0, 1, 2}, {1, 0, 2}, {2, 1, 0}, {2, 0, 1}}
+		for _, order := range orders {
+			day, month, year := parts[order[0]], parts[order[1]], parts[order[2]]
+			yearLength := layout[order[2]]
+			if layout[order[0]] > 2 || layout[order[1]] > 2 || (yearLength != 2 && yearLength != 4) {
+				continue
+			}
+
+			if yearLength == 2 {
+				year += 1900
+				if year < referenceYear-50 {
+					year += 100
+				}
+			}
+
+			if 1 <= day && day <= 31 && 1 <= month && month <= 12 && 1900 <= year && year <= 2099 {
+				return year, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+func isDigits(token []byte) bool {
+	for _, char := range token {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+
+	return len(token) != 0
+}
+
+func atoi(digits []byte) int {
+	value := 0
+	for _, char := range digits {
+		value = value*10 + int(char-'0')
+	}
+
+	return value
+}
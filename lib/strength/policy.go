@@ -0,0 +1,236 @@
+package strength
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/NobleMajo/vault/lib/secret"
+)
+
+// Character classes that a Policy can require.
+const (
+	ClassLower  = "lower"
+	ClassUpper  = "upper"
+	ClassDigit  = "digit"
+	ClassSymbol = "symbol"
+)
+
+// Classes are all character classes.
+var Classes = []string{ClassLower, ClassUpper, ClassDigit, ClassSymbol}
+
+// ErrWeakPassword is returned if a password does not meet the policy.
+var ErrWeakPassword = errors.New("password does not meet the policy")
+
+// PolicyError lists the reasons why a password does not meet the policy.
+type PolicyError struct {
+	Reasons []string
+}
+
+func (err *PolicyError) Error() string {
+	return ErrWeakPassword.Error() + ":\n> " + strings.Join(err.Reasons, "\n> ")
+}
+
+func (err *PolicyError) Unwrap() error {
+	return ErrWeakPassword
+}
+
+// Policy are the requirements for new passwords.
+type Policy struct {
+	// MinScore is the lowest accepted score from 0 to 4.
+	MinScore  int
+	MinLength int
+	// Classes are the character classes that every password has to contain.
+	Classes []string
+	// DenyLists are files with forbidden passwords or words, one per line.
+	DenyLists []string
+}
+
+// Validate checks the score range and the class names.
+func (policy Policy) Validate() error {
+	if policy.MinScore < 0 || policy.MinScore > 4 {
+		return errors.New("minimum password score must be from 0 to 4, got " + strconv.Itoa(policy.MinScore))
+	}
+
+	if policy.MinLength < 0 {
+		return errors.New("minimum password length must not be negative")
+	}
+
+	for _, class := range policy.Classes {
+		if !slices.Contains(Classes, class) {
+			return errors.New("unknown password class '" + class + "', use " + strings.Join(Classes, ", "))
+		}
+	}
+
+	return nil
+}
+
+// Raise returns a policy that is at least as strict as the floor.
+// The minimums are raised and the classes and deny lists are merged,
+// so the floor can never be lowered.
+func (policy Policy) Raise(floor Policy) Policy {
+	raised := Policy{
+		MinScore:  max(policy.MinScore, floor.MinScore),
+		MinLength: max(policy.MinLength, floor.MinLength),
+	}
+
+	for _, class := range append(slices.Clone(floor.Classes), policy.Classes...) {
+		if !slices.Contains(raised.Classes, class) {
+			raised.Classes = append(raised.Classes, class)
+		}
+	}
+
+	for _, path := range append(slices.Clone(floor.DenyLists), policy.DenyLists...) {
+		if !slices.Contains(raised.DenyLists, path) {
+			raised.DenyLists = append(raised.DenyLists, path)
+		}
+	}
+
+	return raised
+}
+
+// LoadDenyList reads the lowercase words of deny list files.
+// Empty lines and lines that start with # are skipped.
+func LoadDenyList(paths ...string) ([]string, error) {
+	var words []string
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("Read deny list '%s' error:\n> %w", path, err)
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			word := strings.ToLower(strings.TrimSpace(scanner.Text()))
+			if len(word) != 0 && !strings.HasPrefix(word, "#") {
+				words = append(words, word)
+			}
+		}
+
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Read deny list '%s' error:\n> %w", path, err)
+		}
+	}
+
+	return words, nil
+}
+
+// Checker checks passwords against a policy.
+// The deny lists are loaded once and also used as dictionary for the estimation.
+type Checker struct {
+	policy     Policy
+	denied     map[string]bool
+	userInputs []string
+}
+
+// NewChecker validates the policy and loads its deny lists.
+// The user inputs, like the user name, make passwords that contain them weaker.
+func NewChecker(policy Policy, userInputs ...string) (*Checker, error) {
+	err := policy.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	words, err := LoadDenyList(policy.DenyLists...)
+	if err != nil {
+		return nil, err
+	}
+
+	checker := &Checker{
+		policy:     policy,
+		denied:     make(map[string]bool, len(words)),
+		userInputs: append(slices.Clone(userInputs), words...),
+	}
+	for _, word := range words {
+		checker.denied[word] = true
+	}
+
+	return checker, nil
+}
+
+// Estimate estimates the password strength with the user inputs and deny list words.
+func (checker *Checker) Estimate(password []byte) Result {
+	return Estimate(password, checker.userInputs...)
+}
+
+// Check returns a *PolicyError if the password does not meet the policy.
+func (checker *Checker) Check(password []byte) error {
+	var reasons []string
+
+	length := utf8.RuneCount(password)
+	if length < checker.policy.MinLength {
+		reasons = append(reasons, "use at least "+strconv.Itoa(checker.policy.MinLength)+" characters, got "+strconv.Itoa(length))
+	}
+
+	for _, class := range checker.policy.Classes {
+		if !containsClass(password, class) {
+			reasons = append(reasons, "use at least one "+classNames[class])
+		}
+	}
+
+	lower := toLower(password)
+	denied := checker.denied[string(lower)]
+	secret.Wipe(lower)
+	if denied {
+		reasons = append(reasons, "the password is on the deny list")
+	}
+
+	result := checker.Estimate(password)
+	if result.Score < checker.policy.MinScore {
+		reason := "the password is " + ScoreNames[result.Score] + " (score " + strconv.Itoa(result.Score) +
+			"), at least " + ScoreNames[checker.policy.MinScore] + " (score " + strconv.Itoa(checker.policy.MinScore) + ") is required"
+		if len(result.Warning) != 0 {
+			reason += ": " + result.Warning
+		}
+		reasons = append(reasons, reason)
+	}
+
+	if len(reasons) != 0 {
+		return &PolicyError{Reasons: reasons}
+	}
+
+	return nil
+}
+
+var classNames = map[string]string{
+	ClassLower:  "lowercase letter",
+	ClassUpper:  "uppercase letter",
+	ClassDigit:  "digit",
+	ClassSymbol: "symbol",
+}
+
+func containsClass(password []byte, class string) bool {
+	for _, char := range password {
+		isLower := 'a' <= char && char <= 'z'
+		isUpper := 'A' <= char && char <= 'Z'
+		isDigit := '0' <= char && char <= '9'
+
+		switch class {
+		case ClassLower:
+			if isLower {
+				return true
+			}
+		case ClassUpper:
+			if isUpper {
+				return true
+			}
+		case ClassDigit:
+			if isDigit {
+				return true
+			}
+		case ClassSymbol:
+			if !isLower && !isUpper && !isDigit {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,94 @@
+package strength
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	denyList := filepath.Join(t.TempDir(), "deny.txt")
+	err := os.WriteFile(denyList, []byte("# company words\nAcmeCorp\n\nsummer2026\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := Policy{MinScore: 3, MinLength: 10, Classes: []string{ClassUpper, ClassDigit}, DenyLists: []string{denyList}}
+	checker, err := NewChecker(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		password string
+		reasons  []string
+	}{
+		{"Kx8!vQz2#mLp9w", nil},
+		{"short", []string{"at least 10 characters", "uppercase letter", "digit", "score"}},
+		{"SUMMER2026", []string{"deny list", "score"}},
+		{"Acmecorp2026", []string{"score"}},
+	}
+
+	for _, test := range tests {
+		err := checker.Check([]byte(test.password))
+		if len(test.reasons) == 0 {
+			if err != nil {
+				t.Fatalf("Check(%q) error: %v", test.password, err)
+			}
+			continue
+		}
+
+		var policyError *PolicyError
+		if !errors.As(err, &policyError) || !errors.Is(err, ErrWeakPassword) {
+			t.Fatalf("Check(%q) = %v, want *PolicyError", test.password, err)
+		}
+
+		if len(policyError.Reasons) != len(test.reasons) {
+			t.Fatalf("Check(%q) reasons = %q, want %q", test.password, policyError.Reasons, test.reasons)
+		}
+		for index, reason := range test.reasons {
+			if !strings.Contains(policyError.Reasons[index], reason) {
+				t.Fatalf("Check(%q) reason %d = %q, want %q", test.password, index, policyError.Reasons[index], reason)
+			}
+		}
+	}
+}
+
+func TestPolicyRaise(t *testing.T) {
+	floor := Policy{MinScore: 2, MinLength: 12, Classes: []string{ClassDigit}, DenyLists: []string{"/etc/deny"}}
+	user := Policy{MinScore: 1, MinLength: 16, Classes: []string{ClassUpper, ClassDigit}, DenyLists: []string{"~/deny"}}
+
+	raised := user.Raise(floor)
+	if raised.MinScore != 2 || raised.MinLength != 16 {
+		t.Fatalf("Raise() = %+v", raised)
+	}
+
+	if strings.Join(raised.Classes, ",") != "digit,upper" || strings.Join(raised.DenyLists, ",") != "/etc/deny,~/deny" {
+		t.Fatalf("Raise() = %+v", raised)
+	}
+
+	if empty := (Policy{}).Raise(floor); empty.MinScore != 2 || empty.MinLength != 12 || len(empty.Classes) != 1 {
+		t.Fatalf("Raise() of empty policy = %+v", empty)
+	}
+}
+
+func TestPolicyValidate(t *testing.T) {
+	invalid := []Policy{
+		{MinScore: 5},
+		{MinScore: -1},
+		{MinLength: -1},
+		{Classes: []string{"emoji"}},
+	}
+
+	for _, policy := range invalid {
+		if policy.Validate() == nil {
+			t.Fatalf("Validate(%+v) succeeded", policy)
+		}
+	}
+
+	if _, err := NewChecker(Policy{DenyLists: []string{"/does/not/exist"}}); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("NewChecker() with missing deny list = %v", err)
+	}
+}
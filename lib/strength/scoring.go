@@ -0,0 +1,244 @@
+package strength
+
+import (
+	"math"
+	"sort"
+)
+
+const (
+	// bruteforceCardinality is the guesses per character of unmatched parts.
+	bruteforceCardinality = 10
+	// minSingleCharGuesses and minMultiCharGuesses are the lowest guesses of
+	// a match that is only a part of the password.
+	minSingleCharGuesses = 10
+	minMultiCharGuesses  = 50
+	// minYearSpace is the lowest distance to the reference year of a date.
+	minYearSpace = 20
+)
+
+// mostGuessableSequence finds the sequence of non overlapping matches, filled
+// with brute force parts, that needs the fewest guesses in total like zxcvbn.
+// A sequence of l matches costs l! * product(guesses) + 10000^(l-1),
+// which prefers fewer and longer matches. All values are in log10.
+func mostGuessableSequence(password []byte, matches []Match) (float64, []Match) {
+	length := len(password)
+	if length == 0 {
+		return 0, nil
+	}
+
+	for index := range matches {
+		matches[index].Log10Guesses = estimateGuesses(matches[index], length)
+	}
+
+	byEnd := make([][]Match, length)
+	for _, match := range matches {
+		byEnd[match.End-1] = append(byEnd[match.End-1], match)
+	}
+	for _, ending := range byEnd {
+		sort.Slice(ending, func(a, b int) bool { return ending[a].Start < ending[b].Start })
+	}
+
+	// optimal[k][l] is the best sequence of l matches that covers password[:k+1]
+	type step struct {
+		match  Match
+		logPi  float64
+		logG   float64
+		exists bool
+	}
+	optimal := make([][]step, length)
+	for k := range optimal {
+		optimal[k] = make([]step, length+1)
+	}
+
+	update := func(match Match, count int) {
+		k := match.End - 1
+		logPi := match.Log10Guesses
+		if count > 1 {
+			logPi += optimal[match.Start-1][count-1].logPi
+		}
+		logG := log10Sum(log10Factorial(count)+logPi, float64(count-1)*4)
+
+		for competing := 1; competing <= count; competing++ {
+			if optimal[k][competing].exists && optimal[k][competing].logG <= logG {
+				return
+			}
+		}
+
+		optimal[k][count] = step{match: match, logPi: logPi, logG: logG, exists: true}
+	}
+
+	bruteforce := func(start int, end int) Match {
+		match := Match{Pattern: PatternBruteforce, Start: start, End: end}
+		match.Log10Guesses = estimateGuesses(match, length)
+		return match
+	}
+
+	for k := 0; k < length; k++ {
+		for _, match := range byEnd[k] {
+			if match.Start == 0 {
+				update(match, 1)
+				continue
+			}
+			for count := 1; count <= length; count++ {
+				if optimal[match.Start-1][count].exists {
+					update(match, count+1)
+				}
+			}
+		}
+
+		update(bruteforce(0, k+1), 1)
+		for start := 1; start <= k; start++ {
+			for count := 1; count <= length; count++ {
+				previous := optimal[start-1][count]
+				// consecutive brute force parts are never better than one
+				if previous.exists && previous.match.Pattern != PatternBruteforce {
+					update(bruteforce(start, k+1), count+1)
+				}
+			}
+		}
+	}
+
+	best := 0
+	for count := 1; count <= length; count++ {
+		if optimal[length-1][count].exists && (best == 0 || optimal[length-1][count].logG < optimal[length-1][best].logG) {
+			best = count
+		}
+	}
+
+	sequence := make([]Match, best)
+	for k, count := length-1, best; count > 0; count-- {
+		match := optimal[k][count].match
+		sequence[count-1] = match
+		k = match.Start - 1
+	}
+
+	return optimal[length-1][best].logG, sequence
+}
+
+// estimateGuesses returns the log10 guesses of a match.
+func estimateGuesses(match Match, passwordLength int) float64 {
+	length := match.End - match.Start
+
+	var guesses float64
+	switch match.Pattern {
+	case PatternBruteforce:
+		guesses = float64(length) * math.Log10(bruteforceCardinality)
+		minimum := minMultiCharGuesses + 1.0
+		if length == 1 {
+			minimum = minSingleCharGuesses + 1.0
+		}
+		return math.Max(guesses, math.Log10(minimum))
+	case PatternDictionary:
+		guesses = math.Log10(float64(match.Rank)) + match.base
+		if match.Reversed {
+			guesses += math.Log10(2)
+		}
+	case PatternSpatial:
+		guesses = spatialGuesses(match, length)
+	case PatternSequence:
+		guesses = match.base + math.Log10(float64(length))
+		if !match.Ascending {
+			guesses += math.Log10(2)
+		}
+	case PatternRepeat:
+		guesses = match.base + math.Log10(float64(match.Repeats))
+	case PatternDate:
+		guesses = math.Log10(float64(yearSpace(match.Year)))
+		if length > 4 {
+			guesses += math.Log10(365)
+		}
+		if match.Separator {
+			guesses += math.Log10(4)
+		}
+	}
+
+	minimum := 1.0
+	if length < passwordLength {
+		minimum = minMultiCharGuesses
+		if length == 1 {
+			minimum = minSingleCharGuesses
+		}
+	}
+
+	return math.Max(guesses, math.Log10(minimum))
+}
+
+// yearSpace is the distance of a year to the current year, at least minYearSpace.
+func yearSpace(year int) int {
+	space := year - referenceYear
+	if space < 0 {
+		space = -space
+	}
+	if space < minYearSpace {
+		return minYearSpace
+	}
+
+	return space
+}
+
+// spatialGuesses counts the keyboard patterns up to the length and turns of the match.
+func spatialGuesses(match Match, length int) float64 {
+	graph := graphs[match.Graph]
+	starts := float64(len(graph.neighbors))
+	degree := graph.averageDegree()
+
+	guesses := 0.0
+	for i := 2; i <= length; i++ {
+		for j := 1; j <= min(match.Turns, i-1); j++ {
+			guesses += binomial(i-1, j-1) * starts * math.Pow(degree, float64(j))
+		}
+	}
+
+	log10Guesses := math.Log10(guesses)
+	if match.Shifted > 0 {
+		log10Guesses += caseVariations(match.Shifted, length-match.Shifted)
+	}
+
+	return log10Guesses
+}
+
+// caseVariations returns the log10 of the ways to change some of the characters,
+// like uppercase letters or l33t substitutions.
+func caseVariations(changed int, unchanged int) float64 {
+	if changed == 0 || unchanged == 0 {
+		return math.Log10(2)
+	}
+
+	variations := 0.0
+	for i := 1; i <= min(changed, unchanged); i++ {
+		variations += binomial(changed+unchanged, i)
+	}
+
+	return math.Log10(variations)
+}
+
+func binomial(n int, k int) float64 {
+	if k > n {
+		return 0
+	}
+
+	result := 1.0
+	for d := 1; d <= k; d++ {
+		result = result * float64(n-k+d) / float64(d)
+	}
+
+	return result
+}
+
+func log10Factorial(n int) float64 {
+	result := 0.0
+	for i := 2; i <= n; i++ {
+		result += math.Log10(float64(i))
+	}
+
+	return result
+}
+
+// log10Sum returns log10(10^a + 10^b) without overflow.
+func log10Sum(a float64, b float64) float64 {
+	if a < b {
+		a, b = b, a
+	}
+
+	return a + math.Log10(1+math.Pow(10, b-a))
+}
@@ -0,0 +1,176 @@
+// Package strength estimates how hard a password is to guess, similar to zxcvbn.
+// The password is split into the cheapest sequence of known patterns like
+// common passwords, dictionary words, keyboard patterns, sequences, repeats
+// and dates. Parts without a pattern are counted as brute force.
+package strength
+
+import (
+	"math"
+	"strconv"
+	"time"
+)
+
+// maxLength limits the analysed part of a password, longer passwords
+// are strong enough and the matching would only get slow.
+const maxLength = 100
+
+// GuessesPerSecond is the assumed speed of an offline attack against a slow hash.
+const GuessesPerSecond = 1e4
+
+// Pattern names of a Match.
+const (
+	PatternDictionary = "dictionary"
+	PatternSpatial    = "spatial"
+	PatternSequence   = "sequence"
+	PatternRepeat     = "repeat"
+	PatternDate       = "date"
+	PatternBruteforce = "bruteforce"
+)
+
+// Match is a part of the password that follows a pattern.
+// It only holds offsets, never the matched password bytes.
+type Match struct {
+	Pattern string
+	// Start and End are byte offsets, End is exclusive.
+	Start int
+	End   int
+	// Log10Guesses is the log10 of the guesses needed for this part.
+	Log10Guesses float64
+
+	// Dictionary is the word list of a dictionary match:
+	// passwords, words, names or user.
+	Dictionary string
+	Rank       int
+	Reversed   bool
+	L33t       bool
+	// Uppercase is set if the match contains uppercase letters.
+	Uppercase bool
+
+	// Graph is the keyboard layout of a spatial match.
+	Graph   string
+	Turns   int
+	Shifted int
+
+	// Ascending is set for sequences like abc, but not for cba.
+	Ascending bool
+	// RepeatUnit is the length of the repeated part, Repeats how often it occurs.
+	RepeatUnit int
+	Repeats    int
+	// Year is the year of a date match, Separator is set for dates like 1.2.1990.
+	Year      int
+	Separator bool
+
+	// base is the log10 of the pattern specific guesses: the case and l33t
+	// variations of words, the first character of sequences and the guesses
+	// of the repeated part.
+	base float64
+}
+
+// Result is the strength estimation of a password.
+type Result struct {
+	// Log10Guesses is the log10 of the guesses needed to find the password.
+	Log10Guesses float64
+	// Score is from 0 (too guessable) to 4 (very unguessable).
+	Score int
+	// CrackTime is the time an offline attack needs with GuessesPerSecond.
+	CrackTime time.Duration
+	// Warning explains the main weakness, it is empty for strong passwords.
+	Warning     string
+	Suggestions []string
+	Sequence    []Match
+}
+
+// ScoreNames are the names of the scores from 0 to 4.
+var ScoreNames = []string{"too guessable", "very guessable", "somewhat guessable", "safely unguessable", "very unguessable"}
+
+// Estimate estimates the strength of the password.
+// The user inputs, like user names or deny list words, are used as an additional dictionary.
+func Estimate(password []byte, userInputs ...string) Result {
+	if len(password) > maxLength {
+		password = password[:maxLength]
+	}
+
+	matches := omnimatch(password, userInputs)
+	log10Guesses, sequence := mostGuessableSequence(password, matches)
+
+	result := Result{
+		Log10Guesses: log10Guesses,
+		Score:        score(log10Guesses),
+		CrackTime:    crackTime(log10Guesses),
+		Sequence:     sequence,
+	}
+	result.Warning, result.Suggestions = feedback(result)
+
+	return result
+}
+
+// score maps the guesses to a score from 0 to 4 like zxcvbn.
+func score(log10Guesses float64) int {
+	switch {
+	case log10Guesses < 3:
+		return 0
+	case log10Guesses < 6:
+		return 1
+	case log10Guesses < 8:
+		return 2
+	case log10Guesses < 10:
+		return 3
+	}
+
+	return 4
+}
+
+// crackTime converts the guesses into the duration of an offline attack.
+// It is capped at the largest duration.
+func crackTime(log10Guesses float64) time.Duration {
+	seconds := log10Guesses - math.Log10(GuessesPerSecond)
+	if seconds > math.Log10(float64(math.MaxInt64)/float64(time.Second)) {
+		return time.Duration(math.MaxInt64)
+	}
+
+	return time.Duration(math.Pow(10, seconds) * float64(time.Second))
+}
+
+// CrackTimeText returns the crack time as human readable text, like "3 hours".
+func (result Result) CrackTimeText() string {
+	const (
+		minute  = 60
+		hour    = 60 * minute
+		day     = 24 * hour
+		month   = 31 * day
+		year    = 12 * month
+		century = 100 * year
+	)
+
+	seconds := math.Pow(10, result.Log10Guesses-math.Log10(GuessesPerSecond))
+	units := []struct {
+		name    string
+		seconds float64
+	}{
+		{"year", year},
+		{"month", month},
+		{"day", day},
+		{"hour", hour},
+		{"minute", minute},
+		{"second", 1},
+	}
+
+	switch {
+	case seconds < 1:
+		return "less than a second"
+	case seconds >= century:
+		return "centuries"
+	}
+
+	for _, unit := range units {
+		if seconds >= unit.seconds {
+			count := int(math.Round(seconds / unit.seconds))
+			if count == 1 {
+				return "1 " + unit.name
+			}
+			return strconv.Itoa(count) + " " + unit.name + "s"
+		}
+	}
+
+	return "less than a second"
+}
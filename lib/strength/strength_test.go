@@ -0,0 +1,117 @@
+package strength
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		password string
+		maxScore int
+		minScore int
+		pattern  string
+		warning  string
+	}{
+		{"password", 0, 0, PatternDictionary, "top-10 common password"},
+		{"p@ssw0rd", 0, 0, PatternDictionary, "similar to a commonly used password"},
+		{"drowssap", 0, 0, PatternDictionary, "similar to a commonly used password"},
+		{"monkey", 0, 0, PatternDictionary, "common password"},
+		{"dragonfly", 1, 0, PatternDictionary, ""},
+		{"zxcvfdsa", 1, 0, PatternSpatial, "keyboard patterns"},
+		{"asdfghjkl;", 1, 0, PatternSpatial, "Straight rows of keys"},
+		{"abcdefghij", 0, 0, PatternSequence, "Sequences"},
+		{"97531", 0, 0, PatternSequence, "Sequences"},
+		{"aaaaaaaaaa", 0, 0, PatternRepeat, "Repeats"},
+		{"13.05.1990", 1, 1, PatternDate, "Dates"},
+		{"1990", 0, 0, PatternDate, "Recent years"},
+		{"8fj3K!x0qLz", 4, 4, PatternBruteforce, ""},
+		{"correct horse battery staple", 4, 4, "", ""},
+	}
+
+	for _, test := range tests {
+		result := Estimate([]byte(test.password))
+
+		if result.Score < test.minScore || result.Score > test.maxScore {
+			t.Fatalf("Estimate(%q) score = %d, want %d to %d", test.password, result.Score, test.minScore, test.maxScore)
+		}
+
+		if len(test.pattern) != 0 && result.Sequence[0].Pattern != test.pattern {
+			t.Fatalf("Estimate(%q) pattern = %s, want %s", test.password, result.Sequence[0].Pattern, test.pattern)
+		}
+
+		if !strings.Contains(result.Warning, test.warning) {
+			t.Fatalf("Estimate(%q) warning = %q, want %q", test.password, result.Warning, test.warning)
+		}
+
+		if result.Score <= 2 && len(result.Suggestions) == 0 {
+			t.Fatalf("Estimate(%q) has no suggestions", test.password)
+		}
+	}
+}
+
+func TestEstimateSequenceCoversPassword(t *testing.T) {
+	for _, password := range []string{"Tr0ub4dour&3", "michael1984!", "qwerty123abc", "x", "ab"} {
+		result := Estimate([]byte(password))
+
+		position := 0
+		for _, match := range result.Sequence {
+			if match.Start != position || match.End <= match.Start {
+				t.Fatalf("Estimate(%q) sequence has gap or overlap at %d: %+v", password, position, match)
+			}
+			position = match.End
+		}
+
+		if position != len(password) {
+			t.Fatalf("Estimate(%q) sequence ends at %d", password, position)
+		}
+	}
+}
+
+func TestEstimateEmpty(t *testing.T) {
+	result := Estimate(nil)
+	if result.Score != 0 || len(result.Suggestions) == 0 {
+		t.Fatalf("Estimate(nil) = %+v", result)
+	}
+}
+
+func TestEstimateUserInputs(t *testing.T) {
+	without := Estimate([]byte("noblemajo"))
+	with := Estimate([]byte("noblemajo"), "NobleMajo")
+
+	if with.Log10Guesses >= without.Log10Guesses {
+		t.Fatalf("user input does not weaken password: %f >= %f", with.Log10Guesses, without.Log10Guesses)
+	}
+}
+
+func TestLongerIsStronger(t *testing.T) {
+	previous := -1.0
+	for length := 1; length <= 20; length++ {
+		result := Estimate([]byte("x7Kq2mZ9pR4vT8wN3bY6"[:length]))
+		if result.Log10Guesses <= previous {
+			t.Fatalf("length %d: %f <= %f", length, result.Log10Guesses, previous)
+		}
+		previous = result.Log10Guesses
+	}
+}
+
+func TestCrackTimeText(t *testing.T) {
+	tests := []struct {
+		log10Guesses float64
+		text         string
+	}{
+		{0, "less than a second"},
+		{4, "1 second"},
+		{6, "2 minutes"},
+		{8.3, "6 hours"},
+		{11, "4 months"},
+		{20, "centuries"},
+	}
+
+	for _, test := range tests {
+		text := Result{Log10Guesses: test.log10Guesses}.CrackTimeText()
+		if text != test.text {
+			t.Fatalf("CrackTimeText(%f) = %q, want %q", test.log10Guesses, text, test.text)
+		}
+	}
+}
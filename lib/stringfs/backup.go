@@ -0,0 +1,168 @@
+package stringfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backup is a numbered backup of a file, number 1 is the newest.
+type Backup struct {
+	Number  int
+	Path    string
+	ModTime time.Time
+	Size    int64
+}
+
+// BackupPath returns the path of a numbered backup like "name.vt.bak.1".
+func BackupPath(path string, extension string, number int) string {
+	return path + "." + extension + "." + strconv.Itoa(number)
+}
+
+// ListBackups returns the existing backups of a file sorted by number, the newest first.
+func ListBackups(path string, extension string) ([]Backup, error) {
+	prefix := path + "." + extension + "."
+
+	matches, err := filepath.Glob(globEscape(prefix) + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []Backup{}
+	for _, match := range matches {
+		number, err := strconv.Atoi(strings.TrimPrefix(match, prefix))
+		if err != nil || number < 1 {
+			continue
+		}
+
+		stat, err := os.Stat(match)
+		if err != nil || stat.IsDir() {
+			continue
+		}
+
+		backups = append(backups, Backup{
+			Number:  number,
+			Path:    match,
+			ModTime: stat.ModTime(),
+			Size:    stat.Size(),
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Number < backups[j].Number
+	})
+
+	return backups, nil
+}
+
+// RotateBackups shifts all existing backups by one number, removes the ones
+// above maxCount and saves the current file as backup number 1.
+// The current file stays in place, the backup is a hard link or a copy of it.
+func RotateBackups(path string, extension string, maxCount int) error {
+	if maxCount < 1 || !Exists(path) {
+		return nil
+	}
+
+	backups, err := ListBackups(path, extension)
+	if err != nil {
+		return errors.New("list backups error:\n> " + err.Error())
+	}
+
+	for index := len(backups) - 1; index >= 0; index-- {
+		backup := backups[index]
+
+		if backup.Number >= maxCount {
+			err = os.Remove(backup.Path)
+			if err != nil {
+				return errors.New("remove old backup error:\n> " + err.Error())
+			}
+			continue
+		}
+
+		err = os.Rename(backup.Path, BackupPath(path, extension, backup.Number+1))
+		if err != nil {
+			return errors.New("rotate backup error:\n> " + err.Error())
+		}
+	}
+
+	err = linkOrCopy(path, BackupPath(path, extension, 1))
+	if err != nil {
+		return errors.New("create backup error:\n> " + err.Error())
+	}
+
+	return nil
+}
+
+// PruneBackups removes all backups that are older than maxAge.
+func PruneBackups(path string, extension string, maxAge time.Duration, now time.Time) error {
+	if maxAge <= 0 {
+		return nil
+	}
+
+	backups, err := ListBackups(path, extension)
+	if err != nil {
+		return errors.New("list backups error:\n> " + err.Error())
+	}
+
+	for _, backup := range backups {
+		if now.Sub(backup.ModTime) > maxAge {
+			err = os.Remove(backup.Path)
+			if err != nil {
+				return errors.New("remove old backup error:\n> " + err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// linkOrCopy hard links the source to the target, with a copy as fallback
+// for file systems without hard link support.
+func linkOrCopy(source string, target string) error {
+	err := os.Link(source, target)
+	if err == nil {
+		return nil
+	}
+
+	sourceFile, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer sourceFile.Close()
+
+	stat, err := sourceFile.Stat()
+	if err != nil {
+		return err
+	}
+
+	targetFile, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, stat.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(targetFile, sourceFile)
+	if err != nil {
+		targetFile.Close()
+		os.Remove(target)
+		return err
+	}
+
+	err = targetFile.Close()
+	if err != nil {
+		os.Remove(target)
+		return err
+	}
+
+	return os.Chtimes(target, stat.ModTime(), stat.ModTime())
+}
+
+func globEscape(path string) string {
+	replacer := strings.NewReplacer("*", `\*`, "?", `\?`, "[", `\[`, `\`, `\\`)
+
+	return replacer.Replace(path)
+}
@@ -0,0 +1,92 @@
+package stringfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotateBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret.vt")
+
+	for _, content := range []string{"v1", "v2", "v3", "v4"} {
+		if err := RotateBackups(path, "bak", 2); err != nil {
+			t.Fatalf("RotateBackups: %v", err)
+		}
+		if err := SafeWriteFile(path, content, 0o600); err != nil {
+			t.Fatalf("SafeWriteFile: %v", err)
+		}
+	}
+
+	backups, err := ListBackups(path, "bak")
+	if err != nil {
+		t.Fatalf("ListBackups: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups, got %d", len(backups))
+	}
+
+	expected := []string{"v3", "v2"}
+	for index, backup := range backups {
+		if backup.Number != index+1 {
+			t.Fatalf("backup %d has number %d", index, backup.Number)
+		}
+
+		got, err := ReadFile(backup.Path)
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+		if got != expected[index] {
+			t.Fatalf("backup %d = %q, want %q", backup.Number, got, expected[index])
+		}
+	}
+
+	got, err := ReadFile(path)
+	if err != nil || got != "v4" {
+		t.Fatalf("current file = %q, %v, want v4", got, err)
+	}
+}
+
+func TestRotateBackupsDisabled(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret.vt")
+
+	if err := WriteFile(path, "v1", 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := RotateBackups(path, "bak", 0); err != nil {
+		t.Fatalf("RotateBackups: %v", err)
+	}
+	if Exists(BackupPath(path, "bak", 1)) {
+		t.Fatal("expected no backup with max count 0")
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret.vt")
+	now := time.Now()
+
+	for number, age := range map[int]time.Duration{1: time.Hour, 2: 48 * time.Hour} {
+		backupPath := BackupPath(path, "bak", number)
+		if err := WriteFile(backupPath, "old", 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		if err := os.Chtimes(backupPath, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatalf("Chtimes: %v", err)
+		}
+	}
+
+	if err := PruneBackups(path, "bak", 24*time.Hour, now); err != nil {
+		t.Fatalf("PruneBackups: %v", err)
+	}
+
+	if !Exists(BackupPath(path, "bak", 1)) {
+		t.Fatal("expected recent backup to be kept")
+	}
+	if Exists(BackupPath(path, "bak", 2)) {
+		t.Fatal("expected old backup to be removed")
+	}
+}
@@ -0,0 +1,28 @@
+//go:build darwin || freebsd
+
+package stringfs
+
+import (
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// CopyOnWriteFileSystem returns the name of the file system of the path,
+// if it is a copy-on-write file system where overwriting a file does not
+// destroy the old data. Otherwise it returns an empty string.
+func CopyOnWriteFileSystem(path string) string {
+	var stat unix.Statfs_t
+
+	err := unix.Statfs(path, &stat)
+	if err != nil {
+		return ""
+	}
+
+	name := strings.TrimRight(string(stat.Fstypename[:]), "\x00")
+	if name == "apfs" || name == "zfs" {
+		return name
+	}
+
+	return ""
+}
@@ -0,0 +1,25 @@
+package stringfs
+
+import "golang.org/x/sys/unix"
+
+var copyOnWriteFileSystems = map[uint32]string{
+	unix.BTRFS_SUPER_MAGIC:    "btrfs",
+	unix.BCACHEFS_SUPER_MAGIC: "bcachefs",
+	unix.F2FS_SUPER_MAGIC:     "f2fs",
+	unix.NILFS_SUPER_MAGIC:    "nilfs",
+	0x2fc12fc1:                "zfs",
+}
+
+// CopyOnWriteFileSystem returns the name of the file system of the path,
+// if it is a copy-on-write or log-structured file system where overwriting
+// a file does not destroy the old data. Otherwise it returns an empty string.
+func CopyOnWriteFileSystem(path string) string {
+	var stat unix.Statfs_t
+
+	err := unix.Statfs(path, &stat)
+	if err != nil {
+		return ""
+	}
+
+	return copyOnWriteFileSystems[uint32(stat.Type)]
+}
@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd)
+
+package stringfs
+
+// CopyOnWriteFileSystem can not detect the file system type on this platform.
+func CopyOnWriteFileSystem(path string) string {
+	return ""
+}
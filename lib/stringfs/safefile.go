@@ -2,39 +2,132 @@ package stringfs
 
 import (
 	"errors"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
 )
 
+// RemoveTmpSafeFile removes temporary files that SafeWriteFileBytes left behind
+// for the path, e.g. after a crash. Only call it while no other process writes the path.
 func RemoveTmpSafeFile(path string) {
 	dir, file := filepath.Split(path)
 
-	RemoveFile(dir + ".tmp_" + file)
+	matches, err := filepath.Glob(globEscape(dir) + ".tmp_*_" + globEscape(file))
+	if err != nil {
+		return
+	}
+
+	for _, match := range matches {
+		RemoveFile(match)
+	}
 }
 
 func SafeWriteFile(path string, content string, mode fs.FileMode) error {
 	return SafeWriteFileBytes(path, []byte(content), mode)
 }
 
+// SafeWriteFileBytes atomically replaces the file at path with the content.
+//
+// The content is written to a new temporary file with a random name (created with O_EXCL)
+// in the same directory, synced to disk and then renamed over the target.
+// Afterwards the directory is synced, so the rename survives a power loss.
+//
+// If the target already exists, its permissions, owner, group and extended attributes
+// are kept and mode is ignored. On every error the temporary file is removed.
 func SafeWriteFileBytes(path string, content []byte, mode fs.FileMode) error {
+	return SafeWriteFileFunc(path, mode, func(writer io.Writer) error {
+		_, err := writer.Write(content)
+		if err != nil {
+			return errors.New("Write file error: " + err.Error())
+		}
+
+		return nil
+	})
+}
+
+// SafeWriteFileFunc atomically replaces the file at path like SafeWriteFileBytes,
+// the content is streamed by write into the temporary file.
+// If write fails, the target is not changed and its error is returned as it is.
+func SafeWriteFileFunc(path string, mode fs.FileMode, write func(writer io.Writer) error) error {
 	dir, file := filepath.Split(path)
+	if len(dir) == 0 {
+		dir = "."
+	}
 
-	err := os.WriteFile(
-		dir+".tmp_"+file,
-		content,
-		mode,
-	)
+	targetStat, err := os.Stat(path)
+	if err == nil {
+		mode = targetStat.Mode().Perm()
+	} else if errors.Is(err, os.ErrNotExist) {
+		targetStat = nil
+	} else {
+		return errors.New("Stat file error: " + err.Error())
+	}
 
+	tmpFile, err := os.CreateTemp(dir, ".tmp_*_"+file)
 	if err != nil {
-		return errors.New("Write file error: " + err.Error())
+		return errors.New("Create temp file error: " + err.Error())
+	}
+	tmpPath := tmpFile.Name()
+
+	err = writeTmpSafeFile(tmpFile, path, targetStat, write, mode)
+	closeErr := tmpFile.Close()
+	if err == nil && closeErr != nil {
+		err = errors.New("Close file error: " + closeErr.Error())
 	}
 
-	err = os.Rename(dir+".tmp_"+file, path)
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
 
+	err = os.Rename(tmpPath, path)
 	if err != nil {
+		os.Remove(tmpPath)
 		return errors.New("Rename file error: " + err.Error())
 	}
 
+	err = syncDir(dir)
+	if err != nil {
+		return errors.New("Sync dir error: " + err.Error())
+	}
+
+	return nil
+}
+
+func writeTmpSafeFile(
+	tmpFile *os.File,
+	path string,
+	targetStat fs.FileInfo,
+	write func(writer io.Writer) error,
+	mode fs.FileMode,
+) error {
+	err := write(tmpFile)
+	if err != nil {
+		return err
+	}
+
+	err = tmpFile.Chmod(mode)
+	if err != nil {
+		return errors.New("Chmod file error: " + err.Error())
+	}
+
+	if targetStat != nil {
+		err = copyOwner(tmpFile, targetStat)
+		if err != nil {
+			return errors.New("Chown file error: " + err.Error())
+		}
+
+		err = copyXattrs(path, tmpFile)
+		if err != nil {
+			return errors.New("Copy extended attributes error: " + err.Error())
+		}
+	}
+
+	err = tmpFile.Sync()
+	if err != nil {
+		return errors.New("Sync file error: " + err.Error())
+	}
+
 	return nil
 }
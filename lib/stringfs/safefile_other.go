@@ -0,0 +1,20 @@
+//go:build !unix
+
+package stringfs
+
+import (
+	"io/fs"
+	"os"
+)
+
+func copyOwner(file *os.File, targetStat fs.FileInfo) error {
+	return nil
+}
+
+func linkCount(stat fs.FileInfo) uint64 {
+	return 1
+}
+
+func syncDir(dir string) error {
+	return nil
+}
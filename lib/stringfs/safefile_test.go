@@ -1,11 +1,24 @@
 package stringfs
 
 import (
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
 )
 
+func tmpSafeFiles(t *testing.T, path string) []string {
+	t.Helper()
+
+	dir, file := filepath.Split(path)
+	matches, err := filepath.Glob(filepath.Join(dir, ".tmp_*_"+file))
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+	return matches
+}
+
 func TestSafeWriteFile(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "secret.txt")
@@ -22,23 +35,116 @@ func TestSafeWriteFile(t *testing.T) {
 		t.Fatalf("ReadFile = %q, want payload", got)
 	}
 
-	tmpPath := filepath.Join(dir, ".tmp_secret.txt")
-	if Exists(tmpPath) {
-		t.Fatalf("expected temp file removed after rename, still exists at %s", tmpPath)
+	if tmpFiles := tmpSafeFiles(t, path); len(tmpFiles) != 0 {
+		t.Fatalf("expected temp file removed after rename, still exists at %v", tmpFiles)
+	}
+}
+
+func TestSafeWriteFileNewFileMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.txt")
+
+	if err := SafeWriteFile(path, "payload", 0o640); err != nil {
+		t.Fatalf("SafeWriteFile: %v", err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if stat.Mode().Perm() != 0o640 {
+		t.Fatalf("mode = %v, want 0640", stat.Mode().Perm())
+	}
+}
+
+func TestSafeWriteFileKeepsTargetMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.txt")
+
+	if err := os.WriteFile(path, []byte("old"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		t.Fatalf("Chmod: %v", err)
+	}
+
+	if err := SafeWriteFile(path, "new", 0o644); err != nil {
+		t.Fatalf("SafeWriteFile: %v", err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if stat.Mode().Perm() != 0o600 {
+		t.Fatalf("mode = %v, want kept 0600", stat.Mode().Perm())
+	}
+
+	got, err := ReadFile(path)
+	if err != nil || got != "new" {
+		t.Fatalf("ReadFile = %q, %v, want new", got, err)
+	}
+}
+
+func TestSafeWriteFileRemovesTempFileOnError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "target")
+
+	// renaming a file over a non-empty directory fails
+	if err := os.MkdirAll(filepath.Join(path, "child"), 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+
+	if err := SafeWriteFile(path, "payload", 0o600); err == nil {
+		t.Fatal("expected error when target is a directory")
+	}
+
+	if tmpFiles := tmpSafeFiles(t, path); len(tmpFiles) != 0 {
+		t.Fatalf("expected temp file removed after error, still exists at %v", tmpFiles)
+	}
+}
+
+func TestSafeWriteFileFuncKeepsTargetOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.txt")
+
+	if err := os.WriteFile(path, []byte("old"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	writeErr := errors.New("stream broken")
+	err := SafeWriteFileFunc(path, 0o600, func(writer io.Writer) error {
+		io.WriteString(writer, "partial")
+		return writeErr
+	})
+	if !errors.Is(err, writeErr) {
+		t.Fatalf("SafeWriteFileFunc = %v, want the write error", err)
+	}
+
+	got, err := ReadFile(path)
+	if err != nil || got != "old" {
+		t.Fatalf("ReadFile = %q, %v, want old", got, err)
+	}
+
+	if tmpFiles := tmpSafeFiles(t, path); len(tmpFiles) != 0 {
+		t.Fatalf("expected temp file removed after error, still exists at %v", tmpFiles)
 	}
 }
 
 func TestRemoveTmpSafeFile(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "secret.txt")
-	tmpPath := filepath.Join(dir, ".tmp_secret.txt")
+	tmpPath := filepath.Join(dir, ".tmp_123456_secret.txt")
+	otherTmpPath := filepath.Join(dir, ".tmp_123456_other.txt")
 
-	if err := os.WriteFile(tmpPath, []byte("tmp"), 0o600); err != nil {
-		t.Fatalf("WriteFile tmp: %v", err)
+	for _, file := range []string{tmpPath, otherTmpPath} {
+		if err := os.WriteFile(file, []byte("tmp"), 0o600); err != nil {
+			t.Fatalf("WriteFile tmp: %v", err)
+		}
 	}
 
 	RemoveTmpSafeFile(path)
 	if Exists(tmpPath) {
 		t.Fatal("expected temp file removed")
 	}
+	if !Exists(otherTmpPath) {
+		t.Fatal("expected temp file of other path to be kept")
+	}
 }
@@ -0,0 +1,52 @@
+//go:build unix
+
+package stringfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// copyOwner gives the file the owner and group of the target.
+// Without the permission to do so (not root), the current owner is kept.
+func copyOwner(file *os.File, targetStat fs.FileInfo) error {
+	sys, ok := targetStat.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+
+	err := file.Chown(int(sys.Uid), int(sys.Gid))
+	if errors.Is(err, fs.ErrPermission) {
+		return nil
+	}
+
+	return err
+}
+
+// linkCount returns the number of hard links of the file, 1 if it is unknown.
+func linkCount(stat fs.FileInfo) uint64 {
+	sys, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 1
+	}
+
+	return uint64(sys.Nlink)
+}
+
+func syncDir(dir string) error {
+	dirFile, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer dirFile.Close()
+
+	err = dirFile.Sync()
+	if errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTSUP) {
+		// some file systems do not support syncing directories
+		return nil
+	}
+
+	return err
+}
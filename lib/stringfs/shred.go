@@ -0,0 +1,125 @@
+package stringfs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ShredFile overwrites a file in place with random data before it gets removed,
+// so the content does not stay on disk. Each pass is synced to disk.
+// Before the unlink the file is renamed to a random name to also hide the file name.
+// It reports whether the content was overwritten: a file with other hard links, like a backup,
+// is only unlinked, so the other links keep their content.
+//
+// Overwriting is not reliable on copy-on-write or log-structured file systems,
+// use CopyOnWriteFileSystem to warn about that.
+func ShredFile(path string, passes int) (bool, error) {
+	stat, err := os.Lstat(path)
+	if err != nil {
+		return false, err
+	}
+
+	if !stat.Mode().IsRegular() {
+		return false, errors.New("can only shred regular files: '" + path + "'")
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return false, errors.New("open file error: " + err.Error())
+	}
+
+	// the link count of the opened file, the path could have been replaced since the lstat
+	stat, err = file.Stat()
+	if err != nil {
+		file.Close()
+		return false, errors.New("stat file error: " + err.Error())
+	}
+
+	if linkCount(stat) > 1 {
+		file.Close()
+
+		err = os.Remove(path)
+		if err != nil {
+			return false, errors.New("remove file error: " + err.Error())
+		}
+
+		return false, nil
+	}
+
+	err = overwriteFile(file, stat.Size(), passes)
+	closeErr := file.Close()
+	if err != nil {
+		return false, err
+	}
+	if closeErr != nil {
+		return false, errors.New("close file error: " + closeErr.Error())
+	}
+
+	dir, _ := filepath.Split(path)
+	if len(dir) == 0 {
+		dir = "."
+	}
+
+	randomName, err := randomFileName()
+	if err != nil {
+		return false, err
+	}
+	randomPath := filepath.Join(dir, randomName)
+
+	err = os.Rename(path, randomPath)
+	if err != nil {
+		return false, errors.New("rename file error: " + err.Error())
+	}
+
+	err = os.Remove(randomPath)
+	if err != nil {
+		return false, errors.New("remove file error: " + err.Error())
+	}
+
+	err = syncDir(dir)
+	if err != nil {
+		return false, errors.New("sync dir error: " + err.Error())
+	}
+
+	return true, nil
+}
+
+func overwriteFile(file *os.File, size int64, passes int) error {
+	if passes < 1 {
+		passes = 1
+	}
+
+	for pass := 1; pass <= passes; pass++ {
+		_, err := file.Seek(0, io.SeekStart)
+		if err != nil {
+			return errors.New("seek file error: " + err.Error())
+		}
+
+		_, err = io.CopyN(file, rand.Reader, size)
+		if err != nil {
+			return errors.New("overwrite pass " + strconv.Itoa(pass) + " error: " + err.Error())
+		}
+
+		err = file.Sync()
+		if err != nil {
+			return errors.New("sync pass " + strconv.Itoa(pass) + " error: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+func randomFileName() (string, error) {
+	randomBytes := make([]byte, 12)
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return "." + hex.EncodeToString(randomBytes), nil
+}
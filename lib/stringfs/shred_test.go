@@ -0,0 +1,93 @@
+package stringfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShredFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret.txt")
+
+	if err := WriteFile(path, "top secret", 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if shredded, err := ShredFile(path, 2); err != nil || !shredded {
+		t.Fatalf("ShredFile: %v, shredded %v", err, shredded)
+	}
+
+	if Exists(path) {
+		t.Fatal("expected file removed")
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected empty dir, found %d entries", len(entries))
+	}
+}
+
+func TestShredFileOverwritesContent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.txt")
+
+	if err := WriteFile(path, "top secret", 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	// an open file keeps the inode alive, so the overwritten content is visible
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer file.Close()
+
+	if shredded, err := ShredFile(path, 1); err != nil || !shredded {
+		t.Fatalf("ShredFile: %v, shredded %v", err, shredded)
+	}
+
+	got := make([]byte, len("top secret"))
+	if _, err := file.ReadAt(got, 0); err != nil {
+		t.Fatalf("ReadAt: %v", err)
+	}
+	if string(got) == "top secret" {
+		t.Fatalf("expected overwritten content, got %q", got)
+	}
+}
+
+func TestShredFileKeepsHardLinks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.txt")
+
+	if err := WriteFile(path, "top secret", 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	// backups are hard links of the vault file
+	linkPath := path + ".bak.1"
+	if err := os.Link(path, linkPath); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+
+	shredded, err := ShredFile(path, 1)
+	if err != nil || shredded {
+		t.Fatalf("ShredFile: %v, shredded %v, want only unlinked", err, shredded)
+	}
+
+	if Exists(path) {
+		t.Fatal("expected file removed")
+	}
+
+	got, err := ReadFile(linkPath)
+	if err != nil || got != "top secret" {
+		t.Fatalf("ReadFile of the hard link = %q, %v, want the kept content", got, err)
+	}
+}
+
+func TestShredFileRejectsDirectory(t *testing.T) {
+	if _, err := ShredFile(t.TempDir(), 1); err == nil {
+		t.Fatal("expected error for directory")
+	}
+}
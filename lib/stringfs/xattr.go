@@ -0,0 +1,66 @@
+//go:build linux || darwin || freebsd || netbsd
+
+package stringfs
+
+import (
+	"bytes"
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// copyXattrs copies the extended attributes of the source path to the file.
+// Attributes that can not be read or written without privileges are skipped.
+func copyXattrs(source string, file *os.File) error {
+	size, err := unix.Listxattr(source, nil)
+	if err != nil {
+		if skippableXattrError(err) {
+			return nil
+		}
+		return err
+	}
+	if size == 0 {
+		return nil
+	}
+
+	names := make([]byte, size)
+	size, err = unix.Listxattr(source, names)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range bytes.Split(names[:size], []byte{0}) {
+		if len(name) == 0 {
+			continue
+		}
+
+		err = copyXattr(source, file, string(name))
+		if err != nil && !skippableXattrError(err) {
+			return errors.New("attribute '" + string(name) + "': " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+func copyXattr(source string, file *os.File, name string) error {
+	size, err := unix.Getxattr(source, name, nil)
+	if err != nil {
+		return err
+	}
+
+	value := make([]byte, size)
+	size, err = unix.Getxattr(source, name, value)
+	if err != nil {
+		return err
+	}
+
+	return unix.Fsetxattr(int(file.Fd()), name, value[:size], 0)
+}
+
+func skippableXattrError(err error) bool {
+	return errors.Is(err, unix.ENOTSUP) ||
+		errors.Is(err, unix.EPERM) ||
+		errors.Is(err, unix.EACCES)
+}
@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || freebsd || netbsd)
+
+package stringfs
+
+import "os"
+
+func copyXattrs(source string, file *os.File) error {
+	return nil
+}
@@ -0,0 +1,35 @@
+//go:build linux
+
+package stringfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestSafeWriteFileKeepsXattrs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret.txt")
+
+	if err := os.WriteFile(path, []byte("old"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := unix.Setxattr(path, "user.vault.test", []byte("value"), 0); err != nil {
+		t.Skipf("file system does not support user xattrs: %v", err)
+	}
+
+	if err := SafeWriteFile(path, "new", 0o600); err != nil {
+		t.Fatalf("SafeWriteFile: %v", err)
+	}
+
+	value := make([]byte, 64)
+	size, err := unix.Getxattr(path, "user.vault.test", value)
+	if err != nil {
+		t.Fatalf("Getxattr: %v", err)
+	}
+	if string(value[:size]) != "value" {
+		t.Fatalf("xattr = %q, want value", value[:size])
+	}
+}
@@ -0,0 +1,132 @@
+package textdiff
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Redacted describes the difference between a and b without revealing any line content.
+// Lines that look like "key=value" or "key: value" are reported by their key,
+// all other lines only by their line number.
+// An empty string is returned if both texts are equal.
+func Redacted(oldName string, newName string, a string, b string) string {
+	lines := Diff(SplitLines(a), SplitLines(b))
+	if !HasChanges(lines) {
+		return ""
+	}
+
+	insertedKeys := map[string]Line{}
+	for _, line := range lines {
+		if line.Kind != Insert {
+			continue
+		}
+		if key := LineKey(line.Text); len(key) != 0 {
+			if _, exists := insertedKeys[key]; !exists {
+				insertedKeys[key] = line
+			}
+		}
+	}
+
+	// a key that is removed and added again is reported once as changed
+	changedKeys := map[string]bool{}
+	for _, line := range lines {
+		if line.Kind != Delete {
+			continue
+		}
+		if key := LineKey(line.Text); len(key) != 0 {
+			if _, ok := insertedKeys[key]; ok {
+				changedKeys[key] = true
+			}
+		}
+	}
+
+	var builder strings.Builder
+	builder.WriteString("--- " + oldName + "\n")
+	builder.WriteString("+++ " + newName + "\n")
+
+	for _, line := range lines {
+		key := LineKey(line.Text)
+
+		switch line.Kind {
+		case Delete:
+			if changedKeys[key] {
+				inserted := insertedKeys[key]
+				builder.WriteString(
+					"~ line " + strconv.Itoa(line.OldLine) +
+						" -> " + strconv.Itoa(inserted.NewLine) +
+						": " + key + " changed\n",
+				)
+			} else if len(key) != 0 {
+				builder.WriteString("- line " + strconv.Itoa(line.OldLine) + ": " + key + " removed\n")
+			} else {
+				builder.WriteString("- line " + strconv.Itoa(line.OldLine) + " removed\n")
+			}
+		case Insert:
+			if changedKeys[key] && insertedKeys[key] == line {
+				continue
+			} else if len(key) != 0 {
+				builder.WriteString("+ line " + strconv.Itoa(line.NewLine) + ": " + key + " added\n")
+			} else {
+				builder.WriteString("+ line " + strconv.Itoa(line.NewLine) + " added\n")
+			}
+		}
+	}
+
+	return builder.String()
+}
+
+// LineKey returns the key of a "key=value", "export key=value" or "key: value" line
+// or an empty string if the line has no recognizable key.
+// Only identifier-like keys are accepted, so bare values like base64 with "=" padding
+// or "user:password" are never reported.
+func LineKey(line string) string {
+	line = strings.TrimSpace(line)
+	if len(line) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+		return ""
+	}
+
+	line = strings.TrimPrefix(line, "export ")
+
+	separator := strings.IndexAny(line, "=:")
+	if separator <= 0 {
+		return ""
+	}
+
+	// "key=" without a value and "abc==" look like base64 padding,
+	// a colon needs a space or the line end after it like in yaml
+	rest := line[separator+1:]
+	if line[separator] == '=' && (len(rest) == 0 || rest[0] == '=') {
+		return ""
+	}
+	if line[separator] == ':' && len(rest) != 0 && rest[0] != ' ' && rest[0] != '\t' {
+		return ""
+	}
+
+	key := strings.TrimSpace(line[:separator])
+	key = strings.Trim(key, `"'`)
+
+	if !isIdentifier(key) {
+		return ""
+	}
+
+	return key
+}
+
+// isIdentifier reports keys like [A-Za-z_][A-Za-z0-9_.-]*.
+func isIdentifier(key string) bool {
+	if len(key) == 0 {
+		return false
+	}
+
+	for index, char := range key {
+		letter := char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char == '_'
+		if index == 0 && !letter {
+			return false
+		}
+		if !letter && !(char >= '0' && char <= '9') && char != '.' && char != '-' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,313 @@
+package textdiff
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Kind int
+
+const (
+	Equal Kind = iota
+	Delete
+	Insert
+)
+
+// Line is one line of an edit script.
+// OldLine and NewLine are 1-based line numbers, 0 if the line does not exist on that side.
+type Line struct {
+	Kind    Kind
+	Text    string
+	OldLine int
+	NewLine int
+}
+
+// SplitLines splits a text into lines without the line endings.
+// A trailing newline does not create an additional empty line.
+func SplitLines(text string) []string {
+	if len(text) == 0 {
+		return nil
+	}
+
+	text = strings.TrimSuffix(text, "\n")
+
+	return strings.Split(text, "\n")
+}
+
+// Diff returns the shortest edit script that turns a into b
+// using the linear space variant of the Myers difference algorithm,
+// which splits the texts at the middle of the edit path.
+// Deletes come before inserts in every changed block.
+func Diff(a []string, b []string) []Line {
+	lines := make([]Line, 0, max(len(a), len(b)))
+	lines = diffRange(lines, a, b, 0, len(a), 0, len(b))
+
+	// sorts every block of changes, the split can leave inserts before deletes
+	for start := 0; start < len(lines); {
+		if lines[start].Kind == Equal {
+			start++
+			continue
+		}
+
+		end := start
+		for end < len(lines) && lines[end].Kind != Equal {
+			end++
+		}
+		sort.SliceStable(lines[start:end], func(i, j int) bool {
+			return lines[start+i].Kind == Delete && lines[start+j].Kind == Insert
+		})
+		start = end
+	}
+
+	return lines
+}
+
+// diffRange appends the edit script of a[aStart:aEnd] and b[bStart:bEnd].
+func diffRange(lines []Line, a []string, b []string, aStart int, aEnd int, bStart int, bEnd int) []Line {
+	for aStart < aEnd && bStart < bEnd && a[aStart] == b[bStart] {
+		lines = append(lines, Line{Equal, a[aStart], aStart + 1, bStart + 1})
+		aStart++
+		bStart++
+	}
+
+	suffix := 0
+	for aStart < aEnd-suffix && bStart < bEnd-suffix && a[aEnd-suffix-1] == b[bEnd-suffix-1] {
+		suffix++
+	}
+	aEnd -= suffix
+	bEnd -= suffix
+
+	if aStart == aEnd {
+		for y := bStart; y < bEnd; y++ {
+			lines = append(lines, Line{Insert, b[y], 0, y + 1})
+		}
+	} else if bStart == bEnd {
+		for x := aStart; x < aEnd; x++ {
+			lines = append(lines, Line{Delete, a[x], x + 1, 0})
+		}
+	} else if x, y, found := middleSplit(a[aStart:aEnd], b[bStart:bEnd]); found {
+		lines = diffRange(lines, a, b, aStart, aStart+x, bStart, bStart+y)
+		lines = diffRange(lines, a, b, aStart+x, aEnd, bStart+y, bEnd)
+	} else {
+		for x := aStart; x < aEnd; x++ {
+			lines = append(lines, Line{Delete, a[x], x + 1, 0})
+		}
+		for y := bStart; y < bEnd; y++ {
+			lines = append(lines, Line{Insert, b[y], 0, y + 1})
+		}
+	}
+
+	for index := 0; index < suffix; index++ {
+		lines = append(lines, Line{Equal, a[aEnd+index], aEnd + index + 1, bEnd + index + 1})
+	}
+
+	return lines
+}
+
+// middleSplit runs the Myers search forward from the start and backward from the end
+// until both paths overlap and returns the overlap point, it only needs O(n+m) memory.
+// found is false if a and b have no line in common.
+func middleSplit(a []string, b []string) (int, int, bool) {
+	n := len(a)
+	m := len(b)
+	maxSteps := (n + m + 1) / 2
+	offset := maxSteps + 1
+	length := 2*maxSteps + 3
+
+	forward := make([]int, length)
+	backward := make([]int, length)
+	for index := range forward {
+		forward[index] = -1
+		backward[index] = -1
+	}
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+
+	delta := n - m
+	// with an odd delta the forward path reaches the overlap first
+	odd := delta%2 != 0
+
+	// diagonals that left the edit graph are skipped
+	forwardStart, forwardEnd, backwardStart, backwardEnd := 0, 0, 0, 0
+
+	for d := 0; d < maxSteps; d++ {
+		for k := -d + forwardStart; k <= d-forwardEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				x = forward[offset+k+1]
+			} else {
+				x = forward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			forward[offset+k] = x
+
+			if x > n {
+				forwardEnd += 2
+			} else if y > m {
+				forwardStart += 2
+			} else if odd {
+				backwardIndex := offset + delta - k
+				if backwardIndex >= 0 && backwardIndex < length && backward[backwardIndex] != -1 &&
+					x >= n-backward[backwardIndex] {
+					return x, y, true
+				}
+			}
+		}
+
+		for k := -d + backwardStart; k <= d-backwardEnd; k += 2 {
+			var x int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				x = backward[offset+k+1]
+			} else {
+				x = backward[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[n-x-1] == b[m-y-1] {
+				x++
+				y++
+			}
+			backward[offset+k] = x
+
+			if x > n {
+				backwardEnd += 2
+			} else if y > m {
+				backwardStart += 2
+			} else if !odd {
+				forwardIndex := offset + delta - k
+				if forwardIndex >= 0 && forwardIndex < length && forward[forwardIndex] != -1 {
+					forwardX := forward[forwardIndex]
+					forwardY := forwardX - (forwardIndex - offset)
+					if forwardX >= n-x {
+						return forwardX, forwardY, true
+					}
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// HasChanges returns true if the edit script contains inserts or deletes.
+func HasChanges(lines []Line) bool {
+	for _, line := range lines {
+		if line.Kind != Equal {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Unified formats the difference between a and b as unified diff
+// with the given number of context lines.
+// An empty string is returned if both texts are equal.
+func Unified(oldName string, newName string, a string, b string, context int) string {
+	lines := Diff(SplitLines(a), SplitLines(b))
+	if !HasChanges(lines) {
+		return ""
+	}
+
+	var builder strings.Builder
+	builder.WriteString("--- " + oldName + "\n")
+	builder.WriteString("+++ " + newName + "\n")
+
+	for start := 0; start < len(lines); {
+		if lines[start].Kind == Equal {
+			start++
+			continue
+		}
+
+		hunkStart := start - context
+		if hunkStart < 0 {
+			hunkStart = 0
+		}
+
+		// extend the hunk until more than 2*context equal lines follow
+		hunkEnd := start
+		equalRun := 0
+		for i := start; i < len(lines); i++ {
+			if lines[i].Kind == Equal {
+				equalRun++
+				if equalRun > 2*context {
+					break
+				}
+			} else {
+				equalRun = 0
+				hunkEnd = i
+			}
+		}
+
+		hunkEnd += context + 1
+		if hunkEnd > len(lines) {
+			hunkEnd = len(lines)
+		}
+
+		oldBefore, newBefore := 0, 0
+		for _, line := range lines[:hunkStart] {
+			if line.Kind != Insert {
+				oldBefore++
+			}
+			if line.Kind != Delete {
+				newBefore++
+			}
+		}
+
+		writeHunk(&builder, lines[hunkStart:hunkEnd], oldBefore, newBefore)
+		start = hunkEnd
+	}
+
+	return builder.String()
+}
+
+func writeHunk(builder *strings.Builder, hunk []Line, oldBefore int, newBefore int) {
+	oldCount, newCount := 0, 0
+
+	for _, line := range hunk {
+		if line.Kind != Insert {
+			oldCount++
+		}
+		if line.Kind != Delete {
+			newCount++
+		}
+	}
+
+	// an empty range starts at the line before, like "-0,0" for an empty old text
+	oldStart := oldBefore
+	if oldCount != 0 {
+		oldStart++
+	}
+	newStart := newBefore
+	if newCount != 0 {
+		newStart++
+	}
+
+	builder.WriteString(
+		"@@ -" + hunkRange(oldStart, oldCount) +
+			" +" + hunkRange(newStart, newCount) + " @@\n",
+	)
+
+	for _, line := range hunk {
+		switch line.Kind {
+		case Equal:
+			builder.WriteString(" " + line.Text + "\n")
+		case Delete:
+			builder.WriteString("-" + line.Text + "\n")
+		case Insert:
+			builder.WriteString("+" + line.Text + "\n")
+		}
+	}
+}
+
+func hunkRange(start int, count int) string {
+	if count == 1 {
+		return strconv.Itoa(start)
+	}
+
+	return strconv.Itoa(start) + "," + strconv.Itoa(count)
+}
@@ -0,0 +1,221 @@
+package textdiff
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func TestDiffEqual(t *testing.T) {
+	lines := Diff([]string{"a", "b"}, []string{"a", "b"})
+	if HasChanges(lines) {
+		t.Fatalf("expected no changes, got %v", lines)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+}
+
+func TestDiffEditScript(t *testing.T) {
+	a := []string{"a", "b", "c", "a", "b", "b", "a"}
+	b := []string{"c", "b", "a", "b", "a", "c"}
+
+	lines := Diff(a, b)
+
+	edits := 0
+	var oldSide, newSide []string
+	for _, line := range lines {
+		switch line.Kind {
+		case Equal:
+			oldSide = append(oldSide, line.Text)
+			newSide = append(newSide, line.Text)
+		case Delete:
+			edits++
+			oldSide = append(oldSide, line.Text)
+		case Insert:
+			edits++
+			newSide = append(newSide, line.Text)
+		}
+	}
+
+	if edits != 5 {
+		t.Fatalf("expected shortest edit script with 5 edits, got %d", edits)
+	}
+	if !equalLines(oldSide, a) || !equalLines(newSide, b) {
+		t.Fatalf("edit script does not rebuild inputs:\n%v\n%v", oldSide, newSide)
+	}
+}
+
+func TestDiffMinimal(t *testing.T) {
+	random := rand.New(rand.NewPCG(1, 2))
+	randomLines := func() []string {
+		lines := make([]string, random.IntN(30))
+		for index := range lines {
+			lines[index] = string(rune('a' + random.IntN(4)))
+		}
+		return lines
+	}
+
+	for round := 0; round < 500; round++ {
+		a := randomLines()
+		b := randomLines()
+
+		var oldSide, newSide []string
+		edits := 0
+		for _, line := range Diff(a, b) {
+			if line.Kind != Insert {
+				oldSide = append(oldSide, line.Text)
+				if a[line.OldLine-1] != line.Text {
+					t.Fatalf("wrong old line number in %v", line)
+				}
+			}
+			if line.Kind != Delete {
+				newSide = append(newSide, line.Text)
+				if b[line.NewLine-1] != line.Text {
+					t.Fatalf("wrong new line number in %v", line)
+				}
+			}
+			if line.Kind != Equal {
+				edits++
+			}
+		}
+
+		if !equalLines(oldSide, a) || !equalLines(newSide, b) {
+			t.Fatalf("edit script does not rebuild %v -> %v", a, b)
+		}
+		if want := len(a) + len(b) - 2*longestCommon(a, b); edits != want {
+			t.Fatalf("%v -> %v: %d edits, want %d", a, b, edits, want)
+		}
+	}
+}
+
+// longestCommon is the length of the longest common subsequence.
+func longestCommon(a []string, b []string) int {
+	table := make([][]int, len(a)+1)
+	for x := range table {
+		table[x] = make([]int, len(b)+1)
+	}
+	for x := len(a) - 1; x >= 0; x-- {
+		for y := len(b) - 1; y >= 0; y-- {
+			if a[x] == b[y] {
+				table[x][y] = table[x+1][y+1] + 1
+			} else {
+				table[x][y] = max(table[x+1][y], table[x][y+1])
+			}
+		}
+	}
+	return table[0][0]
+}
+
+func TestUnified(t *testing.T) {
+	tests := []struct {
+		name     string
+		a        string
+		b        string
+		expected string
+	}{
+		{
+			"equal",
+			"a\nb\n",
+			"a\nb\n",
+			"",
+		},
+		{
+			"change",
+			"a\nb\nc\n",
+			"a\nB\nc\n",
+			"--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			"from empty",
+			"",
+			"a\n",
+			"--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			"two hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			"x\n2\n3\n4\n5\n6\n7\n8\n9\ny\n",
+			"--- old\n+++ new\n@@ -1,2 +1,2 @@\n-1\n+x\n 2\n@@ -9,2 +9,2 @@\n 9\n-10\n+y\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Unified("old", "new", test.a, test.b, 1)
+			if got != test.expected {
+				t.Fatalf("expected:\n%s\ngot:\n%s", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	a := "DB_USER=admin\nDB_PASSWORD=secret\nOLD_TOKEN=abc\nsome free text\n"
+	b := "DB_USER=admin\nDB_PASSWORD=changed\nNEW_TOKEN: xyz\nsome free text\n"
+
+	expected := "--- old\n+++ new\n" +
+		"~ line 2 -> 2: DB_PASSWORD changed\n" +
+		"- line 3: OLD_TOKEN removed\n" +
+		"+ line 3: NEW_TOKEN added\n"
+
+	got := Redacted("old", "new", a, b)
+	if got != expected {
+		t.Fatalf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestRedactedBareValues(t *testing.T) {
+	a := "dGhpc2lzYXNlY3JldA==\nadmin:hunter2\n"
+	b := "bmV3c2VjcmV0\nadmin:hunter3\n"
+
+	got := Redacted("old", "new", a, b)
+	for _, secret := range []string{"dGhp", "admin", "hunter"} {
+		if strings.Contains(got, secret) {
+			t.Fatalf("redacted diff reveals %q:\n%s", secret, got)
+		}
+	}
+	if !strings.Contains(got, "- line 1 removed\n") {
+		t.Fatalf("expected line numbers only:\n%s", got)
+	}
+}
+
+func TestLineKey(t *testing.T) {
+	tests := map[string]string{
+		"KEY=value":            "KEY",
+		"export KEY=value":     "KEY",
+		"  key: value":         "key",
+		`"json_key": "v"`:      "json_key",
+		"# comment=1":          "",
+		"free text here":       "",
+		"=value":               "",
+		"with space = value":   "",
+		"dGhpc2lzYXNlY3JldA==": "",
+		"c2VjcmV0cw=":          "",
+		"EMPTY=":               "",
+		"admin:hunter2":        "",
+		"https://example.com":  "",
+		"9lives=1":             "",
+		"a+b/c=d":              "",
+		"nested:":              "nested",
+		"app.name-1: x":        "app.name-1",
+	}
+
+	for line, expected := range tests {
+		if got := LineKey(line); got != expected {
+			t.Errorf("LineKey(%q) = %q, want %q", line, got, expected)
+		}
+	}
+}
+
+func equalLines(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
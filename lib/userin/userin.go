@@ -2,84 +2,343 @@ package userin
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 	"syscall"
 
+	"github.com/NobleMajo/vault/lib/secret"
 	"golang.org/x/term"
 )
 
-func PromptNewPassword() (string, error) {
-	var newPassword string
-	var newPassword2 string
-	var err error
+// PasswordCheck rates and checks a new password, nil functions are skipped.
+type PasswordCheck struct {
+	// Meter describes the strength of the typed password, it is shown while typing.
+	Meter func(password []byte) string
+	// Check returns an error if the password is not accepted.
+	Check func(password []byte) error
+}
 
+// PromptNewPassword asks twice for a new password.
+// The check shows its meter while typing and can reject the password.
+// The caller has to destroy the returned buffer after use.
+func PromptNewPassword(check PasswordCheck) (*secret.Buffer, error) {
 	for {
-		fmt.Println("Enter your new vault password:")
-		newPassword, err = ReadPassword()
+		fmt.Fprintln(os.Stderr, "Enter your new vault password:")
+		newPassword, err := readNewPassword(check.Meter)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
-		if len(newPassword) < 4 {
-			fmt.Println("Password too short! Use CRTL+C to abort.")
+		if newPassword.Len() < 4 {
+			newPassword.Destroy()
+			fmt.Fprintln(os.Stderr, "Password too short! Use CRTL+C to abort.")
 			continue
 		}
 
-		fmt.Println("Re-enter your new vault password:")
+		if check.Check != nil {
+			if err := check.Check(newPassword.Bytes()); err != nil {
+				newPassword.Destroy()
+				fmt.Fprintln(os.Stderr, "Password rejected, "+err.Error()+"\nUse CRTL+C to abort.")
+				continue
+			}
+		}
+
+		fmt.Fprintln(os.Stderr, "Re-enter your new vault password:")
 
-		newPassword2, err = ReadPassword()
+		newPassword2, err := ReadPassword()
 		if err != nil {
-			return "", err
+			newPassword.Destroy()
+			return nil, err
 		}
 
-		if newPassword != newPassword2 {
-			fmt.Println("Passwords do not match! Use CRTL+C to abort.")
+		equal := newPassword.Equal(newPassword2)
+		newPassword2.Destroy()
+
+		if !equal {
+			newPassword.Destroy()
+			fmt.Fprintln(os.Stderr, "Passwords do not match! Use CRTL+C to abort.")
 			continue
 		}
 
-		break
+		return newPassword, nil
 	}
+}
 
-	return newPassword, nil
+// PromptPassword asks for the vault password.
+// The caller has to destroy the returned buffer after use.
+func PromptPassword() (*secret.Buffer, error) {
+	for {
+		fmt.Fprintln(os.Stderr, "Enter your vault password:")
+		password, err := ReadPassword()
+		if err != nil {
+			return nil, err
+		}
+
+		if password.Len() < 4 {
+			password.Destroy()
+			fmt.Fprintln(os.Stderr, "Password too short! Use CRTL+C to abort.")
+			continue
+		}
+
+		return password, nil
+	}
 }
 
-func PromptPassword() (string, error) {
-	var newPassword string
-	var err error
+// PromptPIN asks for the PIN of a token, like a smart card or HSM.
+// The caller has to destroy the returned buffer after use.
+func PromptPIN(tokenLabel string) (*secret.Buffer, error) {
+	fmt.Fprintln(os.Stderr, "Enter the PIN of token '"+tokenLabel+"':")
+	return ReadPassword()
+}
+
+// PromptSelect asks to choose one of the options, it returns the index.
+func PromptSelect(question string, options []string) (int, error) {
+	fmt.Fprintln(os.Stderr, question)
+	for index, option := range options {
+		fmt.Fprintln(os.Stderr, "  "+strconv.Itoa(index+1)+") "+option)
+	}
 
 	for {
-		fmt.Println("Enter your vault password:")
-		newPassword, err = ReadPassword()
+		line, err := ReadLine()
 		if err != nil {
-			return "", err
+			return -1, err
 		}
 
-		if len(newPassword) < 4 {
-			fmt.Println("Password too short! Use CRTL+C to abort.")
+		number, err := strconv.Atoi(strings.TrimSpace(line))
+		if err != nil || number < 1 || number > len(options) {
+			fmt.Fprintln(os.Stderr, "Enter a number from 1 to "+strconv.Itoa(len(options))+"! Use CRTL+C to abort.")
 			continue
 		}
 
-		break
+		return number - 1, nil
+	}
+}
+
+// ErrNoTerminal is returned if a password has to be read, but there is no terminal.
+var ErrNoTerminal = errors.New("stdin is not a terminal")
+
+// ReadPassword reads a password without echo from the terminal into a secret buffer.
+// If stdin is not a terminal (e.g. when data is piped in by git),
+// the password is read from the controlling terminal via /dev/tty.
+func ReadPassword() (*secret.Buffer, error) {
+	fd := int(syscall.Stdin)
+
+	if !term.IsTerminal(fd) {
+		tty, err := os.Open("/dev/tty")
+		if err != nil {
+			return nil, fmt.Errorf("%w and no tty is available:\n> %v", ErrNoTerminal, err)
+		}
+		defer tty.Close()
+
+		fd = int(tty.Fd())
 	}
 
-	return newPassword, nil
+	rawData, err := term.ReadPassword(fd)
+
+	if err != nil {
+		secret.Wipe(rawData)
+		return nil, err
+	}
+
+	// moves the password out of the go heap and wipes rawData
+	return secret.FromBytes(rawData)
 }
 
-func ReadPassword() (string, error) {
-	rawData, err := term.ReadPassword(int(syscall.Stdin))
+// ErrInterrupted is returned if the password input is aborted with CTRL+C.
+var ErrInterrupted = errors.New("password input interrupted")
+
+// maxPasswordLength limits passwords that are read with a strength meter.
+const maxPasswordLength = 1024
 
+// readNewPassword reads with the meter if stderr is a terminal that can show it.
+func readNewPassword(meter func(password []byte) string) (*secret.Buffer, error) {
+	if meter == nil || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return ReadPassword()
+	}
+
+	return ReadPasswordMeter(meter)
+}
+
+// ReadPasswordMeter reads a password without echo like ReadPassword and shows
+// the meter of the typed password on stderr after every key.
+// The terminal is in raw mode, so editing keys are handled here:
+// backspace, CTRL+U clears, CTRL+C aborts and enter finishes.
+func ReadPasswordMeter(meter func(password []byte) string) (*secret.Buffer, error) {
+	input := os.Stdin
+	if !term.IsTerminal(int(syscall.Stdin)) {
+		tty, err := os.Open("/dev/tty")
+		if err != nil {
+			return nil, fmt.Errorf("%w and no tty is available:\n> %v", ErrNoTerminal, err)
+		}
+		defer tty.Close()
+
+		input = tty
+	}
+
+	fd := int(input.Fd())
+	state, err := term.MakeRaw(fd)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	defer term.Restore(fd, state)
 
-	return string(rawData), nil
+	typed, err := secret.New(maxPasswordLength)
+	if err != nil {
+		return nil, err
+	}
+	defer typed.Destroy()
+
+	keys := &passwordKeys{password: typed.Bytes()}
+	redraw := func() {
+		line := meter(keys.typed())
+		if width, _, err := term.GetSize(int(os.Stderr.Fd())); err == nil && width > 0 && len([]rune(line)) >= width {
+			line = string([]rune(line)[:width-1])
+		}
+		fmt.Fprint(os.Stderr, "\r\033[K"+line)
+	}
+	redraw()
+
+	key := make([]byte, 1)
+	for {
+		count, err := input.Read(key)
+		if err != nil {
+			fmt.Fprint(os.Stderr, "\r\n")
+			return nil, err
+		}
+		if count == 0 {
+			continue
+		}
+
+		done, err := keys.press(key[0])
+		key[0] = 0
+		if err != nil {
+			fmt.Fprint(os.Stderr, "\r\n")
+			return nil, err
+		}
+
+		if done {
+			fmt.Fprint(os.Stderr, "\r\n")
+
+			result, err := secret.New(keys.length)
+			if err != nil {
+				return nil, err
+			}
+			copy(result.Bytes(), keys.typed())
+			return result, nil
+		}
+
+		redraw()
+	}
+}
+
+// escape states of passwordKeys
+const (
+	escapeNone = iota
+	// escapeStart follows ESC
+	escapeStart
+	// escapeCSI follows ESC [, it ends at a final byte like arrow keys
+	escapeCSI
+	// escapeSS3 follows ESC O, the next byte is the final one like F1 to F4
+	escapeSS3
+)
+
+// passwordKeys edits a password with the keys of a terminal in raw mode.
+type passwordKeys struct {
+	password []byte
+	length   int
+	escape   int
 }
 
+func (keys *passwordKeys) typed() []byte {
+	return keys.password[:keys.length]
+}
+
+// press handles one byte, it returns true if enter finished the password.
+func (keys *passwordKeys) press(char byte) (bool, error) {
+	switch {
+	case keys.escape == escapeStart:
+		// skips escape sequences like arrow keys and function keys up to their final byte
+		switch char {
+		case '[':
+			keys.escape = escapeCSI
+		case 'O':
+			keys.escape = escapeSS3
+		default:
+			keys.escape = escapeNone
+		}
+	case keys.escape == escapeCSI:
+		if char >= 0x40 && char <= 0x7e {
+			keys.escape = escapeNone
+		}
+	case keys.escape == escapeSS3:
+		keys.escape = escapeNone
+	case char == 0x1b:
+		keys.escape = escapeStart
+	case char == '\r' || char == '\n':
+		return true, nil
+	case char == 0x03:
+		return false, ErrInterrupted
+	case char == 0x04 && keys.length == 0:
+		return false, io.EOF
+	case char == 0x7f || char == 0x08:
+		// removes the last utf-8 character
+		for keys.length > 0 {
+			keys.length--
+			last := keys.password[keys.length]
+			keys.password[keys.length] = 0
+			if last&0xc0 != 0x80 {
+				break
+			}
+		}
+	case char == 0x15:
+		secret.Wipe(keys.typed())
+		keys.length = 0
+	case char < 0x20:
+	case keys.length < len(keys.password):
+		keys.password[keys.length] = char
+		keys.length++
+	}
+
+	return false, nil
+}
+
+// stdin is shared, so consecutive ReadLine calls do not lose buffered input
+var stdin = bufio.NewReader(os.Stdin)
+
+// tty is the shared reader of /dev/tty while stdin carries data, see ReserveStdin
+var tty *bufio.Reader
+
+// stdinReserved is set while stdin carries the data of a pipeline
+var stdinReserved bool
+
+// ReserveStdin marks stdin as data input of a pipeline,
+// ReadLine and PromptSelect read from the terminal via /dev/tty afterwards like ReadPassword.
+func ReserveStdin() {
+	stdinReserved = true
+}
+
+// ReadLine reads one line from stdin, or from /dev/tty if stdin is reserved.
 func ReadLine() (string, error) {
-	fmt.Print("> ")
-	buf := bufio.NewReader(os.Stdin)
-	rawData, err := buf.ReadBytes('\n')
+	reader := stdin
+	if stdinReserved {
+		if tty == nil {
+			file, err := os.Open("/dev/tty")
+			if err != nil {
+				return "", fmt.Errorf("%w and no tty is available:\n> %v", ErrNoTerminal, err)
+			}
+
+			tty = bufio.NewReader(file)
+		}
+
+		reader = tty
+	}
+
+	fmt.Fprint(os.Stderr, "> ")
+	rawData, err := reader.ReadBytes('\n')
 
 	if err != nil {
 		return "", err
@@ -87,3 +346,9 @@ func ReadLine() (string, error) {
 
 	return string(rawData), nil
 }
+
+// Stdin returns the shared buffered reader of stdin,
+// data read from it does not get lost for the next ReadLine call.
+func Stdin() *bufio.Reader {
+	return stdin
+}
@@ -0,0 +1,72 @@
+package userin
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func typeKeys(t *testing.T, input string) (string, bool, error) {
+	t.Helper()
+
+	keys := &passwordKeys{password: make([]byte, 16)}
+	for index := 0; index < len(input); index++ {
+		done, err := keys.press(input[index])
+		if done || err != nil {
+			return string(keys.typed()), done, err
+		}
+	}
+
+	return string(keys.typed()), false, nil
+}
+
+func TestPasswordKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"plain", "pass1234\r", "pass1234"},
+		{"backspace", "pass12345\x7f\r", "pass1234"},
+		{"backspace utf-8", "passä\x7f\r", "pass"},
+		{"clear", "wrong\x15pass\r", "pass"},
+		{"arrow keys", "pa\x1b[Dss\x1b[C\r", "pass"},
+		{"csi with parameters", "pa\x1b[1;5Dss\x1b[3~\r", "pass"},
+		{"application arrow keys", "pa\x1bOAss\x1bOB\r", "pass"},
+		{"function keys", "\x1bOPpa\x1bOQss\x1bOS\r", "pass"},
+		{"alt key", "pa\x1bxss\r", "pass"},
+		{"control keys", "pa\tss\r", "pass"},
+		{"limit", "0123456789abcdefXYZ\r", "0123456789abcdef"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			typed, done, err := typeKeys(t, test.input)
+			if err != nil || !done {
+				t.Fatalf("typeKeys(%q) = %v, done %v", test.input, err, done)
+			}
+
+			if typed != test.expected {
+				t.Fatalf("typeKeys(%q) = %q, want %q", test.input, typed, test.expected)
+			}
+		})
+	}
+}
+
+func TestPasswordKeysAbort(t *testing.T) {
+	_, _, err := typeKeys(t, "pass\x03")
+	if !errors.Is(err, ErrInterrupted) {
+		t.Fatalf("CTRL+C = %v, want ErrInterrupted", err)
+	}
+
+	_, _, err = typeKeys(t, "\x04")
+	if !errors.Is(err, io.EOF) {
+		t.Fatalf("CTRL+D = %v, want io.EOF", err)
+	}
+
+	// CTRL+D is ignored after the first key
+	typed, done, err := typeKeys(t, "pa\x04ss\r")
+	if err != nil || !done || typed != "pass" {
+		t.Fatalf("CTRL+D after keys = %q, %v, done %v", typed, err, done)
+	}
+}
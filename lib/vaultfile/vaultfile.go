@@ -0,0 +1,565 @@
+package vaultfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// Magic prefixes every vault container file.
+// Files without it are plain vault payloads of older versions.
+const Magic = "VAULT\x00"
+
+const Version = 1
+
+var (
+	// ErrTruncated is matched by errors of vault files that end too early.
+	ErrTruncated = errors.New("vault file truncated")
+	// ErrUnsupportedVersion is matched by errors of vault files or records of newer versions.
+	ErrUnsupportedVersion = errors.New("unsupported vault file version")
+	// ErrHashMismatch is matched by errors of revisions whose content does not match its hash.
+	ErrHashMismatch = errors.New("revision content hash mismatch")
+)
+
+// FormatError describes an invalid vault file, Err is one of the sentinel errors or nil.
+type FormatError struct {
+	Message string
+	Err     error
+}
+
+func (err *FormatError) Error() string {
+	return err.Message
+}
+
+func (err *FormatError) Unwrap() error {
+	return err.Err
+}
+
+const (
+	recordRevision byte = 0x01
+	recordHeader   byte = 0x02
+	recordKeySlot  byte = 0x03
+)
+
+const (
+	// flagHashed marks revision bodies that start with the sha256 hash of the content
+	flagHashed byte = 1 << 0
+	// flagParams marks revisions with encryption parameters after the user
+	flagParams byte = 1 << 1
+	// flagDataKey marks revisions that are encrypted with the data key of the key slots
+	flagDataKey byte = 1 << 2
+	// flagMeta marks revision bodies that start with the time and user of the revision
+	flagMeta byte = 1 << 3
+)
+
+const (
+	paramsAES byte = 1 << 0
+	paramsRSA byte = 1 << 1
+	// paramsKeyfile marks an AES-256 layer with keyfiles mixed into the derived key
+	paramsKeyfile byte = 1 << 2
+)
+
+// Params describe how a revision payload is encrypted, without any secret.
+type Params struct {
+	AES bool
+	// KDFIterations are the PBKDF2-SHA256 iterations of the AES-256 layer.
+	KDFIterations uint32
+	// Keyfile is true if keyfiles are mixed into the password-derived key.
+	Keyfile bool
+
+	RSA     bool
+	RSABits uint16
+	// Recipient is the sha256 hash of the ssh wire format of the rsa public key.
+	Recipient []byte
+}
+
+// SlotKind is the type of a key slot.
+type SlotKind byte
+
+const (
+	// SlotCredentials wraps the data key with the rsa and/or password layers of its Params.
+	SlotCredentials SlotKind = 1
+	// SlotRecovery wraps the data key with a recovery key that is split into shares.
+	SlotRecovery SlotKind = 2
+)
+
+// Recovery describes a recovery key that is split into shares, without any secret.
+type Recovery struct {
+	// ID identifies the shares of this recovery key.
+	ID        uint32
+	Threshold byte
+	Shares    byte
+}
+
+// KeySlot is one way to unlock the data key of a container.
+// The Payload is the encrypted data key.
+type KeySlot struct {
+	Kind     SlotKind
+	Name     string
+	Params   *Params
+	Recovery *Recovery
+	Payload  []byte
+}
+
+// Revision is one encrypted version of the vault content.
+// Time and User are stored in clear text, the Payload is vault encrypted.
+type Revision struct {
+	Time   time.Time
+	User   string
+	Hashed bool
+	// Meta revisions carry Time and User in the encrypted body too, see EncodeMeta,
+	// only those values are authenticated.
+	Meta bool
+	// DataKey revisions are encrypted with the data key of the key slots,
+	// all others with the keys described by Params.
+	DataKey bool
+	Params  *Params
+	Payload []byte
+}
+
+// Container is a vault file with a list of revisions, the oldest first.
+// The last revision is the current content of the vault.
+type Container struct {
+	// Created is the time the vault file was created, zero if unknown.
+	Created time.Time
+	// Slots wrap the data key, vault files without key slots have no data key.
+	Slots     []KeySlot
+	Revisions []Revision
+}
+
+// IsContainer returns true if the raw file content starts with the container magic.
+func IsContainer(raw []byte) bool {
+	return bytes.HasPrefix(raw, []byte(Magic))
+}
+
+// Parse reads a vault file. A plain vault payload without container magic
+// is returned as container with one unhashed revision without time and user.
+func Parse(raw []byte) (*Container, error) {
+	if len(raw) == 0 {
+		return nil, &FormatError{"empty vault file", nil}
+	}
+
+	if !IsContainer(raw) {
+		return &Container{
+			Revisions: []Revision{{Payload: raw}},
+		}, nil
+	}
+
+	raw = raw[len(Magic):]
+	if len(raw) < 1 {
+		return nil, &FormatError{"vault container too short", ErrTruncated}
+	}
+
+	version := raw[0]
+	raw = raw[1:]
+	if version != Version {
+		return nil, &FormatError{"unsupported vault container version " + strconv.Itoa(int(version)), ErrUnsupportedVersion}
+	}
+
+	container := &Container{}
+
+	for len(raw) != 0 {
+		if len(raw) < 5 {
+			return nil, &FormatError{"truncated vault container record header", ErrTruncated}
+		}
+
+		recordType := raw[0]
+		length := binary.BigEndian.Uint32(raw[1:5])
+		raw = raw[5:]
+
+		if uint64(len(raw)) < uint64(length) {
+			return nil, &FormatError{"truncated vault container record", ErrTruncated}
+		}
+
+		data := raw[:length]
+		raw = raw[length:]
+
+		if recordType == recordRevision {
+			revision, err := parseRevision(data)
+			if err != nil {
+				return nil, err
+			}
+			container.Revisions = append(container.Revisions, revision)
+		} else if recordType == recordKeySlot {
+			slot, err := parseKeySlot(data)
+			if err != nil {
+				return nil, err
+			}
+			container.Slots = append(container.Slots, slot)
+		} else if recordType == recordHeader {
+			if len(data) < 8 {
+				return nil, &FormatError{"header record too short", ErrTruncated}
+			}
+
+			createdSeconds := int64(binary.BigEndian.Uint64(data[:8]))
+			if createdSeconds != 0 {
+				container.Created = time.Unix(createdSeconds, 0)
+			}
+		}
+		// unknown records are skipped for forward compatibility
+	}
+
+	if len(container.Revisions) == 0 {
+		return nil, &FormatError{"vault container without revisions", ErrTruncated}
+	}
+
+	return container, nil
+}
+
+func parseRevision(data []byte) (Revision, error) {
+	if len(data) < 11 {
+		return Revision{}, &FormatError{"revision record too short", ErrTruncated}
+	}
+
+	flags := data[0]
+	unixSeconds := int64(binary.BigEndian.Uint64(data[1:9]))
+	userLength := int(binary.BigEndian.Uint16(data[9:11]))
+	data = data[11:]
+
+	if len(data) < userLength {
+		return Revision{}, &FormatError{"revision record user too short", ErrTruncated}
+	}
+
+	revision := Revision{
+		User:    string(data[:userLength]),
+		Hashed:  flags&flagHashed != 0,
+		Meta:    flags&flagMeta != 0,
+		DataKey: flags&flagDataKey != 0,
+	}
+	data = data[userLength:]
+
+	if flags&flagParams != 0 {
+		if len(data) < 2 {
+			return Revision{}, &FormatError{"revision record params too short", ErrTruncated}
+		}
+
+		paramsLength := int(binary.BigEndian.Uint16(data[:2]))
+		data = data[2:]
+		if len(data) < paramsLength {
+			return Revision{}, &FormatError{"revision record params too short", ErrTruncated}
+		}
+
+		params, err := parseParams(data[:paramsLength])
+		if err != nil {
+			return Revision{}, err
+		}
+
+		revision.Params = params
+		data = data[paramsLength:]
+	}
+
+	revision.Payload = data
+
+	if unixSeconds != 0 {
+		revision.Time = time.Unix(unixSeconds, 0)
+	}
+
+	return revision, nil
+}
+
+func parseKeySlot(data []byte) (KeySlot, error) {
+	if len(data) < 2 {
+		return KeySlot{}, &FormatError{"key slot record too short", ErrTruncated}
+	}
+
+	slot := KeySlot{Kind: SlotKind(data[0])}
+	nameLength := int(data[1])
+	data = data[2:]
+
+	if len(data) < nameLength+2 {
+		return KeySlot{}, &FormatError{"key slot record name too short", ErrTruncated}
+	}
+
+	slot.Name = string(data[:nameLength])
+	data = data[nameLength:]
+
+	paramsLength := int(binary.BigEndian.Uint16(data[:2]))
+	data = data[2:]
+	if len(data) < paramsLength {
+		return KeySlot{}, &FormatError{"key slot record params too short", ErrTruncated}
+	}
+
+	params := data[:paramsLength]
+	slot.Payload = data[paramsLength:]
+
+	switch slot.Kind {
+	case SlotCredentials:
+		var err error
+		slot.Params, err = parseParams(params)
+		if err != nil {
+			return KeySlot{}, err
+		}
+	case SlotRecovery:
+		if len(params) < 6 {
+			return KeySlot{}, &FormatError{"recovery key slot params too short", ErrTruncated}
+		}
+
+		slot.Recovery = &Recovery{
+			ID:        binary.BigEndian.Uint32(params[:4]),
+			Threshold: params[4],
+			Shares:    params[5],
+		}
+	default:
+		return KeySlot{}, &FormatError{"unsupported key slot kind " + strconv.Itoa(int(slot.Kind)), ErrUnsupportedVersion}
+	}
+
+	return slot, nil
+}
+
+func (slot *KeySlot) marshal() []byte {
+	name := slot.Name
+	if len(name) > 0xff {
+		name = name[:0xff]
+	}
+
+	var params []byte
+	if slot.Params != nil {
+		params = slot.Params.marshal()
+	} else if slot.Recovery != nil {
+		params = binary.BigEndian.AppendUint32(nil, slot.Recovery.ID)
+		params = append(params, slot.Recovery.Threshold, slot.Recovery.Shares)
+	}
+
+	data := []byte{byte(slot.Kind), byte(len(name))}
+	data = append(data, name...)
+	data = binary.BigEndian.AppendUint16(data, uint16(len(params)))
+	data = append(data, params...)
+
+	return append(data, slot.Payload...)
+}
+
+func parseParams(data []byte) (*Params, error) {
+	if len(data) < 8 {
+		return nil, &FormatError{"revision params too short", ErrTruncated}
+	}
+
+	flags := data[0]
+	params := &Params{
+		AES:           flags&paramsAES != 0,
+		KDFIterations: binary.BigEndian.Uint32(data[1:5]),
+		Keyfile:       flags&paramsKeyfile != 0,
+		RSA:           flags&paramsRSA != 0,
+		RSABits:       binary.BigEndian.Uint16(data[5:7]),
+	}
+
+	recipientLength := int(data[7])
+	if len(data) < 8+recipientLength {
+		return nil, &FormatError{"revision params recipient too short", ErrTruncated}
+	}
+
+	if recipientLength != 0 {
+		params.Recipient = data[8 : 8+recipientLength]
+	}
+
+	return params, nil
+}
+
+func (params *Params) marshal() []byte {
+	var flags byte
+	if params.AES {
+		flags |= paramsAES
+	}
+	if params.RSA {
+		flags |= paramsRSA
+	}
+	if params.Keyfile {
+		flags |= paramsKeyfile
+	}
+
+	recipient := params.Recipient
+	if len(recipient) > 0xff {
+		recipient = recipient[:0xff]
+	}
+
+	data := []byte{flags}
+	data = binary.BigEndian.AppendUint32(data, params.KDFIterations)
+	data = binary.BigEndian.AppendUint16(data, params.RSABits)
+	data = append(data, byte(len(recipient)))
+
+	return append(data, recipient...)
+}
+
+// Marshal encodes the container into the vault file format.
+func (container *Container) Marshal() []byte {
+	var buffer bytes.Buffer
+
+	buffer.WriteString(Magic)
+	buffer.WriteByte(Version)
+
+	if !container.Created.IsZero() {
+		buffer.WriteByte(recordHeader)
+		buffer.Write(binary.BigEndian.AppendUint32(nil, 8))
+		buffer.Write(binary.BigEndian.AppendUint64(nil, uint64(container.Created.Unix())))
+	}
+
+	for _, slot := range container.Slots {
+		data := slot.marshal()
+
+		buffer.WriteByte(recordKeySlot)
+		buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(len(data))))
+		buffer.Write(data)
+	}
+
+	for _, revision := range container.Revisions {
+		user := revision.User
+		if len(user) > 0xffff {
+			user = user[:0xffff]
+		}
+
+		var flags byte
+		if revision.Hashed {
+			flags |= flagHashed
+		}
+		if revision.Meta {
+			flags |= flagMeta
+		}
+		if revision.DataKey {
+			flags |= flagDataKey
+		}
+
+		var params []byte
+		if revision.Params != nil {
+			flags |= flagParams
+			params = revision.Params.marshal()
+		}
+
+		var unixSeconds int64
+		if !revision.Time.IsZero() {
+			unixSeconds = revision.Time.Unix()
+		}
+
+		length := 11 + len(user) + len(revision.Payload)
+		if params != nil {
+			length += 2 + len(params)
+		}
+
+		buffer.WriteByte(recordRevision)
+		buffer.Write(binary.BigEndian.AppendUint32(nil, uint32(length)))
+		buffer.WriteByte(flags)
+		buffer.Write(binary.BigEndian.AppendUint64(nil, uint64(unixSeconds)))
+		buffer.Write(binary.BigEndian.AppendUint16(nil, uint16(len(user))))
+		buffer.WriteString(user)
+		if params != nil {
+			buffer.Write(binary.BigEndian.AppendUint16(nil, uint16(len(params))))
+			buffer.Write(params)
+		}
+		buffer.Write(revision.Payload)
+	}
+
+	return buffer.Bytes()
+}
+
+// Slot returns the index of the first key slot with the name, -1 if there is none.
+func (container *Container) Slot(name string) int {
+	for index, slot := range container.Slots {
+		if slot.Name == name {
+			return index
+		}
+	}
+
+	return -1
+}
+
+// Current returns the newest revision.
+func (container *Container) Current() *Revision {
+	return &container.Revisions[len(container.Revisions)-1]
+}
+
+// Prune drops the oldest revisions until at most maxCount revisions are left
+// and drops all revisions older than maxAge. The current revision is always kept.
+// A maxCount or maxAge of 0 disables that limit.
+func (container *Container) Prune(maxCount int, maxAge time.Duration, now time.Time) {
+	current := len(container.Revisions) - 1
+	kept := []Revision{}
+
+	for index, revision := range container.Revisions {
+		if index != current {
+			if maxCount > 0 && len(container.Revisions)-index > maxCount {
+				continue
+			}
+
+			if maxAge > 0 && !revision.Time.IsZero() && now.Sub(revision.Time) > maxAge {
+				continue
+			}
+		}
+
+		kept = append(kept, revision)
+	}
+
+	container.Revisions = kept
+}
+
+// EncodeBody prefixes the content with its sha256 hash,
+// the result is what gets encrypted into a hashed revision payload.
+func EncodeBody(content []byte) []byte {
+	hash := sha256.Sum256(content)
+
+	return append(hash[:], content...)
+}
+
+// EncodeMeta prefixes the body with the time and user of the revision,
+// so they are encrypted and authenticated with the content.
+func EncodeMeta(body []byte, revisionTime time.Time, user string) []byte {
+	if len(user) > 0xffff {
+		user = user[:0xffff]
+	}
+
+	var unixSeconds int64
+	if !revisionTime.IsZero() {
+		unixSeconds = revisionTime.Unix()
+	}
+
+	meta := make([]byte, 0, 10+len(user)+len(body))
+	meta = binary.BigEndian.AppendUint64(meta, uint64(unixSeconds))
+	meta = binary.BigEndian.AppendUint16(meta, uint16(len(user)))
+	meta = append(meta, user...)
+
+	return append(meta, body...)
+}
+
+// DecodeMeta splits the time and user from the decrypted body of a Meta revision.
+func DecodeMeta(body []byte) (time.Time, string, []byte, error) {
+	if len(body) < 10 {
+		return time.Time{}, "", nil, &FormatError{"revision body meta too short", ErrTruncated}
+	}
+
+	unixSeconds := int64(binary.BigEndian.Uint64(body[:8]))
+	userLength := int(binary.BigEndian.Uint16(body[8:10]))
+	body = body[10:]
+	if len(body) < userLength {
+		return time.Time{}, "", nil, &FormatError{"revision body meta user too short", ErrTruncated}
+	}
+
+	var revisionTime time.Time
+	if unixSeconds != 0 {
+		revisionTime = time.Unix(unixSeconds, 0)
+	}
+
+	return revisionTime, string(body[:userLength]), body[userLength:], nil
+}
+
+// DecodeBody splits a decrypted revision body into content and content hash.
+// For unhashed bodies the hash is calculated, for hashed bodies it is verified.
+func DecodeBody(body []byte, hashed bool) ([]byte, []byte, error) {
+	if !hashed {
+		hash := sha256.Sum256(body)
+		return body, hash[:], nil
+	}
+
+	if len(body) < sha256.Size {
+		return nil, nil, &FormatError{"revision body too short", ErrTruncated}
+	}
+
+	hash := body[:sha256.Size]
+	content := body[sha256.Size:]
+	expectedHash := sha256.Sum256(content)
+
+	if !bytes.Equal(hash, expectedHash[:]) {
+		return nil, nil, &FormatError{"revision content hash mismatch", ErrHashMismatch}
+	}
+
+	return content, hash, nil
+}
@@ -0,0 +1,260 @@
+package vaultfile
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseLegacyPayload(t *testing.T) {
+	container, err := Parse([]byte("legacy payload"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if len(container.Revisions) != 1 {
+		t.Fatalf("expected 1 revision, got %d", len(container.Revisions))
+	}
+
+	current := container.Current()
+	if string(current.Payload) != "legacy payload" || current.Hashed || !current.Time.IsZero() {
+		t.Fatalf("unexpected legacy revision: %+v", current)
+	}
+}
+
+func TestMarshalParse(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	container := &Container{
+		Revisions: []Revision{
+			{Time: now.Add(-time.Hour), User: "alice", Hashed: true, Payload: []byte("first")},
+			{Payload: []byte("legacy")},
+			{Time: now, User: "bob", Hashed: true, Payload: []byte("second")},
+		},
+	}
+
+	raw := container.Marshal()
+	if !IsContainer(raw) {
+		t.Fatal("expected marshaled data to be a container")
+	}
+
+	parsed, err := Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if len(parsed.Revisions) != 3 {
+		t.Fatalf("expected 3 revisions, got %d", len(parsed.Revisions))
+	}
+
+	for index, revision := range parsed.Revisions {
+		expected := container.Revisions[index]
+		if !revision.Time.Equal(expected.Time) ||
+			revision.User != expected.User ||
+			revision.Hashed != expected.Hashed ||
+			!bytes.Equal(revision.Payload, expected.Payload) {
+			t.Fatalf("revision %d = %+v, want %+v", index, revision, expected)
+		}
+	}
+}
+
+func TestMarshalParseParams(t *testing.T) {
+	params := &Params{
+		AES:           true,
+		KDFIterations: 4096,
+		RSA:           true,
+		RSABits:       4096,
+		Recipient:     bytes.Repeat([]byte{0xab}, 32),
+	}
+
+	container := &Container{
+		Created: time.Unix(1600000000, 0),
+		Revisions: []Revision{
+			{User: "alice", Hashed: true, Payload: []byte("old")},
+			{User: "bob", Hashed: true, Params: params, Payload: []byte("payload")},
+		},
+	}
+
+	parsed, err := Parse(container.Marshal())
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if !parsed.Created.Equal(container.Created) {
+		t.Fatalf("Created = %v, want %v", parsed.Created, container.Created)
+	}
+
+	if parsed.Revisions[0].Params != nil {
+		t.Fatalf("unexpected params: %+v", parsed.Revisions[0].Params)
+	}
+
+	current := parsed.Current()
+	if current.User != "bob" || string(current.Payload) != "payload" {
+		t.Fatalf("unexpected revision: %+v", current)
+	}
+
+	got := current.Params
+	if got == nil || !got.AES || got.KDFIterations != 4096 || !got.RSA ||
+		got.RSABits != 4096 || !bytes.Equal(got.Recipient, params.Recipient) {
+		t.Fatalf("Params = %+v, want %+v", got, params)
+	}
+}
+
+func TestMarshalParseKeySlots(t *testing.T) {
+	container := &Container{
+		Slots: []KeySlot{
+			{
+				Kind:    SlotCredentials,
+				Name:    "default",
+				Params:  &Params{AES: true, KDFIterations: 4096},
+				Payload: []byte("wrapped"),
+			},
+			{
+				Kind:     SlotRecovery,
+				Name:     "recovery",
+				Recovery: &Recovery{ID: 0xdeadbeef, Threshold: 3, Shares: 5},
+				Payload:  []byte("recovered"),
+			},
+		},
+		Revisions: []Revision{
+			{Hashed: true, Meta: true, DataKey: true, Payload: []byte("payload")},
+		},
+	}
+
+	parsed, err := Parse(container.Marshal())
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if len(parsed.Slots) != 2 {
+		t.Fatalf("got %d key slots, want 2", len(parsed.Slots))
+	}
+
+	credentials := parsed.Slots[0]
+	if credentials.Kind != SlotCredentials || credentials.Name != "default" ||
+		credentials.Params == nil || credentials.Params.KDFIterations != 4096 ||
+		string(credentials.Payload) != "wrapped" {
+		t.Fatalf("unexpected credentials slot: %+v", credentials)
+	}
+
+	recovery := parsed.Slots[1]
+	if recovery.Kind != SlotRecovery || recovery.Recovery == nil ||
+		*recovery.Recovery != *container.Slots[1].Recovery ||
+		string(recovery.Payload) != "recovered" {
+		t.Fatalf("unexpected recovery slot: %+v", recovery)
+	}
+
+	if parsed.Slot("recovery") != 1 || parsed.Slot("missing") != -1 {
+		t.Fatalf("Slot lookup failed")
+	}
+
+	if !parsed.Current().DataKey || !parsed.Current().Meta {
+		t.Fatalf("DataKey or Meta flag lost")
+	}
+}
+
+func TestParseTruncated(t *testing.T) {
+	container := &Container{
+		Revisions: []Revision{{
+			User:    "alice",
+			Params:  &Params{AES: true, KDFIterations: 4096},
+			Payload: []byte("payload"),
+		}},
+	}
+	raw := container.Marshal()
+
+	for length := len(Magic); length < len(raw); length++ {
+		if _, err := Parse(raw[:length]); !errors.Is(err, ErrTruncated) {
+			t.Fatalf("truncated container of length %d error = %v, want ErrTruncated", length, err)
+		}
+	}
+}
+
+func TestPrune(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	newContainer := func() *Container {
+		return &Container{
+			Revisions: []Revision{
+				{Time: now.Add(-72 * time.Hour), Payload: []byte("1")},
+				{Time: now.Add(-48 * time.Hour), Payload: []byte("2")},
+				{Time: now.Add(-24 * time.Hour), Payload: []byte("3")},
+				{Time: now.Add(-100 * time.Hour), Payload: []byte("4")},
+			},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		maxCount int
+		maxAge   time.Duration
+		expected string
+	}{
+		{"unlimited", 0, 0, "1234"},
+		{"count", 2, 0, "34"},
+		{"age", 0, 50 * time.Hour, "234"},
+		{"count and age", 3, 30 * time.Hour, "34"},
+		{"keeps current", 1, time.Hour, "4"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			container := newContainer()
+			container.Prune(test.maxCount, test.maxAge, now)
+
+			got := ""
+			for _, revision := range container.Revisions {
+				got += string(revision.Payload)
+			}
+
+			if got != test.expected {
+				t.Fatalf("kept revisions %q, want %q", got, test.expected)
+			}
+		})
+	}
+}
+
+func TestBody(t *testing.T) {
+	body := EncodeBody([]byte("content"))
+
+	content, hash, err := DecodeBody(body, true)
+	if err != nil {
+		t.Fatalf("DecodeBody: %v", err)
+	}
+	if string(content) != "content" || len(hash) != 32 {
+		t.Fatalf("unexpected body content %q or hash length %d", content, len(hash))
+	}
+
+	body[len(body)-1] ^= 1
+	if _, _, err := DecodeBody(body, true); err == nil {
+		t.Fatal("expected hash mismatch error")
+	}
+
+	content, _, err = DecodeBody([]byte("plain"), false)
+	if err != nil || string(content) != "plain" {
+		t.Fatalf("DecodeBody unhashed = %q, %v", content, err)
+	}
+}
+
+func TestMeta(t *testing.T) {
+	revisionTime := time.Unix(1700000000, 0)
+	body := EncodeMeta(EncodeBody([]byte("content")), revisionTime, "alice")
+
+	decodedTime, user, rest, err := DecodeMeta(body)
+	if err != nil {
+		t.Fatalf("DecodeMeta: %v", err)
+	}
+	if !decodedTime.Equal(revisionTime) || user != "alice" {
+		t.Fatalf("DecodeMeta = %v, %q", decodedTime, user)
+	}
+
+	content, _, err := DecodeBody(rest, true)
+	if err != nil || string(content) != "content" {
+		t.Fatalf("DecodeBody after meta = %q, %v", content, err)
+	}
+
+	if _, _, _, err := DecodeMeta(body[:12]); err == nil {
+		t.Fatal("expected truncated meta error")
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/NobleMajo/vault/internal/config"
 	"github.com/NobleMajo/vault/internal/subcmd"
+	"github.com/NobleMajo/vault/lib/pkcs11"
 	"github.com/NobleMajo/vault/lib/stringfs"
 	"github.com/joho/godotenv"
 )
@@ -18,14 +19,28 @@ var Commit string = "???????"
 
 func main() {
 	err := godotenv.Load()
-        if err == nil {
-                fmt.Println("Environment variables from .env loaded")
-        }
+	if err == nil {
+		// stderr, because stdout can carry vault data (e.g. git filters)
+		fmt.Fprintln(os.Stderr, "Environment variables from .env loaded")
+	}
 
 	appConfig := config.ParseConfig(DisplayName, ShortName, Version, Commit)
+	defer subcmd.WipeSecrets()
+	subcmd.ConfigureOutput(appConfig)
 
 	stringfs.ParsePath(&appConfig.PublicKeyPath)
-	stringfs.ParsePath(&appConfig.PrivateKeyPath)
+	if !pkcs11.IsURI(appConfig.PrivateKeyPath) {
+		stringfs.ParsePath(&appConfig.PrivateKeyPath)
+	}
+	for index := range appConfig.Keyfiles {
+		stringfs.ParsePath(&appConfig.Keyfiles[index])
+	}
+	for index := range appConfig.NewKeyfiles {
+		// an empty path removes the keyfiles on passwd
+		if len(appConfig.NewKeyfiles[index]) != 0 {
+			stringfs.ParsePath(&appConfig.NewKeyfiles[index])
+		}
+	}
 	targetFile := targetFile(appConfig)
 
 	if appConfig.SubCommand == "lock" {
@@ -58,6 +73,114 @@ func main() {
 			targetFile,
 			appConfig,
 		)
+	} else if appConfig.SubCommand == "passwd-add" {
+		subcmd.PasswdAddOperation(
+			targetFile,
+			appConfig,
+		)
+	} else if appConfig.SubCommand == "passwd-remove" {
+		subcmd.PasswdRemoveOperation(
+			targetFile,
+			appConfig,
+		)
+	} else if appConfig.SubCommand == "passwd-list" {
+		subcmd.PasswdListOperation(
+			targetFile,
+			appConfig,
+		)
+	} else if appConfig.SubCommand == "split" {
+		subcmd.SplitOperation(
+			targetFile,
+			appConfig,
+		)
+	} else if appConfig.SubCommand == "recover-key" {
+		subcmd.RecoverKeyOperation(
+			targetFile,
+			appConfig,
+		)
+	} else if appConfig.SubCommand == "log" {
+		subcmd.LogOperation(
+			targetFile,
+			appConfig,
+		)
+	} else if appConfig.SubCommand == "revert" {
+		subcmd.RevertOperation(
+			targetFile,
+			appConfig.Revision,
+			appConfig,
+		)
+	} else if appConfig.SubCommand == "gen" {
+		subcmd.GenOperation(
+			targetFile,
+			appConfig.EntryKey,
+			appConfig,
+		)
+	} else if appConfig.SubCommand == "totp" {
+		subcmd.TotpOperation(
+			targetFile,
+			appConfig.EntryKey,
+			appConfig,
+		)
+	} else if appConfig.SubCommand == "restore-backup" {
+		subcmd.RestoreBackupOperation(
+			targetFile,
+			appConfig,
+		)
+	} else if appConfig.SubCommand == "shred" {
+		subcmd.ShredOperation(
+			appConfig.Args[0],
+			appConfig,
+		)
+	} else if appConfig.SubCommand == "inspect" {
+		subcmd.InspectOperation(
+			targetFile,
+			appConfig,
+		)
+	} else if appConfig.SubCommand == "verify" {
+		subcmd.VerifyOperation(
+			targetFile,
+			appConfig,
+		)
+	} else if appConfig.SubCommand == "diff" {
+		subcmd.DiffOperation(
+			appConfig.Args[0],
+			appConfig.Args[1],
+			appConfig,
+		)
+	} else if appConfig.SubCommand == "encrypt" {
+		subcmd.EncryptOperation(
+			filterInput(appConfig),
+			appConfig.FilterOutput,
+			appConfig,
+		)
+	} else if appConfig.SubCommand == "decrypt" {
+		subcmd.DecryptOperation(
+			filterInput(appConfig),
+			appConfig.FilterOutput,
+			appConfig,
+		)
+	} else if appConfig.SubCommand == "keyfile-new" {
+		subcmd.KeyfileNewOperation(
+			appConfig.Args[0],
+		)
+	} else if appConfig.SubCommand == "config-show" {
+		subcmd.ConfigShowOperation(
+			appConfig,
+		)
+	} else if appConfig.SubCommand == "git-install" {
+		subcmd.GitInstallOperation(
+			appConfig,
+		)
+	} else if appConfig.SubCommand == "git-filter" {
+		subcmd.GitFilterOperation(
+			appConfig.Args,
+			appConfig,
+		)
+	} else if appConfig.SubCommand == "git-textconv" {
+		subcmd.GitTextconvOperation(
+			appConfig.Args[0],
+			appConfig,
+		)
 	} else {
 		fmt.Fprintf(
 			os.Stderr,
@@ -86,3 +209,14 @@ func targetFile(
 
 	return "vault"
 }
+
+// filterInput is the exact input file of encrypt and decrypt, '-' for stdin.
+func filterInput(
+	appConfig *config.AppConfig,
+) string {
+	if len(appConfig.Args) >= 1 {
+		return appConfig.Args[0]
+	}
+
+	return "-"
+}
@@ -0,0 +1,52 @@
+package vault
+
+import (
+	"strconv"
+
+	"github.com/NobleMajo/vault/lib/armor"
+	"github.com/NobleMajo/vault/lib/vaultfile"
+)
+
+// Armor encodes a raw vault file as text with BEGIN and END VAULT MESSAGE lines,
+// a base64 body and a checksum, which can be sent by chat or email.
+// All functions of the package read armored vault files, functions that
+// change a vault file return it armored if it was armored.
+func Armor(raw []byte) []byte {
+	if armor.IsArmored(raw) {
+		return raw
+	}
+
+	version := 0
+	if vaultfile.IsContainer(raw) {
+		version = vaultfile.Version
+	}
+
+	return armor.Encode(raw, []armor.Header{
+		{Key: "Version", Value: strconv.Itoa(version)},
+		{Key: "Comment", Value: "https://github.com/NobleMajo/vault"},
+	})
+}
+
+// Dearmor returns the binary vault file of an armored one, others are returned as they are.
+// Its errors match ErrCorrupted.
+func Dearmor(raw []byte) ([]byte, error) {
+	if !armor.IsArmored(raw) {
+		return raw, nil
+	}
+
+	binary, _, err := armor.Decode(raw)
+	if err != nil {
+		return nil, corrupted(err)
+	}
+
+	return binary, nil
+}
+
+// keepArmor armors the changed raw vault file if the original one was armored.
+func keepArmor(original []byte, raw []byte) []byte {
+	if armor.IsArmored(original) {
+		return Armor(raw)
+	}
+
+	return raw
+}
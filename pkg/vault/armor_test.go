@@ -0,0 +1,58 @@
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/NobleMajo/vault/lib/armor"
+)
+
+func TestArmor(t *testing.T) {
+	password := Password([]byte("pass1234"))
+
+	raw, err := Seal([]byte("TOKEN=abc\n"), SealOptions{Password: password, Armor: true})
+	if err != nil {
+		t.Fatalf("Seal error: %v", err)
+	}
+	if !bytes.HasPrefix(raw, []byte(armor.BeginLine+"\nVersion: 1\n")) {
+		t.Fatalf("Seal with Armor returned no armored vault file:\n%s", raw)
+	}
+
+	// pasted with text around it
+	pasted := append([]byte("here you go:\r\n"), bytes.ReplaceAll(raw, []byte("\n"), []byte("\r\n"))...)
+	content, err := Open(pasted, OpenOptions{Password: password})
+	if err != nil || string(content) != "TOKEN=abc\n" {
+		t.Fatalf("Open armored = %q, %v", content, err)
+	}
+
+	info, err := Inspect(raw)
+	if err != nil || !info.Armored || !info.Container || info.Size != len(raw) {
+		t.Fatalf("Inspect armored = %+v, %v", info, err)
+	}
+
+	// changed vault files stay armored
+	sealed, err := Seal([]byte("TOKEN=def\n"), SealOptions{Previous: raw, Unlock: OpenOptions{Password: password}})
+	if err != nil || !armor.IsArmored(sealed) {
+		t.Fatalf("Seal with armored previous = %v, armored %v", err, armor.IsArmored(sealed))
+	}
+
+	rewrapped, err := Rewrap(raw, RewrapOptions{
+		From: OpenOptions{Password: password},
+		To:   SealOptions{Password: Password([]byte("other123"))},
+	})
+	if err != nil || !armor.IsArmored(rewrapped) {
+		t.Fatalf("Rewrap armored = %v, armored %v", err, armor.IsArmored(rewrapped))
+	}
+
+	binary, err := Dearmor(raw)
+	if err != nil || armor.IsArmored(binary) || !bytes.Equal(Armor(binary), raw) {
+		t.Fatalf("Dearmor/Armor round trip failed: %v", err)
+	}
+
+	broken := bytes.Replace(raw, []byte("\n\n"), []byte("\n\nAAAA"), 1)
+	_, err = Open(broken, OpenOptions{Password: password})
+	if !errors.Is(err, ErrCorrupted) || !errors.Is(err, armor.ErrChecksum) {
+		t.Fatalf("Open changed armor error = %v, want ErrCorrupted and armor.ErrChecksum", err)
+	}
+}
@@ -0,0 +1,77 @@
+package vault
+
+import (
+	"errors"
+
+	"github.com/NobleMajo/vault/lib/cryption"
+	"github.com/NobleMajo/vault/lib/vaultfile"
+)
+
+// The errors of the package keep their messages and match these sentinel errors,
+// use errors.Is. Key file errors match errors.As with *cryption.KeyError.
+var (
+	// ErrCorrupted matches errors of vault files that can not be parsed
+	// or fail their integrity checks.
+	ErrCorrupted = errors.New("vault file corrupted")
+	// ErrAuthFailed matches errors of a wrong password, keyfile or private key.
+	ErrAuthFailed = cryption.ErrAuthFailed
+	// ErrWrongRecipient matches errors of vault files encrypted for another rsa key.
+	ErrWrongRecipient = cryption.ErrWrongRecipient
+	// ErrUnsupportedKey matches errors of key files that are not openssh or pem encoded rsa keys.
+	ErrUnsupportedKey = cryption.ErrUnsupportedKey
+	// ErrKeyMismatch matches errors of key slots or revisions that need other layers,
+	// keyfiles or another rsa key than the given ones.
+	ErrKeyMismatch = errors.New("encrypted with other keys")
+	// ErrNoMethod matches errors of options without private or public key and password.
+	ErrNoMethod = errors.New("no encryption method selected")
+	// ErrSlotNotFound matches errors of key slot names that do not exist.
+	ErrSlotNotFound = errors.New("key slot not found")
+	// ErrSlotExists matches errors of key slot names that already exist.
+	ErrSlotExists = errors.New("key slot exists")
+	// ErrRevisionNotFound matches errors of revision numbers that do not exist.
+	ErrRevisionNotFound = errors.New("revision not found")
+	// ErrWrongShares matches errors of recovery shares that do not recover the data key.
+	ErrWrongShares = errors.New("wrong recovery shares")
+)
+
+// sentinelError keeps the message of its cause and matches the sentinel error too.
+type sentinelError struct {
+	sentinel error
+	err      error
+}
+
+func (err *sentinelError) Error() string {
+	return err.err.Error()
+}
+
+func (err *sentinelError) Unwrap() []error {
+	return []error{err.sentinel, err.err}
+}
+
+func withSentinel(sentinel error, err error) error {
+	return &sentinelError{sentinel, err}
+}
+
+// sentinel returns an error with the message that matches the sentinel error.
+func sentinel(sentinel error, message string) error {
+	return withSentinel(sentinel, errors.New(message))
+}
+
+func corrupted(err error) error {
+	return withSentinel(ErrCorrupted, err)
+}
+
+// parse parses a raw or armored vault file, its errors match ErrCorrupted.
+func parse(raw []byte) (*vaultfile.Container, error) {
+	raw, err := Dearmor(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	container, err := vaultfile.Parse(raw)
+	if err != nil {
+		return nil, corrupted(err)
+	}
+
+	return container, nil
+}
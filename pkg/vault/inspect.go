@@ -0,0 +1,314 @@
+package vault
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/NobleMajo/vault/lib/secret"
+	"github.com/NobleMajo/vault/lib/vaultfile"
+)
+
+// Info describes a vault file without decrypting it.
+type Info struct {
+	// Container is false for vault files of older versions, they have no metadata.
+	Container bool `json:"container"`
+	// Version is the container format version, 0 without container.
+	Version   int            `json:"version"`
+	Size      int            `json:"size"`
+	Created   time.Time      `json:"created,omitzero"`
+	Slots     []SlotInfo     `json:"slots,omitempty"`
+	Revisions []RevisionInfo `json:"revisions"`
+	// Armored is true for vault files encoded as text, see Armor.
+	Armored bool `json:"armored"`
+}
+
+// SlotInfo describes one key slot that wraps the data key.
+type SlotInfo struct {
+	Number int    `json:"number"`
+	Name   string `json:"name"`
+	// Kind is "credentials" for keys and passwords or "recovery" for a split recovery key.
+	Kind       string          `json:"kind"`
+	Encryption *EncryptionInfo `json:"encryption,omitempty"`
+	// Threshold of Shares are needed to recreate the recovery key.
+	Threshold int `json:"threshold,omitempty"`
+	Shares    int `json:"shares,omitempty"`
+}
+
+// RevisionInfo describes one revision, the time and user are stored in clear text.
+type RevisionInfo struct {
+	// Number is the revision number, 1 is the oldest.
+	Number int       `json:"number"`
+	Time   time.Time `json:"time,omitzero"`
+	User   string    `json:"user,omitempty"`
+	// Hashed is true if the encrypted content carries its sha256 hash.
+	Hashed bool `json:"hashed"`
+	Size   int  `json:"size"`
+	// DataKey is true if the revision is encrypted with the data key of the key slots.
+	DataKey bool `json:"dataKey"`
+	// Encryption is nil for data key revisions and revisions written by older versions.
+	Encryption *EncryptionInfo `json:"encryption,omitempty"`
+}
+
+// EncryptionInfo describes the encryption layers of a revision.
+type EncryptionInfo struct {
+	Algorithms []string `json:"algorithms"`
+	// Password is true if a password is needed to decrypt.
+	Password bool `json:"password"`
+	// Keyfile is true if keyfiles are needed in addition to the password.
+	Keyfile       bool   `json:"keyfile"`
+	KDF           string `json:"kdf,omitempty"`
+	KDFIterations int    `json:"kdfIterations,omitempty"`
+	RSABits       int    `json:"rsaBits,omitempty"`
+	// Recipients are the ssh style fingerprints of the public keys, like "SHA256:...".
+	Recipients []string `json:"recipients,omitempty"`
+}
+
+// Inspect reads the metadata of a raw vault file, no keys or passwords are needed.
+func Inspect(raw []byte) (*Info, error) {
+	binary, err := Dearmor(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	container, err := parse(binary)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{
+		Container: vaultfile.IsContainer(binary),
+		Armored:   len(binary) != len(raw),
+		Size:      len(raw),
+		Created:   container.Created,
+	}
+
+	if info.Container {
+		info.Version = vaultfile.Version
+	}
+
+	for index, slot := range container.Slots {
+		slotInfo := SlotInfo{
+			Number: index + 1,
+			Name:   slot.Name,
+		}
+
+		if slot.Kind == vaultfile.SlotRecovery {
+			slotInfo.Kind = "recovery"
+			slotInfo.Threshold = int(slot.Recovery.Threshold)
+			slotInfo.Shares = int(slot.Recovery.Shares)
+		} else {
+			slotInfo.Kind = "credentials"
+			slotInfo.Encryption = encryptionInfo(slot.Params)
+		}
+
+		info.Slots = append(info.Slots, slotInfo)
+	}
+
+	for index, revision := range container.Revisions {
+		info.Revisions = append(info.Revisions, RevisionInfo{
+			Number:     index + 1,
+			Time:       revision.Time,
+			User:       revision.User,
+			Hashed:     revision.Hashed,
+			Size:       len(revision.Payload),
+			DataKey:    revision.DataKey,
+			Encryption: encryptionInfo(revision.Params),
+		})
+	}
+
+	return info, nil
+}
+
+func encryptionInfo(params *vaultfile.Params) *EncryptionInfo {
+	if params == nil {
+		return nil
+	}
+
+	info := &EncryptionInfo{
+		Algorithms: []string{},
+		Password:   params.AES,
+	}
+
+	if params.AES {
+		info.Algorithms = append(info.Algorithms, "AES-256-CFB", "HMAC-SHA256")
+		info.KDF = "PBKDF2-SHA256"
+		info.KDFIterations = int(params.KDFIterations)
+		info.Keyfile = params.Keyfile
+	}
+
+	if params.RSA {
+		info.Algorithms = append(info.Algorithms, "RSA-"+strconv.Itoa(int(params.RSABits))+"-PKCS1v15")
+		info.RSABits = int(params.RSABits)
+
+		if len(params.Recipient) != 0 {
+			info.Recipients = append(info.Recipients, Fingerprint(params.Recipient))
+		}
+	}
+
+	return info
+}
+
+// Fingerprint formats a public key hash like "ssh-keygen -l".
+func Fingerprint(hash []byte) string {
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(hash)
+}
+
+// SlotCheck is the result of verifying one key slot. Opened is false for slots
+// that need other keys or the recovery shares, they can not be checked.
+// Err is set if an opened slot does not hold the data key, or with the decrypt error
+// of a slot that has the layers of the keys but does not open: the tag of another
+// password and a damaged tag can not be told apart.
+type SlotCheck struct {
+	Number int
+	Name   string
+	// Kind is "credentials" or "recovery" like in SlotInfo.
+	Kind   string
+	Opened bool
+	Err    error
+}
+
+// RevisionCheck is the result of verifying one revision, Err is nil if it is intact.
+type RevisionCheck struct {
+	Number int
+	Err    error
+}
+
+// Verify decrypts every revision in memory to check all authentication tags
+// and content hashes, and every key slot that the options can open.
+// The plain text is wiped and never returned.
+// The returned error is set if the file can not be checked at all,
+// like for wrong keys that open no key slot.
+func Verify(raw []byte, options OpenOptions) ([]SlotCheck, []RevisionCheck, error) {
+	container, err := parse(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opener := newOpener(options)
+	defer opener.wipe()
+
+	if options.Recovery == nil {
+		_, err = opener.credentials()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var slotChecks []SlotCheck
+	if len(container.Slots) != 0 {
+		// wrong keys fail here once instead of in every revision
+		dataKey, err := opener.unlock(container)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		slotChecks = opener.verifySlots(container, dataKey)
+	}
+
+	checks := make([]RevisionCheck, len(container.Revisions))
+	opened := false
+	var firstErr error
+
+	for index := range container.Revisions {
+		revision := &container.Revisions[index]
+		checks[index].Number = index + 1
+		checks[index].Err = opener.verifyRevision(container, revision)
+
+		if checks[index].Err == nil {
+			opened = true
+		} else if firstErr == nil {
+			firstErr = checks[index].Err
+		}
+	}
+
+	// vault files without key slots have no other check of the keys,
+	// if no revision opens and no content hash failed, the keys are wrong
+	if len(container.Slots) == 0 && !opened && !errors.Is(firstErr, ErrCorrupted) {
+		return nil, nil, firstErr
+	}
+
+	return slotChecks, checks, nil
+}
+
+// verifySlots decrypts every credentials key slot that matches the keys
+// and compares it with the unlocked data key.
+func (opener *opener) verifySlots(container *vaultfile.Container, dataKey []byte) []SlotCheck {
+	checks := make([]SlotCheck, len(container.Slots))
+
+	var recipient []byte
+	if opener.keys != nil {
+		recipient, _ = opener.keys.recipient()
+	}
+
+	for index := range container.Slots {
+		slot := &container.Slots[index]
+		checks[index] = SlotCheck{Number: index + 1, Name: slot.Name, Kind: "credentials"}
+		if slot.Kind == vaultfile.SlotRecovery {
+			checks[index].Kind = "recovery"
+		}
+
+		if index == opener.slot {
+			checks[index].Opened = true
+			continue
+		}
+		if slot.Kind != vaultfile.SlotCredentials || opener.keys == nil ||
+			opener.keys.check(slot.Params, recipient) != nil {
+			continue
+		}
+
+		slotKey, err := opener.keys.decrypt(slot.Payload, slot.Params)
+		if err != nil {
+			checks[index].Err = err
+			continue
+		}
+
+		checks[index].Opened = true
+		if subtle.ConstantTimeCompare(slotKey, dataKey) != 1 {
+			checks[index].Err = corrupted(errors.New("key slot '" + slot.Name + "' holds another data key"))
+		}
+		secret.Wipe(slotKey)
+	}
+
+	return checks
+}
+
+func (opener *opener) verifyRevision(container *vaultfile.Container, revision *vaultfile.Revision) error {
+	if !revision.DataKey {
+		keys, err := opener.credentials()
+		if err != nil {
+			return err
+		}
+
+		recipient, err := keys.recipient()
+		if err != nil {
+			return err
+		}
+
+		err = keys.check(revision.Params, recipient)
+		if err != nil {
+			return fmt.Errorf("revision is %w", err)
+		}
+	}
+
+	content, _, err := opener.openRevision(container, revision)
+	if err != nil {
+		return err
+	}
+	secret.Wipe(content)
+
+	return nil
+}
+
+func layerNames(rsa bool, aes bool) string {
+	if rsa && aes {
+		return "rsa and password"
+	} else if rsa {
+		return "rsa only"
+	}
+
+	return "password only"
+}
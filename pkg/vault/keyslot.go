@@ -0,0 +1,471 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/NobleMajo/vault/lib/cryption"
+	"github.com/NobleMajo/vault/lib/secret"
+	"github.com/NobleMajo/vault/lib/vaultfile"
+)
+
+// DefaultSlot is the name of the key slot that gets the keys
+// of a vault file when it gets its data key.
+const DefaultSlot = "default"
+
+// RecoverySlot is the name of the key slot of the recovery key.
+const RecoverySlot = "recovery"
+
+// MaxSlots is the maximum number of credentials key slots of a vault file.
+const MaxSlots = 8
+
+const dataKeySize = 32
+
+// AddSlot unlocks the data key with the From options and wraps it with
+// the keys of the To options into a new named key slot.
+// Vault files without key slots get a data key first.
+func AddSlot(raw []byte, name string, options RewrapOptions) ([]byte, error) {
+	err := validSlotName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	container, err := parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	opener := newOpener(options.From)
+	defer opener.wipe()
+
+	dataKey, err := opener.addKeySlots(container)
+	if err != nil {
+		return nil, err
+	}
+
+	if container.Slot(name) >= 0 {
+		return nil, sentinel(ErrSlotExists, "key slot '"+name+"' already exists")
+	} else if credentialSlots(container) >= MaxSlots {
+		return nil, errors.New("all " + strconv.Itoa(MaxSlots) + " key slots are used, remove one first")
+	}
+
+	toKeys, err := sealKeys(options.To)
+	if err != nil {
+		return nil, err
+	}
+
+	slot, err := toKeys.sealSlot(name, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	container.Slots = append(container.Slots, slot)
+
+	return keepArmor(raw, container.Marshal()), nil
+}
+
+// RemoveSlot removes the named key slot, the options have to unlock the data key
+// with any key slot. The last credentials key slot can not be removed.
+func RemoveSlot(raw []byte, name string, options OpenOptions) ([]byte, error) {
+	container, err := parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	index := container.Slot(name)
+	if index < 0 {
+		return nil, sentinel(ErrSlotNotFound, "key slot '"+name+"' does not exist")
+	}
+
+	if container.Slots[index].Kind == vaultfile.SlotCredentials && credentialSlots(container) == 1 {
+		return nil, errors.New("key slot '" + name + "' is the last key slot with keys and can not be removed")
+	}
+
+	opener := newOpener(options)
+	defer opener.wipe()
+
+	_, err = opener.unlock(container)
+	if err != nil {
+		return nil, err
+	}
+
+	container.Slots = append(container.Slots[:index], container.Slots[index+1:]...)
+
+	return keepArmor(raw, container.Marshal()), nil
+}
+
+func credentialSlots(container *vaultfile.Container) int {
+	count := 0
+	for _, slot := range container.Slots {
+		if slot.Kind == vaultfile.SlotCredentials {
+			count++
+		}
+	}
+
+	return count
+}
+
+// validSlotName allows 1 to 64 letters, digits, '.', '_' and '-'.
+func validSlotName(name string) error {
+	if len(name) == 0 || len(name) > 64 {
+		return errors.New("key slot name must have 1 to 64 characters")
+	}
+
+	for _, char := range name {
+		if !(char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' ||
+			char >= '0' && char <= '9' || char == '.' || char == '_' || char == '-') {
+			return errors.New("invalid key slot name '" + name + "', use letters, digits, '.', '_' and '-'")
+		}
+	}
+
+	return nil
+}
+
+// opener resolves the providers of the open options once
+// and caches the unlocked data key of the key slots.
+type opener struct {
+	options OpenOptions
+	keys    *keys
+	dataKey []byte
+	// slot is the index of the key slot that unlocked the data key, -1 before
+	slot int
+}
+
+func newOpener(options OpenOptions) *opener {
+	return &opener{
+		options: options,
+		slot:    -1,
+	}
+}
+
+func (opener *opener) credentials() (*keys, error) {
+	if opener.keys == nil {
+		keys, err := openKeys(opener.options)
+		if err != nil {
+			return nil, err
+		}
+		opener.keys = keys
+	}
+
+	return opener.keys, nil
+}
+
+// wipe zeroes the unlocked data key.
+func (opener *opener) wipe() {
+	secret.Wipe(opener.dataKey)
+	opener.dataKey = nil
+}
+
+// unlock decrypts the data key with the recovery key
+// or with the first credentials key slot that matches the keys.
+func (opener *opener) unlock(container *vaultfile.Container) ([]byte, error) {
+	if opener.dataKey != nil {
+		return opener.dataKey, nil
+	}
+
+	if len(container.Slots) == 0 {
+		return nil, errors.New("vault file has no key slots")
+	}
+
+	if opener.options.Recovery != nil {
+		return opener.unlockRecovery(container)
+	}
+
+	keys, err := opener.credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	recipient, err := keys.recipient()
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr, mismatchErr error
+	for index := range container.Slots {
+		slot := &container.Slots[index]
+		if slot.Kind != vaultfile.SlotCredentials {
+			continue
+		}
+
+		err := keys.check(slot.Params, recipient)
+		if err != nil {
+			mismatchErr = fmt.Errorf("key slot '%s' is %w", slot.Name, err)
+			continue
+		}
+
+		dataKey, err := keys.decrypt(slot.Payload, slot.Params)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if len(dataKey) != dataKeySize {
+			secret.Wipe(dataKey)
+			lastErr = errors.New("key slot '" + slot.Name + "' holds an invalid data key")
+			continue
+		}
+
+		opener.dataKey = dataKey
+		opener.slot = index
+		return dataKey, nil
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	} else if mismatchErr != nil {
+		return nil, mismatchErr
+	}
+
+	return nil, errors.New("vault file has no key slot with keys")
+}
+
+func (opener *opener) unlockRecovery(container *vaultfile.Container) ([]byte, error) {
+	recovery := opener.options.Recovery
+
+	for index := range container.Slots {
+		slot := &container.Slots[index]
+		if slot.Kind != vaultfile.SlotRecovery || slot.Recovery == nil {
+			continue
+		}
+
+		if slot.Recovery.ID != recovery.ID {
+			return nil, sentinel(ErrWrongShares, "the shares belong to another recovery key, the vault file was split again")
+		}
+
+		dataKey, err := cryption.AES256Decrypt(recovery.Key, slot.Payload)
+		if err != nil {
+			return nil, withSentinel(ErrWrongShares, fmt.Errorf("recovery key decrypt error, maybe wrong shares:\n> %w", err))
+		}
+
+		if len(dataKey) != dataKeySize {
+			secret.Wipe(dataKey)
+			return nil, errors.New("recovery key slot holds an invalid data key")
+		}
+
+		opener.dataKey = dataKey
+		opener.slot = index
+		return dataKey, nil
+	}
+
+	return nil, errors.New("vault file has no recovery key slot")
+}
+
+// openRevision decrypts a revision with the data key or the keys
+// and returns its content and content hash.
+func (opener *opener) openRevision(
+	container *vaultfile.Container,
+	revision *vaultfile.Revision,
+) ([]byte, []byte, error) {
+	body, err := opener.openBody(container, revision)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return decodeBody(body, revision)
+}
+
+// openBody decrypts the body of a revision, for Meta revisions it starts with the time and user.
+func (opener *opener) openBody(
+	container *vaultfile.Container,
+	revision *vaultfile.Revision,
+) ([]byte, error) {
+	if revision.DataKey {
+		dataKey, err := opener.unlock(container)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := cryption.AES256Decrypt(dataKey, revision.Payload)
+		if err != nil {
+			// the data key is unlocked, so the revision is damaged
+			return nil, corrupted(fmt.Errorf("data key decrypt error:\n> %w", err))
+		}
+
+		return body, nil
+	}
+
+	keys, err := opener.credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	return keys.decrypt(revision.Payload, revision.Params)
+}
+
+// decodeBody returns the content and content hash of a decrypted body without its meta,
+// the body is wiped on errors.
+func decodeBody(body []byte, revision *vaultfile.Revision) ([]byte, []byte, error) {
+	rest := body
+	if revision.Meta {
+		var err error
+		_, _, rest, err = vaultfile.DecodeMeta(body)
+		if err != nil {
+			secret.Wipe(body)
+			return nil, nil, corrupted(err)
+		}
+	}
+
+	content, hash, err := vaultfile.DecodeBody(rest, revision.Hashed)
+	if err != nil {
+		secret.Wipe(body)
+		return nil, nil, corrupted(err)
+	}
+
+	return content, hash, nil
+}
+
+// addKeySlots unlocks the data key of the container. A container without
+// key slots gets a new data key, wrapped with the keys as default key slot.
+// Revisions that are not encrypted with the data key get re-encrypted.
+func (opener *opener) addKeySlots(container *vaultfile.Container) ([]byte, error) {
+	if len(container.Slots) != 0 {
+		dataKey, err := opener.unlock(container)
+		if err != nil {
+			return nil, err
+		}
+
+		return dataKey, opener.migrate(container, dataKey)
+	}
+
+	keys, err := opener.credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	_, err = rand.Read(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	opener.dataKey = dataKey
+
+	slot, err := keys.sealSlot(DefaultSlot, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	err = opener.migrate(container, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	container.Slots = []vaultfile.KeySlot{slot}
+	opener.slot = 0
+
+	return dataKey, nil
+}
+
+// migrate re-encrypts the revisions that are not encrypted with the data key yet.
+func (opener *opener) migrate(container *vaultfile.Container, dataKey []byte) error {
+	for index := range container.Revisions {
+		revision := &container.Revisions[index]
+		if revision.DataKey {
+			continue
+		}
+
+		content, _, err := opener.openRevision(container, revision)
+		if err != nil {
+			return fmt.Errorf("decrypt revision %d error:\n> %w", index+1, err)
+		}
+
+		// the clear text time and user were never authenticated, they stay without meta
+		sealed, err := sealDataKeyRevision(dataKey, content, nil)
+		secret.Wipe(content)
+		if err != nil {
+			return fmt.Errorf("encrypt revision %d error:\n> %w", index+1, err)
+		}
+
+		sealed.Time = revision.Time
+		sealed.User = revision.User
+		*revision = sealed
+	}
+
+	return nil
+}
+
+// sealDataKeyRevision encrypts the content with its hash with the data key.
+// The time and user of the meta revision are encrypted with it and set in clear text.
+func sealDataKeyRevision(dataKey []byte, content []byte, meta *vaultfile.Revision) (vaultfile.Revision, error) {
+	body := vaultfile.EncodeBody(content)
+	if meta != nil {
+		hashed := body
+		body = vaultfile.EncodeMeta(hashed, meta.Time, meta.User)
+		secret.Wipe(hashed)
+	}
+	defer secret.Wipe(body)
+
+	payload, err := cryption.AES256Encrypt(dataKey, body)
+	if err != nil {
+		return vaultfile.Revision{}, fmt.Errorf("data key encrypt error:\n> %v", err)
+	}
+
+	revision := vaultfile.Revision{
+		Hashed:  true,
+		DataKey: true,
+		Payload: payload,
+	}
+	if meta != nil {
+		revision.Meta = true
+		revision.Time = meta.Time
+		revision.User = meta.User
+	}
+
+	return revision, nil
+}
+
+// sealSlot wraps the data key with the keys into a credentials key slot.
+func (keys *keys) sealSlot(name string, dataKey []byte) (vaultfile.KeySlot, error) {
+	payload, err := keys.encrypt(dataKey)
+	if err != nil {
+		return vaultfile.KeySlot{}, err
+	}
+
+	params, err := keys.params()
+	if err != nil {
+		return vaultfile.KeySlot{}, err
+	}
+
+	return vaultfile.KeySlot{
+		Kind:    vaultfile.SlotCredentials,
+		Name:    name,
+		Params:  params,
+		Payload: payload,
+	}, nil
+}
+
+// recipient is the fingerprint of the rsa key, nil without rsa layer.
+func (keys *keys) recipient() ([]byte, error) {
+	if !keys.rsa {
+		return nil, nil
+	}
+
+	return cryption.RsaPublicKeyFingerprint(keys.publicKey)
+}
+
+// check returns an error if the params describe other layers or another rsa key.
+// Without params nothing is known and nothing is checked.
+func (keys *keys) check(params *vaultfile.Params, recipient []byte) error {
+	if params == nil {
+		return nil
+	}
+
+	if params.RSA != keys.rsa || params.AES != keys.aes {
+		return sentinel(ErrKeyMismatch, "encrypted with other layers: "+layerNames(params.RSA, params.AES))
+	}
+
+	if params.AES && params.Keyfile != (keys.keyfile != nil) {
+		if params.Keyfile {
+			return sentinel(ErrKeyMismatch, "encrypted with keyfiles")
+		}
+		return sentinel(ErrKeyMismatch, "encrypted without keyfiles")
+	}
+
+	if keys.rsa && len(params.Recipient) != 0 && !bytes.Equal(params.Recipient, recipient) {
+		return withSentinel(ErrWrongRecipient, sentinel(ErrKeyMismatch, "encrypted for the public key "+Fingerprint(params.Recipient)))
+	}
+
+	return nil
+}
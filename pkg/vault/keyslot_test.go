@@ -0,0 +1,188 @@
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/NobleMajo/vault/lib/vaultfile"
+)
+
+func TestKeySlots(t *testing.T) {
+	password := Password([]byte("pass1234"))
+	breakGlass := Password([]byte("break-glass"))
+
+	raw := sealHistory(t, []string{"one", "two"}, password)
+
+	added, err := AddSlot(raw, "ops-break-glass", RewrapOptions{
+		From: OpenOptions{Password: password},
+		To:   SealOptions{Password: breakGlass, KDFIterations: 10000},
+	})
+	if err != nil {
+		t.Fatalf("AddSlot error: %v", err)
+	}
+
+	for _, provider := range []PasswordProvider{password, breakGlass} {
+		content, err := Open(added, OpenOptions{Password: provider})
+		if err != nil || string(content) != "two" {
+			t.Fatalf("Open = %q, %v", content, err)
+		}
+	}
+
+	info, _ := Inspect(added)
+	if len(info.Slots) != 2 || info.Slots[1].Name != "ops-break-glass" ||
+		info.Slots[1].Encryption.KDFIterations != 10000 || info.Slots[0].Encryption.KDFIterations != 4096 {
+		t.Fatalf("unexpected key slots: %+v", info.Slots)
+	}
+
+	// changing one password keeps the revisions and the other key slot
+	newBreakGlass := Password([]byte("new-break-glass"))
+	rewrapped, err := Rewrap(added, RewrapOptions{
+		From: OpenOptions{Password: breakGlass},
+		To:   SealOptions{Password: newBreakGlass},
+	})
+	if err != nil {
+		t.Fatalf("Rewrap error: %v", err)
+	}
+
+	info, _ = Inspect(rewrapped)
+	if info.Slots[1].Encryption.KDFIterations != 10000 {
+		t.Fatalf("Rewrap changed the kdf iterations to %d", info.Slots[1].Encryption.KDFIterations)
+	}
+
+	before, _ := vaultfile.Parse(added)
+	after, _ := vaultfile.Parse(rewrapped)
+	if !bytes.Equal(before.Revisions[0].Payload, after.Revisions[0].Payload) ||
+		after.Slots[1].Name != "ops-break-glass" {
+		t.Fatalf("Rewrap of a key slot changed the revisions or the slot name")
+	}
+
+	for _, provider := range []PasswordProvider{password, newBreakGlass} {
+		_, err := Open(rewrapped, OpenOptions{Password: provider})
+		if err != nil {
+			t.Fatalf("Open after Rewrap error: %v", err)
+		}
+	}
+
+	_, err = Open(rewrapped, OpenOptions{Password: breakGlass})
+	if err == nil {
+		t.Fatalf("old break glass password still works")
+	}
+
+	removed, err := RemoveSlot(rewrapped, DefaultSlot, OpenOptions{Password: newBreakGlass})
+	if err != nil {
+		t.Fatalf("RemoveSlot error: %v", err)
+	}
+
+	_, err = Open(removed, OpenOptions{Password: password})
+	if err == nil {
+		t.Fatalf("password of the removed key slot still works")
+	}
+
+	_, err = RemoveSlot(removed, "ops-break-glass", OpenOptions{Password: newBreakGlass})
+	if err == nil {
+		t.Fatalf("RemoveSlot of the last key slot should fail")
+	}
+}
+
+func TestKeySlotsInvalid(t *testing.T) {
+	password := Password([]byte("pass1234"))
+	raw := sealHistory(t, []string{"one"}, password)
+	add := RewrapOptions{
+		From: OpenOptions{Password: password},
+		To:   SealOptions{Password: password},
+	}
+
+	for _, name := range []string{"", "with space", "ümlaut", DefaultSlot} {
+		_, err := AddSlot(raw, name, add)
+		if err == nil {
+			t.Fatalf("AddSlot(%q) should fail", name)
+		}
+	}
+
+	_, err := AddSlot(raw, "fast", RewrapOptions{
+		From: add.From,
+		To:   SealOptions{Password: password, KDFIterations: 10},
+	})
+	if err == nil {
+		t.Fatalf("AddSlot with 10 kdf iterations should fail")
+	}
+
+	_, err = AddSlot(raw, "wrong", RewrapOptions{
+		From: OpenOptions{Password: Password([]byte("wrong123"))},
+		To:   add.To,
+	})
+	if !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("AddSlot with a wrong password error = %v, want ErrAuthFailed", err)
+	}
+
+	_, err = RemoveSlot(raw, "missing", add.From)
+	if !errors.Is(err, ErrSlotNotFound) {
+		t.Fatalf("RemoveSlot of a missing slot error = %v, want ErrSlotNotFound", err)
+	}
+
+	for count := 1; count < MaxSlots; count++ {
+		raw, err = AddSlot(raw, "slot-"+strconv.Itoa(count), add)
+		if err != nil {
+			t.Fatalf("AddSlot %d error: %v", count, err)
+		}
+	}
+
+	_, err = AddSlot(raw, "slot-1", add)
+	if !errors.Is(err, ErrSlotExists) {
+		t.Fatalf("AddSlot of an existing slot error = %v, want ErrSlotExists", err)
+	}
+
+	_, err = AddSlot(raw, "one-too-many", add)
+	if err == nil {
+		t.Fatalf("AddSlot beyond MaxSlots should fail")
+	}
+}
+
+func TestKeyfiles(t *testing.T) {
+	password := Password([]byte("pass1234"))
+	keyfile := Keyfiles(bytes.Repeat([]byte{1}, 32))
+	otherKeyfile := Keyfiles(bytes.Repeat([]byte{2}, 32))
+
+	raw, err := Seal([]byte("content"), SealOptions{Password: password, Keyfiles: keyfile})
+	if err != nil {
+		t.Fatalf("Seal error: %v", err)
+	}
+
+	info, _ := Inspect(raw)
+	if !info.Slots[0].Encryption.Keyfile {
+		t.Fatalf("expected the key slot to need a keyfile: %+v", info.Slots[0].Encryption)
+	}
+
+	if _, err := Open(raw, OpenOptions{Password: password}); err == nil {
+		t.Fatal("expected Open without keyfile to fail")
+	}
+	if _, err := Open(raw, OpenOptions{Password: password, Keyfiles: otherKeyfile}); err == nil {
+		t.Fatal("expected Open with another keyfile to fail")
+	}
+	if _, err := Open(raw, OpenOptions{Password: Password([]byte("wrong-pass")), Keyfiles: keyfile}); err == nil {
+		t.Fatal("expected Open with a wrong password to fail")
+	}
+
+	content, err := Open(raw, OpenOptions{Password: password, Keyfiles: keyfile})
+	if err != nil || string(content) != "content" {
+		t.Fatalf("Open = %q, %v", content, err)
+	}
+
+	// dropping the keyfile with a password change
+	rewrapped, err := Rewrap(raw, RewrapOptions{
+		From: OpenOptions{Password: password, Keyfiles: keyfile},
+		To:   SealOptions{Password: password},
+	})
+	if err != nil {
+		t.Fatalf("Rewrap error: %v", err)
+	}
+	if _, err := Open(rewrapped, OpenOptions{Password: password}); err != nil {
+		t.Fatalf("Open after Rewrap error: %v", err)
+	}
+
+	if _, err := Seal([]byte("content"), SealOptions{PublicKey: PublicKey(nil), Keyfiles: keyfile}); err == nil {
+		t.Fatal("expected keyfiles without password layer to fail")
+	}
+}
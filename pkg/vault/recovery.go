@@ -0,0 +1,241 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/NobleMajo/vault/lib/cryption"
+	"github.com/NobleMajo/vault/lib/secret"
+	"github.com/NobleMajo/vault/lib/shamir"
+	"github.com/NobleMajo/vault/lib/vaultfile"
+)
+
+const shareVersion = 1
+
+// SharePrefix starts every printed recovery share.
+const SharePrefix = "vault-share-"
+
+var shareEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// RecoveryKey is a recovery key recreated from its shares.
+type RecoveryKey struct {
+	// ID identifies the recovery key slot of the key.
+	ID  uint32
+	Key []byte
+}
+
+// RecoveryShare is one share of a split recovery key.
+type RecoveryShare struct {
+	// ID identifies the recovery key, all shares of one split have the same ID.
+	ID        uint32
+	Threshold int
+	// Number is the share number, from 1 to the number of shares.
+	Number int
+	value  []byte
+}
+
+// SplitOptions configure a new recovery key.
+type SplitOptions struct {
+	// Open unlocks the data key of the vault file.
+	Open OpenOptions
+
+	Shares    int
+	Threshold int
+}
+
+// Split creates a new recovery key, stores it as recovery key slot
+// and returns the changed raw vault file and the key split into shares.
+// Any Threshold of the shares recreate the key with CombineShares.
+// A previous recovery key slot is replaced, its shares become useless.
+// Vault files without key slots get a data key first, wrapped with the keys of the open options.
+func Split(raw []byte, options SplitOptions) ([]byte, []RecoveryShare, error) {
+	container, err := parse(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recoveryKey := make([]byte, dataKeySize)
+	defer secret.Wipe(recoveryKey)
+
+	idBytes := make([]byte, 4)
+	_, err = rand.Read(idBytes)
+	if err == nil {
+		_, err = rand.Read(recoveryKey)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parts, err := shamir.Split(recoveryKey, options.Shares, options.Threshold)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opener := newOpener(options.Open)
+	defer opener.wipe()
+
+	dataKey, err := opener.addKeySlots(container)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payload, err := cryption.AES256Encrypt(recoveryKey, dataKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("recovery key encrypt error:\n> %v", err)
+	}
+
+	id := binary.BigEndian.Uint32(idBytes)
+	slot := vaultfile.KeySlot{
+		Kind: vaultfile.SlotRecovery,
+		Name: RecoverySlot,
+		Recovery: &vaultfile.Recovery{
+			ID:        id,
+			Threshold: byte(options.Threshold),
+			Shares:    byte(options.Shares),
+		},
+		Payload: payload,
+	}
+
+	replaced := false
+	for index := range container.Slots {
+		if container.Slots[index].Kind == vaultfile.SlotRecovery {
+			container.Slots[index] = slot
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		container.Slots = append(container.Slots, slot)
+	}
+
+	shares := make([]RecoveryShare, len(parts))
+	for index, part := range parts {
+		shares[index] = RecoveryShare{
+			ID:        id,
+			Threshold: options.Threshold,
+			Number:    int(part.X),
+			value:     part.Y,
+		}
+	}
+
+	return keepArmor(raw, container.Marshal()), shares, nil
+}
+
+// String formats the share as printable text with a checksum,
+// like "vault-share-1:ABCDE-FGHIJ-...".
+func (share RecoveryShare) String() string {
+	data := []byte{shareVersion}
+	data = binary.BigEndian.AppendUint32(data, share.ID)
+	data = append(data, byte(share.Threshold), byte(share.Number))
+	data = append(data, share.value...)
+
+	checksum := sha256.Sum256(data)
+	data = append(data, checksum[:4]...)
+
+	encoded := shareEncoding.EncodeToString(data)
+	groups := []string{}
+	for len(encoded) > 5 {
+		groups = append(groups, encoded[:5])
+		encoded = encoded[5:]
+	}
+	groups = append(groups, encoded)
+
+	return SharePrefix + strconv.Itoa(share.Number) + ":" + strings.Join(groups, "-")
+}
+
+// ParseShare reads a share printed by String.
+// Case, whitespace and dashes of the text are ignored.
+func ParseShare(text string) (RecoveryShare, error) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(strings.ToLower(text), SharePrefix) {
+		return RecoveryShare{}, errors.New("not a vault recovery share, it starts with '" + SharePrefix + "'")
+	}
+
+	_, body, found := strings.Cut(text, ":")
+	if !found {
+		return RecoveryShare{}, errors.New("recovery share without ':'")
+	}
+
+	body = strings.Map(func(r rune) rune {
+		if r == '-' || unicode.IsSpace(r) {
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, body)
+
+	data, err := shareEncoding.DecodeString(body)
+	if err != nil {
+		return RecoveryShare{}, errors.New("recovery share is not valid base32, check for typos")
+	}
+
+	if len(data) < 8+4 {
+		return RecoveryShare{}, errors.New("recovery share too short")
+	}
+
+	payload := data[:len(data)-4]
+	checksum := sha256.Sum256(payload)
+	if !bytes.Equal(checksum[:4], data[len(data)-4:]) {
+		return RecoveryShare{}, errors.New("recovery share checksum mismatch, check for typos")
+	}
+
+	if payload[0] != shareVersion {
+		return RecoveryShare{}, errors.New("unsupported recovery share version " + strconv.Itoa(int(payload[0])))
+	}
+
+	share := RecoveryShare{
+		ID:        binary.BigEndian.Uint32(payload[1:5]),
+		Threshold: int(payload[5]),
+		Number:    int(payload[6]),
+		value:     payload[7:],
+	}
+
+	if share.Number == 0 || share.Threshold < 2 {
+		return RecoveryShare{}, errors.New("invalid recovery share")
+	}
+
+	return share, nil
+}
+
+// CombineShares recreates the recovery key from at least threshold shares of one split.
+func CombineShares(shares []RecoveryShare) (*RecoveryKey, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no recovery shares")
+	}
+
+	first := shares[0]
+	parts := make([]shamir.Share, len(shares))
+	for index, share := range shares {
+		if share.ID != first.ID || share.Threshold != first.Threshold {
+			return nil, errors.New("the recovery shares belong to different recovery keys")
+		}
+
+		parts[index] = shamir.Share{
+			X: byte(share.Number),
+			Y: share.value,
+		}
+	}
+
+	if len(shares) < first.Threshold {
+		return nil, errors.New(
+			"recovery needs " + strconv.Itoa(first.Threshold) + " shares, got " + strconv.Itoa(len(shares)),
+		)
+	}
+
+	key, err := shamir.Combine(parts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecoveryKey{
+		ID:  first.ID,
+		Key: key,
+	}, nil
+}
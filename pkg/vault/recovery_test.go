@@ -0,0 +1,178 @@
+package vault
+
+import (
+	"strings"
+	"testing"
+)
+
+func splitVault(t *testing.T, raw []byte, password PasswordProvider) ([]byte, []RecoveryShare) {
+	split, shares, err := Split(raw, SplitOptions{
+		Open:      OpenOptions{Password: password},
+		Shares:    5,
+		Threshold: 3,
+	})
+	if err != nil {
+		t.Fatalf("Split error: %v", err)
+	}
+
+	if len(shares) != 5 {
+		t.Fatalf("Split returned %d shares, want 5", len(shares))
+	}
+
+	return split, shares
+}
+
+func TestSplitRecover(t *testing.T) {
+	password := Password([]byte("pass1234"))
+	raw := sealHistory(t, []string{"one", "two"}, password)
+	hashes, err := Hashes(raw, OpenOptions{Password: password})
+	if err != nil {
+		t.Fatalf("Hashes error: %v", err)
+	}
+
+	split, shares := splitVault(t, raw, password)
+
+	// the old password still opens every revision, now through the default key slot
+	splitHashes, err := Hashes(split, OpenOptions{Password: password})
+	if err != nil {
+		t.Fatalf("Hashes after split error: %v", err)
+	}
+
+	if strings.Join(splitHashes, ",") != strings.Join(hashes, ",") {
+		t.Fatalf("hashes changed by split: %v, want %v", splitHashes, hashes)
+	}
+
+	parsed := []RecoveryShare{}
+	for _, index := range []int{4, 0, 2} {
+		share, err := ParseShare(strings.ToLower(shares[index].String()))
+		if err != nil {
+			t.Fatalf("ParseShare error: %v", err)
+		}
+		parsed = append(parsed, share)
+	}
+
+	_, err = CombineShares(parsed[:2])
+	if err == nil {
+		t.Fatalf("CombineShares with too few shares should fail")
+	}
+
+	recoveryKey, err := CombineShares(parsed)
+	if err != nil {
+		t.Fatalf("CombineShares error: %v", err)
+	}
+
+	content, err := Open(split, OpenOptions{Recovery: recoveryKey, Revision: 1})
+	if err != nil {
+		t.Fatalf("Open with recovery key error: %v", err)
+	}
+
+	if string(content) != "one" {
+		t.Fatalf("Open with recovery key = %q, want %q", content, "one")
+	}
+
+	// recovery sets a new password for the default key slot
+	newPassword := Password([]byte("new-pass"))
+	recovered, err := Rewrap(split, RewrapOptions{
+		From: OpenOptions{Recovery: recoveryKey},
+		To:   SealOptions{Password: newPassword},
+	})
+	if err != nil {
+		t.Fatalf("Rewrap with recovery key error: %v", err)
+	}
+
+	_, err = Open(recovered, OpenOptions{Password: password})
+	if err == nil {
+		t.Fatalf("Open with the lost password should fail")
+	}
+
+	content, err = Open(recovered, OpenOptions{Password: newPassword})
+	if err != nil || string(content) != "two" {
+		t.Fatalf("Open with new password = %q, %v", content, err)
+	}
+
+	info, err := Inspect(recovered)
+	if err != nil {
+		t.Fatalf("Inspect error: %v", err)
+	}
+
+	if len(info.Slots) != 2 || info.Slots[0].Name != DefaultSlot || info.Slots[1].Kind != "recovery" ||
+		info.Slots[1].Threshold != 3 || info.Slots[1].Shares != 5 || !info.Revisions[0].DataKey {
+		t.Fatalf("unexpected slots: %+v", info.Slots)
+	}
+}
+
+func TestSealKeepsKeySlots(t *testing.T) {
+	password := Password([]byte("pass1234"))
+	raw, err := Seal([]byte("one"), SealOptions{Password: password})
+	if err != nil {
+		t.Fatalf("Seal error: %v", err)
+	}
+
+	split, shares := splitVault(t, raw, password)
+
+	_, err = Seal([]byte("two"), SealOptions{Password: password, Previous: split})
+	if err == nil {
+		t.Fatalf("Seal without unlock options should fail for a vault with key slots")
+	}
+
+	sealed, err := Seal([]byte("two"), SealOptions{
+		Previous: split,
+		Unlock:   OpenOptions{Password: password},
+	})
+	if err != nil {
+		t.Fatalf("Seal error: %v", err)
+	}
+
+	recoveryKey, err := CombineShares(shares[1:4])
+	if err != nil {
+		t.Fatalf("CombineShares error: %v", err)
+	}
+
+	content, err := Open(sealed, OpenOptions{Recovery: recoveryKey})
+	if err != nil || string(content) != "two" {
+		t.Fatalf("Open with recovery key = %q, %v", content, err)
+	}
+
+	// a second split replaces the recovery key, old shares stop working
+	resplit, _ := splitVault(t, sealed, password)
+	_, err = Open(resplit, OpenOptions{Recovery: recoveryKey})
+	if err == nil {
+		t.Fatalf("Open with the shares of a replaced recovery key should fail")
+	}
+}
+
+func TestParseShareInvalid(t *testing.T) {
+	_, shares := splitVault(t, sealHistory(t, []string{"one"}, Password([]byte("pass"))), Password([]byte("pass")))
+	text := shares[0].String()
+
+	// flip one character of the body
+	colon := strings.Index(text, ":")
+	typo := []byte(text)
+	if typo[colon+1] == 'A' {
+		typo[colon+1] = 'B'
+	} else {
+		typo[colon+1] = 'A'
+	}
+
+	tests := []string{
+		"",
+		"share:ABCDE",
+		"vault-share-1",
+		"vault-share-1:!!!!",
+		"vault-share-1:ABCDEFGH",
+		string(typo),
+	}
+
+	for _, test := range tests {
+		_, err := ParseShare(test)
+		if err == nil {
+			t.Fatalf("ParseShare(%q) should fail", test)
+		}
+	}
+
+	// whitespace between the groups is ignored
+	share, err := ParseShare(" " + text[:colon+1] + strings.ReplaceAll(text[colon+1:], "-", "- ") + "\n")
+	if err != nil || share.Number != 1 || share.Threshold != 3 {
+		t.Fatalf("ParseShare = %+v, %v", share, err)
+	}
+}
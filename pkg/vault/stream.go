@@ -0,0 +1,71 @@
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"io"
+
+	"github.com/NobleMajo/vault/lib/cryption"
+)
+
+// IsStream reports whether the data starts like an encrypted stream of SealStream.
+func IsStream(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(cryption.StreamMagic))
+}
+
+// SealStream returns a writer that encrypts everything written to it into w,
+// so large content is not held in memory, see cryption.NewEncryptWriter.
+// Encrypted streams have no history and no key slots, keyfiles are not supported.
+// Close writes the final chunk and must be called, it does not close w.
+func SealStream(w io.Writer, options SealOptions) (io.WriteCloser, error) {
+	if options.Keyfiles != nil {
+		return nil, errors.New("keyfiles are not supported by encrypted streams")
+	}
+
+	keys, err := sealKeys(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return cryption.NewEncryptWriter(w, cryption.StreamOptions{
+		Password:   keys.password,
+		PublicKey:  keys.publicKey,
+		Iterations: keys.iterations,
+	})
+}
+
+// OpenStream returns a reader that decrypts an encrypted stream of SealStream from r.
+// A wrong password or private key fails here and matches ErrAuthFailed,
+// modified or truncated chunks fail while reading and match ErrCorrupted.
+func OpenStream(r io.Reader, options OpenOptions) (io.Reader, error) {
+	keys, err := openKeys(options)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := cryption.NewDecryptReader(r, cryption.StreamOptions{
+		Password:   keys.password,
+		PrivateKey: keys.privateKey,
+	})
+	if errors.Is(err, cryption.ErrTruncated) {
+		return nil, corrupted(err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &streamReader{reader}, nil
+}
+
+// streamReader marks the errors after the authenticated header as corruption.
+type streamReader struct {
+	reader io.Reader
+}
+
+func (reader *streamReader) Read(data []byte) (int, error) {
+	n, err := reader.reader.Read(data)
+	if err != nil && err != io.EOF {
+		err = corrupted(err)
+	}
+
+	return n, err
+}
@@ -0,0 +1,89 @@
+package vault
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func sealStream(t *testing.T, plainText []byte, options SealOptions) []byte {
+	t.Helper()
+
+	var sealed bytes.Buffer
+	writer, err := SealStream(&sealed, options)
+	if err != nil {
+		t.Fatalf("SealStream error: %v", err)
+	}
+
+	_, err = writer.Write(plainText)
+	if err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+
+	err = writer.Close()
+	if err != nil {
+		t.Fatalf("Close error: %v", err)
+	}
+
+	return sealed.Bytes()
+}
+
+func TestStream(t *testing.T) {
+	keysDir := testKeysDir(t)
+	plainText := bytes.Repeat([]byte("SECRET=value\n"), 10000)
+
+	sealed := sealStream(t, plainText, SealOptions{
+		PublicKey:     PublicKeyFile(filepath.Join(keysDir, "test_id_rsa.pub")),
+		Password:      Password([]byte("pass1234")),
+		KDFIterations: 1000,
+	})
+
+	if !IsStream(sealed) {
+		t.Fatalf("IsStream = false for a sealed stream")
+	}
+
+	reader, err := OpenStream(bytes.NewReader(sealed), OpenOptions{
+		PrivateKey: PrivateKeyFile(filepath.Join(keysDir, "test_id_rsa")),
+		Password:   Password([]byte("pass1234")),
+	})
+	if err != nil {
+		t.Fatalf("OpenStream error: %v", err)
+	}
+
+	opened, err := io.ReadAll(reader)
+	if err != nil || !bytes.Equal(opened, plainText) {
+		t.Fatalf("ReadAll = %d bytes, %v, want %d bytes", len(opened), err, len(plainText))
+	}
+}
+
+func TestStreamErrors(t *testing.T) {
+	password := Password([]byte("pass1234"))
+	sealed := sealStream(t, bytes.Repeat([]byte("x"), 200000), SealOptions{Password: password, KDFIterations: 1000})
+
+	_, err := OpenStream(bytes.NewReader(sealed), OpenOptions{Password: Password([]byte("wrong123"))})
+	if !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("OpenStream with a wrong password = %v, want ErrAuthFailed", err)
+	}
+
+	for name, damaged := range map[string][]byte{
+		"modified":  append(bytes.Clone(sealed[:len(sealed)-1]), sealed[len(sealed)-1]^0xff),
+		"truncated": sealed[:len(sealed)/2],
+	} {
+		reader, err := OpenStream(bytes.NewReader(damaged), OpenOptions{Password: password})
+		if err != nil {
+			t.Fatalf("%s: OpenStream error: %v", name, err)
+		}
+
+		_, err = io.ReadAll(reader)
+		if !errors.Is(err, ErrCorrupted) {
+			t.Fatalf("%s: ReadAll = %v, want ErrCorrupted", name, err)
+		}
+	}
+
+	_, err = SealStream(io.Discard, SealOptions{Password: password, Keyfiles: Keyfiles([]byte("hash"))})
+	if err == nil {
+		t.Fatalf("SealStream with keyfiles should fail")
+	}
+}
@@ -0,0 +1,670 @@
+// Package vault reads and writes vault files.
+//
+// A vault file is a container with a history of revisions.
+// The revisions are encrypted with a random data key, which is wrapped
+// by up to MaxSlots AES-256 (password) and/or RSA (X509AES256) key slots
+// and an optional recovery key slot.
+// Vault files of older versions encrypt the content directly with the keys.
+// All functions work on the raw file content, return errors and hold no global state.
+// Keys and passwords are requested through providers when they are needed.
+package vault
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/NobleMajo/vault/lib/cryption"
+	"github.com/NobleMajo/vault/lib/secret"
+	"github.com/NobleMajo/vault/lib/vaultfile"
+)
+
+// PasswordProvider returns the password for the AES-256 layer.
+// The returned bytes are not modified or kept after the call that requested them.
+type PasswordProvider func() ([]byte, error)
+
+// KeyfileProvider returns the keyfile hash for the AES-256 layer, see cryption.HashKeyfiles.
+type KeyfileProvider func() ([]byte, error)
+
+// PrivateKeyProvider returns the private key for the RSA layer,
+// a *rsa.PrivateKey or a token key that decrypts on the token.
+type PrivateKeyProvider func() (crypto.Decrypter, error)
+
+// PublicKeyProvider returns the public key for the RSA layer.
+type PublicKeyProvider func() (*rsa.PublicKey, error)
+
+// Password returns a provider for a fixed password.
+func Password(password []byte) PasswordProvider {
+	return func() ([]byte, error) {
+		return password, nil
+	}
+}
+
+// Keyfiles returns a provider for an already hashed set of keyfiles.
+func Keyfiles(keyfileHash []byte) KeyfileProvider {
+	return func() ([]byte, error) {
+		return keyfileHash, nil
+	}
+}
+
+// KeyfileFiles returns a provider that hashes the given keyfiles, their order does not matter.
+func KeyfileFiles(paths ...string) KeyfileProvider {
+	return func() ([]byte, error) {
+		keyfileHash, err := cryption.HashKeyfiles(paths...)
+		if err != nil {
+			return nil, fmt.Errorf("load keyfile error:\n> %w", err)
+		}
+
+		return keyfileHash, nil
+	}
+}
+
+// PrivateKey returns a provider for an already loaded private key.
+func PrivateKey(privateKey crypto.Decrypter) PrivateKeyProvider {
+	return func() (crypto.Decrypter, error) {
+		return privateKey, nil
+	}
+}
+
+// PublicKey returns a provider for an already loaded public key.
+func PublicKey(publicKey *rsa.PublicKey) PublicKeyProvider {
+	return func() (*rsa.PublicKey, error) {
+		return publicKey, nil
+	}
+}
+
+// PrivateKeyFile returns a provider that loads a pem or openssh rsa private key file.
+func PrivateKeyFile(path string) PrivateKeyProvider {
+	return func() (crypto.Decrypter, error) {
+		privateKey, err := cryption.LoadRsaPrivateKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("load private key error:\n> %w", err)
+		}
+
+		return privateKey, nil
+	}
+}
+
+// PublicKeyFile returns a provider that loads a pem or openssh rsa public key file.
+func PublicKeyFile(path string) PublicKeyProvider {
+	return func() (*rsa.PublicKey, error) {
+		publicKey, err := cryption.LoadRsaPublicKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("load public key error:\n> %w", err)
+		}
+
+		return publicKey, nil
+	}
+}
+
+// OpenOptions configure how a vault file is decrypted.
+// A nil provider means the vault file does not use that layer.
+type OpenOptions struct {
+	PrivateKey PrivateKeyProvider
+	Password   PasswordProvider
+	// Keyfiles are needed in addition to the password if the vault file was sealed with keyfiles.
+	Keyfiles KeyfileProvider
+
+	// Recovery unlocks the data key with the recovery key slot instead of the keys.
+	Recovery *RecoveryKey
+
+	// Revision selects the revision to open, 1 is the oldest and 0 the current one.
+	Revision int
+}
+
+// SealOptions configure how a vault file is encrypted.
+// A nil provider disables that layer, at least one is required.
+type SealOptions struct {
+	PublicKey PublicKeyProvider
+	Password  PasswordProvider
+	// Keyfiles are mixed into the password-derived key, they need the password layer.
+	Keyfiles KeyfileProvider
+	// KDFIterations are the PBKDF2 iterations of the password layer,
+	// 0 uses cryption.AES256KDFIterations.
+	KDFIterations int
+
+	// Previous is the raw content of the vault file that gets replaced.
+	// With History its revisions are kept and the new content is appended.
+	Previous []byte
+	// PreviousTime is used as revision time of a previous vault file
+	// without history, like its modification time.
+	PreviousTime time.Time
+
+	History       bool
+	HistoryLimit  int
+	HistoryMaxAge time.Duration
+
+	// Armor returns the vault file as armored text, see Armor.
+	// An armored previous vault file stays armored without it.
+	Armor bool
+
+	// User and Time are stored with the new revision, in clear text and authenticated
+	// in the encrypted body, see History. A zero Time means now.
+	User string
+	Time time.Time
+
+	// Unlock opens the data key of a previous vault file with key slots.
+	// The new revision is encrypted with it and the key slots are kept,
+	// PublicKey and Password are only used for a new vault file
+	// or a previous one without key slots.
+	Unlock OpenOptions
+}
+
+// RewrapOptions configure the re-encryption of all revisions.
+// From opens the existing vault file, only the providers of To are used.
+type RewrapOptions struct {
+	From OpenOptions
+	To   SealOptions
+}
+
+// Open decrypts the current or the selected revision of a raw vault file.
+func Open(raw []byte, options OpenOptions) ([]byte, error) {
+	container, err := parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := revisionIndex(container, options.Revision)
+	if err != nil {
+		return nil, err
+	}
+
+	opener := newOpener(options)
+	defer opener.wipe()
+
+	content, _, err := opener.openRevision(container, &container.Revisions[index])
+
+	return content, err
+}
+
+// Seal encrypts the plain text into a raw vault container.
+// With History the revisions of the previous vault file are kept,
+// otherwise only its creation time.
+// The key slots of a previous vault file are always kept.
+func Seal(plainText []byte, options SealOptions) ([]byte, error) {
+	revisionTime := options.Time
+	if revisionTime.IsZero() {
+		revisionTime = time.Now()
+	}
+
+	container := &vaultfile.Container{}
+	var slots []vaultfile.KeySlot
+	if len(options.Previous) != 0 {
+		previousRaw, err := Dearmor(options.Previous)
+		var previous *vaultfile.Container
+		if err == nil {
+			previous, err = vaultfile.Parse(previousRaw)
+		}
+		if err != nil && options.History {
+			return nil, fmt.Errorf("parse previous vault file error:\n> %w", err)
+		}
+
+		if err == nil {
+			if !vaultfile.IsContainer(previousRaw) {
+				previous.Created = options.PreviousTime
+				previous.Current().Time = options.PreviousTime
+			}
+
+			if options.History {
+				container = previous
+			} else {
+				container.Created = previous.Created
+			}
+			container.Slots = previous.Slots
+			slots = previous.Slots
+		}
+	}
+
+	if container.Created.IsZero() {
+		container.Created = revisionTime
+	}
+
+	// the time and user are authenticated with the content
+	meta := &vaultfile.Revision{Time: revisionTime, User: options.User}
+
+	var revision vaultfile.Revision
+	if len(slots) != 0 {
+		opener := newOpener(options.Unlock)
+		defer opener.wipe()
+
+		dataKey, err := opener.unlock(&vaultfile.Container{Slots: slots})
+		if err != nil {
+			return nil, fmt.Errorf("unlock key slots of previous vault file error:\n> %w", err)
+		}
+
+		revision, err = sealDataKeyRevision(dataKey, plainText, meta)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		keys, err := sealKeys(options)
+		if err != nil {
+			return nil, err
+		}
+
+		dataKey := make([]byte, dataKeySize)
+		defer secret.Wipe(dataKey)
+
+		_, err = rand.Read(dataKey)
+		if err != nil {
+			return nil, err
+		}
+
+		slot, err := keys.sealSlot(DefaultSlot, dataKey)
+		if err != nil {
+			return nil, err
+		}
+		container.Slots = []vaultfile.KeySlot{slot}
+
+		revision, err = sealDataKeyRevision(dataKey, plainText, meta)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	container.Revisions = append(container.Revisions, revision)
+	container.Prune(options.HistoryLimit, options.HistoryMaxAge, revisionTime)
+
+	if options.Armor {
+		return Armor(container.Marshal()), nil
+	}
+
+	return keepArmor(options.Previous, container.Marshal()), nil
+}
+
+// Rewrap replaces the keys of a key slot, for example to change the password or the key.
+// The slot that the From options unlock gets the keys of the To options,
+// after a recovery that is the first credentials key slot.
+// The data key and the revisions stay the same. Vault files without key slots
+// get a data key first, their revisions are re-encrypted once.
+func Rewrap(raw []byte, options RewrapOptions) ([]byte, error) {
+	container, err := parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	rewrapped, err := rewrapSlot(container, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return keepArmor(raw, rewrapped), nil
+}
+
+func rewrapSlot(container *vaultfile.Container, options RewrapOptions) ([]byte, error) {
+	opener := newOpener(options.From)
+	defer opener.wipe()
+
+	dataKey, err := opener.addKeySlots(container)
+	if err != nil {
+		return nil, err
+	}
+
+	index := opener.slot
+	if container.Slots[index].Kind != vaultfile.SlotCredentials {
+		index = -1
+		for slotIndex, slot := range container.Slots {
+			if slot.Kind == vaultfile.SlotCredentials {
+				index = slotIndex
+				break
+			}
+		}
+	}
+
+	name := DefaultSlot
+	if index >= 0 {
+		name = container.Slots[index].Name
+
+		// a new password keeps the kdf cost of its key slot
+		params := container.Slots[index].Params
+		if options.To.KDFIterations == 0 && params != nil && params.AES && params.KDFIterations != 0 {
+			options.To.KDFIterations = int(params.KDFIterations)
+		}
+	}
+
+	toKeys, err := sealKeys(options.To)
+	if err != nil {
+		return nil, err
+	}
+
+	slot, err := toKeys.sealSlot(name, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if index >= 0 {
+		container.Slots[index] = slot
+	} else {
+		container.Slots = append([]vaultfile.KeySlot{slot}, container.Slots...)
+	}
+
+	return container.Marshal(), nil
+}
+
+// Hashes decrypts all revisions and returns the sha256 hashes of their content
+// as hex, the oldest first.
+func Hashes(raw []byte, options OpenOptions) ([]string, error) {
+	container, err := parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	opener := newOpener(options)
+	defer opener.wipe()
+
+	hashes := make([]string, len(container.Revisions))
+
+	for index := range container.Revisions {
+		content, hash, err := opener.openRevision(container, &container.Revisions[index])
+		if err != nil {
+			return nil, fmt.Errorf("revision %d error:\n> %w", index+1, err)
+		}
+		secret.Wipe(content)
+
+		hashes[index] = hex.EncodeToString(hash)
+	}
+
+	return hashes, nil
+}
+
+// HistoryEntry is one revision of History.
+type HistoryEntry struct {
+	// Number is the revision number, 1 is the oldest.
+	Number int
+	Time   time.Time
+	User   string
+	// Authenticated is true if Time and User are read from the encrypted revision.
+	Authenticated bool
+	// Changed is true if the clear text time or user differs from the encrypted one.
+	Changed bool
+	// Hash is the sha256 hash of the content as hex, empty if Err is set.
+	Hash string
+	// Err is set if the revision could not be opened, e.g. it was written with another password.
+	Err error
+}
+
+// History opens all revisions and returns their time, user and content hash, the oldest first.
+// Revisions that can not be opened are returned with their error and clear text values,
+// an error is only returned if the keys are wrong or no revision opens.
+func History(raw []byte, options OpenOptions) ([]HistoryEntry, error) {
+	container, err := parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	opener := newOpener(options)
+	defer opener.wipe()
+
+	if len(container.Slots) != 0 {
+		// wrong keys fail here once instead of in every revision
+		_, err = opener.unlock(container)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	entries := make([]HistoryEntry, len(container.Revisions))
+	opened := false
+	var firstErr error
+
+	for index := range container.Revisions {
+		revision := &container.Revisions[index]
+		entries[index] = HistoryEntry{
+			Number: index + 1,
+			Time:   revision.Time,
+			User:   revision.User,
+		}
+
+		err := opener.historyEntry(container, revision, &entries[index])
+		if err != nil {
+			entries[index].Err = fmt.Errorf("revision %d error:\n> %w", index+1, err)
+			if firstErr == nil {
+				firstErr = entries[index].Err
+			}
+			continue
+		}
+
+		opened = true
+	}
+
+	if !opened && firstErr != nil {
+		return nil, firstErr
+	}
+
+	return entries, nil
+}
+
+func (opener *opener) historyEntry(
+	container *vaultfile.Container,
+	revision *vaultfile.Revision,
+	entry *HistoryEntry,
+) error {
+	body, err := opener.openBody(container, revision)
+	if err != nil {
+		return err
+	}
+	defer secret.Wipe(body)
+
+	if revision.Meta {
+		revisionTime, user, _, err := vaultfile.DecodeMeta(body)
+		if err != nil {
+			return corrupted(err)
+		}
+
+		entry.Authenticated = true
+		entry.Changed = !revisionTime.Equal(revision.Time) || user != revision.User
+		entry.Time = revisionTime
+		entry.User = user
+	}
+
+	// the content and hash are parts of the body
+	_, hash, err := decodeBody(body, revision)
+	if err != nil {
+		return err
+	}
+
+	entry.Hash = hex.EncodeToString(hash)
+
+	return nil
+}
+
+func revisionIndex(container *vaultfile.Container, revision int) (int, error) {
+	if revision == 0 {
+		return len(container.Revisions) - 1, nil
+	}
+
+	if revision < 0 || revision > len(container.Revisions) {
+		return 0, sentinel(ErrRevisionNotFound, "revision "+strconv.Itoa(revision)+" does not exist, use 1 to "+strconv.Itoa(len(container.Revisions)))
+	}
+
+	return revision - 1, nil
+}
+
+// keys are the resolved providers of one operation.
+type keys struct {
+	privateKey crypto.Decrypter
+	publicKey  *rsa.PublicKey
+	password   []byte
+	// keyfile is the keyfile hash mixed into the password layer, nil without keyfiles
+	keyfile []byte
+	rsa     bool
+	aes     bool
+	// iterations are the PBKDF2 iterations to encrypt the password layer
+	iterations int
+}
+
+func openKeys(options OpenOptions) (*keys, error) {
+	if options.PrivateKey == nil && options.Password == nil {
+		return nil, sentinel(ErrNoMethod, "no decryption method selected")
+	}
+
+	result := &keys{
+		rsa:        options.PrivateKey != nil,
+		aes:        options.Password != nil,
+		iterations: cryption.AES256KDFIterations,
+	}
+
+	var err error
+	if result.rsa {
+		result.privateKey, err = options.PrivateKey()
+		if err != nil {
+			return nil, err
+		}
+		result.publicKey, err = cryption.RsaDecrypterPublicKey(result.privateKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if result.aes {
+		result.password, err = options.Password()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if options.Keyfiles != nil {
+		result.keyfile, err = loadKeyfile(result.aes, options.Keyfiles)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+func sealKeys(options SealOptions) (*keys, error) {
+	if options.PublicKey == nil && options.Password == nil {
+		return nil, ErrNoMethod
+	}
+
+	result := &keys{
+		rsa:        options.PublicKey != nil,
+		aes:        options.Password != nil,
+		iterations: options.KDFIterations,
+	}
+
+	if result.iterations == 0 {
+		result.iterations = cryption.AES256KDFIterations
+	} else if result.iterations < 1000 {
+		return nil, errors.New("at least 1000 kdf iterations are needed, got " + strconv.Itoa(result.iterations))
+	}
+
+	var err error
+	if result.rsa {
+		result.publicKey, err = options.PublicKey()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if result.aes {
+		result.password, err = options.Password()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if options.Keyfiles != nil {
+		result.keyfile, err = loadKeyfile(result.aes, options.Keyfiles)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// loadKeyfile resolves the keyfile hash, keyfiles only extend the password layer.
+func loadKeyfile(aes bool, provider KeyfileProvider) ([]byte, error) {
+	if !aes {
+		return nil, errors.New("keyfiles need the password layer")
+	}
+
+	keyfile, err := provider()
+	if err != nil {
+		return nil, err
+	} else if len(keyfile) == 0 {
+		return nil, errors.New("empty keyfile hash")
+	}
+
+	return keyfile, nil
+}
+
+// params describes the encryption of the keys, stored in clear text for Inspect.
+func (keys *keys) params() (*vaultfile.Params, error) {
+	params := &vaultfile.Params{
+		AES: keys.aes,
+		RSA: keys.rsa,
+	}
+
+	if keys.aes {
+		params.KDFIterations = uint32(keys.iterations)
+		params.Keyfile = keys.keyfile != nil
+	}
+
+	if keys.rsa {
+		recipient, err := cryption.RsaPublicKeyFingerprint(keys.publicKey)
+		if err != nil {
+			return nil, err
+		}
+
+		params.RSABits = uint16(keys.publicKey.N.BitLen())
+		params.Recipient = recipient
+	}
+
+	return params, nil
+}
+
+// encrypt applies the AES-256 layer first and the RSA layer around it.
+func (keys *keys) encrypt(payload []byte) ([]byte, error) {
+	var err error
+
+	if keys.aes {
+		payload, err = cryption.AES256EncryptKeyfile(keys.password, payload, keys.iterations, keys.keyfile)
+		if err != nil {
+			return nil, fmt.Errorf("AES256 encrypt error, maybe wrong password:\n> %v", err)
+		}
+	}
+
+	if keys.rsa {
+		payload, err = cryption.X509AES256Encrypt(keys.publicKey, payload)
+		if err != nil {
+			return nil, fmt.Errorf("x509 encrypt error:\n> %v", err)
+		}
+	}
+
+	return payload, nil
+}
+
+// decrypt removes the RSA and the AES-256 layer, the params
+// of the payload define the PBKDF2 iterations of the password.
+func (keys *keys) decrypt(payload []byte, params *vaultfile.Params) ([]byte, error) {
+	var err error
+
+	if keys.rsa {
+		payload, err = cryption.X509AES256Decrypt(keys.privateKey, payload)
+		if err != nil {
+			return nil, fmt.Errorf("x509 decrypt error:\n> %w", err)
+		}
+	}
+
+	if keys.aes {
+		iterations := cryption.AES256KDFIterations
+		if params != nil && params.KDFIterations != 0 {
+			iterations = int(params.KDFIterations)
+		}
+
+		payload, err = cryption.AES256DecryptKeyfile(keys.password, payload, iterations, keys.keyfile)
+		if err != nil {
+			return nil, fmt.Errorf("AES256 decrypt error, maybe wrong password:\n> %w", err)
+		}
+	}
+
+	return payload, nil
+}
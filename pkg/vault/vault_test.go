@@ -0,0 +1,526 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/NobleMajo/vault/lib/cryption"
+	"github.com/NobleMajo/vault/lib/vaultfile"
+)
+
+func testKeysDir(t *testing.T) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd error: %v", err)
+	}
+
+	return filepath.Join(wd, "..", "..", "test-keys")
+}
+
+func TestSealOpen(t *testing.T) {
+	keysDir := testKeysDir(t)
+	plainText := []byte("SECRET=value\n")
+
+	tests := []struct {
+		name string
+		seal SealOptions
+		open OpenOptions
+	}{
+		{
+			name: "password",
+			seal: SealOptions{Password: Password([]byte("pass1234"))},
+			open: OpenOptions{Password: Password([]byte("pass1234"))},
+		},
+		{
+			name: "rsa",
+			seal: SealOptions{PublicKey: PublicKeyFile(filepath.Join(keysDir, "test_id_rsa.pub"))},
+			open: OpenOptions{PrivateKey: PrivateKeyFile(filepath.Join(keysDir, "test_id_rsa"))},
+		},
+		{
+			name: "rsa and password with history",
+			seal: SealOptions{
+				PublicKey: PublicKeyFile(filepath.Join(keysDir, "test_id_rsa.pub")),
+				Password:  Password([]byte("pass1234")),
+				History:   true,
+			},
+			open: OpenOptions{
+				PrivateKey: PrivateKeyFile(filepath.Join(keysDir, "test_id_rsa")),
+				Password:   Password([]byte("pass1234")),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		raw, err := Seal(plainText, test.seal)
+		if err != nil {
+			t.Fatalf("%s: Seal error: %v", test.name, err)
+		}
+
+		opened, err := Open(raw, test.open)
+		if err != nil {
+			t.Fatalf("%s: Open error: %v", test.name, err)
+		}
+
+		if !bytes.Equal(opened, plainText) {
+			t.Fatalf("%s: Open = %q, want %q", test.name, opened, plainText)
+		}
+	}
+}
+
+func TestOpenWrongPassword(t *testing.T) {
+	raw, err := Seal([]byte("content"), SealOptions{Password: Password([]byte("pass1234"))})
+	if err != nil {
+		t.Fatalf("Seal error: %v", err)
+	}
+
+	_, err = Open(raw, OpenOptions{Password: Password([]byte("wrong123"))})
+	if !errors.Is(err, cryption.ErrAuthFailed) {
+		t.Fatalf("Open with wrong password error = %v, want ErrAuthFailed", err)
+	}
+}
+
+func TestOpenCorrupted(t *testing.T) {
+	raw, err := Seal([]byte("content"), SealOptions{Password: Password([]byte("pass1234"))})
+	if err != nil {
+		t.Fatalf("Seal error: %v", err)
+	}
+
+	_, err = Open(raw[:len(raw)/2], OpenOptions{Password: Password([]byte("pass1234"))})
+	if !errors.Is(err, ErrCorrupted) {
+		t.Fatalf("Open of truncated vault error = %v, want ErrCorrupted", err)
+	}
+}
+
+func TestNoMethod(t *testing.T) {
+	_, err := Seal([]byte("content"), SealOptions{})
+	if err == nil {
+		t.Fatalf("Seal without providers should fail")
+	}
+
+	_, err = Open([]byte("content"), OpenOptions{})
+	if err == nil {
+		t.Fatalf("Open without providers should fail")
+	}
+}
+
+func TestProvidersAreCalledOnce(t *testing.T) {
+	calls := 0
+	password := func() ([]byte, error) {
+		calls++
+		return []byte("pass1234"), nil
+	}
+
+	raw := sealHistory(t, []string{"one", "two", "three"}, password)
+	calls = 0
+
+	_, err := Hashes(raw, OpenOptions{Password: password})
+	if err != nil {
+		t.Fatalf("Hashes error: %v", err)
+	}
+
+	if calls != 1 {
+		t.Fatalf("password provider called %d times, want 1", calls)
+	}
+}
+
+func sealHistory(t *testing.T, contents []string, password PasswordProvider) []byte {
+	var raw []byte
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for index, content := range contents {
+		var err error
+		raw, err = Seal([]byte(content), SealOptions{
+			Password: password,
+			Previous: raw,
+			History:  true,
+			User:     "alice",
+			Unlock:   OpenOptions{Password: password},
+			Time:     start.Add(time.Duration(index) * time.Hour),
+		})
+
+		if err != nil {
+			t.Fatalf("Seal %q error: %v", content, err)
+		}
+	}
+
+	return raw
+}
+
+func TestHistory(t *testing.T) {
+	password := Password([]byte("pass1234"))
+	raw := sealHistory(t, []string{"one", "two", "three"}, password)
+
+	for revision, expected := range map[int]string{0: "three", 1: "one", 2: "two", 3: "three"} {
+		content, err := Open(raw, OpenOptions{Password: password, Revision: revision})
+		if err != nil {
+			t.Fatalf("Open revision %d error: %v", revision, err)
+		}
+
+		if string(content) != expected {
+			t.Fatalf("Open revision %d = %q, want %q", revision, content, expected)
+		}
+	}
+
+	_, err := Open(raw, OpenOptions{Password: password, Revision: 4})
+	if err == nil {
+		t.Fatalf("Open of a missing revision should fail")
+	}
+
+	hashes, err := Hashes(raw, OpenOptions{Password: password})
+	if err != nil {
+		t.Fatalf("Hashes error: %v", err)
+	}
+
+	if len(hashes) != 3 || hashes[0] == hashes[1] || len(hashes[0]) != 64 {
+		t.Fatalf("unexpected hashes: %v", hashes)
+	}
+}
+
+func TestHistoryEntries(t *testing.T) {
+	password := Password([]byte("pass1234"))
+	raw := sealHistory(t, []string{"one", "two", "three"}, password)
+
+	container, err := vaultfile.Parse(raw)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	// rewrite the clear text user of the first and damage the second revision
+	container.Revisions[0].User = "mallory"
+	container.Revisions[1].Payload[len(container.Revisions[1].Payload)-1] ^= 0xff
+	raw = container.Marshal()
+
+	entries, err := History(raw, OpenOptions{Password: password})
+	if err != nil {
+		t.Fatalf("History error: %v", err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("entries = %d, want 3", len(entries))
+	}
+
+	first := entries[0]
+	if first.Err != nil || !first.Authenticated || !first.Changed || first.User != "alice" {
+		t.Fatalf("tampered user not detected: %+v", first)
+	}
+
+	if !first.Time.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("time = %v, want the sealed time", first.Time)
+	}
+
+	if !errors.Is(entries[1].Err, ErrCorrupted) || entries[1].Hash != "" {
+		t.Fatalf("damaged revision should have a corrupted error: %+v", entries[1])
+	}
+
+	if entries[2].Err != nil || entries[2].Changed || len(entries[2].Hash) != 64 {
+		t.Fatalf("unexpected current revision: %+v", entries[2])
+	}
+
+	_, err = History(raw, OpenOptions{Password: Password([]byte("wrong123"))})
+	if !errors.Is(err, ErrAuthFailed) {
+		t.Fatalf("History with a wrong password = %v, want ErrAuthFailed", err)
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	password := Password([]byte("pass1234"))
+	raw := sealHistory(t, []string{"one", "two"}, password)
+
+	raw, err := Seal([]byte("three"), SealOptions{
+		Unlock:       OpenOptions{Password: password},
+		Previous:     raw,
+		History:      true,
+		HistoryLimit: 2,
+	})
+	if err != nil {
+		t.Fatalf("Seal error: %v", err)
+	}
+
+	info, err := Inspect(raw)
+	if err != nil {
+		t.Fatalf("Inspect error: %v", err)
+	}
+
+	if len(info.Revisions) != 2 {
+		t.Fatalf("revisions = %d, want 2", len(info.Revisions))
+	}
+}
+
+func TestRewrap(t *testing.T) {
+	oldPassword := Password([]byte("pass1234"))
+	newPassword := Password([]byte("other123"))
+
+	tests := []struct {
+		name string
+		raw  []byte
+	}{
+		{"history", sealHistory(t, []string{"one", "two"}, oldPassword)},
+	}
+
+	legacy := legacyPayload(t, []byte("two"), []byte("pass1234"))
+	tests = append(tests, struct {
+		name string
+		raw  []byte
+	}{"legacy", legacy})
+
+	for _, test := range tests {
+		rewrapped, err := Rewrap(test.raw, RewrapOptions{
+			From: OpenOptions{Password: oldPassword},
+			To:   SealOptions{Password: newPassword},
+		})
+		if err != nil {
+			t.Fatalf("%s: Rewrap error: %v", test.name, err)
+		}
+
+		oldInfo, _ := Inspect(test.raw)
+		newInfo, err := Inspect(rewrapped)
+		if err != nil {
+			t.Fatalf("%s: Inspect error: %v", test.name, err)
+		}
+
+		if !newInfo.Container || len(oldInfo.Revisions) != len(newInfo.Revisions) {
+			t.Fatalf("%s: Rewrap changed the history: %+v -> %+v", test.name, oldInfo, newInfo)
+		}
+
+		content, err := Open(rewrapped, OpenOptions{Password: newPassword})
+		if err != nil || string(content) != "two" {
+			t.Fatalf("%s: Open after Rewrap = %q, %v", test.name, content, err)
+		}
+
+		_, err = Open(rewrapped, OpenOptions{Password: oldPassword})
+		if err == nil {
+			t.Fatalf("%s: old password still works after Rewrap", test.name)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	raw := sealHistory(t, []string{"one", "two"}, Password([]byte("pass1234")))
+
+	info, err := Inspect(raw)
+	if err != nil {
+		t.Fatalf("Inspect error: %v", err)
+	}
+
+	if !info.Container || info.Version != 1 || info.Size != len(raw) {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+
+	second := info.Revisions[1]
+	if second.Number != 2 || second.User != "alice" || !second.Hashed ||
+		!second.Time.Equal(time.Date(2026, 1, 1, 1, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected revision info: %+v", second)
+	}
+
+	legacy := legacyPayload(t, []byte("one"), []byte("pass1234"))
+	info, err = Inspect(legacy)
+	if err != nil {
+		t.Fatalf("Inspect error: %v", err)
+	}
+
+	if info.Container || info.Version != 0 || len(info.Revisions) != 1 || info.Revisions[0].Encryption != nil {
+		t.Fatalf("unexpected legacy info: %+v", info)
+	}
+}
+
+// legacyPayload creates a password only vault file of versions without container.
+func legacyPayload(t *testing.T, plainText []byte, password []byte) []byte {
+	t.Helper()
+
+	payload, err := cryption.AES256Encrypt(password, plainText)
+	if err != nil {
+		t.Fatalf("AES256Encrypt error: %v", err)
+	}
+
+	return payload
+}
+
+func TestOpenLegacy(t *testing.T) {
+	legacy := legacyPayload(t, []byte("old"), []byte("pass1234"))
+
+	content, err := Open(legacy, OpenOptions{Password: Password([]byte("pass1234"))})
+	if err != nil || string(content) != "old" {
+		t.Fatalf("Open legacy = %q, %v", content, err)
+	}
+
+	raw, err := Seal([]byte("new"), SealOptions{
+		Password:     Password([]byte("pass1234")),
+		Previous:     legacy,
+		PreviousTime: time.Unix(1600000000, 0),
+		History:      true,
+	})
+	if err != nil {
+		t.Fatalf("Seal error: %v", err)
+	}
+
+	info, _ := Inspect(raw)
+	if len(info.Revisions) != 2 || !info.Created.Equal(time.Unix(1600000000, 0)) ||
+		!info.Revisions[0].Time.Equal(time.Unix(1600000000, 0)) {
+		t.Fatalf("unexpected info after legacy upgrade: %+v", info)
+	}
+}
+
+func TestInspectEncryption(t *testing.T) {
+	keysDir := testKeysDir(t)
+
+	raw, err := Seal([]byte("content"), SealOptions{
+		PublicKey: PublicKeyFile(filepath.Join(keysDir, "test_id_rsa.pub")),
+		Password:  Password([]byte("pass1234")),
+	})
+	if err != nil {
+		t.Fatalf("Seal error: %v", err)
+	}
+
+	info, err := Inspect(raw)
+	if err != nil {
+		t.Fatalf("Inspect error: %v", err)
+	}
+
+	if info.Created.IsZero() {
+		t.Fatalf("missing creation time")
+	}
+
+	if len(info.Slots) != 1 || info.Slots[0].Name != DefaultSlot || !info.Revisions[0].DataKey {
+		t.Fatalf("unexpected key slots: %+v", info.Slots)
+	}
+
+	encryption := info.Slots[0].Encryption
+	if encryption == nil || !encryption.Password || encryption.KDF != "PBKDF2-SHA256" ||
+		encryption.KDFIterations != 4096 || encryption.RSABits != 4096 {
+		t.Fatalf("unexpected encryption info: %+v", encryption)
+	}
+
+	// same as "ssh-keygen -lf test-keys/test_id_rsa.pub"
+	expected := "SHA256:mVKJwMIZztV94HVziXFZN6hZ17hRXPb7bqNM0z9Xbvw"
+	if len(encryption.Recipients) != 1 || encryption.Recipients[0] != expected {
+		t.Fatalf("Recipients = %v, want %s", encryption.Recipients, expected)
+	}
+}
+
+func TestSealKeepsCreated(t *testing.T) {
+	password := Password([]byte("pass1234"))
+	first := sealHistory(t, []string{"one"}, password)
+
+	raw, err := Seal([]byte("two"), SealOptions{
+		Previous: first,
+		Unlock:   OpenOptions{Password: password},
+	})
+	if err != nil {
+		t.Fatalf("Seal error: %v", err)
+	}
+
+	firstInfo, _ := Inspect(first)
+	info, _ := Inspect(raw)
+
+	if !info.Created.Equal(firstInfo.Created) || len(info.Revisions) != 1 {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	password := Password([]byte("pass1234"))
+	raw := sealHistory(t, []string{"one", "two", "three"}, password)
+
+	slots, checks, err := Verify(raw, OpenOptions{Password: password})
+	if err != nil {
+		t.Fatalf("Verify error: %v", err)
+	}
+
+	if len(slots) != 1 || !slots[0].Opened || slots[0].Err != nil {
+		t.Fatalf("unexpected slot checks: %+v", slots)
+	}
+
+	for _, check := range checks {
+		if check.Err != nil {
+			t.Fatalf("revision %d: %v", check.Number, check.Err)
+		}
+	}
+
+	// flip a bit in the payload of the second revision
+	container, _ := vaultfile.Parse(raw)
+	container.Revisions[1].Payload[20] ^= 0x01
+	tampered := container.Marshal()
+
+	_, checks, err = Verify(tampered, OpenOptions{Password: password})
+	if err != nil {
+		t.Fatalf("Verify error: %v", err)
+	}
+
+	if checks[0].Err != nil || checks[1].Err == nil || checks[2].Err != nil {
+		t.Fatalf("unexpected checks: %+v", checks)
+	}
+
+	// a wrong password fails once as auth error, not as corrupted revisions
+	_, _, err = Verify(raw, OpenOptions{Password: Password([]byte("wrong123"))})
+	if !errors.Is(err, ErrAuthFailed) || errors.Is(err, ErrCorrupted) {
+		t.Fatalf("Verify error = %v, want auth failed", err)
+	}
+}
+
+func TestVerifySlots(t *testing.T) {
+	password := Password([]byte("pass1234"))
+	raw := sealHistory(t, []string{"one"}, password)
+
+	raw, err := AddSlot(raw, "backup", RewrapOptions{
+		From: OpenOptions{Password: password},
+		To:   SealOptions{Password: Password([]byte("other567")), KDFIterations: 10000},
+	})
+	if err != nil {
+		t.Fatalf("AddSlot error: %v", err)
+	}
+
+	// the second slot has another password, it can not be checked with the first one
+	slots, _, err := Verify(raw, OpenOptions{Password: password})
+	if err != nil || !slots[0].Opened || slots[1].Opened {
+		t.Fatalf("Verify = %+v, %v", slots, err)
+	}
+
+	slots, _, err = Verify(raw, OpenOptions{Password: Password([]byte("other567"))})
+	if err != nil || slots[0].Opened || !slots[1].Opened || slots[1].Err != nil {
+		t.Fatalf("Verify = %+v, %v", slots, err)
+	}
+
+	// a damaged spare slot with the same password can not be opened, it is reported
+	raw, err = AddSlot(raw, "spare", RewrapOptions{
+		From: OpenOptions{Password: password},
+		To:   SealOptions{Password: password, KDFIterations: 10000},
+	})
+	if err != nil {
+		t.Fatalf("AddSlot error: %v", err)
+	}
+	container, _ := vaultfile.Parse(raw)
+	container.Slots[2].Payload[len(container.Slots[2].Payload)-1] ^= 0x01
+
+	slots, _, err = Verify(container.Marshal(), OpenOptions{Password: password})
+	if err != nil || slots[2].Opened || slots[2].Err == nil {
+		t.Fatalf("Verify = %+v, %v, want the damaged spare slot reported", slots, err)
+	}
+}
+
+func TestVerifyOtherRecipient(t *testing.T) {
+	keysDir := testKeysDir(t)
+
+	raw, err := Seal([]byte("content"), SealOptions{
+		PublicKey: PublicKeyFile(filepath.Join(keysDir, "test_id_rsa.pub")),
+	})
+	if err != nil {
+		t.Fatalf("Seal error: %v", err)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey error: %v", err)
+	}
+
+	_, _, err = Verify(raw, OpenOptions{PrivateKey: PrivateKey(otherKey)})
+	if !errors.Is(err, ErrWrongRecipient) || !strings.Contains(err.Error(), "SHA256:mVKJwMIZ") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}